@@ -45,6 +45,13 @@ const Record = sequelize.define('Record', {
     type: DataTypes.INTEGER,
     allowNull: true,
   },
+  weight: {
+    type: DataTypes.INTEGER,
+    allowNull: true,
+    validate: {
+      min: 0
+    }
+  },
   disabled: {
     type: DataTypes.BOOLEAN,
     allowNull: false,
@@ -11,6 +11,7 @@ export class RecordService {
       content,
       ttl = 300,
       prio = null,
+      weight = null,
       disabled = false,
       createdBy = 'api',
       comment = null
@@ -34,6 +35,7 @@ export class RecordService {
         content: this.formatRecordContent(type, content),
         ttl,
         prio: type === 'MX' || type === 'SRV' ? prio : null,
+        weight: type === 'A' || type === 'AAAA' ? weight : null,
         disabled,
         createdBy,
         comment
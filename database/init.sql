@@ -4,6 +4,8 @@ DROP TRIGGER IF EXISTS domains_change_trigger ON domains;
 DROP FUNCTION IF EXISTS notify_dns_change() CASCADE;
 DROP FUNCTION IF EXISTS notify_records_change() CASCADE;
 DROP FUNCTION IF EXISTS notify_domains_change() CASCADE;
+DROP TRIGGER IF EXISTS records_version_trigger ON records;
+DROP FUNCTION IF EXISTS record_version() CASCADE;
 
 -- DNS Management Database Schema
 -- PowerDNS compatible with extensions for management
@@ -30,6 +32,7 @@ CREATE TABLE IF NOT EXISTS records (
     content VARCHAR(65000) DEFAULT NULL,
     ttl INT DEFAULT 300,
     prio INT DEFAULT NULL,
+    weight INT DEFAULT NULL,
     disabled BOOLEAN DEFAULT FALSE,
     ordername VARCHAR(255) DEFAULT NULL,
     auth BOOLEAN DEFAULT TRUE,
@@ -77,6 +80,42 @@ CREATE TABLE IF NOT EXISTS dns_logs (
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Zone generation audit log, written by dns-reloader
+CREATE TABLE IF NOT EXISTS zone_generations (
+    id SERIAL PRIMARY KEY,
+    domain_id INT REFERENCES domains(id) ON DELETE SET NULL,
+    domain_name VARCHAR(255) NOT NULL,
+    serial VARCHAR(20) DEFAULT NULL,
+    record_count INT NOT NULL DEFAULT 0,
+    content_hash CHAR(64) DEFAULT NULL,
+    duration_ms INT NOT NULL DEFAULT 0,
+    trigger_table VARCHAR(32) DEFAULT NULL,
+    trigger_action VARCHAR(20) DEFAULT NULL,
+    trigger_record_id INT DEFAULT NULL,
+    trigger_name VARCHAR(255) DEFAULT NULL,
+    trigger_type VARCHAR(10) DEFAULT NULL,
+    result VARCHAR(10) NOT NULL, -- changed, failed
+    error TEXT DEFAULT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS zone_generations_domain_name_created_at_index ON zone_generations(domain_name, created_at);
+
+-- Before/after images of every record change, written by a trigger. No
+-- foreign keys so history outlives deleted records and domains.
+CREATE TABLE IF NOT EXISTS record_versions (
+    id SERIAL PRIMARY KEY,
+    record_id INT NOT NULL,
+    domain_id INT DEFAULT NULL,
+    action VARCHAR(10) NOT NULL, -- INSERT, UPDATE, DELETE
+    old_data JSONB DEFAULT NULL,
+    new_data JSONB DEFAULT NULL,
+    changed_by VARCHAR(100) DEFAULT NULL,
+    changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS record_versions_record_id_index ON record_versions(record_id, id);
+
 -- Function for auto-updating timestamps
 CREATE OR REPLACE FUNCTION update_updated_at_column()
 RETURNS TRIGGER AS $$
@@ -95,12 +134,22 @@ DROP TRIGGER IF EXISTS update_records_updated_at ON records;
 CREATE TRIGGER update_records_updated_at BEFORE UPDATE ON records 
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
--- Function for DNS change notifications (records table)
+-- Function for DNS change notifications (records table). The channel is the
+-- trigger's first argument, defaulting to dns_records_changed. Keep in sync
+-- with dns-reloader/triggers.sql.
 CREATE OR REPLACE FUNCTION notify_records_change() 
 RETURNS TRIGGER AS $$
 DECLARE
     notification_data JSON;
 BEGIN
+    -- Batch imports notify once themselves after committing
+    IF current_setting('dns_reloader.suppress_notify', true) = 'on' THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+
     notification_data = json_build_object(
         'table', TG_TABLE_NAME,
         'action', TG_OP,
@@ -111,7 +160,7 @@ BEGIN
         'timestamp', CURRENT_TIMESTAMP
     );
     
-    PERFORM pg_notify('dns_records_changed', notification_data::text);
+    PERFORM pg_notify(COALESCE(TG_ARGV[0], 'dns_records_changed'), notification_data::text);
     
     IF TG_OP = 'DELETE' THEN
         RETURN OLD;
@@ -137,7 +186,7 @@ BEGIN
         'timestamp', CURRENT_TIMESTAMP
     );
     
-    PERFORM pg_notify('dns_records_changed', notification_data::text);
+    PERFORM pg_notify(COALESCE(TG_ARGV[0], 'dns_records_changed'), notification_data::text);
     
     IF TG_OP = 'DELETE' THEN
         RETURN OLD;
@@ -147,6 +196,37 @@ BEGIN
 END;
 $$ LANGUAGE plpgsql;
 
+-- Function for record history (records table). Timestamps are stored as
+-- UTC with an offset so the images decode as RFC 3339.
+CREATE OR REPLACE FUNCTION record_version()
+RETURNS TRIGGER AS $$
+DECLARE
+    old_data JSONB;
+    new_data JSONB;
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        old_data = to_jsonb(OLD) || jsonb_build_object(
+            'created_at', OLD.created_at AT TIME ZONE 'UTC',
+            'updated_at', OLD.updated_at AT TIME ZONE 'UTC'
+        );
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        new_data = to_jsonb(NEW) || jsonb_build_object(
+            'created_at', NEW.created_at AT TIME ZONE 'UTC',
+            'updated_at', NEW.updated_at AT TIME ZONE 'UTC'
+        );
+    END IF;
+
+    INSERT INTO record_versions (record_id, domain_id, action, old_data, new_data, changed_by)
+    VALUES (COALESCE(NEW.id, OLD.id), COALESCE(NEW.domain_id, OLD.domain_id), TG_OP, old_data, new_data, current_user);
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
 -- Clear existing data to avoid conflicts
 DELETE FROM records;
 DELETE FROM domains;
@@ -210,6 +290,10 @@ CREATE TRIGGER domains_change_trigger
     AFTER INSERT OR UPDATE OR DELETE ON domains
     FOR EACH ROW EXECUTE FUNCTION notify_domains_change();
 
+CREATE TRIGGER records_version_trigger
+    AFTER INSERT OR UPDATE OR DELETE ON records
+    FOR EACH ROW EXECUTE FUNCTION record_version();
+
 -- Grant permissions
 GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA public TO coredns;
 GRANT ALL PRIVILEGES ON ALL SEQUENCES IN SCHEMA public TO coredns;
@@ -0,0 +1,143 @@
+-- DNS Management Database Schema for MySQL / MariaDB (DB_DRIVER=mysql)
+-- PowerDNS compatible with the same extensions as the PostgreSQL schema.
+-- Changes are detected by polling table checksums, so no notify triggers
+-- are needed.
+
+CREATE TABLE IF NOT EXISTS domains (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    master VARCHAR(128) DEFAULT NULL,
+    last_check INT DEFAULT NULL,
+    type VARCHAR(6) NOT NULL DEFAULT 'NATIVE',
+    notified_serial INT UNSIGNED DEFAULT NULL,
+    account VARCHAR(40) CHARACTER SET 'utf8' DEFAULT NULL,
+    created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) Engine=InnoDB CHARACTER SET 'latin1';
+
+CREATE TABLE IF NOT EXISTS records (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    domain_id INT DEFAULT NULL,
+    name VARCHAR(255) DEFAULT NULL,
+    type VARCHAR(10) DEFAULT NULL,
+    content VARCHAR(64000) DEFAULT NULL,
+    ttl INT DEFAULT 300,
+    prio INT DEFAULT NULL,
+    weight INT DEFAULT NULL,
+    disabled TINYINT(1) DEFAULT 0,
+    ordername VARCHAR(255) BINARY DEFAULT NULL,
+    auth TINYINT(1) DEFAULT 1,
+    created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    created_by VARCHAR(100) DEFAULT 'system',
+    comment TEXT DEFAULT NULL,
+    CONSTRAINT records_domain_id_fk FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE
+) Engine=InnoDB CHARACTER SET 'latin1';
+
+CREATE INDEX records_name_type_index ON records(name, type);
+CREATE INDEX records_domain_id_index ON records(domain_id);
+CREATE INDEX records_disabled_index ON records(disabled);
+
+CREATE TABLE IF NOT EXISTS domainmetadata (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    domain_id INT NOT NULL,
+    kind VARCHAR(32),
+    content TEXT,
+    CONSTRAINT domainmetadata_domain_id_fk FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE
+) Engine=InnoDB CHARACTER SET 'latin1';
+
+-- Zone generation audit log, written by dns-reloader
+CREATE TABLE IF NOT EXISTS zone_generations (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    domain_id INT DEFAULT NULL,
+    domain_name VARCHAR(255) NOT NULL,
+    serial VARCHAR(20) DEFAULT NULL,
+    record_count INT NOT NULL DEFAULT 0,
+    content_hash CHAR(64) DEFAULT NULL,
+    duration_ms INT NOT NULL DEFAULT 0,
+    trigger_table VARCHAR(32) DEFAULT NULL,
+    trigger_action VARCHAR(20) DEFAULT NULL,
+    trigger_record_id INT DEFAULT NULL,
+    trigger_name VARCHAR(255) DEFAULT NULL,
+    trigger_type VARCHAR(10) DEFAULT NULL,
+    result VARCHAR(10) NOT NULL, -- changed, failed
+    error TEXT DEFAULT NULL,
+    created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP
+) Engine=InnoDB;
+
+CREATE INDEX zone_generations_domain_name_created_at_index ON zone_generations(domain_name, created_at);
+
+-- Before/after images of every record change, written by triggers. No
+-- foreign keys so history outlives deleted records and domains.
+CREATE TABLE IF NOT EXISTS record_versions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    record_id BIGINT NOT NULL,
+    domain_id INT DEFAULT NULL,
+    action VARCHAR(10) NOT NULL, -- INSERT, UPDATE, DELETE
+    old_data JSON DEFAULT NULL,
+    new_data JSON DEFAULT NULL,
+    changed_by VARCHAR(100) DEFAULT NULL,
+    changed_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP
+) Engine=InnoDB;
+
+CREATE INDEX record_versions_record_id_index ON record_versions(record_id, id);
+
+-- Record images use the same keys and types as the PostgreSQL schema:
+-- booleans as JSON booleans and timestamps as RFC 3339 UTC.
+DELIMITER //
+
+CREATE TRIGGER records_version_insert AFTER INSERT ON records FOR EACH ROW
+BEGIN
+    INSERT INTO record_versions (record_id, domain_id, action, new_data, changed_by)
+    VALUES (NEW.id, NEW.domain_id, 'INSERT', JSON_OBJECT(
+        'id', NEW.id, 'domain_id', NEW.domain_id, 'name', NEW.name, 'type', NEW.type,
+        'content', NEW.content, 'ttl', NEW.ttl, 'prio', NEW.prio, 'weight', NEW.weight,
+        'disabled', IF(NEW.disabled, CAST('true' AS JSON), CAST('false' AS JSON)),
+        'ordername', NEW.ordername,
+        'auth', IF(NEW.auth, CAST('true' AS JSON), CAST('false' AS JSON)),
+        'created_at', DATE_FORMAT(CONVERT_TZ(NEW.created_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%sZ'),
+        'updated_at', DATE_FORMAT(CONVERT_TZ(NEW.updated_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%sZ'),
+        'created_by', NEW.created_by, 'comment', NEW.comment
+    ), CURRENT_USER());
+END//
+
+CREATE TRIGGER records_version_update AFTER UPDATE ON records FOR EACH ROW
+BEGIN
+    INSERT INTO record_versions (record_id, domain_id, action, old_data, new_data, changed_by)
+    VALUES (NEW.id, NEW.domain_id, 'UPDATE', JSON_OBJECT(
+        'id', OLD.id, 'domain_id', OLD.domain_id, 'name', OLD.name, 'type', OLD.type,
+        'content', OLD.content, 'ttl', OLD.ttl, 'prio', OLD.prio, 'weight', OLD.weight,
+        'disabled', IF(OLD.disabled, CAST('true' AS JSON), CAST('false' AS JSON)),
+        'ordername', OLD.ordername,
+        'auth', IF(OLD.auth, CAST('true' AS JSON), CAST('false' AS JSON)),
+        'created_at', DATE_FORMAT(CONVERT_TZ(OLD.created_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%sZ'),
+        'updated_at', DATE_FORMAT(CONVERT_TZ(OLD.updated_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%sZ'),
+        'created_by', OLD.created_by, 'comment', OLD.comment
+    ), JSON_OBJECT(
+        'id', NEW.id, 'domain_id', NEW.domain_id, 'name', NEW.name, 'type', NEW.type,
+        'content', NEW.content, 'ttl', NEW.ttl, 'prio', NEW.prio, 'weight', NEW.weight,
+        'disabled', IF(NEW.disabled, CAST('true' AS JSON), CAST('false' AS JSON)),
+        'ordername', NEW.ordername,
+        'auth', IF(NEW.auth, CAST('true' AS JSON), CAST('false' AS JSON)),
+        'created_at', DATE_FORMAT(CONVERT_TZ(NEW.created_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%sZ'),
+        'updated_at', DATE_FORMAT(CONVERT_TZ(NEW.updated_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%sZ'),
+        'created_by', NEW.created_by, 'comment', NEW.comment
+    ), CURRENT_USER());
+END//
+
+CREATE TRIGGER records_version_delete AFTER DELETE ON records FOR EACH ROW
+BEGIN
+    INSERT INTO record_versions (record_id, domain_id, action, old_data, changed_by)
+    VALUES (OLD.id, OLD.domain_id, 'DELETE', JSON_OBJECT(
+        'id', OLD.id, 'domain_id', OLD.domain_id, 'name', OLD.name, 'type', OLD.type,
+        'content', OLD.content, 'ttl', OLD.ttl, 'prio', OLD.prio, 'weight', OLD.weight,
+        'disabled', IF(OLD.disabled, CAST('true' AS JSON), CAST('false' AS JSON)),
+        'ordername', OLD.ordername,
+        'auth', IF(OLD.auth, CAST('true' AS JSON), CAST('false' AS JSON)),
+        'created_at', DATE_FORMAT(CONVERT_TZ(OLD.created_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%sZ'),
+        'updated_at', DATE_FORMAT(CONVERT_TZ(OLD.updated_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%sZ'),
+        'created_by', OLD.created_by, 'comment', OLD.comment
+    ), CURRENT_USER());
+END//
+
+DELIMITER ;
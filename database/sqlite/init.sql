@@ -0,0 +1,141 @@
+-- DNS Management Database Schema for SQLite (DB_DRIVER=sqlite)
+-- PowerDNS compatible with the same extensions as the PostgreSQL schema.
+-- Apply with: sqlite3 /var/lib/dns-reloader/dns.db < init.sql
+-- Changes are detected by polling PRAGMA data_version, so no notify
+-- triggers are needed.
+
+PRAGMA foreign_keys = ON;
+PRAGMA journal_mode = WAL;
+
+CREATE TABLE IF NOT EXISTS domains (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL UNIQUE COLLATE NOCASE,
+    master VARCHAR(128) DEFAULT NULL,
+    last_check INTEGER DEFAULT NULL,
+    type VARCHAR(6) NOT NULL DEFAULT 'NATIVE',
+    notified_serial INTEGER DEFAULT NULL,
+    account VARCHAR(40) DEFAULT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS records (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    domain_id INTEGER DEFAULT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    name VARCHAR(255) DEFAULT NULL,
+    type VARCHAR(10) DEFAULT NULL,
+    content VARCHAR(65000) DEFAULT NULL,
+    ttl INTEGER DEFAULT 300,
+    prio INTEGER DEFAULT NULL,
+    weight INTEGER DEFAULT NULL,
+    disabled BOOLEAN DEFAULT 0,
+    ordername VARCHAR(255) DEFAULT NULL,
+    auth BOOLEAN DEFAULT 1,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by VARCHAR(100) DEFAULT 'system',
+    comment TEXT DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS records_name_type_index ON records(name, type);
+CREATE INDEX IF NOT EXISTS records_domain_id_index ON records(domain_id);
+CREATE INDEX IF NOT EXISTS records_disabled_index ON records(disabled);
+
+CREATE TABLE IF NOT EXISTS domainmetadata (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    domain_id INTEGER NOT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    kind VARCHAR(32),
+    content TEXT
+);
+
+-- Zone generation audit log, written by dns-reloader
+CREATE TABLE IF NOT EXISTS zone_generations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    domain_id INTEGER DEFAULT NULL,
+    domain_name VARCHAR(255) NOT NULL,
+    serial VARCHAR(20) DEFAULT NULL,
+    record_count INTEGER NOT NULL DEFAULT 0,
+    content_hash CHAR(64) DEFAULT NULL,
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    trigger_table VARCHAR(32) DEFAULT NULL,
+    trigger_action VARCHAR(20) DEFAULT NULL,
+    trigger_record_id INTEGER DEFAULT NULL,
+    trigger_name VARCHAR(255) DEFAULT NULL,
+    trigger_type VARCHAR(10) DEFAULT NULL,
+    result VARCHAR(10) NOT NULL, -- changed, failed
+    error TEXT DEFAULT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS zone_generations_domain_name_created_at_index ON zone_generations(domain_name, created_at);
+
+-- Before/after images of every record change, written by triggers. No
+-- foreign keys so history outlives deleted records and domains.
+CREATE TABLE IF NOT EXISTS record_versions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    record_id INTEGER NOT NULL,
+    domain_id INTEGER DEFAULT NULL,
+    action VARCHAR(10) NOT NULL, -- INSERT, UPDATE, DELETE
+    old_data TEXT DEFAULT NULL,
+    new_data TEXT DEFAULT NULL,
+    changed_by VARCHAR(100) DEFAULT NULL,
+    changed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS record_versions_record_id_index ON record_versions(record_id, id);
+
+-- Record images use the same keys and types as the PostgreSQL schema:
+-- booleans as JSON booleans and timestamps as RFC 3339 UTC.
+CREATE TRIGGER IF NOT EXISTS records_version_insert AFTER INSERT ON records
+BEGIN
+    INSERT INTO record_versions (record_id, domain_id, action, new_data)
+    VALUES (NEW.id, NEW.domain_id, 'INSERT', json_object(
+        'id', NEW.id, 'domain_id', NEW.domain_id, 'name', NEW.name, 'type', NEW.type,
+        'content', NEW.content, 'ttl', NEW.ttl, 'prio', NEW.prio, 'weight', NEW.weight,
+        'disabled', json(CASE WHEN NEW.disabled THEN 'true' ELSE 'false' END),
+        'ordername', NEW.ordername,
+        'auth', json(CASE WHEN NEW.auth THEN 'true' ELSE 'false' END),
+        'created_at', strftime('%Y-%m-%dT%H:%M:%SZ', NEW.created_at),
+        'updated_at', strftime('%Y-%m-%dT%H:%M:%SZ', NEW.updated_at),
+        'created_by', NEW.created_by, 'comment', NEW.comment
+    ));
+END;
+
+CREATE TRIGGER IF NOT EXISTS records_version_update AFTER UPDATE ON records
+BEGIN
+    INSERT INTO record_versions (record_id, domain_id, action, old_data, new_data)
+    VALUES (NEW.id, NEW.domain_id, 'UPDATE', json_object(
+        'id', OLD.id, 'domain_id', OLD.domain_id, 'name', OLD.name, 'type', OLD.type,
+        'content', OLD.content, 'ttl', OLD.ttl, 'prio', OLD.prio, 'weight', OLD.weight,
+        'disabled', json(CASE WHEN OLD.disabled THEN 'true' ELSE 'false' END),
+        'ordername', OLD.ordername,
+        'auth', json(CASE WHEN OLD.auth THEN 'true' ELSE 'false' END),
+        'created_at', strftime('%Y-%m-%dT%H:%M:%SZ', OLD.created_at),
+        'updated_at', strftime('%Y-%m-%dT%H:%M:%SZ', OLD.updated_at),
+        'created_by', OLD.created_by, 'comment', OLD.comment
+    ), json_object(
+        'id', NEW.id, 'domain_id', NEW.domain_id, 'name', NEW.name, 'type', NEW.type,
+        'content', NEW.content, 'ttl', NEW.ttl, 'prio', NEW.prio, 'weight', NEW.weight,
+        'disabled', json(CASE WHEN NEW.disabled THEN 'true' ELSE 'false' END),
+        'ordername', NEW.ordername,
+        'auth', json(CASE WHEN NEW.auth THEN 'true' ELSE 'false' END),
+        'created_at', strftime('%Y-%m-%dT%H:%M:%SZ', NEW.created_at),
+        'updated_at', strftime('%Y-%m-%dT%H:%M:%SZ', NEW.updated_at),
+        'created_by', NEW.created_by, 'comment', NEW.comment
+    ));
+END;
+
+CREATE TRIGGER IF NOT EXISTS records_version_delete AFTER DELETE ON records
+BEGIN
+    INSERT INTO record_versions (record_id, domain_id, action, old_data)
+    VALUES (OLD.id, OLD.domain_id, 'DELETE', json_object(
+        'id', OLD.id, 'domain_id', OLD.domain_id, 'name', OLD.name, 'type', OLD.type,
+        'content', OLD.content, 'ttl', OLD.ttl, 'prio', OLD.prio, 'weight', OLD.weight,
+        'disabled', json(CASE WHEN OLD.disabled THEN 'true' ELSE 'false' END),
+        'ordername', OLD.ordername,
+        'auth', json(CASE WHEN OLD.auth THEN 'true' ELSE 'false' END),
+        'created_at', strftime('%Y-%m-%dT%H:%M:%SZ', OLD.created_at),
+        'updated_at', strftime('%Y-%m-%dT%H:%M:%SZ', OLD.updated_at),
+        'created_by', OLD.created_by, 'comment', OLD.comment
+    ));
+END;
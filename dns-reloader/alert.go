@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Alert describes a failure that has persisted past ALERT_THRESHOLD
+// consecutive occurrences, or its recovery.
+type Alert struct {
+	Kind          string      `json:"kind"`
+	Subject       string      `json:"subject"`
+	Resolved      bool        `json:"resolved"`
+	Error         string      `json:"error,omitempty"`
+	Failures      int         `json:"consecutive_failures"`
+	FirstFailure  time.Time   `json:"first_failure"`
+	LastFailure   time.Time   `json:"last_failure"`
+	PendingZones  []string    `json:"pending_reload_zones,omitempty"`
+	RecentChanges []ChangeSet `json:"recent_changes,omitempty"`
+}
+
+func (a Alert) Summary() string {
+	if a.Resolved {
+		return fmt.Sprintf("[dns-reloader] RESOLVED: %s %s recovered after %d failures", a.Kind, a.Subject, a.Failures)
+	}
+	return fmt.Sprintf("[dns-reloader] %s %s failing (%d consecutive since %s): %s",
+		a.Kind, a.Subject, a.Failures, a.FirstFailure.UTC().Format(time.RFC3339), a.Error)
+}
+
+// AlertNotifier delivers an alert to one destination.
+type AlertNotifier interface {
+	Name() string
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// WebhookNotifier posts alerts as generic JSON, or in the message format
+// expected by Slack or Discord incoming webhooks.
+type WebhookNotifier struct {
+	url    string
+	format string
+}
+
+func NewWebhookNotifier(url, format string) (*WebhookNotifier, error) {
+	switch format {
+	case "json", "slack", "discord":
+		return &WebhookNotifier{url: url, format: format}, nil
+	default:
+		return nil, fmt.Errorf("unknown ALERT_WEBHOOK_FORMAT %q", format)
+	}
+}
+
+func (w *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	switch w.format {
+	case "slack":
+		return postJSON(ctx, w.url, map[string]string{"text": alertText(alert)})
+	case "discord":
+		text := alertText(alert)
+		if len(text) > 2000 {
+			text = text[:1997] + "..."
+		}
+		return postJSON(ctx, w.url, map[string]string{"content": text})
+	default:
+		return postJSON(ctx, w.url, alert)
+	}
+}
+
+// alertText renders an alert as plain text for chat and mail destinations.
+func alertText(alert Alert) string {
+	var b strings.Builder
+	b.WriteString(alert.Summary())
+	if len(alert.PendingZones) > 0 {
+		fmt.Fprintf(&b, "\nPending reload: %s", strings.Join(alert.PendingZones, ", "))
+	}
+	if len(alert.RecentChanges) > 0 {
+		b.WriteString("\nRecent changes:")
+		for _, cs := range alert.RecentChanges {
+			fmt.Fprintf(&b, "\n- %s %s %s %s %s", cs.AppliedAt.UTC().Format(time.RFC3339), cs.Action, cs.Table, cs.Type, cs.Name)
+			if cs.Error != "" {
+				fmt.Fprintf(&b, " (error: %s)", cs.Error)
+			}
+		}
+	}
+	return b.String()
+}
+
+type failureState struct {
+	count     int
+	first     time.Time
+	last      time.Time
+	lastError string
+	alerted   time.Time
+}
+
+// alertManager counts consecutive failures per kind and subject and notifies
+// once the threshold is reached, again every repeat interval while the
+// failure persists, and once more when it recovers.
+type alertManager struct {
+	mu        sync.Mutex
+	notifiers []AlertNotifier
+	threshold int
+	repeat    time.Duration
+	failures  map[string]*failureState
+	logger    *logrus.Logger
+}
+
+func newAlertManager(config *Config, logger *logrus.Logger) (*alertManager, error) {
+	var notifiers []AlertNotifier
+	if config.AlertWebhookURL != "" {
+		webhook, err := NewWebhookNotifier(config.AlertWebhookURL, config.AlertWebhookFormat)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, webhook)
+	}
+	if config.SMTPHost != "" && len(config.AlertEmailTo) > 0 {
+		mailer, err := NewSMTPNotifier(config)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, mailer)
+	}
+
+	threshold := config.AlertThreshold
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &alertManager{
+		notifiers: notifiers,
+		threshold: threshold,
+		repeat:    config.AlertRepeatInterval,
+		failures:  make(map[string]*failureState),
+		logger:    logger,
+	}, nil
+}
+
+// failure records one failure and returns the alert to send, if any.
+// threshold overrides the configured count when positive.
+func (m *alertManager) failure(kind, subject string, err error, threshold int) *Alert {
+	if m == nil || len(m.notifiers) == 0 {
+		return nil
+	}
+	if threshold < 1 {
+		threshold = m.threshold
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := kind + "/" + subject
+	now := time.Now()
+	state, ok := m.failures[key]
+	if !ok {
+		state = &failureState{first: now}
+		m.failures[key] = state
+	}
+	state.count++
+	state.last = now
+	state.lastError = err.Error()
+
+	if state.count < threshold {
+		return nil
+	}
+	if !state.alerted.IsZero() && (m.repeat <= 0 || now.Sub(state.alerted) < m.repeat) {
+		return nil
+	}
+	state.alerted = now
+	return &Alert{
+		Kind:         kind,
+		Subject:      subject,
+		Error:        state.lastError,
+		Failures:     state.count,
+		FirstFailure: state.first,
+		LastFailure:  state.last,
+	}
+}
+
+// success clears the failure count and returns a recovery alert if the
+// failure had been alerted on.
+func (m *alertManager) success(kind, subject string) *Alert {
+	if m == nil || len(m.notifiers) == 0 {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := kind + "/" + subject
+	state, ok := m.failures[key]
+	if !ok {
+		return nil
+	}
+	delete(m.failures, key)
+	if state.alerted.IsZero() {
+		return nil
+	}
+	return &Alert{
+		Kind:         kind,
+		Subject:      subject,
+		Resolved:     true,
+		Failures:     state.count,
+		FirstFailure: state.first,
+		LastFailure:  state.last,
+	}
+}
+
+// dispatch delivers alert to every notifier in the background so a slow
+// destination never holds up zone generation.
+func (m *alertManager) dispatch(ctx context.Context, alert Alert) {
+	for _, notifier := range m.notifiers {
+		go func(notifier AlertNotifier) {
+			sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+			defer cancel()
+			if err := notifier.Notify(sendCtx, alert); err != nil {
+				m.logger.WithError(err).WithFields(logrus.Fields{
+					"notifier": notifier.Name(),
+					"kind":     alert.Kind,
+					"subject":  alert.Subject,
+				}).Error("Failed to deliver alert")
+			}
+		}(notifier)
+	}
+}
+
+func (r *Reloader) alertFailure(kind, subject string, err error) {
+	r.raiseAlert(kind, subject, err, 0)
+}
+
+func (r *Reloader) raiseAlert(kind, subject string, err error, threshold int) {
+	alert := r.alerts.failure(kind, subject, err, threshold)
+	if alert == nil {
+		return
+	}
+	if r.pending != nil {
+		alert.PendingZones = r.pending.zones
+	}
+	alert.RecentChanges = r.changes.Since(alert.FirstFailure.Add(-time.Hour))
+	if len(alert.RecentChanges) > 5 {
+		alert.RecentChanges = alert.RecentChanges[:5]
+	}
+	r.logger.WithFields(logrus.Fields{
+		"kind":     kind,
+		"subject":  subject,
+		"failures": alert.Failures,
+	}).Warn("Sending failure alert")
+	r.alerts.dispatch(r.ctx, *alert)
+}
+
+func (r *Reloader) alertRecovered(kind, subject string) {
+	if alert := r.alerts.success(kind, subject); alert != nil {
+		r.alerts.dispatch(r.ctx, *alert)
+	}
+}
+
+// databaseDown alerts once the database has been unreachable for longer than
+// DATABASE_ALERT_AFTER.
+func (r *Reloader) databaseDown(err error) {
+	if r.dbDownSince.IsZero() {
+		r.dbDownSince = time.Now()
+	}
+	down := time.Since(r.dbDownSince)
+	if down < r.config.DatabaseAlertAfter {
+		return
+	}
+	r.raiseAlert("database", r.storage.Host(), fmt.Errorf("unreachable for %s: %w", down.Round(time.Second), err), 1)
+}
+
+func (r *Reloader) databaseUp() {
+	r.dbDownSince = time.Time{}
+	r.alertRecovered("database", r.storage.Host())
+}
+
+// checkDatabase pings the database on every listener or polling tick.
+func (r *Reloader) checkDatabase() {
+	if r.rawDB == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.ctx, 5*time.Second)
+	defer cancel()
+	if err := r.rawDB.PingContext(ctx); err != nil {
+		r.logger.WithError(err).Warn("Database ping failed")
+		r.databaseDown(err)
+		return
+	}
+	r.databaseUp()
+}
@@ -0,0 +1,1413 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        v5.29.3
+// source: dnsreloader.proto
+
+package dnsreloaderv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Domain struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Type          string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	Master        *string                `protobuf:"bytes,4,opt,name=master,proto3,oneof" json:"master,omitempty"`
+	Account       *string                `protobuf:"bytes,5,opt,name=account,proto3,oneof" json:"account,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Domain) Reset() {
+	*x = Domain{}
+	mi := &file_dnsreloader_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Domain) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Domain) ProtoMessage() {}
+
+func (x *Domain) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsreloader_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Domain.ProtoReflect.Descriptor instead.
+func (*Domain) Descriptor() ([]byte, []int) {
+	return file_dnsreloader_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Domain) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Domain) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Domain) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Domain) GetMaster() string {
+	if x != nil && x.Master != nil {
+		return *x.Master
+	}
+	return ""
+}
+
+func (x *Domain) GetAccount() string {
+	if x != nil && x.Account != nil {
+		return *x.Account
+	}
+	return ""
+}
+
+func (x *Domain) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Domain) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type Record struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	DomainId      int64                  `protobuf:"varint,2,opt,name=domain_id,json=domainId,proto3" json:"domain_id,omitempty"`
+	Name          string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Type          string                 `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"`
+	Content       string                 `protobuf:"bytes,5,opt,name=content,proto3" json:"content,omitempty"`
+	Ttl           int32                  `protobuf:"varint,6,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	Prio          *int32                 `protobuf:"varint,7,opt,name=prio,proto3,oneof" json:"prio,omitempty"`
+	Weight        *int32                 `protobuf:"varint,8,opt,name=weight,proto3,oneof" json:"weight,omitempty"`
+	Disabled      bool                   `protobuf:"varint,9,opt,name=disabled,proto3" json:"disabled,omitempty"`
+	Auth          bool                   `protobuf:"varint,10,opt,name=auth,proto3" json:"auth,omitempty"`
+	Comment       *string                `protobuf:"bytes,11,opt,name=comment,proto3,oneof" json:"comment,omitempty"`
+	CreatedBy     string                 `protobuf:"bytes,12,opt,name=created_by,json=createdBy,proto3" json:"created_by,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,14,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Record) Reset() {
+	*x = Record{}
+	mi := &file_dnsreloader_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Record) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Record) ProtoMessage() {}
+
+func (x *Record) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsreloader_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Record.ProtoReflect.Descriptor instead.
+func (*Record) Descriptor() ([]byte, []int) {
+	return file_dnsreloader_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Record) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Record) GetDomainId() int64 {
+	if x != nil {
+		return x.DomainId
+	}
+	return 0
+}
+
+func (x *Record) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Record) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Record) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *Record) GetTtl() int32 {
+	if x != nil {
+		return x.Ttl
+	}
+	return 0
+}
+
+func (x *Record) GetPrio() int32 {
+	if x != nil && x.Prio != nil {
+		return *x.Prio
+	}
+	return 0
+}
+
+func (x *Record) GetWeight() int32 {
+	if x != nil && x.Weight != nil {
+		return *x.Weight
+	}
+	return 0
+}
+
+func (x *Record) GetDisabled() bool {
+	if x != nil {
+		return x.Disabled
+	}
+	return false
+}
+
+func (x *Record) GetAuth() bool {
+	if x != nil {
+		return x.Auth
+	}
+	return false
+}
+
+func (x *Record) GetComment() string {
+	if x != nil && x.Comment != nil {
+		return *x.Comment
+	}
+	return ""
+}
+
+func (x *Record) GetCreatedBy() string {
+	if x != nil {
+		return x.CreatedBy
+	}
+	return ""
+}
+
+func (x *Record) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Record) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type RecordInput struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Content       string                 `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+	Ttl           *int32                 `protobuf:"varint,4,opt,name=ttl,proto3,oneof" json:"ttl,omitempty"`
+	Prio          *int32                 `protobuf:"varint,5,opt,name=prio,proto3,oneof" json:"prio,omitempty"`
+	Weight        *int32                 `protobuf:"varint,6,opt,name=weight,proto3,oneof" json:"weight,omitempty"`
+	Disabled      bool                   `protobuf:"varint,7,opt,name=disabled,proto3" json:"disabled,omitempty"`
+	Comment       *string                `protobuf:"bytes,8,opt,name=comment,proto3,oneof" json:"comment,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecordInput) Reset() {
+	*x = RecordInput{}
+	mi := &file_dnsreloader_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordInput) ProtoMessage() {}
+
+func (x *RecordInput) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsreloader_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordInput.ProtoReflect.Descriptor instead.
+func (*RecordInput) Descriptor() ([]byte, []int) {
+	return file_dnsreloader_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RecordInput) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *RecordInput) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *RecordInput) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *RecordInput) GetTtl() int32 {
+	if x != nil && x.Ttl != nil {
+		return *x.Ttl
+	}
+	return 0
+}
+
+func (x *RecordInput) GetPrio() int32 {
+	if x != nil && x.Prio != nil {
+		return *x.Prio
+	}
+	return 0
+}
+
+func (x *RecordInput) GetWeight() int32 {
+	if x != nil && x.Weight != nil {
+		return *x.Weight
+	}
+	return 0
+}
+
+func (x *RecordInput) GetDisabled() bool {
+	if x != nil {
+		return x.Disabled
+	}
+	return false
+}
+
+func (x *RecordInput) GetComment() string {
+	if x != nil && x.Comment != nil {
+		return *x.Comment
+	}
+	return ""
+}
+
+type ListDomainsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDomainsRequest) Reset() {
+	*x = ListDomainsRequest{}
+	mi := &file_dnsreloader_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDomainsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDomainsRequest) ProtoMessage() {}
+
+func (x *ListDomainsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsreloader_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDomainsRequest.ProtoReflect.Descriptor instead.
+func (*ListDomainsRequest) Descriptor() ([]byte, []int) {
+	return file_dnsreloader_proto_rawDescGZIP(), []int{3}
+}
+
+type ListDomainsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domains       []*Domain              `protobuf:"bytes,1,rep,name=domains,proto3" json:"domains,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDomainsResponse) Reset() {
+	*x = ListDomainsResponse{}
+	mi := &file_dnsreloader_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDomainsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDomainsResponse) ProtoMessage() {}
+
+func (x *ListDomainsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsreloader_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDomainsResponse.ProtoReflect.Descriptor instead.
+func (*ListDomainsResponse) Descriptor() ([]byte, []int) {
+	return file_dnsreloader_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListDomainsResponse) GetDomains() []*Domain {
+	if x != nil {
+		return x.Domains
+	}
+	return nil
+}
+
+type GetDomainRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domain        string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDomainRequest) Reset() {
+	*x = GetDomainRequest{}
+	mi := &file_dnsreloader_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDomainRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDomainRequest) ProtoMessage() {}
+
+func (x *GetDomainRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsreloader_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDomainRequest.ProtoReflect.Descriptor instead.
+func (*GetDomainRequest) Descriptor() ([]byte, []int) {
+	return file_dnsreloader_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetDomainRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+type CreateDomainRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Name    string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type    string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Master  *string                `protobuf:"bytes,3,opt,name=master,proto3,oneof" json:"master,omitempty"`
+	Account *string                `protobuf:"bytes,4,opt,name=account,proto3,oneof" json:"account,omitempty"`
+	// Defaults to true.
+	Seed          *bool `protobuf:"varint,5,opt,name=seed,proto3,oneof" json:"seed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateDomainRequest) Reset() {
+	*x = CreateDomainRequest{}
+	mi := &file_dnsreloader_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateDomainRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateDomainRequest) ProtoMessage() {}
+
+func (x *CreateDomainRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsreloader_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateDomainRequest.ProtoReflect.Descriptor instead.
+func (*CreateDomainRequest) Descriptor() ([]byte, []int) {
+	return file_dnsreloader_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CreateDomainRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CreateDomainRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *CreateDomainRequest) GetMaster() string {
+	if x != nil && x.Master != nil {
+		return *x.Master
+	}
+	return ""
+}
+
+func (x *CreateDomainRequest) GetAccount() string {
+	if x != nil && x.Account != nil {
+		return *x.Account
+	}
+	return ""
+}
+
+func (x *CreateDomainRequest) GetSeed() bool {
+	if x != nil && x.Seed != nil {
+		return *x.Seed
+	}
+	return false
+}
+
+type CreateDomainResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domain        *Domain                `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	Records       []*Record              `protobuf:"bytes,2,rep,name=records,proto3" json:"records,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateDomainResponse) Reset() {
+	*x = CreateDomainResponse{}
+	mi := &file_dnsreloader_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateDomainResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateDomainResponse) ProtoMessage() {}
+
+func (x *CreateDomainResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsreloader_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateDomainResponse.ProtoReflect.Descriptor instead.
+func (*CreateDomainResponse) Descriptor() ([]byte, []int) {
+	return file_dnsreloader_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *CreateDomainResponse) GetDomain() *Domain {
+	if x != nil {
+		return x.Domain
+	}
+	return nil
+}
+
+func (x *CreateDomainResponse) GetRecords() []*Record {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+type UpdateDomainRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domain        string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Master        *string                `protobuf:"bytes,3,opt,name=master,proto3,oneof" json:"master,omitempty"`
+	Account       *string                `protobuf:"bytes,4,opt,name=account,proto3,oneof" json:"account,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateDomainRequest) Reset() {
+	*x = UpdateDomainRequest{}
+	mi := &file_dnsreloader_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateDomainRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateDomainRequest) ProtoMessage() {}
+
+func (x *UpdateDomainRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsreloader_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateDomainRequest.ProtoReflect.Descriptor instead.
+func (*UpdateDomainRequest) Descriptor() ([]byte, []int) {
+	return file_dnsreloader_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *UpdateDomainRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *UpdateDomainRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *UpdateDomainRequest) GetMaster() string {
+	if x != nil && x.Master != nil {
+		return *x.Master
+	}
+	return ""
+}
+
+func (x *UpdateDomainRequest) GetAccount() string {
+	if x != nil && x.Account != nil {
+		return *x.Account
+	}
+	return ""
+}
+
+type DeleteDomainRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domain        string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteDomainRequest) Reset() {
+	*x = DeleteDomainRequest{}
+	mi := &file_dnsreloader_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteDomainRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteDomainRequest) ProtoMessage() {}
+
+func (x *DeleteDomainRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsreloader_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteDomainRequest.ProtoReflect.Descriptor instead.
+func (*DeleteDomainRequest) Descriptor() ([]byte, []int) {
+	return file_dnsreloader_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DeleteDomainRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+type ListRecordsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domain        string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRecordsRequest) Reset() {
+	*x = ListRecordsRequest{}
+	mi := &file_dnsreloader_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRecordsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRecordsRequest) ProtoMessage() {}
+
+func (x *ListRecordsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsreloader_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRecordsRequest.ProtoReflect.Descriptor instead.
+func (*ListRecordsRequest) Descriptor() ([]byte, []int) {
+	return file_dnsreloader_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ListRecordsRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+type ListRecordsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domain        string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	Records       []*Record              `protobuf:"bytes,2,rep,name=records,proto3" json:"records,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRecordsResponse) Reset() {
+	*x = ListRecordsResponse{}
+	mi := &file_dnsreloader_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRecordsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRecordsResponse) ProtoMessage() {}
+
+func (x *ListRecordsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsreloader_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRecordsResponse.ProtoReflect.Descriptor instead.
+func (*ListRecordsResponse) Descriptor() ([]byte, []int) {
+	return file_dnsreloader_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListRecordsResponse) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *ListRecordsResponse) GetRecords() []*Record {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+type CreateRecordRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domain        string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	Record        *RecordInput           `protobuf:"bytes,2,opt,name=record,proto3" json:"record,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateRecordRequest) Reset() {
+	*x = CreateRecordRequest{}
+	mi := &file_dnsreloader_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateRecordRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateRecordRequest) ProtoMessage() {}
+
+func (x *CreateRecordRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsreloader_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateRecordRequest.ProtoReflect.Descriptor instead.
+func (*CreateRecordRequest) Descriptor() ([]byte, []int) {
+	return file_dnsreloader_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *CreateRecordRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *CreateRecordRequest) GetRecord() *RecordInput {
+	if x != nil {
+		return x.Record
+	}
+	return nil
+}
+
+type UpdateRecordRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domain        string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	Id            int64                  `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	Record        *RecordInput           `protobuf:"bytes,3,opt,name=record,proto3" json:"record,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateRecordRequest) Reset() {
+	*x = UpdateRecordRequest{}
+	mi := &file_dnsreloader_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateRecordRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateRecordRequest) ProtoMessage() {}
+
+func (x *UpdateRecordRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsreloader_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateRecordRequest.ProtoReflect.Descriptor instead.
+func (*UpdateRecordRequest) Descriptor() ([]byte, []int) {
+	return file_dnsreloader_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *UpdateRecordRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *UpdateRecordRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *UpdateRecordRequest) GetRecord() *RecordInput {
+	if x != nil {
+		return x.Record
+	}
+	return nil
+}
+
+type DeleteRecordRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domain        string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	Id            int64                  `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteRecordRequest) Reset() {
+	*x = DeleteRecordRequest{}
+	mi := &file_dnsreloader_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRecordRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRecordRequest) ProtoMessage() {}
+
+func (x *DeleteRecordRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsreloader_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRecordRequest.ProtoReflect.Descriptor instead.
+func (*DeleteRecordRequest) Descriptor() ([]byte, []int) {
+	return file_dnsreloader_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *DeleteRecordRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *DeleteRecordRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type WatchChangesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only stream changes whose name or regenerated zones include this zone.
+	Zone          string `protobuf:"bytes,1,opt,name=zone,proto3" json:"zone,omitempty"`
+	SinceId       int64  `protobuf:"varint,2,opt,name=since_id,json=sinceId,proto3" json:"since_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchChangesRequest) Reset() {
+	*x = WatchChangesRequest{}
+	mi := &file_dnsreloader_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchChangesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchChangesRequest) ProtoMessage() {}
+
+func (x *WatchChangesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsreloader_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchChangesRequest.ProtoReflect.Descriptor instead.
+func (*WatchChangesRequest) Descriptor() ([]byte, []int) {
+	return file_dnsreloader_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *WatchChangesRequest) GetZone() string {
+	if x != nil {
+		return x.Zone
+	}
+	return ""
+}
+
+func (x *WatchChangesRequest) GetSinceId() int64 {
+	if x != nil {
+		return x.SinceId
+	}
+	return 0
+}
+
+type ChangeEvent struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Id                int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Action            string                 `protobuf:"bytes,2,opt,name=action,proto3" json:"action,omitempty"`
+	Table             string                 `protobuf:"bytes,3,opt,name=table,proto3" json:"table,omitempty"`
+	DomainId          int64                  `protobuf:"varint,4,opt,name=domain_id,json=domainId,proto3" json:"domain_id,omitempty"`
+	Name              string                 `protobuf:"bytes,5,opt,name=name,proto3" json:"name,omitempty"`
+	Type              string                 `protobuf:"bytes,6,opt,name=type,proto3" json:"type,omitempty"`
+	Zones             []string               `protobuf:"bytes,7,rep,name=zones,proto3" json:"zones,omitempty"`
+	Backend           string                 `protobuf:"bytes,8,opt,name=backend,proto3" json:"backend,omitempty"`
+	Reloaded          bool                   `protobuf:"varint,9,opt,name=reloaded,proto3" json:"reloaded,omitempty"`
+	Error             string                 `protobuf:"bytes,10,opt,name=error,proto3" json:"error,omitempty"`
+	Verification      string                 `protobuf:"bytes,11,opt,name=verification,proto3" json:"verification,omitempty"`
+	VerificationError string                 `protobuf:"bytes,12,opt,name=verification_error,json=verificationError,proto3" json:"verification_error,omitempty"`
+	AppliedAt         *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=applied_at,json=appliedAt,proto3" json:"applied_at,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *ChangeEvent) Reset() {
+	*x = ChangeEvent{}
+	mi := &file_dnsreloader_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChangeEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChangeEvent) ProtoMessage() {}
+
+func (x *ChangeEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_dnsreloader_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChangeEvent.ProtoReflect.Descriptor instead.
+func (*ChangeEvent) Descriptor() ([]byte, []int) {
+	return file_dnsreloader_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ChangeEvent) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *ChangeEvent) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *ChangeEvent) GetTable() string {
+	if x != nil {
+		return x.Table
+	}
+	return ""
+}
+
+func (x *ChangeEvent) GetDomainId() int64 {
+	if x != nil {
+		return x.DomainId
+	}
+	return 0
+}
+
+func (x *ChangeEvent) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ChangeEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ChangeEvent) GetZones() []string {
+	if x != nil {
+		return x.Zones
+	}
+	return nil
+}
+
+func (x *ChangeEvent) GetBackend() string {
+	if x != nil {
+		return x.Backend
+	}
+	return ""
+}
+
+func (x *ChangeEvent) GetReloaded() bool {
+	if x != nil {
+		return x.Reloaded
+	}
+	return false
+}
+
+func (x *ChangeEvent) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *ChangeEvent) GetVerification() string {
+	if x != nil {
+		return x.Verification
+	}
+	return ""
+}
+
+func (x *ChangeEvent) GetVerificationError() string {
+	if x != nil {
+		return x.VerificationError
+	}
+	return ""
+}
+
+func (x *ChangeEvent) GetAppliedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.AppliedAt
+	}
+	return nil
+}
+
+var File_dnsreloader_proto protoreflect.FileDescriptor
+
+const file_dnsreloader_proto_rawDesc = "" +
+	"\n" +
+	"\x11dnsreloader.proto\x12\x0ednsreloader.v1\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\x89\x02\n" +
+	"\x06Domain\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x1b\n" +
+	"\x06master\x18\x04 \x01(\tH\x00R\x06master\x88\x01\x01\x12\x1d\n" +
+	"\aaccount\x18\x05 \x01(\tH\x01R\aaccount\x88\x01\x01\x129\n" +
+	"\n" +
+	"created_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAtB\t\n" +
+	"\a_masterB\n" +
+	"\n" +
+	"\b_account\"\xc3\x03\n" +
+	"\x06Record\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1b\n" +
+	"\tdomain_id\x18\x02 \x01(\x03R\bdomainId\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x04 \x01(\tR\x04type\x12\x18\n" +
+	"\acontent\x18\x05 \x01(\tR\acontent\x12\x10\n" +
+	"\x03ttl\x18\x06 \x01(\x05R\x03ttl\x12\x17\n" +
+	"\x04prio\x18\a \x01(\x05H\x00R\x04prio\x88\x01\x01\x12\x1b\n" +
+	"\x06weight\x18\b \x01(\x05H\x01R\x06weight\x88\x01\x01\x12\x1a\n" +
+	"\bdisabled\x18\t \x01(\bR\bdisabled\x12\x12\n" +
+	"\x04auth\x18\n" +
+	" \x01(\bR\x04auth\x12\x1d\n" +
+	"\acomment\x18\v \x01(\tH\x02R\acomment\x88\x01\x01\x12\x1d\n" +
+	"\n" +
+	"created_by\x18\f \x01(\tR\tcreatedBy\x129\n" +
+	"\n" +
+	"created_at\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x0e \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAtB\a\n" +
+	"\x05_prioB\t\n" +
+	"\a_weightB\n" +
+	"\n" +
+	"\b_comment\"\xff\x01\n" +
+	"\vRecordInput\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x18\n" +
+	"\acontent\x18\x03 \x01(\tR\acontent\x12\x15\n" +
+	"\x03ttl\x18\x04 \x01(\x05H\x00R\x03ttl\x88\x01\x01\x12\x17\n" +
+	"\x04prio\x18\x05 \x01(\x05H\x01R\x04prio\x88\x01\x01\x12\x1b\n" +
+	"\x06weight\x18\x06 \x01(\x05H\x02R\x06weight\x88\x01\x01\x12\x1a\n" +
+	"\bdisabled\x18\a \x01(\bR\bdisabled\x12\x1d\n" +
+	"\acomment\x18\b \x01(\tH\x03R\acomment\x88\x01\x01B\x06\n" +
+	"\x04_ttlB\a\n" +
+	"\x05_prioB\t\n" +
+	"\a_weightB\n" +
+	"\n" +
+	"\b_comment\"\x14\n" +
+	"\x12ListDomainsRequest\"G\n" +
+	"\x13ListDomainsResponse\x120\n" +
+	"\adomains\x18\x01 \x03(\v2\x16.dnsreloader.v1.DomainR\adomains\"*\n" +
+	"\x10GetDomainRequest\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\"\xb2\x01\n" +
+	"\x13CreateDomainRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x1b\n" +
+	"\x06master\x18\x03 \x01(\tH\x00R\x06master\x88\x01\x01\x12\x1d\n" +
+	"\aaccount\x18\x04 \x01(\tH\x01R\aaccount\x88\x01\x01\x12\x17\n" +
+	"\x04seed\x18\x05 \x01(\bH\x02R\x04seed\x88\x01\x01B\t\n" +
+	"\a_masterB\n" +
+	"\n" +
+	"\b_accountB\a\n" +
+	"\x05_seed\"x\n" +
+	"\x14CreateDomainResponse\x12.\n" +
+	"\x06domain\x18\x01 \x01(\v2\x16.dnsreloader.v1.DomainR\x06domain\x120\n" +
+	"\arecords\x18\x02 \x03(\v2\x16.dnsreloader.v1.RecordR\arecords\"\x94\x01\n" +
+	"\x13UpdateDomainRequest\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x1b\n" +
+	"\x06master\x18\x03 \x01(\tH\x00R\x06master\x88\x01\x01\x12\x1d\n" +
+	"\aaccount\x18\x04 \x01(\tH\x01R\aaccount\x88\x01\x01B\t\n" +
+	"\a_masterB\n" +
+	"\n" +
+	"\b_account\"-\n" +
+	"\x13DeleteDomainRequest\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\",\n" +
+	"\x12ListRecordsRequest\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\"_\n" +
+	"\x13ListRecordsResponse\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\x120\n" +
+	"\arecords\x18\x02 \x03(\v2\x16.dnsreloader.v1.RecordR\arecords\"b\n" +
+	"\x13CreateRecordRequest\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\x123\n" +
+	"\x06record\x18\x02 \x01(\v2\x1b.dnsreloader.v1.RecordInputR\x06record\"r\n" +
+	"\x13UpdateRecordRequest\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\x03R\x02id\x123\n" +
+	"\x06record\x18\x03 \x01(\v2\x1b.dnsreloader.v1.RecordInputR\x06record\"=\n" +
+	"\x13DeleteRecordRequest\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\x03R\x02id\"D\n" +
+	"\x13WatchChangesRequest\x12\x12\n" +
+	"\x04zone\x18\x01 \x01(\tR\x04zone\x12\x19\n" +
+	"\bsince_id\x18\x02 \x01(\x03R\asinceId\"\x80\x03\n" +
+	"\vChangeEvent\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x16\n" +
+	"\x06action\x18\x02 \x01(\tR\x06action\x12\x14\n" +
+	"\x05table\x18\x03 \x01(\tR\x05table\x12\x1b\n" +
+	"\tdomain_id\x18\x04 \x01(\x03R\bdomainId\x12\x12\n" +
+	"\x04name\x18\x05 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x06 \x01(\tR\x04type\x12\x14\n" +
+	"\x05zones\x18\a \x03(\tR\x05zones\x12\x18\n" +
+	"\abackend\x18\b \x01(\tR\abackend\x12\x1a\n" +
+	"\breloaded\x18\t \x01(\bR\breloaded\x12\x14\n" +
+	"\x05error\x18\n" +
+	" \x01(\tR\x05error\x12\"\n" +
+	"\fverification\x18\v \x01(\tR\fverification\x12-\n" +
+	"\x12verification_error\x18\f \x01(\tR\x11verificationError\x129\n" +
+	"\n" +
+	"applied_at\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\tappliedAt2\xb4\x06\n" +
+	"\vDNSReloader\x12V\n" +
+	"\vListDomains\x12\".dnsreloader.v1.ListDomainsRequest\x1a#.dnsreloader.v1.ListDomainsResponse\x12E\n" +
+	"\tGetDomain\x12 .dnsreloader.v1.GetDomainRequest\x1a\x16.dnsreloader.v1.Domain\x12Y\n" +
+	"\fCreateDomain\x12#.dnsreloader.v1.CreateDomainRequest\x1a$.dnsreloader.v1.CreateDomainResponse\x12K\n" +
+	"\fUpdateDomain\x12#.dnsreloader.v1.UpdateDomainRequest\x1a\x16.dnsreloader.v1.Domain\x12K\n" +
+	"\fDeleteDomain\x12#.dnsreloader.v1.DeleteDomainRequest\x1a\x16.google.protobuf.Empty\x12V\n" +
+	"\vListRecords\x12\".dnsreloader.v1.ListRecordsRequest\x1a#.dnsreloader.v1.ListRecordsResponse\x12K\n" +
+	"\fCreateRecord\x12#.dnsreloader.v1.CreateRecordRequest\x1a\x16.dnsreloader.v1.Record\x12K\n" +
+	"\fUpdateRecord\x12#.dnsreloader.v1.UpdateRecordRequest\x1a\x16.dnsreloader.v1.Record\x12K\n" +
+	"\fDeleteRecord\x12#.dnsreloader.v1.DeleteRecordRequest\x1a\x16.google.protobuf.Empty\x12R\n" +
+	"\fWatchChanges\x12#.dnsreloader.v1.WatchChangesRequest\x1a\x1b.dnsreloader.v1.ChangeEvent0\x01B#Z!dns-reloader/api/v1;dnsreloaderv1b\x06proto3"
+
+var (
+	file_dnsreloader_proto_rawDescOnce sync.Once
+	file_dnsreloader_proto_rawDescData []byte
+)
+
+func file_dnsreloader_proto_rawDescGZIP() []byte {
+	file_dnsreloader_proto_rawDescOnce.Do(func() {
+		file_dnsreloader_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_dnsreloader_proto_rawDesc), len(file_dnsreloader_proto_rawDesc)))
+	})
+	return file_dnsreloader_proto_rawDescData
+}
+
+var file_dnsreloader_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
+var file_dnsreloader_proto_goTypes = []any{
+	(*Domain)(nil),                // 0: dnsreloader.v1.Domain
+	(*Record)(nil),                // 1: dnsreloader.v1.Record
+	(*RecordInput)(nil),           // 2: dnsreloader.v1.RecordInput
+	(*ListDomainsRequest)(nil),    // 3: dnsreloader.v1.ListDomainsRequest
+	(*ListDomainsResponse)(nil),   // 4: dnsreloader.v1.ListDomainsResponse
+	(*GetDomainRequest)(nil),      // 5: dnsreloader.v1.GetDomainRequest
+	(*CreateDomainRequest)(nil),   // 6: dnsreloader.v1.CreateDomainRequest
+	(*CreateDomainResponse)(nil),  // 7: dnsreloader.v1.CreateDomainResponse
+	(*UpdateDomainRequest)(nil),   // 8: dnsreloader.v1.UpdateDomainRequest
+	(*DeleteDomainRequest)(nil),   // 9: dnsreloader.v1.DeleteDomainRequest
+	(*ListRecordsRequest)(nil),    // 10: dnsreloader.v1.ListRecordsRequest
+	(*ListRecordsResponse)(nil),   // 11: dnsreloader.v1.ListRecordsResponse
+	(*CreateRecordRequest)(nil),   // 12: dnsreloader.v1.CreateRecordRequest
+	(*UpdateRecordRequest)(nil),   // 13: dnsreloader.v1.UpdateRecordRequest
+	(*DeleteRecordRequest)(nil),   // 14: dnsreloader.v1.DeleteRecordRequest
+	(*WatchChangesRequest)(nil),   // 15: dnsreloader.v1.WatchChangesRequest
+	(*ChangeEvent)(nil),           // 16: dnsreloader.v1.ChangeEvent
+	(*timestamppb.Timestamp)(nil), // 17: google.protobuf.Timestamp
+	(*emptypb.Empty)(nil),         // 18: google.protobuf.Empty
+}
+var file_dnsreloader_proto_depIdxs = []int32{
+	17, // 0: dnsreloader.v1.Domain.created_at:type_name -> google.protobuf.Timestamp
+	17, // 1: dnsreloader.v1.Domain.updated_at:type_name -> google.protobuf.Timestamp
+	17, // 2: dnsreloader.v1.Record.created_at:type_name -> google.protobuf.Timestamp
+	17, // 3: dnsreloader.v1.Record.updated_at:type_name -> google.protobuf.Timestamp
+	0,  // 4: dnsreloader.v1.ListDomainsResponse.domains:type_name -> dnsreloader.v1.Domain
+	0,  // 5: dnsreloader.v1.CreateDomainResponse.domain:type_name -> dnsreloader.v1.Domain
+	1,  // 6: dnsreloader.v1.CreateDomainResponse.records:type_name -> dnsreloader.v1.Record
+	1,  // 7: dnsreloader.v1.ListRecordsResponse.records:type_name -> dnsreloader.v1.Record
+	2,  // 8: dnsreloader.v1.CreateRecordRequest.record:type_name -> dnsreloader.v1.RecordInput
+	2,  // 9: dnsreloader.v1.UpdateRecordRequest.record:type_name -> dnsreloader.v1.RecordInput
+	17, // 10: dnsreloader.v1.ChangeEvent.applied_at:type_name -> google.protobuf.Timestamp
+	3,  // 11: dnsreloader.v1.DNSReloader.ListDomains:input_type -> dnsreloader.v1.ListDomainsRequest
+	5,  // 12: dnsreloader.v1.DNSReloader.GetDomain:input_type -> dnsreloader.v1.GetDomainRequest
+	6,  // 13: dnsreloader.v1.DNSReloader.CreateDomain:input_type -> dnsreloader.v1.CreateDomainRequest
+	8,  // 14: dnsreloader.v1.DNSReloader.UpdateDomain:input_type -> dnsreloader.v1.UpdateDomainRequest
+	9,  // 15: dnsreloader.v1.DNSReloader.DeleteDomain:input_type -> dnsreloader.v1.DeleteDomainRequest
+	10, // 16: dnsreloader.v1.DNSReloader.ListRecords:input_type -> dnsreloader.v1.ListRecordsRequest
+	12, // 17: dnsreloader.v1.DNSReloader.CreateRecord:input_type -> dnsreloader.v1.CreateRecordRequest
+	13, // 18: dnsreloader.v1.DNSReloader.UpdateRecord:input_type -> dnsreloader.v1.UpdateRecordRequest
+	14, // 19: dnsreloader.v1.DNSReloader.DeleteRecord:input_type -> dnsreloader.v1.DeleteRecordRequest
+	15, // 20: dnsreloader.v1.DNSReloader.WatchChanges:input_type -> dnsreloader.v1.WatchChangesRequest
+	4,  // 21: dnsreloader.v1.DNSReloader.ListDomains:output_type -> dnsreloader.v1.ListDomainsResponse
+	0,  // 22: dnsreloader.v1.DNSReloader.GetDomain:output_type -> dnsreloader.v1.Domain
+	7,  // 23: dnsreloader.v1.DNSReloader.CreateDomain:output_type -> dnsreloader.v1.CreateDomainResponse
+	0,  // 24: dnsreloader.v1.DNSReloader.UpdateDomain:output_type -> dnsreloader.v1.Domain
+	18, // 25: dnsreloader.v1.DNSReloader.DeleteDomain:output_type -> google.protobuf.Empty
+	11, // 26: dnsreloader.v1.DNSReloader.ListRecords:output_type -> dnsreloader.v1.ListRecordsResponse
+	1,  // 27: dnsreloader.v1.DNSReloader.CreateRecord:output_type -> dnsreloader.v1.Record
+	1,  // 28: dnsreloader.v1.DNSReloader.UpdateRecord:output_type -> dnsreloader.v1.Record
+	18, // 29: dnsreloader.v1.DNSReloader.DeleteRecord:output_type -> google.protobuf.Empty
+	16, // 30: dnsreloader.v1.DNSReloader.WatchChanges:output_type -> dnsreloader.v1.ChangeEvent
+	21, // [21:31] is the sub-list for method output_type
+	11, // [11:21] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_dnsreloader_proto_init() }
+func file_dnsreloader_proto_init() {
+	if File_dnsreloader_proto != nil {
+		return
+	}
+	file_dnsreloader_proto_msgTypes[0].OneofWrappers = []any{}
+	file_dnsreloader_proto_msgTypes[1].OneofWrappers = []any{}
+	file_dnsreloader_proto_msgTypes[2].OneofWrappers = []any{}
+	file_dnsreloader_proto_msgTypes[6].OneofWrappers = []any{}
+	file_dnsreloader_proto_msgTypes[8].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_dnsreloader_proto_rawDesc), len(file_dnsreloader_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   17,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_dnsreloader_proto_goTypes,
+		DependencyIndexes: file_dnsreloader_proto_depIdxs,
+		MessageInfos:      file_dnsreloader_proto_msgTypes,
+	}.Build()
+	File_dnsreloader_proto = out.File
+	file_dnsreloader_proto_goTypes = nil
+	file_dnsreloader_proto_depIdxs = nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ZoneGeneration is one row of the zone_generations audit table.
+type ZoneGeneration struct {
+	ID              uint      `gorm:"primaryKey;column:id" json:"id"`
+	DomainID        *int      `gorm:"column:domain_id" json:"domain_id,omitempty"`
+	DomainName      string    `gorm:"column:domain_name" json:"domain_name"`
+	Serial          string    `gorm:"column:serial" json:"serial,omitempty"`
+	RecordCount     int       `gorm:"column:record_count" json:"record_count"`
+	ContentHash     string    `gorm:"column:content_hash" json:"content_hash,omitempty"`
+	DurationMs      int64     `gorm:"column:duration_ms" json:"duration_ms"`
+	TriggerTable    string    `gorm:"column:trigger_table" json:"trigger_table,omitempty"`
+	TriggerAction   string    `gorm:"column:trigger_action" json:"trigger_action,omitempty"`
+	TriggerRecordID *int      `gorm:"column:trigger_record_id" json:"trigger_record_id,omitempty"`
+	TriggerName     string    `gorm:"column:trigger_name" json:"trigger_name,omitempty"`
+	TriggerType     string    `gorm:"column:trigger_type" json:"trigger_type,omitempty"`
+	Result          string    `gorm:"column:result" json:"result"`
+	Error           string    `gorm:"column:error" json:"error,omitempty"`
+	Account         *string   `gorm:"column:account" json:"account,omitempty"`
+	CreatedAt       time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (ZoneGeneration) TableName() string {
+	return "zone_generations"
+}
+
+// auditGeneration records a zone write or a failed attempt. Unchanged zones
+// are not written and so are not audited. Audit failures are logged but never
+// block zone generation.
+func (r *Reloader) auditGeneration(ctx context.Context, domain Domain, zone generatedZone, elapsed time.Duration, trigger *DNSChangeNotification, genErr error) {
+	if !r.config.AuditGenerations {
+		return
+	}
+
+	domainID := int(domain.ID)
+	row := ZoneGeneration{
+		DomainID:    &domainID,
+		DomainName:  domain.Name,
+		Serial:      zone.Serial,
+		RecordCount: zone.Records,
+		ContentHash: zone.Hash,
+		DurationMs:  elapsed.Milliseconds(),
+		Result:      "changed",
+		Account:     domain.Account,
+		CreatedAt:   time.Now(),
+	}
+	if trigger != nil {
+		row.TriggerTable = trigger.Table
+		row.TriggerAction = trigger.Action
+		row.TriggerName = trigger.Name
+		row.TriggerType = trigger.Type
+		if trigger.ID != 0 {
+			id := trigger.ID
+			row.TriggerRecordID = &id
+		}
+	} else {
+		row.TriggerAction = "STARTUP"
+	}
+	if genErr != nil {
+		row.Result = "failed"
+		row.Error = genErr.Error()
+	}
+
+	db := r.db.WithContext(ctx)
+	if !r.accounts {
+		db = db.Omit("account")
+	}
+	if err := db.Create(&row).Error; err != nil {
+		r.logger.WithError(err).WithField("domain", domain.Name).Warn("Failed to write zone generation audit record")
+	}
+}
+
+func (r *Reloader) handleZoneGenerations(w http.ResponseWriter, req *http.Request) {
+	limit := 50
+	if v := req.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = n
+	}
+
+	query := r.scopeAccountRecords(req.Context(), r.db.WithContext(req.Context())).Where("domain_name = ?", req.PathValue("name"))
+	if req.URL.Query().Get("result") != "" {
+		query = query.Where("result = ?", req.URL.Query().Get("result"))
+	}
+
+	var rows []ZoneGeneration
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&rows).Error; err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to fetch zone generations")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"generations": rows,
+	})
+}
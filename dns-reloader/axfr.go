@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// axfrOwner is the created_by of records loaded by import-axfr.
+const axfrOwner = "axfr"
+
+// tsigKey signs zone transfers.
+type tsigKey struct {
+	Name      string
+	Algorithm string
+	Secret    string
+}
+
+// parseTSIG reads a key in dig's -y form, [algorithm:]name:secret, with the
+// algorithm defaulting to hmac-sha256.
+func parseTSIG(s string) (*tsigKey, error) {
+	parts := strings.Split(s, ":")
+	key := &tsigKey{Algorithm: dns.HmacSHA256}
+	switch len(parts) {
+	case 2:
+		key.Name, key.Secret = parts[0], parts[1]
+	case 3:
+		key.Algorithm, key.Name, key.Secret = dns.Fqdn(strings.ToLower(parts[0])), parts[1], parts[2]
+	default:
+		return nil, errors.New("TSIG key must be [algorithm:]name:secret")
+	}
+	if key.Name == "" || key.Secret == "" {
+		return nil, errors.New("TSIG key must be [algorithm:]name:secret")
+	}
+	switch key.Algorithm {
+	case dns.HmacSHA1, dns.HmacSHA224, dns.HmacSHA256, dns.HmacSHA384, dns.HmacSHA512, dns.HmacMD5:
+	default:
+		return nil, fmt.Errorf("unsupported TSIG algorithm %q", parts[0])
+	}
+	key.Name = dns.Fqdn(strings.ToLower(key.Name))
+	return key, nil
+}
+
+// importAXFR transfers a zone from a live server and loads it into the
+// records table, creating the domain if it does not exist. A domain that
+// already has records is only overwritten with -replace.
+func (r *Reloader) importAXFR(args []string) error {
+	fs := flag.NewFlagSet("import-axfr", flag.ContinueOnError)
+	tsig := fs.String("tsig", "", "sign the transfer with a TSIG key, [algorithm:]name:secret or the name of a stored key")
+	domainType := fs.String("type", "NATIVE", "type of the domain if it has to be created")
+	replace := fs.Bool("replace", false, "replace the records of a domain that already has some")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: reloader import-axfr [flags] <zone> <server>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("import-axfr takes a zone and a server")
+	}
+	zone := strings.TrimSuffix(strings.ToLower(fs.Arg(0)), ".")
+	server := fs.Arg(1)
+
+	var key *tsigKey
+	if strings.Contains(*tsig, ":") {
+		var err error
+		if key, err = parseTSIG(*tsig); err != nil {
+			return err
+		}
+	}
+	if err := r.connectDB(); err != nil {
+		return err
+	}
+	if *tsig != "" && key == nil {
+		var err error
+		if key, err = r.findTSIGKey(r.ctx, *tsig); err != nil {
+			return err
+		}
+	}
+
+	rrs, err := transferZone(zone, server, key)
+	if err != nil {
+		return fmt.Errorf("failed to transfer %s from %s: %w", zone, server, err)
+	}
+	records := make([]Record, 0, len(rrs))
+	for _, rr := range rrs {
+		record := recordFromRR(rr, 0)
+		record.CreatedBy = axfrOwner
+		records = append(records, record)
+	}
+	for _, problem := range validateZoneRecords(zone, records) {
+		r.logger.WithField("zone", zone).Warn(problem)
+	}
+
+	domain, replaced, err := r.loadTransferredZone(r.ctx, zone, *domainType, *replace, records)
+	if err != nil {
+		return err
+	}
+	r.domainChanged(domain, "IMPORT")
+
+	r.logger.WithFields(logrus.Fields{
+		"zone":     zone,
+		"server":   server,
+		"records":  len(records),
+		"replaced": replaced,
+	}).Info("Imported zone over AXFR")
+	fmt.Printf("Imported %d records into %s from %s\n", len(records), zone, server)
+	return nil
+}
+
+// loadTransferredZone writes records as the whole content of zone in one
+// transaction, returning the domain and how many records it replaced.
+func (r *Reloader) loadTransferredZone(ctx context.Context, zone, domainType string, replace bool, records []Record) (*Domain, int64, error) {
+	var domain Domain
+	var replaced int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("name = ?", zone).First(&domain).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := validateDomainName(zone); err != nil {
+				return err
+			}
+			domain = Domain{Name: zone, Type: strings.ToUpper(domainType)}
+			if err := validateDomainFields(&domain, domainType); err != nil {
+				return err
+			}
+			err = tx.Omit(clause.Associations).Create(&domain).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&Record{}).Where("domain_id = ?", domain.ID).Count(&replaced).Error; err != nil {
+			return err
+		}
+		if replaced > 0 && !replace {
+			return fmt.Errorf("%s already has %d records; use -replace to overwrite them", zone, replaced)
+		}
+		if replaced > 0 {
+			if err := tx.Where("domain_id = ?", domain.ID).Delete(&Record{}).Error; err != nil {
+				return err
+			}
+		}
+
+		for i := range records {
+			records[i].DomainID = int(domain.ID)
+		}
+		return tx.Omit(clause.Associations).CreateInBatches(records, 500).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &domain, replaced, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+)
+
+// backupManifest describes one backup: the archive holding the zones
+// directory and, for each zone file in it, the serial it was served with
+// and its hash, so a restore can be checked file by file.
+type backupManifest struct {
+	CreatedAt     time.Time            `json:"created_at"`
+	Trigger       string               `json:"trigger"`
+	Archive       string               `json:"archive"`
+	ArchiveSHA256 string               `json:"archive_sha256"`
+	Zones         []backupManifestZone `json:"zones"`
+}
+
+type backupManifestZone struct {
+	Zone   string `json:"zone"`
+	File   string `json:"file"`
+	Serial uint32 `json:"serial,omitempty"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+}
+
+// zoneBackup uploads the zones directory as a tar.gz archive, with a JSON
+// manifest next to it, to an S3-compatible bucket under BACKUP_S3_PREFIX.
+// Backups older than BACKUP_RETENTION are deleted after each upload.
+// Restoring is extracting the archive into an empty zones directory.
+type zoneBackup struct {
+	s3        *s3Client
+	prefix    string
+	retention time.Duration
+}
+
+func newZoneBackup(config *Config) (*zoneBackup, error) {
+	if config.BackupS3Bucket == "" {
+		return nil, nil
+	}
+	client, err := newS3Client(config.BackupS3Endpoint, config.BackupS3Region, config.BackupS3Bucket)
+	if err != nil {
+		return nil, err
+	}
+	prefix := config.BackupS3Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &zoneBackup{s3: client, prefix: prefix, retention: config.BackupRetention}, nil
+}
+
+// backupZones uploads a backup of the zones directory. trigger says why,
+// "schedule" or the action of the change, and is recorded in the manifest.
+func (r *Reloader) backupZones(ctx context.Context, trigger string) {
+	if r.backup == nil {
+		return
+	}
+	r.lastBackup = time.Now()
+	manifest, err := r.backup.upload(ctx, r.config.ZonesDirectory, trigger)
+	if err != nil {
+		zoneBackups.WithLabelValues("failed").Inc()
+		r.logger.WithError(err).Error("Failed to back up zones")
+		r.alertFailure("backup", r.config.BackupS3Bucket, err)
+		return
+	}
+	zoneBackups.WithLabelValues("uploaded").Inc()
+	r.alertRecovered("backup", r.config.BackupS3Bucket)
+	r.logger.WithFields(logrus.Fields{
+		"archive": manifest.Archive,
+		"zones":   len(manifest.Zones),
+		"trigger": trigger,
+	}).Info("Backed up zones")
+
+	if pruned, err := r.backup.prune(ctx); err != nil {
+		r.logger.WithError(err).Warn("Failed to delete expired zone backups")
+	} else if pruned > 0 {
+		r.logger.WithField("deleted", pruned).Info("Deleted expired zone backups")
+	}
+}
+
+// checkBackup takes the scheduled backup once BACKUP_INTERVAL has passed
+// since the last one. Called on every listener or polling tick.
+func (r *Reloader) checkBackup() {
+	if r.backup == nil || r.config.BackupInterval <= 0 || time.Since(r.lastBackup) < r.config.BackupInterval {
+		return
+	}
+	r.backupZones(r.ctx, "schedule")
+}
+
+func (b *zoneBackup) upload(ctx context.Context, dir, trigger string) (*backupManifest, error) {
+	now := time.Now().UTC()
+	name := b.prefix + now.Format("20060102T150405Z")
+	manifest := &backupManifest{CreatedAt: now, Trigger: trigger, Archive: name + ".tar.gz"}
+
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		header := &tar.Header{Name: rel, Mode: 0644, Size: int64(len(content)), ModTime: now}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return err
+		}
+
+		if zone, ok := strings.CutPrefix(entry.Name(), "db."); ok {
+			hash := sha256.Sum256(content)
+			manifest.Zones = append(manifest.Zones, backupManifestZone{
+				Zone:   zone,
+				File:   rel,
+				Serial: zoneFileSerial(content, zone),
+				SHA256: hex.EncodeToString(hash[:]),
+				Size:   len(content),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive zones directory: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	sort.Slice(manifest.Zones, func(i, j int) bool { return manifest.Zones[i].File < manifest.Zones[j].File })
+	hash := sha256.Sum256(archive.Bytes())
+	manifest.ArchiveSHA256 = hex.EncodeToString(hash[:])
+
+	body, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	// The manifest goes last, so one that exists always has its archive.
+	if err := b.s3.put(ctx, manifest.Archive, archive.Bytes(), "application/gzip"); err != nil {
+		return nil, err
+	}
+	if err := b.s3.put(ctx, name+".manifest.json", body, "application/json"); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// prune deletes the backups older than the retention period and returns
+// how many objects went.
+func (b *zoneBackup) prune(ctx context.Context) (int, error) {
+	if b.retention <= 0 {
+		return 0, nil
+	}
+	objects, err := b.s3.list(ctx, b.prefix)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-b.retention)
+	deleted := 0
+	for _, object := range objects {
+		if !object.LastModified.Before(cutoff) {
+			continue
+		}
+		if !strings.HasSuffix(object.Key, ".tar.gz") && !strings.HasSuffix(object.Key, ".manifest.json") {
+			continue
+		}
+		if err := b.s3.delete(ctx, object.Key); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// zoneFileSerial returns the SOA serial in a zone file, or 0 if it has
+// none.
+func zoneFileSerial(content []byte, zone string) uint32 {
+	rrs, err := parseZone(bytes.NewReader(content), zone, "db."+zone)
+	if err != nil {
+		return 0
+	}
+	for _, rr := range rrs {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Serial
+		}
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Canary records are TXT records stamped with the time their zone was last
+// published. External monitoring queries them to check the whole pipeline
+// (database, zone file, CoreDNS) end to end: a stamp older than twice the
+// refresh interval means something along the way has stalled.
+
+func (r *Reloader) canaryEnabled(zone string) bool {
+	if r.config.CanaryRecord == "" {
+		return false
+	}
+	if len(r.config.CanaryZones) == 0 {
+		return true
+	}
+	for _, z := range r.config.CanaryZones {
+		if z == zone {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reloader) canaryLine(published time.Time) string {
+	return fmt.Sprintf("%-20s %d IN TXT \"published=%s\"\n",
+		r.config.CanaryRecord, 60, published.UTC().Format(time.RFC3339))
+}
+
+// splitCanary removes the canary line from rendered zone content and returns
+// the remaining content and the canary's publish time, if any.
+func (r *Reloader) splitCanary(content string) (string, time.Time) {
+	if r.config.CanaryRecord == "" {
+		return content, time.Time{}
+	}
+
+	var published time.Time
+	var body strings.Builder
+	for _, line := range strings.SplitAfter(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 5 && fields[0] == r.config.CanaryRecord && fields[3] == "TXT" {
+			value := strings.TrimPrefix(strings.Trim(fields[4], `"`), "published=")
+			published, _ = time.Parse(time.RFC3339, value)
+			continue
+		}
+		body.WriteString(line)
+	}
+	return body.String(), published
+}
+
+// refreshCanaries republishes zones whose canary is older than the refresh
+// interval. Called on every listener or polling tick.
+func (r *Reloader) refreshCanaries() {
+	if r.config.CanaryRecord == "" || time.Since(r.lastCanaryRefresh) < r.config.CanaryInterval {
+		return
+	}
+	r.lastCanaryRefresh = time.Now()
+
+	change := &DNSChangeNotification{
+		Table:     "canary",
+		Action:    "CANARY_REFRESH",
+		Timestamp: time.Now(),
+	}
+	if err := r.triggerCoreReload(r.ctx, change); err != nil {
+		r.logger.WithError(err).Error("Failed to refresh canary records")
+	}
+}
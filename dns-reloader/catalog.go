@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+)
+
+// catalogVersion is the catalog zone schema version of RFC 9432.
+const catalogVersion = "2"
+
+// catalogZone is the name of the catalog zone, or "" when CATALOG_ZONE is
+// unset.
+func (r *Reloader) catalogZone() string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(r.config.CatalogZone)), ".")
+}
+
+// catalogMemberID is the unique label of a member zone: the hex SHA-1 of
+// its name in wire format, as PowerDNS and Knot derive it, so the label of
+// a zone is stable across regenerations and reloaders.
+func catalogMemberID(zone string) string {
+	buf := make([]byte, 256)
+	n, err := dns.PackDomainName(dns.Fqdn(strings.ToLower(zone)), buf, 0, nil, false)
+	if err != nil {
+		n = copy(buf, zone)
+	}
+	sum := sha1.Sum(buf[:n])
+	return hex.EncodeToString(sum[:])
+}
+
+// renderCatalog builds the body of the catalog zone listing members, the
+// SOA aside, in a stable order so the file only changes with them.
+func renderCatalog(catalog string, members []string) string {
+	origin := dns.Fqdn(catalog)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s 0 IN NS invalid.\n", origin)
+	fmt.Fprintf(&b, "version.%s 0 IN TXT %q\n", origin, catalogVersion)
+	for _, member := range members {
+		fmt.Fprintf(&b, "%s.zones.%s 0 IN PTR %s\n", catalogMemberID(member), origin, dns.Fqdn(member))
+	}
+	return b.String()
+}
+
+// catalogSOA is the SOA line of the catalog zone. Catalog zones are never
+// queried, so the names are placeholders; the timers follow RFC 9432's
+// example.
+func catalogSOA(catalog string, serial uint32) string {
+	return fmt.Sprintf("%s 0 IN SOA invalid. invalid. %d 3600 600 2147483646 0\n", dns.Fqdn(catalog), serial)
+}
+
+// writeCatalogZone writes the CATALOG_ZONE file as an RFC 9432 catalog
+// listing every zone this reloader generates, so BIND and Knot secondaries
+// that consume it provision and drop member zones as domains come and go.
+// The serial is only advanced when the membership changes. Its secondaries
+// transfer it from DNS_LISTEN_ADDR like any generated zone, and are sent a
+// NOTIFY when it changes. With SHARD_COUNT it lists only this shard's
+// zones, so each shard needs a CATALOG_ZONE of its own.
+func (r *Reloader) writeCatalogZone(domains []Domain) {
+	catalog := r.catalogZone()
+	if catalog == "" {
+		return
+	}
+	var members []string
+	for _, domain := range domains {
+		if r.generates(domain) && domain.Name != catalog {
+			members = append(members, strings.ToLower(domain.Name))
+		}
+	}
+	slices.Sort(members)
+	members = slices.Compact(members)
+
+	zone, err := r.writeCatalogFile(catalog, members)
+	r.status.recordGeneration(catalog, zone, err)
+	if err != nil {
+		r.logger.WithError(err).WithField("domain", catalog).Error("Failed to write catalog zone")
+		r.alertFailure("generation", catalog, err)
+		return
+	}
+	r.alertRecovered("generation", catalog)
+	if zone.Changed {
+		r.logger.WithFields(logrus.Fields{
+			"domain":  catalog,
+			"serial":  zone.Serial,
+			"members": len(members),
+		}).Info("Catalog zone updated")
+		r.sendAlsoNotify([]string{catalog})
+	}
+}
+
+// writeCatalogFile renders the catalog and writes it when its members
+// differ from those on disk.
+func (r *Reloader) writeCatalogFile(catalog string, members []string) (generatedZone, error) {
+	path := r.viewZoneFilePath(catalog, zoneOptions{})
+	body := renderCatalog(catalog, members)
+
+	serial := uint32(1)
+	existing, err := os.ReadFile(path)
+	if err == nil {
+		current, onDisk := fileSerial(string(existing))
+		if onDisk {
+			if content := catalogSOA(catalog, current) + body; content == string(existing) {
+				return generatedZone{Serial: strconv.FormatUint(uint64(current), 10), Records: len(members), Hash: zoneHash(content)}, nil
+			}
+			serial = current + 1
+		}
+	}
+
+	content := catalogSOA(catalog, serial) + body
+	zone := generatedZone{
+		Changed: true,
+		Serial:  strconv.FormatUint(uint64(serial), 10),
+		Records: len(members),
+		Hash:    zoneHash(content),
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return zone, fmt.Errorf("failed to create zones directory: %w", err)
+	}
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, []byte(content), 0644); err != nil {
+		return zone, fmt.Errorf("failed to write temporary zone file: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		return zone, fmt.Errorf("failed to move zone file: %w", err)
+	}
+	return zone, nil
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChangeSet is one applied regeneration and reload cycle.
+type ChangeSet struct {
+	ID        int       `json:"id"`
+	Action    string    `json:"action"`
+	Table     string    `json:"table"`
+	DomainID  int       `json:"domain_id,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Type      string    `json:"type,omitempty"`
+	Zones     []string  `json:"zones,omitempty"`
+	Backend   string    `json:"backend"`
+	Reloaded  bool      `json:"reloaded"`
+	Error     string    `json:"error,omitempty"`
+	AppliedAt time.Time `json:"applied_at"`
+
+	Verification      string `json:"verification,omitempty"`
+	VerificationError string `json:"verification_error,omitempty"`
+}
+
+// ChangeHistory keeps the most recent applied change sets in memory.
+type ChangeHistory struct {
+	mu     sync.Mutex
+	nextID int
+	limit  int
+	items  []ChangeSet
+}
+
+func NewChangeHistory(limit int) *ChangeHistory {
+	return &ChangeHistory{limit: limit}
+}
+
+// Add stores cs and returns it with its assigned ID.
+func (h *ChangeHistory) Add(cs ChangeSet) ChangeSet {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextID++
+	cs.ID = h.nextID
+	h.items = append(h.items, cs)
+	if len(h.items) > h.limit {
+		h.items = h.items[len(h.items)-h.limit:]
+	}
+	return cs
+}
+
+// After returns the buffered change sets with an ID greater than id, oldest
+// first.
+func (h *ChangeHistory) After(id int) []ChangeSet {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var result []ChangeSet
+	for _, cs := range h.items {
+		if cs.ID > id {
+			result = append(result, cs)
+		}
+	}
+	return result
+}
+
+// Since returns change sets applied after t, newest first.
+func (h *ChangeHistory) Since(t time.Time) []ChangeSet {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	result := make([]ChangeSet, 0, len(h.items))
+	for i := len(h.items) - 1; i >= 0; i-- {
+		if h.items[i].AppliedAt.After(t) {
+			result = append(result, h.items[i])
+		}
+	}
+	return result
+}
+
+// UpcomingChange is a change the reloader will apply at a known time
+// without anyone writing to the database, such as a DNSSEC rollover phase
+// running out or a record reaching its not_before or not_after.
+type UpcomingChange struct {
+	Action      string    `json:"action"`
+	Table       string    `json:"table"`
+	DomainID    int       `json:"domain_id,omitempty"`
+	Zone        string    `json:"zone,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	Type        string    `json:"type,omitempty"`
+	Description string    `json:"description"`
+	DueAt       time.Time `json:"due_at"`
+}
+
+// upcomingSources list the upcoming changes of each kind due after now and
+// no later than until.
+var upcomingSources = []func(r *Reloader, ctx context.Context, now, until time.Time) ([]UpcomingChange, error){
+	(*Reloader).upcomingRollovers,
+	(*Reloader).upcomingSchedules,
+}
+
+// calendarDays is how many days of changes a request asks for, back for
+// applied ones and ahead for upcoming ones.
+func (r *Reloader) calendarDays(req *http.Request) int {
+	days := r.config.ChangeCalendarDays
+	if v := req.URL.Query().Get("days"); v != "" {
+		if n := parseInt(v); n > 0 {
+			days = n
+		}
+	}
+	return days
+}
+
+func (r *Reloader) recentChanges(req *http.Request) ([]ChangeSet, error) {
+	return r.scopeChanges(req.Context(), r.changes.Since(time.Now().AddDate(0, 0, -r.calendarDays(req))))
+}
+
+// upcomingChanges returns the changes due in the days the request asks
+// for, soonest first, limited to the domains of the request's account.
+func (r *Reloader) upcomingChanges(req *http.Request) ([]UpcomingChange, error) {
+	ctx := req.Context()
+	now := time.Now().UTC()
+	until := now.AddDate(0, 0, r.calendarDays(req))
+	upcoming := []UpcomingChange{}
+	for _, source := range upcomingSources {
+		changes, err := source(r, ctx, now, until)
+		if err != nil {
+			return nil, err
+		}
+		upcoming = append(upcoming, changes...)
+	}
+	if account := requestAccount(ctx); account != "" {
+		var ids []int
+		if err := r.db.WithContext(ctx).Model(&Domain{}).Where("account = ?", account).Pluck("id", &ids).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch domains: %w", err)
+		}
+		upcoming = slices.DeleteFunc(upcoming, func(change UpcomingChange) bool {
+			return !slices.Contains(ids, change.DomainID)
+		})
+	}
+	slices.SortStableFunc(upcoming, func(a, b UpcomingChange) int { return a.DueAt.Compare(b.DueAt) })
+	return upcoming, nil
+}
+
+func (r *Reloader) handleChanges(w http.ResponseWriter, req *http.Request) {
+	changes, err := r.recentChanges(req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	upcoming, err := r.upcomingChanges(req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"applied":  changes,
+		"upcoming": upcoming,
+	})
+}
+
+func (r *Reloader) handleChangesICal(w http.ResponseWriter, req *http.Request) {
+	changes, err := r.recentChanges(req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	upcoming, err := r.upcomingChanges(req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	var cal strings.Builder
+	writeICalLine(&cal, "BEGIN:VCALENDAR")
+	writeICalLine(&cal, "VERSION:2.0")
+	writeICalLine(&cal, "PRODID:-//core-admin//dns-reloader//EN")
+	writeICalLine(&cal, "X-WR-CALNAME:DNS changes")
+
+	stamp := time.Now().UTC().Format(icalTimeFormat)
+	for _, cs := range changes {
+		summary := fmt.Sprintf("DNS %s on %s", cs.Action, cs.Table)
+		if cs.Name != "" {
+			summary = fmt.Sprintf("DNS %s %s %s", cs.Action, cs.Type, cs.Name)
+		}
+		description := fmt.Sprintf("Backend: %s\nReloaded: %t", cs.Backend, cs.Reloaded)
+		if cs.Error != "" {
+			description += "\nError: " + cs.Error
+		}
+		if cs.Verification != "" {
+			description += "\nVerification: " + cs.Verification
+		}
+
+		writeICalLine(&cal, "BEGIN:VEVENT")
+		writeICalLine(&cal, fmt.Sprintf("UID:change-%d-%d@dns-reloader", cs.AppliedAt.Unix(), cs.ID))
+		writeICalLine(&cal, "DTSTAMP:"+stamp)
+		writeICalLine(&cal, "DTSTART:"+cs.AppliedAt.UTC().Format(icalTimeFormat))
+		writeICalLine(&cal, "SUMMARY:"+escapeICalText(summary))
+		writeICalLine(&cal, "DESCRIPTION:"+escapeICalText(description))
+		writeICalLine(&cal, "END:VEVENT")
+	}
+	for _, change := range upcoming {
+		summary := fmt.Sprintf("Scheduled DNS %s on %s", change.Action, change.Table)
+		if change.Zone != "" {
+			summary += " in " + change.Zone
+		}
+
+		writeICalLine(&cal, "BEGIN:VEVENT")
+		writeICalLine(&cal, fmt.Sprintf("UID:upcoming-%s-%d-%s-%s-%d@dns-reloader",
+			strings.ToLower(change.Action), change.DomainID, strings.ToLower(change.Name), strings.ToLower(change.Type), change.DueAt.Unix()))
+		writeICalLine(&cal, "DTSTAMP:"+stamp)
+		writeICalLine(&cal, "DTSTART:"+change.DueAt.UTC().Format(icalTimeFormat))
+		writeICalLine(&cal, "SUMMARY:"+escapeICalText(summary))
+		writeICalLine(&cal, "DESCRIPTION:"+escapeICalText(change.Description))
+		writeICalLine(&cal, "END:VEVENT")
+	}
+	writeICalLine(&cal, "END:VCALENDAR")
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Write([]byte(cal.String()))
+}
+
+const icalTimeFormat = "20060102T150405Z"
+
+func writeICalLine(b *strings.Builder, line string) {
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func escapeICalText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\n", `\n`,
+	).Replace(s)
+}
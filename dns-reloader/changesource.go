@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ChangeSource delivers change events from a message bus in place of
+// LISTEN/NOTIFY. Payloads are the JSON the notify_*_change triggers send.
+type ChangeSource interface {
+	Name() string
+	// Receive blocks until the next event arrives. ack marks the event as
+	// handled, so a source with stored offsets does not deliver it again.
+	Receive(ctx context.Context) (payload []byte, ack func() error, err error)
+	Close() error
+}
+
+// errResync is returned by Receive when events may have been missed, so
+// every zone has to be rebuilt.
+var errResync = errors.New("change source reconnected")
+
+func NewChangeSource(config *Config, logger *logrus.Logger) (ChangeSource, error) {
+	switch config.ChangeSource {
+	case "nats":
+		return NewNATSSource(config, logger)
+	case "kafka":
+		return NewKafkaSource(config)
+	case "redis":
+		return NewRedisSource(config)
+	default:
+		return nil, fmt.Errorf("unknown change source %q", config.ChangeSource)
+	}
+}
+
+// NATSSource subscribes to a NATS subject, in a queue group when one is
+// set. Core NATS keeps no history, so a reconnect forces a resync.
+type NATSSource struct {
+	conn        *nats.Conn
+	sub         *nats.Subscription
+	reconnected chan struct{}
+}
+
+func NewNATSSource(config *Config, logger *logrus.Logger) (*NATSSource, error) {
+	if config.NATSSubject == "" {
+		return nil, fmt.Errorf("NATS_SUBJECT is required for the nats change source")
+	}
+	s := &NATSSource{reconnected: make(chan struct{}, 1)}
+	options := []nats.Option{
+		nats.Name("dns-reloader"),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			logger.WithError(err).Warn("NATS disconnected, reconnecting")
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			logger.WithField("server", conn.ConnectedUrl()).Info("NATS reconnected")
+			select {
+			case s.reconnected <- struct{}{}:
+			default:
+			}
+		}),
+	}
+	if config.NATSCredentials != "" {
+		options = append(options, nats.UserCredentials(config.NATSCredentials))
+	}
+
+	conn, err := nats.Connect(config.NATSURL, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	if config.NATSQueue != "" {
+		s.sub, err = conn.QueueSubscribeSync(config.NATSSubject, config.NATSQueue)
+	} else {
+		s.sub, err = conn.SubscribeSync(config.NATSSubject)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", config.NATSSubject, err)
+	}
+	s.conn = conn
+	return s, nil
+}
+
+func (s *NATSSource) Name() string {
+	return "nats"
+}
+
+func (s *NATSSource) Receive(ctx context.Context) ([]byte, func() error, error) {
+	select {
+	case <-s.reconnected:
+		return nil, nil, errResync
+	default:
+	}
+	msg, err := s.sub.NextMsgWithContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg.Data, func() error { return nil }, nil
+}
+
+func (s *NATSSource) Close() error {
+	s.conn.Close()
+	return nil
+}
+
+// KafkaSource reads a Kafka topic as a member of a consumer group. Offsets
+// are committed only once a change has been applied, so events that
+// arrived while the reloader was down are applied on start.
+type KafkaSource struct {
+	reader *kafka.Reader
+}
+
+func NewKafkaSource(config *Config) (*KafkaSource, error) {
+	if len(config.KafkaBrokers) == 0 || config.KafkaTopic == "" {
+		return nil, fmt.Errorf("KAFKA_BROKERS and KAFKA_TOPIC are required for the kafka change source")
+	}
+	return &KafkaSource{reader: kafka.NewReader(kafka.ReaderConfig{
+		Brokers: config.KafkaBrokers,
+		Topic:   config.KafkaTopic,
+		GroupID: config.KafkaGroupID,
+	})}, nil
+}
+
+func (k *KafkaSource) Name() string {
+	return "kafka"
+}
+
+func (k *KafkaSource) Receive(ctx context.Context) ([]byte, func() error, error) {
+	msg, err := k.reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg.Value, func() error { return k.reader.CommitMessages(ctx, msg) }, nil
+}
+
+func (k *KafkaSource) Close() error {
+	return k.reader.Close()
+}
+
+// RedisSource subscribes to a Redis pub/sub channel. Pub/sub keeps no
+// history either: every subscription confirmation after the first means
+// go-redis reconnected, so it forces a resync. Messages that are not JSON
+// changes still trigger a full regeneration.
+type RedisSource struct {
+	client     *redis.Client
+	pubsub     *redis.PubSub
+	subscribed bool
+}
+
+func NewRedisSource(config *Config) (*RedisSource, error) {
+	options, err := redis.ParseURL(config.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+	client := redis.NewClient(options)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pubsub := client.Subscribe(ctx, config.RedisChannel)
+	// Wait for the confirmation so an unreachable server fails here.
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		client.Close()
+		return nil, fmt.Errorf("failed to subscribe to redis channel %s: %w", config.RedisChannel, err)
+	}
+	return &RedisSource{client: client, pubsub: pubsub, subscribed: true}, nil
+}
+
+func (s *RedisSource) Name() string {
+	return "redis"
+}
+
+func (s *RedisSource) Receive(ctx context.Context) ([]byte, func() error, error) {
+	for {
+		msg, err := s.pubsub.Receive(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch msg := msg.(type) {
+		case *redis.Message:
+			return []byte(msg.Payload), func() error { return nil }, nil
+		case *redis.Subscription:
+			if msg.Kind == "subscribe" && s.subscribed {
+				return nil, nil, errResync
+			}
+			s.subscribed = true
+		}
+	}
+}
+
+func (s *RedisSource) Close() error {
+	s.pubsub.Close()
+	return s.client.Close()
+}
+
+// sourceEvent is an event handed from receiveEvents to the main loop.
+type sourceEvent struct {
+	change *DNSChangeNotification
+	ack    func() error
+}
+
+// receiveEvents reads from the change source until the reloader stops,
+// retrying after errors.
+func (r *Reloader) receiveEvents(events chan<- sourceEvent) {
+	for {
+		payload, ack, err := r.source.Receive(r.ctx)
+		if r.ctx.Err() != nil {
+			return
+		}
+		var change *DNSChangeNotification
+		switch {
+		case errors.Is(err, errResync):
+			change = &DNSChangeNotification{
+				Table:     r.source.Name(),
+				Action:    "RESYNC",
+				Timestamp: time.Now(),
+			}
+		case err != nil:
+			r.logger.WithError(err).WithField("source", r.source.Name()).Error("Failed to receive change event")
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		default:
+			change, err = changeHandler("records")(string(payload))
+			if err != nil {
+				r.logger.WithError(err).Debug("Change event is not a change record")
+			}
+		}
+		select {
+		case events <- sourceEvent{change: change, ack: ack}:
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+// listenForEvents is listenForNotifications for CHANGE_SOURCE: changes come
+// from the message bus and are applied one at a time, each acknowledged
+// once applied.
+func (r *Reloader) listenForEvents() error {
+	r.logger.WithField("source", r.source.Name()).Info("Listening for DNS change events...")
+	r.status.setMode(r.source.Name())
+
+	if r.isLeader() {
+		if _, err := r.regenerateAllZones(r.ctx, nil); err != nil {
+			r.logger.WithError(err).Error("Failed initial zone generation")
+		}
+	}
+
+	events := make(chan sourceEvent)
+	go r.receiveEvents(events)
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return nil
+		case event := <-events:
+			r.stats.notifications.Add(1)
+			sourceEventsReceived.WithLabelValues(r.source.Name()).Inc()
+			ctx, span := tracer.Start(r.ctx, "event.receive", trace.WithAttributes(
+				attribute.String("messaging.system", r.source.Name()),
+				attribute.Int64("dns.change.lag_ms", time.Since(event.change.Timestamp).Milliseconds()),
+			))
+			err := r.triggerCoreReload(ctx, event.change)
+			endSpan(span, err)
+			if err != nil {
+				// Not acknowledged, so a source with offsets may deliver
+				// it again after a restart.
+				r.logger.WithError(err).Error("Failed to handle change event")
+				continue
+			}
+			if event.ack != nil {
+				if err := event.ack(); err != nil {
+					r.logger.WithError(err).Warn("Failed to acknowledge change event")
+				}
+			}
+		case change := <-r.localChanges:
+			r.handleLocalChange(change)
+		case <-ticker.C:
+			if r.isLeader() {
+				r.retryPendingReload()
+				r.refreshCanaries()
+				r.rotateWeights()
+				r.checkDrift()
+				r.checkBackup()
+				r.refreshSignatures()
+				r.checkRollovers()
+				r.checkSecondaries()
+			}
+			r.checkDatabase()
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+)
+
+const usage = `Usage: reloader [command] [flags]
+
+Commands:
+  run        watch the database and keep zones and CoreDNS up to date (default)
+  oneshot    regenerate all zones once and exit
+  validate   render and parse all zones; exit 1 if any has problems
+  export     write all rendered zones as a tar.gz archive or into a directory,
+             as zone files or, with -format=octodns, octoDNS YAML; with
+             -format=csv, write all records as one CSV file
+  import <file>
+             add and update records from a CSV file written by export
+  stats      print domain and record counts
+  import-axfr <zone> <server>
+             transfer a zone over AXFR and load it into the records table
+  sync-providers [zone...]
+             push all or the named zones to the SYNC_PROVIDERS
+  dnssec keygen <zone>
+             generate DNSSEC keys for a zone and mark it for signing
+  dnssec rollover <zsk|ksk|confirm|status> <zone>
+             start a key rollover, confirm a new KSK's DS is at the parent,
+             or show the rollover state
+  tsig <add|list|delete> [name]
+             manage the TSIG keys that authenticate zone transfers and
+             NOTIFYs; add prints the new key once
+  dyndns <add|list|delete> [hostname]
+             manage the credentials that may update one hostname through
+             /nic/update; add prints them once
+  token <add|list|delete> [name]
+             manage API tokens with a role, optionally scoped to an
+             account or record name patterns with an expiry and rate
+             limit; add prints the secret once
+  migrate    create or upgrade the database schema
+  migrate-triggers
+             install or update the change notification triggers
+
+Configuration is read from the same environment variables as run.
+`
+
+// runCommand runs one of the one-off subcommands and returns the process
+// exit code.
+func runCommand(command string, args []string) int {
+	commands := map[string]func(*Reloader, []string) error{
+		"oneshot":  (*Reloader).oneshot,
+		"validate": (*Reloader).validateZones,
+		"export":   (*Reloader).exportZones,
+		"stats":    (*Reloader).printStats,
+		"migrate":  (*Reloader).migrate,
+
+		"migrate-triggers": (*Reloader).migrateTriggers,
+		"import":           (*Reloader).importRecords,
+		"import-axfr":      (*Reloader).importAXFR,
+		"sync-providers":   (*Reloader).syncProvidersCommand,
+		"dnssec":           (*Reloader).dnssecCommand,
+		"tsig":             (*Reloader).tsigCommand,
+		"dyndns":           (*Reloader).dyndnsCommand,
+		"token":            (*Reloader).tokenCommand,
+	}
+
+	fn, ok := commands[command]
+	if !ok {
+		if command == "help" {
+			fmt.Fprint(os.Stdout, usage)
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		return 2
+	}
+
+	r := NewReloader()
+	defer r.cleanup()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		r.cancel()
+	}()
+
+	if err := fn(r, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		r.logger.WithError(err).Errorf("%s failed", command)
+		return 1
+	}
+	return 0
+}
+
+// oneshot regenerates every zone and exits, optionally reloading CoreDNS. It
+// fails if any zone could not be generated.
+func (r *Reloader) oneshot(args []string) error {
+	fs := flag.NewFlagSet("oneshot", flag.ContinueOnError)
+	reload := fs.Bool("reload", false, "reload CoreDNS if any zone changed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *reload {
+		backend, err := NewReloadBackend(r.config, r.logger, r.zoneFilePath)
+		if err != nil {
+			return fmt.Errorf("failed to initialize reload backend: %w", err)
+		}
+		r.backend = backend
+	}
+	if err := r.waitForDatabase(); err != nil {
+		return err
+	}
+
+	changed, err := r.regenerateAllZones(r.ctx, nil)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	r.status.mu.Lock()
+	for _, zs := range r.status.zones {
+		if zs.GenerationError != "" {
+			failed = append(failed, zs.Zone)
+		}
+	}
+	r.status.mu.Unlock()
+	if len(failed) > 0 {
+		return fmt.Errorf("%d zone(s) failed to generate: %s", len(failed), strings.Join(failed, ", "))
+	}
+
+	if *reload && len(changed) > 0 {
+		if err := r.reloadCoreDNS(r.ctx, changed); err != nil {
+			return fmt.Errorf("failed to reload CoreDNS: %w", err)
+		}
+	}
+	r.logger.WithField("changed", len(changed)).Info("One-shot regeneration completed")
+	return nil
+}
+
+// validateZones renders every zone without writing it and reports the
+// problems found, one per line.
+func (r *Reloader) validateZones(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := r.connectDB(); err != nil {
+		return err
+	}
+
+	zones, err := r.renderAllZones(r.ctx)
+	if err != nil {
+		return err
+	}
+
+	invalid := 0
+	for _, z := range zones {
+		problems := zoneProblems(z.Domain.Name, z.Records, z.Content)
+		if len(problems) == 0 {
+			continue
+		}
+		invalid++
+		for _, problem := range problems {
+			fmt.Printf("%s: %s\n", z.Domain.Name, problem)
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d zone(s) have problems", invalid, len(zones))
+	}
+	fmt.Printf("%d zone(s) OK\n", len(zones))
+	return nil
+}
+
+// exportZones writes every rendered zone to a tar.gz archive (stdout by
+// default) or, with -dir, as individual files. -format=octodns writes
+// octoDNS YAML zone files instead of zone files, for use with the octoDNS
+// YamlProvider. -format=csv writes the stored records as a single CSV
+// file for import.
+func (r *Reloader) exportZones(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := fs.String("o", "-", "archive path, or - for stdout")
+	dir := fs.String("dir", "", "write one file per zone into this directory instead of an archive")
+	format := fs.String("format", "zone", "zone for zone files, octodns for octoDNS YAML, csv for a records CSV")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ext := ".zone"
+	switch *format {
+	case "zone":
+	case "octodns":
+		ext = ".yaml"
+	case "csv":
+		if *dir != "" {
+			return errors.New("-dir cannot be used with -format=csv")
+		}
+	default:
+		return fmt.Errorf("unknown export format %q", *format)
+	}
+	if err := r.connectDB(); err != nil {
+		return err
+	}
+
+	zones, err := r.renderAllZones(r.ctx)
+	if err != nil {
+		return err
+	}
+	if *format == "octodns" {
+		for i, z := range zones {
+			content, skipped, err := renderOctoDNS(z)
+			if err != nil {
+				return fmt.Errorf("failed to convert %s: %w", z.Domain.Name, err)
+			}
+			if len(skipped) > 0 {
+				r.logger.WithField("domain", z.Domain.Name).WithField("records", skipped).Warn("Leaving out records octoDNS cannot represent")
+			}
+			zones[i].Content = content
+		}
+	}
+
+	if *dir != "" {
+		if err := os.MkdirAll(*dir, 0755); err != nil {
+			return fmt.Errorf("failed to create export directory: %w", err)
+		}
+		for _, z := range zones {
+			path := filepath.Join(*dir, z.Domain.Name+ext)
+			if err := os.WriteFile(path, []byte(z.Content), 0644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", path, err)
+			}
+		}
+		return nil
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create archive: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "csv" {
+		return writeRecordsCSV(w, zones)
+	}
+	return writeZonesArchive(w, zones, ext)
+}
+
+type zoneStats struct {
+	Domain   string `json:"domain"`
+	Records  int    `json:"records"`
+	Disabled int    `json:"disabled"`
+}
+
+type databaseStats struct {
+	Domains       int            `json:"domains"`
+	Records       int            `json:"records"`
+	Disabled      int            `json:"disabled"`
+	RecordsByType map[string]int `json:"records_by_type"`
+	Zones         []zoneStats    `json:"zones"`
+}
+
+// printStats prints domain and record counts as a table, or as JSON with
+// -json.
+func (r *Reloader) printStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := r.connectDB(); err != nil {
+		return err
+	}
+
+	domains, err := r.listDomains(r.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch domains: %w", err)
+	}
+	var records []Record
+	if err := r.db.WithContext(r.ctx).Select("domain_id", "type", "disabled").Find(&records).Error; err != nil {
+		return fmt.Errorf("failed to fetch records: %w", err)
+	}
+
+	stats := databaseStats{
+		Domains:       len(domains),
+		Records:       len(records),
+		RecordsByType: make(map[string]int),
+		Zones:         make([]zoneStats, 0, len(domains)),
+	}
+	byDomain := make(map[int]*zoneStats, len(domains))
+	for i, domain := range domains {
+		stats.Zones = append(stats.Zones, zoneStats{Domain: domain.Name})
+		byDomain[int(domain.ID)] = &stats.Zones[i]
+	}
+	for _, record := range records {
+		stats.RecordsByType[strings.ToUpper(record.Type)]++
+		zs := byDomain[record.DomainID]
+		if zs != nil {
+			zs.Records++
+		}
+		if record.Disabled {
+			stats.Disabled++
+			if zs != nil {
+				zs.Disabled++
+			}
+		}
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stats)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Domains:\t%d\n", stats.Domains)
+	fmt.Fprintf(tw, "Records:\t%d (%d disabled)\n", stats.Records, stats.Disabled)
+	fmt.Fprintln(tw)
+	types := make([]string, 0, len(stats.RecordsByType))
+	for t := range stats.RecordsByType {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	fmt.Fprintln(tw, "TYPE\tRECORDS")
+	for _, t := range types {
+		fmt.Fprintf(tw, "%s\t%d\n", t, stats.RecordsByType[t])
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "ZONE\tRECORDS\tDISABLED")
+	for _, zs := range stats.Zones {
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", zs.Domain, zs.Records, zs.Disabled)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	cloudflareEndpoint = "https://api.cloudflare.com/client/v4"
+	// cloudflareBatchSize is the number of operations sent per batch call.
+	cloudflareBatchSize = 100
+)
+
+// cloudflareTypes are the record types synced to Cloudflare; others stay
+// local.
+var cloudflareTypes = map[string]bool{
+	"A": true, "AAAA": true, "CNAME": true, "MX": true, "NS": true, "PTR": true, "TXT": true,
+}
+
+// cloudflareProxiable are the types Cloudflare can proxy.
+var cloudflareProxiable = map[string]bool{"A": true, "AAAA": true, "CNAME": true}
+
+// CloudflareProvider mirrors zones into Cloudflare zones of the same name
+// through the v4 API with an API token. With CLOUDFLARE_ZONES set only
+// those zones are synced.
+//
+// Whether a record is proxied comes from a comment on its RRset, a line of
+// "cloudflare:proxied" or "cloudflare:dns-only", and otherwise from the
+// zone's X-CLOUDFLARE-PROXIED metadata, defaulting to DNS only.
+type CloudflareProvider struct {
+	token  string
+	zones  []string
+	client *http.Client
+}
+
+func NewCloudflareProvider(config *Config) (*CloudflareProvider, error) {
+	if config.CloudflareToken == "" {
+		return nil, fmt.Errorf("CLOUDFLARE_API_TOKEN is required for the cloudflare provider")
+	}
+	return &CloudflareProvider{
+		token:  config.CloudflareToken,
+		zones:  config.CloudflareZones,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (c *CloudflareProvider) Name() string {
+	return "cloudflare"
+}
+
+type cloudflareRecord struct {
+	ID       string  `json:"id,omitempty"`
+	Type     string  `json:"type,omitempty"`
+	Name     string  `json:"name,omitempty"`
+	Content  string  `json:"content"`
+	TTL      int     `json:"ttl"`
+	Proxied  *bool   `json:"proxied,omitempty"`
+	Priority *uint16 `json:"priority,omitempty"`
+}
+
+func (rec cloudflareRecord) key() string {
+	return rec.Name + "/" + rec.Type + "/" + rec.Content
+}
+
+// sameSettings reports whether rec already has the TTL, proxied flag and
+// priority of want.
+func (rec cloudflareRecord) sameSettings(want cloudflareRecord) bool {
+	proxied := func(p *bool) bool { return p != nil && *p }
+	priority := func(p *uint16) int {
+		if p == nil {
+			return -1
+		}
+		return int(*p)
+	}
+	return rec.TTL == want.TTL && proxied(rec.Proxied) == proxied(want.Proxied) && priority(rec.Priority) == priority(want.Priority)
+}
+
+type cloudflareBatch struct {
+	Deletes []cloudflareRecord `json:"deletes,omitempty"`
+	Patches []cloudflareRecord `json:"patches,omitempty"`
+	Posts   []cloudflareRecord `json:"posts,omitempty"`
+}
+
+func (c *CloudflareProvider) Sync(ctx context.Context, zone providerZone) (providerSyncResult, error) {
+	var result providerSyncResult
+	if len(c.zones) > 0 && !slices.Contains(c.zones, zone.Name) {
+		return result, errZoneNotHosted
+	}
+	id, err := c.zoneID(ctx, zone.Name)
+	if err != nil {
+		return result, err
+	}
+	current, err := c.records(ctx, id)
+	if err != nil {
+		return result, err
+	}
+
+	existing := make(map[string]cloudflareRecord, len(current))
+	for _, rec := range current {
+		rec.Name = strings.ToLower(rec.Name)
+		if !cloudflareTypes[rec.Type] || (rec.Type == "NS" && rec.Name == zone.Name) {
+			continue
+		}
+		rec.Content = cloudflareContent(rec.Type, rec.Content)
+		existing[rec.key()] = rec
+	}
+
+	var ops []cloudflareBatch
+	for _, set := range zone.RRsets {
+		if !cloudflareTypes[set.Type] {
+			continue
+		}
+		records, err := cloudflareRecords(set, zone.Options.CFProxied)
+		if err != nil {
+			return result, err
+		}
+		for _, want := range records {
+			have, ok := existing[want.key()]
+			delete(existing, want.key())
+			switch {
+			case !ok:
+				ops = append(ops, cloudflareBatch{Posts: []cloudflareRecord{want}})
+			case !have.sameSettings(want):
+				want.ID = have.ID
+				ops = append(ops, cloudflareBatch{Patches: []cloudflareRecord{want}})
+			}
+		}
+	}
+	var deletes []cloudflareBatch
+	for _, rec := range existing {
+		deletes = append(deletes, cloudflareBatch{Deletes: []cloudflareRecord{{ID: rec.ID}}})
+	}
+	ops = append(deletes, ops...)
+
+	// Cloudflare applies a batch's deletes, then patches, then posts, so
+	// batches are cut from the ordered operations and merged.
+	for chunk := range slices.Chunk(ops, cloudflareBatchSize) {
+		var batch cloudflareBatch
+		for _, op := range chunk {
+			batch.Deletes = append(batch.Deletes, op.Deletes...)
+			batch.Patches = append(batch.Patches, op.Patches...)
+			batch.Posts = append(batch.Posts, op.Posts...)
+		}
+		if err := c.call(ctx, http.MethodPost, "/zones/"+id+"/dns_records/batch", batch, nil, nil); err != nil {
+			return result, err
+		}
+		result.Upserted += len(batch.Patches) + len(batch.Posts)
+		result.Deleted += len(batch.Deletes)
+	}
+	return result, nil
+}
+
+// cloudflareRecords converts an RRset to the records Cloudflare stores, one
+// per value.
+func cloudflareRecords(set providerRRset, proxiedByDefault bool) ([]cloudflareRecord, error) {
+	proxied := proxiedByDefault
+	for _, comment := range set.Comments {
+		for _, line := range strings.Split(comment, "\n") {
+			switch strings.ToLower(strings.TrimSpace(line)) {
+			case "cloudflare:proxied":
+				proxied = true
+			case "cloudflare:dns-only":
+				proxied = false
+			}
+		}
+	}
+	ttl := min(max(int(set.TTL), 60), 86400)
+
+	records := make([]cloudflareRecord, 0, len(set.Values))
+	for _, value := range set.Values {
+		rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", set.Name, set.TTL, set.Type, value))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s %s %s: %w", set.Name, set.Type, value, err)
+		}
+		rec := cloudflareRecord{Type: set.Type, Name: strings.TrimSuffix(set.Name, "."), TTL: ttl}
+		switch v := rr.(type) {
+		case *dns.A:
+			rec.Content = v.A.String()
+		case *dns.AAAA:
+			rec.Content = v.AAAA.String()
+		case *dns.CNAME:
+			rec.Content = v.Target
+		case *dns.NS:
+			rec.Content = v.Ns
+		case *dns.PTR:
+			rec.Content = v.Ptr
+		case *dns.MX:
+			rec.Content = v.Mx
+			rec.Priority = &v.Preference
+		case *dns.TXT:
+			rec.Content = strings.Join(v.Txt, "")
+		}
+		rec.Content = cloudflareContent(set.Type, rec.Content)
+		if cloudflareProxiable[set.Type] {
+			rec.Proxied = &proxied
+			if proxied {
+				// Proxied records always have an automatic TTL.
+				rec.TTL = 1
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// cloudflareContent normalises content for comparison: host names without
+// the trailing dot and in lower case, and TXT data as one unquoted string,
+// whichever form Cloudflare returns it in.
+func cloudflareContent(rrtype, content string) string {
+	switch rrtype {
+	case "CNAME", "MX", "NS", "PTR":
+		return strings.ToLower(strings.TrimSuffix(content, "."))
+	case "TXT":
+		if rr, err := dns.NewRR(". 0 IN TXT " + content); err == nil && strings.HasPrefix(content, `"`) {
+			return strings.Join(rr.(*dns.TXT).Txt, "")
+		}
+	}
+	return content
+}
+
+// zoneID returns the ID of the Cloudflare zone named zone.
+func (c *CloudflareProvider) zoneID(ctx context.Context, zone string) (string, error) {
+	var zones []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/zones?"+url.Values{"name": {zone}}.Encode(), nil, &zones, nil); err != nil {
+		return "", err
+	}
+	for _, z := range zones {
+		if strings.EqualFold(z.Name, zone) {
+			return z.ID, nil
+		}
+	}
+	return "", errZoneNotHosted
+}
+
+// records lists every DNS record in the zone, page by page.
+func (c *CloudflareProvider) records(ctx context.Context, id string) ([]cloudflareRecord, error) {
+	var all []cloudflareRecord
+	for page := 1; ; page++ {
+		var records []cloudflareRecord
+		var info struct {
+			TotalPages int `json:"total_pages"`
+		}
+		query := url.Values{"per_page": {"5000"}, "page": {strconv.Itoa(page)}}
+		if err := c.call(ctx, http.MethodGet, "/zones/"+id+"/dns_records?"+query.Encode(), nil, &records, &info); err != nil {
+			return nil, err
+		}
+		all = append(all, records...)
+		if page >= info.TotalPages {
+			return all, nil
+		}
+	}
+}
+
+// call sends a request and decodes the result, and result_info when info
+// is not nil, from Cloudflare's response envelope.
+func (c *CloudflareProvider) call(ctx context.Context, method, path string, body, out, info interface{}) error {
+	var payload io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, cloudflareEndpoint+path, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloudflare request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Success bool `json:"success"`
+		Errors  []struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+		Result     json.RawMessage `json:"result"`
+		ResultInfo json.RawMessage `json:"result_info"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<20)).Decode(&envelope); err != nil {
+		return fmt.Errorf("cloudflare returned %s: failed to decode response: %w", resp.Status, err)
+	}
+	if resp.StatusCode >= 300 || !envelope.Success {
+		var messages []string
+		for _, e := range envelope.Errors {
+			messages = append(messages, fmt.Sprintf("%d: %s", e.Code, e.Message))
+		}
+		return fmt.Errorf("cloudflare %s %s returned %s: %s", method, strings.SplitN(path, "?", 2)[0], resp.Status, strings.Join(messages, "; "))
+	}
+	if out != nil {
+		if err := json.Unmarshal(envelope.Result, out); err != nil {
+			return fmt.Errorf("failed to decode cloudflare result: %w", err)
+		}
+	}
+	if info != nil && len(envelope.ResultInfo) > 0 {
+		if err := json.Unmarshal(envelope.ResultInfo, info); err != nil {
+			return fmt.Errorf("failed to decode cloudflare result info: %w", err)
+		}
+	}
+	return nil
+}
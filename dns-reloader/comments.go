@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Comment is a row of the PowerDNS comments table: a note attached to the
+// RRset identified by domain_id, name and type rather than to one record.
+type Comment struct {
+	ID         int     `gorm:"primaryKey;column:id" json:"id"`
+	DomainID   int     `gorm:"column:domain_id" json:"-"`
+	Name       string  `gorm:"column:name" json:"-"`
+	Type       string  `gorm:"column:type" json:"-"`
+	ModifiedAt int64   `gorm:"column:modified_at" json:"modified_at"`
+	Account    *string `gorm:"column:account" json:"account,omitempty"`
+	Comment    string  `gorm:"column:comment" json:"comment"`
+}
+
+func (Comment) TableName() string {
+	return "comments"
+}
+
+// attachComments fills in the Comments of each record from the comments
+// table. Every record of an RRset gets the same comments. It does nothing
+// when the schema has no comments table.
+func (r *Reloader) attachComments(ctx context.Context, records []Record) error {
+	if !r.comments || len(records) == 0 {
+		return nil
+	}
+
+	domainIDs := make(map[int]bool)
+	for _, record := range records {
+		domainIDs[record.DomainID] = true
+	}
+	ids := make([]int, 0, len(domainIDs))
+	for id := range domainIDs {
+		ids = append(ids, id)
+	}
+
+	var comments []Comment
+	if err := r.db.WithContext(ctx).Where("domain_id IN ?", ids).Order("modified_at, id").Find(&comments).Error; err != nil {
+		return fmt.Errorf("failed to load comments: %w", err)
+	}
+	if len(comments) == 0 {
+		return nil
+	}
+
+	byRRset := make(map[string][]Comment, len(comments))
+	for _, comment := range comments {
+		key := rrsetKey(comment.DomainID, comment.Name, comment.Type)
+		byRRset[key] = append(byRRset[key], comment)
+	}
+	for i := range records {
+		records[i].Comments = byRRset[rrsetKey(records[i].DomainID, records[i].Name, records[i].Type)]
+	}
+	return nil
+}
+
+func rrsetKey(domainID int, name, rrtype string) string {
+	return fmt.Sprintf("%d/%s/%s", domainID, strings.ToLower(strings.TrimSuffix(name, ".")), strings.ToUpper(rrtype))
+}
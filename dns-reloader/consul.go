@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+)
+
+// consulOwner is the created_by of records mirrored from Consul.
+const consulOwner = "consul"
+
+// consulSource is a Consul KV tree holding one RRset per key, laid out as
+// <prefix>/<name>/<TYPE>, so dns/www.lab.test/A holds the A records of
+// www.lab.test. A value is either JSON, {"ttl": 300, "records": [...]}, or
+// plain text with one record content per line and the default TTL. MX and
+// SRV contents start with their priority, as in a zone file.
+type consulSource struct {
+	address string
+	token   string
+	prefix  string
+	wait    time.Duration
+	client  *http.Client
+}
+
+func newConsulSource(config *Config) (*consulSource, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.ConsulCACert != "" {
+		pem, err := os.ReadFile(config.ConsulCACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Consul CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", config.ConsulCACert)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	address := strings.TrimSuffix(config.ConsulAddress, "/")
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	return &consulSource{
+		address: address,
+		token:   config.ConsulToken,
+		prefix:  strings.Trim(config.ConsulPrefix, "/") + "/",
+		wait:    config.ConsulWait,
+		// Blocking queries hold the request open for up to wait, plus the
+		// jitter Consul adds of wait/16.
+		client: &http.Client{Transport: transport, Timeout: config.ConsulWait + config.ConsulWait/16 + 30*time.Second},
+	}, nil
+}
+
+// consulKV is one entry of a recursive KV read. Value arrives base64
+// encoded, which encoding/json decodes into the byte slice.
+type consulKV struct {
+	Key   string `json:"Key"`
+	Value []byte `json:"Value"`
+}
+
+// consulRRset is the JSON form of a key's value.
+type consulRRset struct {
+	TTL     int      `json:"ttl"`
+	Records []string `json:"records"`
+}
+
+// list reads every key under the prefix. With index above zero it is a
+// blocking query that returns once the tree changes past index or the wait
+// runs out. It returns the entries and the X-Consul-Index to block on next.
+func (c *consulSource) list(ctx context.Context, index uint64) ([]consulKV, uint64, error) {
+	query := url.Values{"recurse": {"true"}}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", fmt.Sprintf("%ds", int(c.wait.Seconds())))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.address+"/v1/kv/"+c.prefix+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("consul request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	next, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, next, nil
+	case resp.StatusCode >= 300:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, 0, fmt.Errorf("consul returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var kvs []consulKV
+	if err := json.NewDecoder(resp.Body).Decode(&kvs); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode consul response: %w", err)
+	}
+	return kvs, next, nil
+}
+
+// consulRecords converts a key and its value to records, or reports why the
+// key cannot be used.
+func consulRecords(domainID int, name, rrtype string, value []byte) ([]Record, error) {
+	rrset := consulRRset{TTL: etcdDefaultTTL}
+	trimmed := strings.TrimSpace(string(value))
+	if strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal(value, &rrset); err != nil {
+			return nil, err
+		}
+		if rrset.TTL <= 0 {
+			rrset.TTL = etcdDefaultTTL
+		}
+	} else {
+		for _, line := range strings.Split(trimmed, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				rrset.Records = append(rrset.Records, line)
+			}
+		}
+	}
+
+	records := make([]Record, 0, len(rrset.Records))
+	for _, content := range rrset.Records {
+		record := Record{DomainID: domainID, Name: name, Type: rrtype, Content: strings.TrimSpace(content), TTL: rrset.TTL, Auth: true}
+		if rrtype == "MX" || rrtype == "SRV" {
+			prio, rest, _ := strings.Cut(record.Content, " ")
+			priority, err := strconv.Atoi(prio)
+			if err != nil {
+				return nil, fmt.Errorf("%s content %q does not start with a priority", rrtype, content)
+			}
+			record.Prio = &priority
+			record.Content = strings.TrimSpace(rest)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// syncConsul reads the Consul tree, blocking on index when it is above
+// zero, and mirrors it into the records table. It returns the index to
+// block on next. Keys outside every domain are ignored.
+func (r *Reloader) syncConsul(ctx context.Context, index uint64) (uint64, error) {
+	kvs, next, err := r.consul.list(ctx, index)
+	if err != nil {
+		sourceSyncs.WithLabelValues(consulOwner, "failed").Inc()
+		return 0, fmt.Errorf("failed to read consul: %w", err)
+	}
+
+	var domains []Domain
+	if err := r.db.WithContext(ctx).Find(&domains).Error; err != nil {
+		sourceSyncs.WithLabelValues(consulOwner, "failed").Inc()
+		return 0, fmt.Errorf("failed to fetch domains: %w", err)
+	}
+
+	var desired []Record
+	for _, kv := range kvs {
+		path := strings.TrimPrefix(kv.Key, r.consul.prefix)
+		slash := strings.LastIndex(path, "/")
+		if slash <= 0 || slash == len(path)-1 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSuffix(path[:slash], "."))
+		rrtype := strings.ToUpper(path[slash+1:])
+		if _, ok := dns.StringToType[rrtype]; !ok {
+			r.logger.WithField("key", kv.Key).Warn("Ignoring consul key with unknown record type")
+			continue
+		}
+		domain := zoneFor(name, domains)
+		if domain == nil {
+			continue
+		}
+		records, err := consulRecords(int(domain.ID), name, rrtype, kv.Value)
+		if err != nil {
+			r.logger.WithError(err).WithField("key", kv.Key).Warn("Ignoring unreadable consul key")
+			continue
+		}
+		desired = append(desired, records...)
+	}
+
+	if err := r.mirrorRecords(ctx, consulOwner, desired); err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+// watchConsul keeps the mirrored records in step with Consul using blocking
+// queries, resyncing each time one returns. As Consul documents, an index
+// that goes backwards means the cluster state was reset and the next query
+// starts over without one. Blocking queries time out after CONSUL_WAIT, so
+// keys for domains created since are picked up within that.
+func (r *Reloader) watchConsul() {
+	r.logger.WithFields(logrus.Fields{
+		"address": r.consul.address,
+		"prefix":  r.consul.prefix,
+	}).Info("Watching Consul for DNS changes")
+
+	var index uint64
+	for r.ctx.Err() == nil {
+		next, err := r.syncConsul(r.ctx, index)
+		if err != nil {
+			if r.ctx.Err() != nil {
+				return
+			}
+			r.logger.WithError(err).Error("Failed to sync records from consul")
+			index = 0
+			select {
+			case <-r.ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+		if next < index {
+			next = 0
+		}
+		index = next
+		if index == 0 {
+			// Without an index the next read would not block.
+			select {
+			case <-r.ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}
+}
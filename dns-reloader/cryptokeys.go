@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// CryptoKey is a row of the PowerDNS cryptokeys table. Content is the
+// private key in BIND Private-key-format, or that encrypted with
+// DNSSEC_KEY_SECRET for keys made by dnssec keygen.
+type CryptoKey struct {
+	ID        int    `gorm:"primaryKey;column:id"`
+	DomainID  int    `gorm:"column:domain_id"`
+	Flags     int    `gorm:"column:flags"`
+	Active    *bool  `gorm:"column:active"`
+	Published *bool  `gorm:"column:published"`
+	Content   string `gorm:"column:content"`
+}
+
+func (CryptoKey) TableName() string {
+	return "cryptokeys"
+}
+
+// dnssecKey is a cryptokeys row with the DNSKEY derived from it. Private
+// is the row's decrypted content, read only when the zone is signed.
+// ParentDS marks a key-signing key in a rollover whose DS the parent must
+// hold although it does not sign.
+type dnssecKey struct {
+	ID        int
+	Active    bool
+	Published bool
+	ParentDS  bool
+	DNSKEY    *dns.DNSKEY
+	Private   string
+}
+
+// dsDigestTypes are the DS digests published for each key: SHA-256, which
+// every registry accepts, and SHA-384.
+var dsDigestTypes = []uint8{dns.SHA256, dns.SHA384}
+
+// loadDNSSECKeys reads the cryptokeys for domains from db and sets each
+// domain's Keys. Keys that cannot be parsed are logged and left out. It
+// does nothing when the schema has no cryptokeys table.
+func (r *Reloader) loadDNSSECKeys(ctx context.Context, db *gorm.DB, domains []Domain) error {
+	if !r.cryptokeys || len(domains) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(domains))
+	for i, domain := range domains {
+		ids[i] = domain.ID
+	}
+
+	var rows []CryptoKey
+	if err := db.WithContext(ctx).Where("domain_id IN ?", ids).Order("id").Find(&rows).Error; err != nil {
+		return fmt.Errorf("failed to read cryptokeys: %w", err)
+	}
+	byDomain := make(map[int][]CryptoKey)
+	for _, row := range rows {
+		byDomain[row.DomainID] = append(byDomain[row.DomainID], row)
+	}
+
+	for i := range domains {
+		domains[i].Keys = nil
+		for _, row := range byDomain[int(domains[i].ID)] {
+			content, err := r.decryptKeyContent(row.Content)
+			if err != nil {
+				r.logger.WithError(err).WithFields(logrus.Fields{
+					"domain": domains[i].Name,
+					"key_id": row.ID,
+				}).Warn("Ignoring encrypted cryptokey")
+				continue
+			}
+			key, err := dnskeyFromPrivate(domains[i].Name, uint16(row.Flags), content)
+			if err != nil {
+				r.logger.WithError(err).WithFields(logrus.Fields{
+					"domain": domains[i].Name,
+					"key_id": row.ID,
+				}).Warn("Ignoring unreadable cryptokey")
+				continue
+			}
+			domains[i].Keys = append(domains[i].Keys, dnssecKey{
+				ID:        row.ID,
+				Active:    row.Active != nil && *row.Active,
+				Published: row.Published == nil || *row.Published,
+				DNSKEY:    key,
+				Private:   content,
+			})
+		}
+	}
+	return r.markParentDS(ctx, db, domains)
+}
+
+// delegationKeys returns the DS data the parent zone should hold for
+// domain: a SHA-256 digest of each active, published key-signing key.
+func (r *Reloader) delegationKeys(ctx context.Context, domain Domain) ([]DelegationKey, error) {
+	domains := []Domain{domain}
+	if err := r.loadDNSSECKeys(ctx, r.db, domains); err != nil {
+		return nil, err
+	}
+	var keys []DelegationKey
+	for _, key := range domains[0].Keys {
+		if !key.Active || !key.Published || key.DNSKEY.Flags&dns.SEP == 0 {
+			continue
+		}
+		if ds := key.DNSKEY.ToDS(dns.SHA256); ds != nil {
+			keys = append(keys, delegationKey(key.DNSKEY, ds))
+		}
+	}
+	return keys, nil
+}
+
+func delegationKey(key *dns.DNSKEY, ds *dns.DS) DelegationKey {
+	return DelegationKey{
+		KeyTag:     ds.KeyTag,
+		Algorithm:  key.Algorithm,
+		Flags:      key.Flags,
+		PublicKey:  key.PublicKey,
+		DigestType: ds.DigestType,
+		Digest:     strings.ToUpper(ds.Digest),
+	}
+}
+
+// dnskeyFromPrivate builds the DNSKEY for a BIND format private key.
+func dnskeyFromPrivate(zone string, flags uint16, content string) (*dns.DNSKEY, error) {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(content, "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if ok {
+			fields[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+		}
+	}
+	algorithmField, _, _ := strings.Cut(fields["algorithm"], " ")
+	algorithm, err := strconv.ParseUint(algorithmField, 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid algorithm %q", fields["algorithm"])
+	}
+	decode := func(name string) ([]byte, error) {
+		value, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("missing %s", name)
+		}
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		return b, nil
+	}
+
+	var public []byte
+	switch uint8(algorithm) {
+	case dns.RSASHA1, dns.RSASHA1NSEC3SHA1, dns.RSASHA256, dns.RSASHA512:
+		modulus, err := decode("modulus")
+		if err != nil {
+			return nil, err
+		}
+		exponent, err := decode("publicexponent")
+		if err != nil {
+			return nil, err
+		}
+		exponent = new(big.Int).SetBytes(exponent).Bytes()
+		// RFC 3110: exponent length, then exponent, then modulus.
+		if len(exponent) < 256 {
+			public = append([]byte{byte(len(exponent))}, exponent...)
+		} else {
+			public = append([]byte{0, byte(len(exponent) >> 8), byte(len(exponent))}, exponent...)
+		}
+		public = append(public, modulus...)
+	case dns.ECDSAP256SHA256, dns.ECDSAP384SHA384:
+		private, err := decode("privatekey")
+		if err != nil {
+			return nil, err
+		}
+		curve, size := ecdh.P256(), 32
+		if uint8(algorithm) == dns.ECDSAP384SHA384 {
+			curve, size = ecdh.P384(), 48
+		}
+		if len(private) > size {
+			return nil, errors.New("invalid privatekey length")
+		}
+		padded := make([]byte, size)
+		copy(padded[size-len(private):], private)
+		key, err := curve.NewPrivateKey(padded)
+		if err != nil {
+			return nil, err
+		}
+		// Uncompressed point without its 0x04 prefix (RFC 6605).
+		public = key.PublicKey().Bytes()[1:]
+	case dns.ED25519:
+		seed, err := decode("privatekey")
+		if err != nil {
+			return nil, err
+		}
+		if len(seed) != ed25519.SeedSize {
+			return nil, errors.New("invalid privatekey length")
+		}
+		public = ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %d", algorithm)
+	}
+
+	return &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: dns.Fqdn(zone), Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET},
+		Flags:     flags,
+		Protocol:  3,
+		Algorithm: uint8(algorithm),
+		PublicKey: base64.StdEncoding.EncodeToString(public),
+	}, nil
+}
+
+type dnssecKeyResponse struct {
+	ID        int             `json:"id"`
+	Flags     uint16          `json:"flags"`
+	Algorithm uint8           `json:"algorithm"`
+	KeyTag    uint16          `json:"key_tag"`
+	Active    bool            `json:"active"`
+	Published bool            `json:"published"`
+	DNSKEY    string          `json:"dnskey"`
+	DS        []DelegationKey `json:"ds,omitempty"`
+}
+
+// handleDNSSEC serves GET /api/v1/domains/{id}/dnssec: the domain's keys
+// with their DNSKEY records and, for key-signing keys, the DS digests to
+// give the parent.
+func (r *Reloader) handleDNSSEC(w http.ResponseWriter, req *http.Request) {
+	domain, err := r.findDomain(req.Context(), req.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	domains := []Domain{*domain}
+	if err := r.loadDNSSECKeys(req.Context(), r.db, domains); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"domain": domain.Name,
+		"keys":   dnssecKeyResponses(domains[0].Keys),
+	})
+}
+
+func dnssecKeyResponses(keys []dnssecKey) []dnssecKeyResponse {
+	resps := make([]dnssecKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		resp := dnssecKeyResponse{
+			ID:        key.ID,
+			Flags:     key.DNSKEY.Flags,
+			Algorithm: key.DNSKEY.Algorithm,
+			KeyTag:    key.DNSKEY.KeyTag(),
+			Active:    key.Active,
+			Published: key.Published,
+			DNSKEY:    strings.TrimPrefix(key.DNSKEY.String(), key.DNSKEY.Hdr.String()),
+		}
+		if key.DNSKEY.Flags&dns.SEP != 0 {
+			for _, digestType := range dsDigestTypes {
+				if ds := key.DNSKEY.ToDS(digestType); ds != nil {
+					resp.DS = append(resp.DS, delegationKey(key.DNSKEY, ds))
+				}
+			}
+		}
+		resps = append(resps, resp)
+	}
+	return resps
+}
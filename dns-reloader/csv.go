@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// csvOwner is the created_by of records added by import.
+const csvOwner = "import"
+
+// recordsCSVColumns is the column layout of export -format=csv, one row
+// per record:
+//
+//	domain    the zone the record belongs to
+//	name      the full record name, without the trailing dot
+//	type      the record type, such as A or MX
+//	content   the record data as stored, without the priority
+//	ttl       the TTL in seconds
+//	prio      the MX or SRV priority, empty for other types
+//	disabled  true or false
+//	comment   the record's comment, empty for none
+//
+// import -format=csv reads the same layout; only domain, name, type and
+// content are required, and columns may come in any order. Names may also
+// be relative to the domain or @ for the apex.
+var recordsCSVColumns = []string{"domain", "name", "type", "content", "ttl", "prio", "disabled", "comment"}
+
+// writeRecordsCSV writes the stored records of zones to w in the
+// recordsCSVColumns layout, disabled records included, so the file can be
+// edited and imported again.
+func writeRecordsCSV(w io.Writer, zones []renderedZone) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(recordsCSVColumns); err != nil {
+		return err
+	}
+	for _, zone := range zones {
+		for _, record := range zone.Records {
+			var prio, comment string
+			if record.Prio != nil {
+				prio = strconv.Itoa(*record.Prio)
+			}
+			if record.Comment != nil {
+				comment = *record.Comment
+			}
+			row := []string{
+				zone.Domain.Name, record.Name, record.Type, record.Content,
+				strconv.Itoa(record.TTL), prio, strconv.FormatBool(record.Disabled), comment,
+			}
+			if err := out.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// csvImportResult counts what an import changed in one domain.
+type csvImportResult struct {
+	Added   int
+	Updated int
+	Deleted int
+}
+
+// errDryRun rolls back the transaction of an import run with -dry-run.
+var errDryRun = errors.New("dry run")
+
+// importRecords loads records from a CSV file in the recordsCSVColumns
+// layout. Rows are matched to stored records by name, type and content:
+// matches have their TTL, priority, disabled flag and comment updated and
+// the rest are added. With -prune, records of the domains in the file that
+// have no row are deleted, so an edited export round-trips exactly. The
+// whole file is validated and applied in one transaction; any problem
+// leaves the database untouched.
+func (r *Reloader) importRecords(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "csv", "format of the file; only csv is supported")
+	prune := fs.Bool("prune", false, "delete records of the domains in the file that it does not list")
+	dryRun := fs.Bool("dry-run", false, "report what would change without writing anything")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: reloader import [flags] <file|->")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "csv" {
+		return fmt.Errorf("unknown import format %q", *format)
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("import takes one file, or - for stdin")
+	}
+
+	var in io.Reader = os.Stdin
+	if fs.Arg(0) != "-" {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", fs.Arg(0), err)
+		}
+		defer f.Close()
+		in = f
+	}
+	rows, domains, err := parseRecordsCSV(in, true, 0)
+	if err != nil {
+		return err
+	}
+	if err := r.connectDB(); err != nil {
+		return err
+	}
+
+	byDomain := make(map[string][]int)
+	var names []string
+	for i, domain := range domains {
+		if _, ok := byDomain[domain]; !ok {
+			names = append(names, domain)
+		}
+		byDomain[domain] = append(byDomain[domain], i)
+	}
+	sort.Strings(names)
+
+	results := make(map[string]csvImportResult, len(names))
+	changed := make(map[string]*Domain)
+	var problems []string
+	err = r.db.WithContext(r.ctx).Transaction(func(tx *gorm.DB) error {
+		if _, ok := r.storage.(*PostgresStorage); ok {
+			if err := tx.Exec("SET LOCAL dns_reloader.suppress_notify = 'on'").Error; err != nil {
+				return fmt.Errorf("failed to suppress notifications: %w", err)
+			}
+		}
+		for _, name := range names {
+			var domain Domain
+			if err := tx.Where("name = ?", name).First(&domain).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					problems = append(problems, fmt.Sprintf("domain %s does not exist", name))
+					continue
+				}
+				return fmt.Errorf("failed to fetch domain %s: %w", name, err)
+			}
+			result, rowProblems, err := r.importDomainRecords(r.ctx, tx, &domain, rows, byDomain[name], *prune)
+			if err != nil {
+				return err
+			}
+			problems = append(problems, rowProblems...)
+			results[name] = result
+			if result.Added+result.Updated+result.Deleted > 0 {
+				changed[name] = &domain
+			}
+		}
+		if len(problems) > 0 {
+			return errValidation(problems)
+		}
+		if *dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		return fmt.Errorf("%d problem(s) in %s, nothing imported", len(problems), fs.Arg(0))
+	}
+	if err != nil && !errors.Is(err, errDryRun) {
+		return err
+	}
+
+	for _, name := range names {
+		result := results[name]
+		fmt.Printf("%s: %d added, %d updated, %d deleted\n", name, result.Added, result.Updated, result.Deleted)
+		if domain := changed[name]; domain != nil && !*dryRun {
+			r.domainChanged(domain, "IMPORT")
+		}
+	}
+	r.logger.WithFields(logrus.Fields{
+		"file":    fs.Arg(0),
+		"rows":    len(rows),
+		"domains": len(names),
+		"changed": len(changed),
+		"dry_run": *dryRun,
+	}).Info("Imported records from CSV")
+	return nil
+}
+
+// importDomainRecords applies the rows at indexes to domain through tx.
+// Deletions go first and additions last, so a name can change from an A
+// record to a CNAME in one import. Problems are prefixed with the 1-based
+// row number.
+func (r *Reloader) importDomainRecords(ctx context.Context, tx *gorm.DB, domain *Domain, rows []recordRequest, indexes []int, prune bool) (csvImportResult, []string, error) {
+	var result csvImportResult
+	if err := r.checkWritable(domain); err != nil {
+		return result, nil, err
+	}
+	var existing []Record
+	if err := tx.Where("domain_id = ?", domain.ID).Order("id").Find(&existing).Error; err != nil {
+		return result, nil, fmt.Errorf("failed to fetch records for %s: %w", domain.Name, err)
+	}
+	key := func(name, rrtype, content string) string {
+		return strings.ToLower(name) + "/" + strings.ToUpper(rrtype) + "/" + content
+	}
+	stored := make(map[string]Record, len(existing))
+	for _, record := range existing {
+		stored[key(record.Name, record.Type, record.Content)] = record
+	}
+
+	var problems []string
+	matched := make(map[string]int)
+	for _, i := range indexes {
+		row := rows[i]
+		k := key(qualifyName(row.Name, domain.Name), strings.TrimSpace(row.Type), strings.TrimSpace(row.Content))
+		if first, ok := matched[k]; ok {
+			problems = append(problems, fmt.Sprintf("row %d: duplicate of row %d", i+1, first+1))
+			continue
+		}
+		matched[k] = i
+	}
+
+	if prune {
+		for k, record := range stored {
+			if _, ok := matched[k]; ok {
+				continue
+			}
+			if err := tx.Delete(&Record{}, record.ID).Error; err != nil {
+				return result, nil, fmt.Errorf("failed to delete record %d: %w", record.ID, err)
+			}
+			result.Deleted++
+		}
+	}
+
+	var added []int
+	for _, i := range indexes {
+		row := rows[i]
+		k := key(qualifyName(row.Name, domain.Name), strings.TrimSpace(row.Type), strings.TrimSpace(row.Content))
+		if matched[k] != i {
+			continue
+		}
+		current, ok := stored[k]
+		if !ok {
+			added = append(added, i)
+			continue
+		}
+		// The CSV has no weight column, so weights are kept as stored.
+		row.Weight = current.Weight
+		record := current
+		if err := applyRecordRequest(ctx, tx, domain, &record, row); err != nil {
+			var apiErr *apiError
+			if !errors.As(err, &apiErr) {
+				return result, nil, err
+			}
+			for _, problem := range apiErr.problems {
+				problems = append(problems, fmt.Sprintf("row %d: %s", i+1, problem))
+			}
+			continue
+		}
+		if record.TTL == current.TTL && samePtr(record.Prio, current.Prio) && record.Disabled == current.Disabled && samePtr(record.Comment, current.Comment) {
+			continue
+		}
+		if err := tx.Omit(clause.Associations, "CreatedAt", "CreatedBy").Save(&record).Error; err != nil {
+			return result, nil, fmt.Errorf("failed to update record %d: %w", record.ID, err)
+		}
+		result.Updated++
+	}
+
+	for _, i := range added {
+		record := Record{CreatedBy: csvOwner}
+		if err := applyRecordRequest(ctx, tx, domain, &record, rows[i]); err != nil {
+			var apiErr *apiError
+			if !errors.As(err, &apiErr) {
+				return result, nil, err
+			}
+			for _, problem := range apiErr.problems {
+				problems = append(problems, fmt.Sprintf("row %d: %s", i+1, problem))
+			}
+			continue
+		}
+		if err := tx.Omit(clause.Associations).Create(&record).Error; err != nil {
+			return result, nil, fmt.Errorf("failed to insert row %d: %w", i+1, err)
+		}
+		result.Added++
+	}
+	return result, problems, nil
+}
+
+// samePtr reports whether a and b are both nil or point to equal values.
+func samePtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// renderDnsmasq renders zones as a dnsmasq conf.d file. Each zone is
+// declared local so names missing from it are answered from here rather
+// than forwarded. Wildcard names become address=/ lines, which also match
+// the name below the wildcard itself. SOA and NS records have no meaning
+// to dnsmasq and are left out; types without a directive of their own are
+// written as dns-rr lines.
+func renderDnsmasq(zones []outputZone) (string, error) {
+	var conf strings.Builder
+	conf.WriteString("# Generated by dns-reloader, do not edit\n")
+	for _, zone := range zones {
+		conf.WriteString("\n# " + zone.Name + "\n")
+		conf.WriteString("local=/" + zone.Name + "/\n")
+		for _, rr := range zone.RRs {
+			if line := dnsmasqLine(rr); line != "" {
+				conf.WriteString(line + "\n")
+			}
+		}
+	}
+	return conf.String(), nil
+}
+
+// dnsmasqLine converts one record to its dnsmasq directive, or returns ""
+// for records dnsmasq does not serve.
+func dnsmasqLine(rr dns.RR) string {
+	header := rr.Header()
+	name := strings.TrimSuffix(header.Name, ".")
+	switch v := rr.(type) {
+	case *dns.SOA, *dns.NS:
+		return ""
+	case *dns.A:
+		if wildcard, ok := strings.CutPrefix(name, "*."); ok {
+			return fmt.Sprintf("address=/%s/%s", wildcard, v.A)
+		}
+		return fmt.Sprintf("host-record=%s,%s,%d", name, v.A, header.Ttl)
+	case *dns.AAAA:
+		if wildcard, ok := strings.CutPrefix(name, "*."); ok {
+			return fmt.Sprintf("address=/%s/%s", wildcard, v.AAAA)
+		}
+		return fmt.Sprintf("host-record=%s,%s,%d", name, v.AAAA, header.Ttl)
+	case *dns.CNAME:
+		return fmt.Sprintf("cname=%s,%s,%d", name, strings.TrimSuffix(v.Target, "."), header.Ttl)
+	case *dns.MX:
+		return fmt.Sprintf("mx-host=%s,%s,%d", name, strings.TrimSuffix(v.Mx, "."), v.Preference)
+	case *dns.SRV:
+		return fmt.Sprintf("srv-host=%s,%s,%d,%d,%d", name, strings.TrimSuffix(v.Target, "."), v.Port, v.Priority, v.Weight)
+	case *dns.PTR:
+		return fmt.Sprintf("ptr-record=%s,%s", name, strings.TrimSuffix(v.Ptr, "."))
+	case *dns.TXT:
+		return fmt.Sprintf("txt-record=%s,\"%s\"", name, strings.Join(v.Txt, "\",\""))
+	}
+
+	rdata, err := wireRdata(rr)
+	if err != nil {
+		return fmt.Sprintf("# %s: %v", rr.String(), err)
+	}
+	return fmt.Sprintf("dns-rr=%s,%d,%s", name, header.Rrtype, hex.EncodeToString(rdata))
+}
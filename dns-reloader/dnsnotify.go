@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+)
+
+// notifies reports whether secondaries transfer zone from us and should be
+// told when it changes: MASTER zones, and with DNS_LISTEN_ADDR set every
+// generated zone, since the built-in server transfers those whatever their
+// type. NATIVE zones otherwise replicate through the database.
+func (r *Reloader) notifies(opts zoneOptions) bool {
+	return opts.Master || (r.config.DNSListenAddr != "" && opts.Generated)
+}
+
+// sendAlsoNotify sends a DNS NOTIFY for each reloaded zone whose serial
+// changed to the ALSO_NOTIFY targets and its own ALSO-NOTIFY ones, so
+// secondaries transfer it now instead of on their refresh timer. Notifies
+// are sent in the background and retried NOTIFY_RETRIES times; failures
+// are logged, as secondaries still catch up on the timer. They are signed
+// with the zone's first TSIG-ALLOW-AXFR key, or the first AXFR_TSIG_KEYS
+// key, so secondaries that transfer with a key can check where they came
+// from.
+func (r *Reloader) sendAlsoNotify(zones []string) {
+	for _, zone := range zones {
+		opts := r.zoneOptions(zone)
+		if !r.notifies(opts) {
+			continue
+		}
+		targets := slices.Concat(r.config.AlsoNotify, opts.AlsoNotify)
+		if len(targets) == 0 {
+			continue
+		}
+		serial := r.status.serial(zone)
+		if serial == "" || !r.serialChanged(zone, serial) {
+			r.logger.WithFields(logrus.Fields{
+				"domain": zone,
+				"serial": serial,
+			}).Debug("Serial unchanged, not sending NOTIFY")
+			continue
+		}
+		keyName := ""
+		if keys := slices.Concat(opts.TSIGAllowAXFR, r.config.AXFRTSIGKeys); len(keys) > 0 {
+			keyName = keys[0]
+		}
+		slices.Sort(targets)
+		for _, target := range slices.Compact(targets) {
+			go r.notifyTarget(zone, serial, target, keyName)
+		}
+	}
+}
+
+// serialChanged records serial as the last one notified for zone and
+// reports whether it differs from the one before.
+func (r *Reloader) serialChanged(zone, serial string) bool {
+	r.notifiedMu.Lock()
+	defer r.notifiedMu.Unlock()
+	if r.notified == nil {
+		r.notified = make(map[string]string)
+	}
+	if r.notified[zone] == serial {
+		return false
+	}
+	r.notified[zone] = serial
+	return true
+}
+
+// notifyTarget sends one NOTIFY, retrying until it is acknowledged, the
+// retries run out or the reloader stops.
+func (r *Reloader) notifyTarget(zone, serial, target, keyName string) {
+	logger := r.logger.WithFields(logrus.Fields{
+		"domain": zone,
+		"serial": serial,
+		"target": target,
+	})
+	var key *tsigKey
+	if keyName != "" {
+		if key = r.cachedTSIGKey(keyName); key == nil {
+			notifiesSent.WithLabelValues("failed").Inc()
+			logger.WithField("key", keyName).Warn("TSIG key for NOTIFY not found")
+			return
+		}
+	}
+	var err error
+	for attempt := 0; attempt <= r.config.NotifyRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-time.After(r.config.NotifyRetryDelay):
+			}
+		}
+		if err = sendNotify(r.ctx, zone, target, key); err == nil {
+			notifiesSent.WithLabelValues("acknowledged").Inc()
+			logger.Debug("Sent NOTIFY")
+			return
+		}
+	}
+	notifiesSent.WithLabelValues("failed").Inc()
+	logger.WithError(err).Warn("Failed to send NOTIFY")
+}
+
+// sendNotify sends a NOTIFY for zone to target, which may omit the port,
+// signed with key when it is not nil.
+func sendNotify(ctx context.Context, zone, target string, key *tsigKey) error {
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, "53")
+	}
+
+	msg := new(dns.Msg)
+	msg.SetNotify(dns.Fqdn(zone))
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	client := &dns.Client{}
+	if key != nil {
+		msg.SetTsig(key.Name, key.Algorithm, 300, time.Now().Unix())
+		client.TsigSecret = map[string]string{key.Name: key.Secret}
+	}
+	resp, _, err := client.ExchangeContext(ctx, msg, target)
+	if err != nil {
+		return err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("NOTIFY answered %s", dns.RcodeToString[resp.Rcode])
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+)
+
+// transferChunk is how many records go in each message of an outgoing
+// AXFR, small enough that a chunk of RRSIGs fits the 64 KiB TCP limit.
+const transferChunk = 100
+
+// dnsACLs are the networks allowed server-wide, beyond each zone's own:
+// AXFR_ALLOW_FROM for transfers and UPDATE_ALLOW_FROM for dynamic updates.
+type dnsACLs struct {
+	transfer []netip.Prefix
+	update   []netip.Prefix
+}
+
+// startDNSServer answers DNS on DNS_LISTEN_ADDR, over UDP and TCP, so
+// secondaries can pull generated zones with AXFR or IXFR instead of having
+// the files copied to them. Only the SOA of a zone is answered otherwise.
+// Requests signed with a stored TSIG key are verified and their responses
+// signed. It is off unless DNS_LISTEN_ADDR is set.
+func (r *Reloader) startDNSServer() error {
+	if r.config.DNSListenAddr == "" {
+		return nil
+	}
+	var acls dnsACLs
+	var err error
+	if acls.transfer, err = parsePrefixes(r.config.AXFRAllowFrom); err != nil {
+		return fmt.Errorf("invalid AXFR_ALLOW_FROM: %w", err)
+	}
+	if acls.update, err = parsePrefixes(r.config.UpdateAllowFrom); err != nil {
+		return fmt.Errorf("invalid UPDATE_ALLOW_FROM: %w", err)
+	}
+
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		r.serveDNS(w, req, acls)
+	})
+	servers := make([]*dns.Server, 0, 2)
+	for _, network := range []string{"udp", "tcp"} {
+		server := &dns.Server{Net: network, Handler: handler, TsigProvider: tsigProvider{r: r}, MsgAcceptFunc: acceptDNS}
+		if network == "udp" {
+			conn, err := net.ListenPacket(network, r.config.DNSListenAddr)
+			if err != nil {
+				return fmt.Errorf("failed to listen for DNS: %w", err)
+			}
+			server.PacketConn = conn
+		} else {
+			lis, err := net.Listen(network, r.config.DNSListenAddr)
+			if err != nil {
+				return fmt.Errorf("failed to listen for DNS: %w", err)
+			}
+			server.Listener = lis
+		}
+		servers = append(servers, server)
+	}
+
+	go func() {
+		<-r.ctx.Done()
+		for _, server := range servers {
+			server.Shutdown()
+		}
+	}()
+
+	r.logger.WithFields(logrus.Fields{
+		"addr":         r.config.DNSListenAddr,
+		"allow_axfr":   r.config.AXFRAllowFrom,
+		"tsig_keys":    r.config.AXFRTSIGKeys,
+		"allow_update": r.config.UpdateAllowFrom,
+	}).Info("DNS server listening")
+	for _, server := range servers {
+		go func(server *dns.Server) {
+			if err := server.ActivateAndServe(); err != nil {
+				r.logger.WithError(err).WithField("net", server.Net).Error("DNS server failed")
+			}
+		}(server)
+	}
+	return nil
+}
+
+// acceptDNS lets UPDATEs through to the handler, whose sections may hold
+// any number of records, and leaves other messages to the library's
+// checks.
+func acceptDNS(dh dns.Header) dns.MsgAcceptAction {
+	response := dh.Bits&(1<<15) != 0
+	if !response && int(dh.Bits>>11)&0xF == dns.OpcodeUpdate {
+		if dh.Qdcount != 1 {
+			return dns.MsgReject
+		}
+		return dns.MsgAccept
+	}
+	return dns.DefaultMsgAcceptFunc(dh)
+}
+
+// parsePrefixes reads networks given as addresses or CIDR prefixes.
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		if addr, err := netip.ParseAddr(value); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an address or network", value)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// remoteAddr is the address a DNS request came from.
+func remoteAddr(w dns.ResponseWriter) netip.Addr {
+	addrPort, err := netip.ParseAddrPort(w.RemoteAddr().String())
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addrPort.Addr().Unmap()
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveDNS answers a query for a generated zone: AXFR over TCP, and IXFR
+// from the zone's journal, to peers in allowed or the zone's
+// ALLOW-AXFR-FROM, or signed with a key in AXFR_TSIG_KEYS or its
+// TSIG-ALLOW-AXFR, and SOA to anyone, which secondaries ask for to see
+// whether the serial changed. An IXFR the journal cannot answer gets the
+// whole zone, as RFC 1995 allows. NOTIFYs for zones this reloader is
+// secondary for are accepted from their masters, and UPDATEs are written
+// to the records table. Anything else is refused; this is not a general
+// purpose authoritative server.
+func (r *Reloader) serveDNS(w dns.ResponseWriter, req *dns.Msg, acls dnsACLs) {
+	msg := new(dns.Msg)
+	msg.SetReply(req)
+	tsig := req.IsTsig()
+	if tsig != nil {
+		if err := w.TsigStatus(); err != nil {
+			r.logger.WithError(err).WithFields(logrus.Fields{
+				"key":  tsig.Hdr.Name,
+				"peer": remoteAddr(w).String(),
+			}).Warn("Rejected DNS request with bad TSIG")
+			msg.SetRcode(req, dns.RcodeNotAuth)
+			w.WriteMsg(msg)
+			return
+		}
+	}
+	write := func() {
+		if tsig != nil {
+			msg.SetTsig(tsig.Hdr.Name, tsig.Algorithm, tsig.Fudge, time.Now().Unix())
+		}
+		w.WriteMsg(msg)
+	}
+	if req.Opcode == dns.OpcodeNotify && len(req.Question) == 1 {
+		msg.Authoritative = true
+		msg.Rcode = r.receiveNotify(req.Question[0], remoteAddr(w), tsig)
+		write()
+		return
+	}
+	if req.Opcode == dns.OpcodeUpdate {
+		msg.Rcode = r.receiveUpdate(req, remoteAddr(w), tsig, acls.update)
+		write()
+		return
+	}
+	if req.Opcode != dns.OpcodeQuery || len(req.Question) != 1 {
+		msg.SetRcode(req, dns.RcodeNotImplemented)
+		write()
+		return
+	}
+	question := req.Question[0]
+	zone := strings.ToLower(strings.TrimSuffix(question.Name, "."))
+	opts := r.zoneOptions(zone)
+	if !opts.Generated || question.Qclass != dns.ClassINET {
+		msg.SetRcode(req, dns.RcodeNotAuth)
+		write()
+		return
+	}
+
+	switch question.Qtype {
+	case dns.TypeAXFR, dns.TypeIXFR:
+		peer := remoteAddr(w)
+		logger := r.logger.WithFields(logrus.Fields{"domain": zone, "peer": peer.String()})
+		if !r.transferAllowed(opts, acls.transfer, peer, tsig) {
+			logger.Warn("Refused zone transfer")
+			zoneTransfers.WithLabelValues("refused").Inc()
+			msg.SetRcode(req, dns.RcodeRefused)
+			write()
+			return
+		}
+		var since *dns.SOA
+		if question.Qtype == dns.TypeIXFR && len(req.Ns) > 0 {
+			since, _ = req.Ns[0].(*dns.SOA)
+		}
+		_, udp := w.RemoteAddr().(*net.UDPAddr)
+		if (udp && question.Qtype == dns.TypeAXFR) || (question.Qtype == dns.TypeIXFR && since == nil) {
+			msg.SetRcode(req, dns.RcodeFormatError)
+			write()
+			return
+		}
+		records, err := r.zoneTransferRecords(zone)
+		if err != nil {
+			logger.WithError(err).Error("Failed to read zone for transfer")
+			zoneTransfers.WithLabelValues("failed").Inc()
+			msg.SetRcode(req, dns.RcodeServerFailure)
+			write()
+			return
+		}
+
+		// An IXFR over UDP only gets the current SOA, which has the
+		// secondary retry over TCP if it is behind.
+		result := "served"
+		if since != nil {
+			current := records[0].(*dns.SOA)
+			if udp || since.Serial == current.Serial {
+				msg.Authoritative = true
+				msg.Answer = records[:1]
+				write()
+				return
+			}
+			deltas, err := r.zoneDeltas(r.ctx, zone, since.Serial, current.Serial)
+			if err != nil {
+				logger.WithError(err).Warn("Failed to read zone journal, sending the whole zone")
+			} else if deltas != nil {
+				records = incrementalRecords(current, deltas)
+				result = "incremental"
+			}
+			logger = logger.WithField("from_serial", since.Serial)
+		}
+		if err := sendTransfer(w, req, records); err != nil {
+			logger.WithError(err).Warn("Zone transfer failed")
+			zoneTransfers.WithLabelValues("failed").Inc()
+			return
+		}
+		logger.WithFields(logrus.Fields{
+			"type":    dns.TypeToString[question.Qtype],
+			"records": len(records),
+			"result":  result,
+		}).Info("Served zone transfer")
+		zoneTransfers.WithLabelValues(result).Inc()
+	case dns.TypeSOA:
+		records, err := parseZoneFile(r.zoneFilePath(zone), zone)
+		if err != nil {
+			msg.SetRcode(req, dns.RcodeServerFailure)
+			write()
+			return
+		}
+		msg.Authoritative = true
+		for _, rr := range records {
+			if rr.Header().Rrtype == dns.TypeSOA {
+				msg.Answer = append(msg.Answer, rr)
+				break
+			}
+		}
+		write()
+	default:
+		msg.SetRcode(req, dns.RcodeRefused)
+		write()
+	}
+}
+
+// transferAllowed reports whether a peer may transfer a zone: it is in
+// allowed or the zone's ALLOW-AXFR-FROM, or signed the request with a key
+// in AXFR_TSIG_KEYS or the zone's TSIG-ALLOW-AXFR. The signature has been
+// verified by the time the handler runs.
+func (r *Reloader) transferAllowed(opts zoneOptions, allowed []netip.Prefix, peer netip.Addr, tsig *dns.TSIG) bool {
+	return aclAllows(slices.Concat(allowed, opts.AllowAXFR), slices.Concat(opts.TSIGAllowAXFR, r.config.AXFRTSIGKeys), peer, tsig)
+}
+
+// aclAllows reports whether peer is in networks or signed its request with
+// one of keys.
+func aclAllows(networks []netip.Prefix, keys []string, peer netip.Addr, tsig *dns.TSIG) bool {
+	if containsAddr(networks, peer) {
+		return true
+	}
+	if tsig == nil {
+		return false
+	}
+	key := strings.ToLower(tsig.Hdr.Name)
+	return slices.ContainsFunc(keys, func(name string) bool {
+		return dns.Fqdn(strings.ToLower(name)) == key
+	})
+}
+
+// zoneTransferRecords reads a zone's file as it is served, SOA first and
+// last as an AXFR needs it.
+func (r *Reloader) zoneTransferRecords(zone string) ([]dns.RR, error) {
+	rrs, err := parseZoneFile(r.zoneFilePath(zone), zone)
+	if err != nil {
+		return nil, err
+	}
+	var soa dns.RR
+	records := make([]dns.RR, 1, len(rrs)+1)
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == dns.TypeSOA {
+			if soa == nil {
+				soa = rr
+			}
+			continue
+		}
+		records = append(records, rr)
+	}
+	if soa == nil {
+		return nil, fmt.Errorf("zone file has no SOA")
+	}
+	records[0] = soa
+	return append(records, soa), nil
+}
+
+// sendTransfer writes records as an AXFR or IXFR response in
+// transferChunk messages and closes the connection.
+func sendTransfer(w dns.ResponseWriter, req *dns.Msg, records []dns.RR) error {
+	ch := make(chan *dns.Envelope, len(records)/transferChunk+1)
+	for start := 0; start < len(records); start += transferChunk {
+		ch <- &dns.Envelope{RR: records[start:min(start+transferChunk, len(records))]}
+	}
+	close(ch)
+	defer w.Close()
+	return new(dns.Transfer).Out(w, req, ch)
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// dnsUpdateOwner is the created_by of records added by DNS UPDATE.
+const dnsUpdateOwner = "dnsupdate"
+
+// updateError is why an UPDATE was not applied, and the rcode it is
+// answered with.
+type updateError struct {
+	rcode  int
+	reason string
+}
+
+func (e *updateError) Error() string {
+	return e.reason
+}
+
+func updateRejected(rcode int, format string, args ...interface{}) error {
+	return &updateError{rcode: rcode, reason: fmt.Sprintf(format, args...)}
+}
+
+// receiveUpdate applies an RFC 2136 UPDATE to a generated zone's records,
+// as DHCP servers and appliances send them, and queues a regeneration. It
+// must come from UPDATE_ALLOW_FROM or the zone's ALLOW-DNSUPDATE-FROM, or
+// be signed with a key in UPDATE_TSIG_KEYS or its TSIG-ALLOW-DNSUPDATE;
+// with none of them set updates are refused. Prerequisites are checked and
+// the update applied in one transaction, so either all of it is written or
+// none. Unless X-SERIAL-STRATEGY manages it, the stored SOA serial is
+// advanced with each change, as RFC 2136 3.6 asks. It returns the rcode to
+// answer with.
+func (r *Reloader) receiveUpdate(req *dns.Msg, peer netip.Addr, tsig *dns.TSIG, allowed []netip.Prefix) int {
+	if len(req.Question) != 1 || req.Question[0].Qtype != dns.TypeSOA {
+		return dns.RcodeFormatError
+	}
+	question := req.Question[0]
+	zone := strings.ToLower(strings.TrimSuffix(question.Name, "."))
+	logger := r.logger.WithFields(logrus.Fields{"domain": zone, "peer": peer.String()})
+	if tsig != nil {
+		logger = logger.WithField("key", tsig.Hdr.Name)
+	}
+
+	opts := r.zoneOptions(zone)
+	if !opts.Generated || zone == r.catalogZone() || question.Qclass != dns.ClassINET {
+		return dns.RcodeNotAuth
+	}
+	keys := slices.Concat(opts.TSIGAllowUpdate, r.config.UpdateTSIGKeys)
+	if !aclAllows(slices.Concat(allowed, opts.AllowUpdate), keys, peer, tsig) {
+		logger.Warn("Refused DNS UPDATE")
+		dnsUpdates.WithLabelValues("refused").Inc()
+		return dns.RcodeRefused
+	}
+	domain, err := r.findDomain(r.ctx, zone)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to find domain for DNS UPDATE")
+		dnsUpdates.WithLabelValues("failed").Inc()
+		return dns.RcodeServerFailure
+	}
+	if err := r.checkWritable(domain); err != nil {
+		logger.WithError(err).Warn("Refused DNS UPDATE")
+		dnsUpdates.WithLabelValues("refused").Inc()
+		return dns.RcodeRefused
+	}
+
+	changes, err := r.applyUpdate(r.ctx, domain, req.Answer, req.Ns, opts.SerialStrategy == "")
+	var rejected *updateError
+	switch {
+	case errors.As(err, &rejected):
+		logger.WithFields(logrus.Fields{
+			"rcode":  dns.RcodeToString[rejected.rcode],
+			"reason": rejected.reason,
+		}).Info("Rejected DNS UPDATE")
+		dnsUpdates.WithLabelValues("rejected").Inc()
+		return rejected.rcode
+	case err != nil:
+		logger.WithError(err).Error("Failed to apply DNS UPDATE")
+		dnsUpdates.WithLabelValues("failed").Inc()
+		return dns.RcodeServerFailure
+	}
+
+	dnsUpdates.WithLabelValues("applied").Inc()
+	logger.WithField("changes", changes).Info("Applied DNS UPDATE")
+	if changes > 0 {
+		r.enqueueChange(&DNSChangeNotification{
+			Table:     "records",
+			Action:    "DNS_UPDATE",
+			DomainID:  int(domain.ID),
+			Name:      domain.Name,
+			Timestamp: time.Now(),
+		})
+	}
+	return dns.RcodeSuccess
+}
+
+// applyUpdate checks prereqs against domain's enabled records and applies
+// updates to them, returning how many records were added, changed or
+// removed. With bumpSerial the SOA serial is advanced when anything else
+// changed. The per-row database notifications are suppressed; the caller
+// queues a single change.
+func (r *Reloader) applyUpdate(ctx context.Context, domain *Domain, prereqs, updates []dns.RR, bumpSerial bool) (int, error) {
+	apex := dns.Fqdn(domain.Name)
+	if err := prescanUpdate(apex, updates); err != nil {
+		return 0, err
+	}
+
+	var changes int
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, ok := r.storage.(*PostgresStorage); ok {
+			if err := tx.Exec("SET LOCAL dns_reloader.suppress_notify = 'on'").Error; err != nil {
+				return fmt.Errorf("failed to suppress notifications: %w", err)
+			}
+		}
+		var records []Record
+		if err := tx.Where("domain_id = ? AND disabled = ?", domain.ID, false).Order("id").Find(&records).Error; err != nil {
+			return fmt.Errorf("failed to fetch records: %w", err)
+		}
+		zone := newUpdateZone(domain, records)
+		if err := zone.check(prereqs); err != nil {
+			return err
+		}
+		for _, rr := range updates {
+			zone.apply(rr)
+		}
+		if bumpSerial {
+			zone.bumpSerial()
+		}
+		var err error
+		changes, err = zone.save(tx)
+		return err
+	})
+	return changes, err
+}
+
+// prescanUpdate checks the update section as RFC 2136 3.4.1 asks before
+// anything is applied. The DNSSEC records the reloader manages itself
+// cannot be updated.
+func prescanUpdate(apex string, updates []dns.RR) error {
+	for _, rr := range updates {
+		header := rr.Header()
+		if !dns.IsSubDomain(apex, header.Name) {
+			return updateRejected(dns.RcodeNotZone, "%s is outside the zone", header.Name)
+		}
+		if rfc2136Skipped[dns.TypeToString[header.Rrtype]] {
+			return updateRejected(dns.RcodeRefused, "%s records cannot be updated", dns.TypeToString[header.Rrtype])
+		}
+		meta := isMetaType(header.Rrtype)
+		switch header.Class {
+		case dns.ClassINET:
+			if meta {
+				return updateRejected(dns.RcodeFormatError, "cannot add %s records", dns.TypeToString[header.Rrtype])
+			}
+		case dns.ClassANY:
+			if header.Ttl != 0 || header.Rdlength != 0 || (meta && header.Rrtype != dns.TypeANY) {
+				return updateRejected(dns.RcodeFormatError, "malformed RRset deletion for %s", header.Name)
+			}
+		case dns.ClassNONE:
+			if header.Ttl != 0 || meta {
+				return updateRejected(dns.RcodeFormatError, "malformed record deletion for %s", header.Name)
+			}
+		default:
+			return updateRejected(dns.RcodeFormatError, "unexpected class %s", dns.ClassToString[header.Class])
+		}
+	}
+	return nil
+}
+
+func isMetaType(rrtype uint16) bool {
+	switch rrtype {
+	case dns.TypeANY, dns.TypeAXFR, dns.TypeIXFR, dns.TypeMAILA, dns.TypeMAILB, dns.TypeTSIG, dns.TypeOPT, dns.TypeTKEY:
+		return true
+	}
+	return false
+}
+
+// updateZone is a zone's records as an UPDATE changes them, written back
+// by save.
+type updateZone struct {
+	domain  *Domain
+	apex    string
+	entries []*updateEntry
+}
+
+// updateEntry is one record of an updateZone. rr is nil when the stored
+// content does not parse, so the record can only be removed with its
+// RRset or name.
+type updateEntry struct {
+	record  Record
+	rr      dns.RR
+	added   bool
+	changed bool
+	deleted bool
+}
+
+func newUpdateZone(domain *Domain, records []Record) *updateZone {
+	zone := &updateZone{domain: domain, apex: dns.Fqdn(domain.Name)}
+	for _, record := range records {
+		rr, _ := recordRR(domain.Name, record)
+		zone.entries = append(zone.entries, &updateEntry{record: record, rr: rr})
+	}
+	return zone
+}
+
+// owner is the fully qualified, lower case owner of an entry.
+func (e *updateEntry) owner() string {
+	return dns.Fqdn(strings.ToLower(e.record.Name))
+}
+
+// at returns the live records at name, of rrtype unless it is ANY.
+func (z *updateZone) at(name string, rrtype uint16) []*updateEntry {
+	name = strings.ToLower(name)
+	var entries []*updateEntry
+	for _, entry := range z.entries {
+		if entry.deleted || entry.owner() != name {
+			continue
+		}
+		if rrtype == dns.TypeANY || strings.EqualFold(entry.record.Type, dns.TypeToString[rrtype]) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// check evaluates the prerequisites of RFC 2136 3.2.
+func (z *updateZone) check(prereqs []dns.RR) error {
+	required := make(map[string][]dns.RR)
+	var order []string
+	for _, rr := range prereqs {
+		header := rr.Header()
+		if header.Ttl != 0 {
+			return updateRejected(dns.RcodeFormatError, "prerequisite for %s has a TTL", header.Name)
+		}
+		if !dns.IsSubDomain(z.apex, header.Name) {
+			return updateRejected(dns.RcodeNotZone, "prerequisite %s is outside the zone", header.Name)
+		}
+		rrtype := dns.TypeToString[header.Rrtype]
+		switch header.Class {
+		case dns.ClassANY:
+			if header.Rdlength != 0 {
+				return updateRejected(dns.RcodeFormatError, "prerequisite for %s has data", header.Name)
+			}
+			if header.Rrtype == dns.TypeANY && len(z.at(header.Name, dns.TypeANY)) == 0 {
+				return updateRejected(dns.RcodeNameError, "%s does not exist", header.Name)
+			}
+			if header.Rrtype != dns.TypeANY && len(z.at(header.Name, header.Rrtype)) == 0 {
+				return updateRejected(dns.RcodeNXRrset, "%s %s does not exist", header.Name, rrtype)
+			}
+		case dns.ClassNONE:
+			if header.Rdlength != 0 {
+				return updateRejected(dns.RcodeFormatError, "prerequisite for %s has data", header.Name)
+			}
+			if header.Rrtype == dns.TypeANY && len(z.at(header.Name, dns.TypeANY)) > 0 {
+				return updateRejected(dns.RcodeYXDomain, "%s exists", header.Name)
+			}
+			if header.Rrtype != dns.TypeANY && len(z.at(header.Name, header.Rrtype)) > 0 {
+				return updateRejected(dns.RcodeYXRrset, "%s %s exists", header.Name, rrtype)
+			}
+		case dns.ClassINET:
+			key := strings.ToLower(header.Name) + "/" + rrtype
+			if _, ok := required[key]; !ok {
+				order = append(order, key)
+			}
+			required[key] = append(required[key], rr)
+		default:
+			return updateRejected(dns.RcodeFormatError, "unexpected prerequisite class %s", dns.ClassToString[header.Class])
+		}
+	}
+
+	for _, key := range order {
+		want := required[key]
+		header := want[0].Header()
+		var have []dns.RR
+		for _, entry := range z.at(header.Name, header.Rrtype) {
+			if entry.rr != nil {
+				have = append(have, entry.rr)
+			}
+		}
+		if !sameRRs(have, want) {
+			return updateRejected(dns.RcodeNXRrset, "%s %s does not match", header.Name, dns.TypeToString[header.Rrtype])
+		}
+	}
+	return nil
+}
+
+// sameRRs reports whether a and b hold the same records, TTLs aside.
+func sameRRs(a, b []dns.RR) bool {
+	contains := func(rrs []dns.RR, rr dns.RR) bool {
+		return slices.ContainsFunc(rrs, func(other dns.RR) bool { return dns.IsDuplicate(other, rr) })
+	}
+	for _, rr := range a {
+		if !contains(b, rr) {
+			return false
+		}
+	}
+	for _, rr := range b {
+		if !contains(a, rr) {
+			return false
+		}
+	}
+	return true
+}
+
+// apply makes one change of the update section, following RFC 2136
+// 3.4.2: changes that would leave the zone without its SOA or apex NS
+// records, or put a CNAME beside other data, are ignored.
+func (z *updateZone) apply(rr dns.RR) {
+	header := rr.Header()
+	name := strings.ToLower(header.Name)
+	atApex := name == z.apex
+	switch header.Class {
+	case dns.ClassINET:
+		z.add(rr)
+	case dns.ClassANY:
+		for _, entry := range z.at(name, header.Rrtype) {
+			if atApex && (strings.EqualFold(entry.record.Type, "SOA") || strings.EqualFold(entry.record.Type, "NS")) {
+				continue
+			}
+			entry.deleted = true
+		}
+	case dns.ClassNONE:
+		if header.Rrtype == dns.TypeSOA {
+			return
+		}
+		match := dns.Copy(rr)
+		match.Header().Class = dns.ClassINET
+		rrset := z.at(name, header.Rrtype)
+		for _, entry := range rrset {
+			if entry.rr == nil || !dns.IsDuplicate(entry.rr, match) {
+				continue
+			}
+			if atApex && header.Rrtype == dns.TypeNS && len(rrset) == 1 {
+				return
+			}
+			entry.deleted = true
+		}
+	}
+}
+
+// add adds rr, or replaces the record it duplicates so its TTL is taken.
+// A CNAME replaces the CNAME already at its name, and an SOA the zone's
+// SOA when its serial is newer.
+func (z *updateZone) add(rr dns.RR) {
+	header := rr.Header()
+	name := strings.ToLower(header.Name)
+	record := recordFromRR(rr, int(z.domain.ID))
+
+	if header.Rrtype == dns.TypeSOA {
+		soas := z.at(name, dns.TypeSOA)
+		if name != z.apex || len(soas) == 0 {
+			return
+		}
+		current, ok := soas[0].rr.(*dns.SOA)
+		if ok && !serialNewer(rr.(*dns.SOA).Serial, current.Serial) {
+			return
+		}
+		soas[0].replace(record, rr)
+		return
+	}
+
+	for _, entry := range z.at(name, dns.TypeANY) {
+		cname := strings.EqualFold(entry.record.Type, "CNAME")
+		if cname && header.Rrtype == dns.TypeCNAME {
+			entry.replace(record, rr)
+			return
+		}
+		if cname != (header.Rrtype == dns.TypeCNAME) {
+			return
+		}
+	}
+	for _, entry := range z.at(name, header.Rrtype) {
+		if entry.rr != nil && dns.IsDuplicate(entry.rr, rr) {
+			if entry.record.TTL != record.TTL {
+				entry.record.TTL = record.TTL
+				entry.changed = true
+			}
+			return
+		}
+	}
+	record.CreatedBy = dnsUpdateOwner
+	z.entries = append(z.entries, &updateEntry{record: record, rr: rr, added: true})
+}
+
+// bumpSerial advances the serial of the zone's SOA when other records
+// changed and the update did not set the SOA itself.
+func (z *updateZone) bumpSerial() {
+	changed := false
+	for _, entry := range z.entries {
+		if strings.EqualFold(entry.record.Type, "SOA") && entry.changed {
+			return
+		}
+		changed = changed || entry.added != entry.deleted || entry.changed
+	}
+	soas := z.at(z.apex, dns.TypeSOA)
+	if !changed || len(soas) == 0 {
+		return
+	}
+	fields := strings.Fields(soas[0].record.Content)
+	if len(fields) < 3 {
+		return
+	}
+	serial, err := strconv.ParseUint(fields[2], 10, 32)
+	if err != nil {
+		return
+	}
+	fields[2] = strconv.FormatUint(uint64(uint32(serial)+1), 10)
+	soas[0].record.Content = strings.Join(fields, " ")
+	soas[0].changed = true
+}
+
+// replace takes the data and TTL of record in place of the entry's.
+func (e *updateEntry) replace(record Record, rr dns.RR) {
+	e.record.Content = record.Content
+	e.record.Prio = record.Prio
+	e.record.TTL = record.TTL
+	e.rr = rr
+	e.changed = true
+}
+
+// save writes the changed entries through tx and returns how many there
+// were. Added records are checked like those from the API first.
+func (z *updateZone) save(tx *gorm.DB) (int, error) {
+	var problems []string
+	for _, entry := range z.entries {
+		if entry.added && !entry.deleted {
+			problems = append(problems, validateRecord(z.domain.Name, entry.record)...)
+		}
+	}
+	if len(problems) > 0 {
+		return 0, updateRejected(dns.RcodeRefused, "%s", strings.Join(problems, "; "))
+	}
+
+	changes := 0
+	for _, entry := range z.entries {
+		var err error
+		switch {
+		case entry.added && entry.deleted:
+			continue
+		case entry.deleted:
+			err = tx.Delete(&Record{}, entry.record.ID).Error
+		case entry.added:
+			err = tx.Omit(clause.Associations).Create(&entry.record).Error
+		case entry.changed:
+			err = tx.Omit(clause.Associations, "CreatedAt", "CreatedBy").Save(&entry.record).Error
+		default:
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to write %s %s: %w", entry.record.Name, entry.record.Type, err)
+		}
+		changes++
+	}
+	return changes, nil
+}
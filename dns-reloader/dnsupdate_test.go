@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestApplyUpdate(t *testing.T) {
+	rrs := func(t *testing.T, texts ...string) []dns.RR {
+		t.Helper()
+		var rrs []dns.RR
+		for _, text := range texts {
+			rr, err := dns.NewRR(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rrs = append(rrs, rr)
+		}
+		return rrs
+	}
+
+	tests := []struct {
+		name    string
+		update  func(t *testing.T, m *dns.Msg)
+		rcode   int
+		changes int
+		has     []string
+		lacks   []string
+	}{
+		{
+			name: "add a record",
+			update: func(t *testing.T, m *dns.Msg) {
+				m.Insert(rrs(t, "host.example.com. 60 IN A 192.0.2.9"))
+			},
+			changes: 2,
+			has:     []string{"host.example.com A 192.0.2.9 60", "www.example.com A 192.0.2.1 300"},
+		},
+		{
+			name: "duplicate takes the new TTL",
+			update: func(t *testing.T, m *dns.Msg) {
+				m.Insert(rrs(t, "www.example.com. 60 IN A 192.0.2.1"))
+			},
+			changes: 2,
+			has:     []string{"www.example.com A 192.0.2.1 60"},
+		},
+		{
+			name: "delete an RRset",
+			update: func(t *testing.T, m *dns.Msg) {
+				m.RemoveRRset(rrs(t, "www.example.com. 0 IN A 0.0.0.0"))
+			},
+			changes: 3,
+			lacks:   []string{"www.example.com A 192.0.2.1 300", "www.example.com A 192.0.2.2 300"},
+		},
+		{
+			name: "delete one record",
+			update: func(t *testing.T, m *dns.Msg) {
+				m.Remove(rrs(t, "www.example.com. 0 IN A 192.0.2.2"))
+			},
+			changes: 2,
+			has:     []string{"www.example.com A 192.0.2.1 300"},
+			lacks:   []string{"www.example.com A 192.0.2.2 300"},
+		},
+		{
+			name: "apex NS is kept",
+			update: func(t *testing.T, m *dns.Msg) {
+				m.RemoveRRset(rrs(t, "example.com. 0 IN NS ns1.example.net."))
+			},
+			has: []string{"example.com NS ns1.example.net. 3600"},
+		},
+		{
+			name: "CNAME beside other data is ignored",
+			update: func(t *testing.T, m *dns.Msg) {
+				m.Insert(rrs(t, "www.example.com. 300 IN CNAME example.com."))
+			},
+			lacks: []string{"www.example.com CNAME example.com. 300"},
+		},
+		{
+			name: "name in use",
+			update: func(t *testing.T, m *dns.Msg) {
+				m.NameUsed(rrs(t, "www.example.com. 0 IN A 0.0.0.0"))
+				m.Insert(rrs(t, "www.example.com. 300 IN TXT \"up\""))
+			},
+			changes: 2,
+			has:     []string{`www.example.com TXT "up" 300`},
+		},
+		{
+			name: "name not in use",
+			update: func(t *testing.T, m *dns.Msg) {
+				m.NameNotUsed(rrs(t, "www.example.com. 0 IN A 0.0.0.0"))
+				m.Insert(rrs(t, "www.example.com. 300 IN TXT \"up\""))
+			},
+			rcode: dns.RcodeYXDomain,
+			lacks: []string{`www.example.com TXT "up" 300`},
+		},
+		{
+			name: "missing name",
+			update: func(t *testing.T, m *dns.Msg) {
+				m.NameUsed(rrs(t, "gone.example.com. 0 IN A 0.0.0.0"))
+			},
+			rcode: dns.RcodeNameError,
+		},
+		{
+			name: "RRset differs",
+			update: func(t *testing.T, m *dns.Msg) {
+				m.Used(rrs(t, "www.example.com. 0 IN A 192.0.2.1"))
+				m.RemoveRRset(rrs(t, "www.example.com. 0 IN A 0.0.0.0"))
+			},
+			rcode: dns.RcodeNXRrset,
+			has:   []string{"www.example.com A 192.0.2.1 300", "www.example.com A 192.0.2.2 300"},
+		},
+		{
+			name: "RRset matches",
+			update: func(t *testing.T, m *dns.Msg) {
+				m.Used(rrs(t, "www.example.com. 0 IN A 192.0.2.1", "www.example.com. 0 IN A 192.0.2.2"))
+				m.RemoveRRset(rrs(t, "www.example.com. 0 IN A 0.0.0.0"))
+			},
+			changes: 3,
+			lacks:   []string{"www.example.com A 192.0.2.1 300"},
+		},
+		{
+			name: "outside the zone",
+			update: func(t *testing.T, m *dns.Msg) {
+				m.Insert(rrs(t, "www.example.org. 300 IN A 192.0.2.9"))
+			},
+			rcode: dns.RcodeNotZone,
+		},
+		{
+			name: "managed DNSSEC records",
+			update: func(t *testing.T, m *dns.Msg) {
+				m.Insert(rrs(t, "example.com. 300 IN NSEC www.example.com. A"))
+			},
+			rcode: dns.RcodeRefused,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReloader(t, map[string]string{"DOMAIN_NAMESERVERS": "ns1.example.net"})
+			ctx := t.Context()
+			domain := createTestZone(t, r, "example.com")
+			for _, content := range []string{"192.0.2.1", "192.0.2.2"} {
+				if _, err := r.createRecord(ctx, "example.com", recordRequest{Name: "www", Type: "A", Content: content}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			soa := storedRecords(t, r, domain, "SOA")
+
+			m := new(dns.Msg)
+			m.SetUpdate("example.com.")
+			tt.update(t, m)
+			changes, err := r.applyUpdate(ctx, domain, m.Answer, m.Ns, true)
+			rcode := dns.RcodeSuccess
+			var rejected *updateError
+			if errors.As(err, &rejected) {
+				rcode = rejected.rcode
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if rcode != tt.rcode || changes != tt.changes {
+				t.Fatalf("got %s with %d changes, want %s with %d (%v)", dns.RcodeToString[rcode], changes, dns.RcodeToString[tt.rcode], tt.changes, err)
+			}
+
+			stored := storedRecords(t, r, domain, "")
+			for _, want := range tt.has {
+				if !slices.Contains(stored, want) {
+					t.Errorf("missing %s in %q", want, stored)
+				}
+			}
+			for _, unwanted := range tt.lacks {
+				if slices.Contains(stored, unwanted) {
+					t.Errorf("unexpected %s in %q", unwanted, stored)
+				}
+			}
+			if bumped := !slices.Equal(storedRecords(t, r, domain, "SOA"), soa); bumped != (tt.changes > 0) {
+				t.Errorf("serial bumped %t with %d changes", bumped, tt.changes)
+			}
+		})
+	}
+}
+
+// storedRecords lists the records of domain, of rrtype if it is set, as
+// "name type content ttl".
+func storedRecords(t *testing.T, r *Reloader, domain *Domain, rrtype string) []string {
+	t.Helper()
+	query := r.db.Where("domain_id = ?", domain.ID)
+	if rrtype != "" {
+		query = query.Where("type = ?", rrtype)
+	}
+	var records []Record
+	if err := query.Order("id").Find(&records).Error; err != nil {
+		t.Fatal(err)
+	}
+	var stored []string
+	for _, record := range records {
+		stored = append(stored, strings.Join([]string{record.Name, record.Type, record.Content, strconv.Itoa(record.TTL)}, " "))
+	}
+	return stored
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var domainTypes = map[string]bool{"NATIVE": true, "MASTER": true, "SLAVE": true}
+
+// domainLabelPattern is a label of a domain name as the reloader accepts
+// it: letters, digits, hyphens and underscores, lowercased.
+var domainLabelPattern = regexp.MustCompile(`^[a-z0-9_-]{1,63}$`)
+
+type domainCreateRequest struct {
+	Name    string  `json:"name"`
+	Type    string  `json:"type"`
+	Master  *string `json:"master,omitempty"`
+	Account *string `json:"account,omitempty"`
+	// Seed defaults to true; set false to create the domain without the
+	// templated SOA and NS records.
+	Seed *bool `json:"seed,omitempty"`
+}
+
+type domainUpdateRequest struct {
+	Type    string  `json:"type"`
+	Master  *string `json:"master,omitempty"`
+	Account *string `json:"account,omitempty"`
+}
+
+// seedRecords builds the SOA and NS records for a new domain from the
+// DOMAIN_* template settings. The SOA names the first nameserver as primary;
+// without configured nameservers it falls back to ns1.<domain>.
+func (r *Reloader) seedRecords(domain *Domain) []Record {
+	primary := "ns1." + domain.Name + "."
+	if len(r.config.DomainNameservers) > 0 {
+		primary = dns.Fqdn(r.config.DomainNameservers[0])
+	}
+	hostmaster := r.config.DomainHostmaster
+	if hostmaster == "" {
+		hostmaster = "hostmaster." + domain.Name
+	}
+	serial := time.Now().UTC().Format("20060102") + "01"
+
+	records := []Record{{
+		DomainID:  int(domain.ID),
+		Name:      domain.Name,
+		Type:      "SOA",
+		Content:   fmt.Sprintf("%s %s %s %s", primary, dns.Fqdn(strings.Replace(hostmaster, "@", ".", 1)), serial, r.config.DomainSOATimers),
+		TTL:       r.config.DomainDefaultTTL,
+		Auth:      true,
+		CreatedBy: "api",
+	}}
+	for _, ns := range r.config.DomainNameservers {
+		records = append(records, Record{
+			DomainID:  int(domain.ID),
+			Name:      domain.Name,
+			Type:      "NS",
+			Content:   dns.Fqdn(ns),
+			TTL:       r.config.DomainDefaultTTL,
+			Auth:      true,
+			CreatedBy: "api",
+		})
+	}
+	return records
+}
+
+func (r *Reloader) listDomains(ctx context.Context) ([]Domain, error) {
+	var domains []Domain
+	if err := scopeAccount(ctx, r.db.WithContext(ctx)).Order("name").Find(&domains).Error; err != nil {
+		return nil, err
+	}
+	if err := r.loadZoneOptions(ctx, r.db, domains); err != nil {
+		return nil, err
+	}
+	if err := r.loadDNSSECKeys(ctx, r.db, domains); err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+// validateDomainName checks a lowercased domain name without its final
+// dot. Its labels may only hold letters, digits, hyphens and underscores,
+// up to 63 bytes each and 253 in all, as the name ends up in zone file
+// paths and reload commands.
+func validateDomainName(name string) error {
+	if name == "" || len(name) > 253 {
+		return fmt.Errorf("invalid domain name %q: it must be 1 to 253 bytes long", name)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if !domainLabelPattern.MatchString(label) {
+			return fmt.Errorf("invalid domain name %q: label %q must be 1 to 63 letters, digits, - or _", name, label)
+		}
+	}
+	return nil
+}
+
+func validateDomainFields(domain *Domain, rawType string) error {
+	var problems []string
+	if !domainTypes[domain.Type] {
+		problems = append(problems, fmt.Sprintf("unknown domain type %q", rawType))
+	}
+	if domain.Type == "SLAVE" && (domain.Master == nil || *domain.Master == "") {
+		problems = append(problems, "SLAVE domains require a master")
+	}
+	if len(problems) > 0 {
+		return errValidation(problems)
+	}
+	return nil
+}
+
+func (r *Reloader) createDomain(ctx context.Context, body domainCreateRequest) (*Domain, []Record, error) {
+	account, err := accountFor(ctx, body.Account)
+	if err != nil {
+		return nil, nil, err
+	}
+	domain := Domain{
+		Name:    strings.TrimSuffix(strings.ToLower(strings.TrimSpace(body.Name)), "."),
+		Type:    strings.ToUpper(body.Type),
+		Master:  body.Master,
+		Account: account,
+	}
+	if domain.Type == "" {
+		domain.Type = "NATIVE"
+	}
+
+	if err := validateDomainName(domain.Name); err != nil {
+		return nil, nil, errValidation([]string{err.Error()})
+	}
+	if err := validateDomainFields(&domain, body.Type); err != nil {
+		return nil, nil, err
+	}
+
+	var existing int64
+	if err := r.db.WithContext(ctx).Model(&Domain{}).Where("name = ?", domain.Name).Count(&existing).Error; err != nil {
+		return nil, nil, err
+	}
+	if existing > 0 {
+		return nil, nil, errConflict("domain already exists")
+	}
+
+	var seeded []Record
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&domain).Error; err != nil {
+			return err
+		}
+		if body.Seed != nil && !*body.Seed {
+			return nil
+		}
+		seeded = r.seedRecords(&domain)
+		return tx.Omit(clause.Associations).Create(&seeded).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.domainChanged(&domain, "INSERT")
+	return &domain, seeded, nil
+}
+
+func (r *Reloader) updateDomain(ctx context.Context, ref string, body domainUpdateRequest) (*Domain, error) {
+	domain, err := r.findDomain(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if body.Type != "" {
+		domain.Type = strings.ToUpper(body.Type)
+	}
+	domain.Master = body.Master
+	if domain.Account, err = accountFor(ctx, body.Account); err != nil {
+		return nil, err
+	}
+	if err := validateDomainFields(domain, body.Type); err != nil {
+		return nil, err
+	}
+
+	if err := r.db.WithContext(ctx).Model(domain).Omit(clause.Associations).
+		Updates(map[string]interface{}{"type": domain.Type, "master": domain.Master, "account": domain.Account}).Error; err != nil {
+		return nil, err
+	}
+	r.domainChanged(domain, "UPDATE")
+	return domain, nil
+}
+
+// deleteDomain removes the domain (its records cascade) and its zone file,
+// since regeneration only ever writes zones that still exist.
+func (r *Reloader) deleteDomain(ctx context.Context, ref string) error {
+	domain, err := r.findDomain(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	if err := r.db.WithContext(ctx).Delete(&Domain{}, domain.ID).Error; err != nil {
+		return err
+	}
+
+	if err := os.Remove(r.zoneFilePath(domain.Name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		r.logger.WithError(err).WithField("domain", domain.Name).Error("Failed to remove zone file for deleted domain")
+	}
+	if err := os.Remove(r.dsSetPath(domain.Name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		r.logger.WithError(err).WithField("domain", domain.Name).Warn("Failed to remove DS set for deleted domain")
+	}
+	zoneDrift.DeleteLabelValues(domain.Name)
+
+	r.domainChanged(domain, "DELETE")
+	return nil
+}
+
+func (r *Reloader) domainChanged(domain *Domain, action string) {
+	r.apiChanged(&DNSChangeNotification{
+		Table:     "domains",
+		Action:    action,
+		ID:        int(domain.ID),
+		DomainID:  int(domain.ID),
+		Name:      domain.Name,
+		Timestamp: time.Now(),
+	})
+}
+
+func (r *Reloader) handleListDomains(w http.ResponseWriter, req *http.Request) {
+	domains, err := r.listDomains(req.Context())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"domains": domains,
+	})
+}
+
+func (r *Reloader) handleGetDomain(w http.ResponseWriter, req *http.Request) {
+	domain, err := r.findDomain(req.Context(), req.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, domain)
+}
+
+func (r *Reloader) handleCreateDomain(w http.ResponseWriter, req *http.Request) {
+	var body domainCreateRequest
+	if err := readJSON(req, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	domain, seeded, err := r.createDomain(req.Context(), body)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"domain":  domain,
+		"records": seeded,
+	})
+}
+
+func (r *Reloader) handleUpdateDomain(w http.ResponseWriter, req *http.Request) {
+	var body domainUpdateRequest
+	if err := readJSON(req, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	domain, err := r.updateDomain(req.Context(), req.PathValue("id"), body)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, domain)
+}
+
+func (r *Reloader) handleDeleteDomain(w http.ResponseWriter, req *http.Request) {
+	if err := r.deleteDomain(req.Context(), req.PathValue("id")); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
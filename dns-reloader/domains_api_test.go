@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDomainNameValidation(t *testing.T) {
+	r := newTestReloader(t, nil)
+	ctx := t.Context()
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"example.com", true},
+		{"_dmarc.example.com", true},
+		{"2.0.192.in-addr.arpa", true},
+		{"xn--bcher-kva.example", true},
+		{strings.Repeat("a", 63) + ".example", true},
+		{strings.Repeat("a", 64) + ".example", false},
+		{strings.Repeat(strings.Repeat("a", 63)+".", 4) + "com", false},
+		{"", false},
+		{"example..com", false},
+		{"ex ample.com", false},
+		{"example.com;reboot", false},
+		{"$(reboot).example.com", false},
+		{"../etc.example.com", false},
+		{"example.com/zone", false},
+		{"*.example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateDomainName(tt.name); (err == nil) != tt.ok {
+				t.Fatalf("validateDomainName: %v, want ok %t", err, tt.ok)
+			}
+			if tt.ok {
+				return
+			}
+			if _, _, err := r.createDomain(ctx, domainCreateRequest{Name: tt.name, Type: "NATIVE"}); err == nil {
+				t.Error("createDomain accepted it")
+			}
+			if _, _, err := r.loadTransferredZone(ctx, tt.name, "NATIVE", false, nil); err == nil {
+				t.Error("loadTransferredZone created it")
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// checkDrift re-renders every zone in memory and compares it with the file
+// on disk, catching hand edits and partial writes that the change-driven
+// path never revisits. Called on every listener or polling tick.
+func (r *Reloader) checkDrift() {
+	if r.config.DriftCheckInterval <= 0 || time.Since(r.lastDriftCheck) < r.config.DriftCheckInterval {
+		return
+	}
+	r.lastDriftCheck = time.Now()
+
+	drifted, err := r.detectDrift(r.ctx)
+	if err != nil {
+		r.logger.WithError(err).Error("Zone drift check failed")
+		return
+	}
+	if len(drifted) == 0 {
+		r.logger.Debug("Zone drift check found no differences")
+		return
+	}
+	if !r.config.DriftAutoHeal {
+		return
+	}
+
+	r.logger.WithField("zones", drifted).Info("Rewriting drifted zone files")
+	change := &DNSChangeNotification{
+		Table:     "drift",
+		Action:    "DRIFT_HEAL",
+		Timestamp: time.Now(),
+	}
+	if err := r.triggerCoreReload(r.ctx, change); err != nil {
+		r.logger.WithError(err).Error("Failed to heal drifted zones")
+	}
+}
+
+// detectDrift returns the zones whose file on disk does not match what the
+// database renders to. The canary line is ignored.
+func (r *Reloader) detectDrift(ctx context.Context) ([]string, error) {
+	var domains []Domain
+	if err := r.db.WithContext(ctx).Find(&domains).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch domains: %w", err)
+	}
+	if err := r.loadZoneOptions(ctx, r.db, domains); err != nil {
+		return nil, err
+	}
+	if err := r.loadDNSSECKeys(ctx, r.db, domains); err != nil {
+		return nil, err
+	}
+	if err := r.loadHealth(ctx, r.db); err != nil {
+		return nil, err
+	}
+
+	var drifted []string
+	for _, domain := range domains {
+		if !r.generates(domain) {
+			continue
+		}
+		var records []Record
+		if err := r.db.WithContext(ctx).Where("domain_id = ?", domain.ID).Find(&records).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch records for %s: %w", domain.Name, err)
+		}
+
+		actual := "missing"
+		var body string
+		existing, err := os.ReadFile(r.viewZoneFilePath(domain.Name, domain.Options))
+		switch {
+		case err == nil:
+			unsigned, _, _ := splitSignatures(string(existing))
+			body, _ = r.splitCanary(unsigned)
+			actual = zoneHash(body)
+		case !errors.Is(err, fs.ErrNotExist):
+			return nil, fmt.Errorf("failed to read zone file for %s: %w", domain.Name, err)
+		}
+		_, expected := r.renderZoneFile(domain, zoneRecords(records, domain.Options), body)
+
+		drift := actual != expected.Hash
+		r.status.recordDrift(domain.Name, drift)
+		if !drift {
+			zoneDrift.WithLabelValues(domain.Name).Set(0)
+			r.alertRecovered("drift", domain.Name)
+			continue
+		}
+
+		zoneDrift.WithLabelValues(domain.Name).Set(1)
+		zoneDriftDetected.Inc()
+		drifted = append(drifted, domain.Name)
+		r.logger.WithFields(logrus.Fields{
+			"domain":   domain.Name,
+			"path":     r.viewZoneFilePath(domain.Name, domain.Options),
+			"expected": expected.Hash,
+			"actual":   actual,
+		}).Warn("Zone file on disk has drifted from the database")
+		r.alertFailure("drift", domain.Name, fmt.Errorf("zone file hash %s does not match rendered %s", actual, expected.Hash))
+	}
+	return drifted, nil
+}
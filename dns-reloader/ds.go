@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+)
+
+// zoneDS returns the DS data the parent of a signed zone should hold: a
+// digest of each key-signing key that signs it, and of any in a rollover,
+// in every dsDigestTypes digest. Unlike delegationKeys it covers keys read
+// from DNSSEC_KEY_DIRECTORY too, and for zones signed externally the
+// key-signing keys published in the zone file.
+func (r *Reloader) zoneDS(domain Domain) ([]DelegationKey, error) {
+	var dnskeys []*dns.DNSKEY
+	if domain.Options.Signer == signerExternal {
+		rrs, err := parseZoneFile(r.zoneFilePath(domain.Name), domain.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, rr := range rrs {
+			if key, ok := rr.(*dns.DNSKEY); ok && key.Flags&dns.SEP != 0 {
+				dnskeys = append(dnskeys, key)
+			}
+		}
+	} else {
+		keys, err := r.signingKeys(domain)
+		if err != nil {
+			return nil, err
+		}
+		ksks, _ := splitKeys(keys)
+		dnskeys = parentKeys(domain, ksks)
+	}
+	var ds []DelegationKey
+	for _, key := range dnskeys {
+		for _, digestType := range dsDigestTypes {
+			if digest := key.ToDS(digestType); digest != nil {
+				ds = append(ds, delegationKey(key, digest))
+			}
+		}
+	}
+	return ds, nil
+}
+
+// parentKeys returns the DNSKEYs the parent should have a DS for: those of
+// the key-signing keys and those of the keys marked ParentDS.
+func parentKeys(domain Domain, ksks []signingKey) []*dns.DNSKEY {
+	keys := make([]*dns.DNSKEY, 0, len(ksks))
+	for _, key := range ksks {
+		keys = append(keys, key.DNSKEY)
+	}
+	for _, key := range domain.Keys {
+		if key.ParentDS {
+			keys = append(keys, key.DNSKEY)
+		}
+	}
+	return keys
+}
+
+// dsRecord formats key as a DS record for zone, as the parent would
+// publish it.
+func dsRecord(zone string, key DelegationKey) string {
+	return fmt.Sprintf("%s IN DS %d %d %d %s", dns.Fqdn(zone), key.KeyTag, key.Algorithm, key.DigestType, key.Digest)
+}
+
+// dsSetPath is where a signed zone's DS records are exported, named as
+// dnssec-signzone names its dsset files.
+func (r *Reloader) dsSetPath(zone string) string {
+	return filepath.Join(r.config.ZonesDirectory, "dsset-"+zone+".")
+}
+
+// exportDS writes the DS records of a signed zone to its dsset file, and
+// logs them whenever they change so the parent can be updated. The file of
+// a zone that is no longer signed is removed.
+func (r *Reloader) exportDS(domain Domain) error {
+	path := r.dsSetPath(domain.Name)
+	if !domain.Options.Sign {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		} else if err == nil {
+			r.logger.WithField("domain", domain.Name).Info("Zone is no longer signed; remove its DS records from the parent zone")
+		}
+		return nil
+	}
+
+	keys, err := r.zoneDS(domain)
+	if err != nil {
+		return err
+	}
+	records := make([]string, len(keys))
+	for i, key := range keys {
+		records[i] = dsRecord(domain.Name, key)
+	}
+	content := strings.Join(records, "\n") + "\n"
+	if existing, err := os.ReadFile(path); err == nil && string(existing) == content {
+		return nil
+	}
+
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write DS set: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		return fmt.Errorf("failed to move DS set: %w", err)
+	}
+	r.logger.WithFields(logrus.Fields{
+		"domain": domain.Name,
+		"path":   path,
+		"ds":     records,
+	}).Info("DS records changed; update the parent zone")
+	return nil
+}
+
+// handleDS serves GET /api/v1/domains/{id}/ds: the DS records the parent of
+// a signed zone should publish.
+func (r *Reloader) handleDS(w http.ResponseWriter, req *http.Request) {
+	domain, err := r.findDomain(req.Context(), req.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if !r.zoneOptions(domain.Name).Sign {
+		writeAPIError(w, errConflict(fmt.Sprintf("%s is not signed", domain.Name)))
+		return
+	}
+	domains := []Domain{*domain}
+	if err := r.loadDNSSECKeys(req.Context(), r.db, domains); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	domains[0].Options = r.zoneOptions(domain.Name)
+	keys, err := r.zoneDS(domains[0])
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	records := make([]string, len(keys))
+	for i, key := range keys {
+		records[i] = dsRecord(domain.Name, key)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"domain":  domain.Name,
+		"ds":      keys,
+		"records": records,
+	})
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// dyndnsOwner is the created_by of records written through /nic/update.
+const dyndnsOwner = "dyndns"
+
+// DynDNSHost is a row of the dyndns_hosts table: a hostname that may be
+// updated through /nic/update with its own credentials, and nothing else.
+type DynDNSHost struct {
+	ID         int        `gorm:"primaryKey;column:id"`
+	DomainID   int        `gorm:"column:domain_id"`
+	Hostname   string     `gorm:"column:hostname"`
+	Username   string     `gorm:"column:username"`
+	SecretHash string     `gorm:"column:secret_hash"`
+	CreatedAt  time.Time  `gorm:"column:created_at"`
+	UpdatedAt  *time.Time `gorm:"column:updated_at;autoUpdateTime:false"`
+}
+
+func (DynDNSHost) TableName() string {
+	return "dyndns_hosts"
+}
+
+// hashSecret is how DynDNS and API token secrets are stored. They are
+// generated with enough entropy that a plain SHA-256 is enough, and it
+// lets a bearer token be looked up without a username.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// createDynDNSHost allows hostname to be updated through /nic/update and
+// returns its generated secret. The username defaults to the hostname.
+func (r *Reloader) createDynDNSHost(ctx context.Context, hostname, username string) (*DynDNSHost, string, error) {
+	if !r.dyndns {
+		return nil, "", errConflict("the database has no dyndns_hosts table")
+	}
+	hostname = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(hostname)), ".")
+	if username == "" {
+		username = hostname
+	}
+	var domains []Domain
+	if err := r.db.WithContext(ctx).Find(&domains).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to fetch domains: %w", err)
+	}
+	domain := zoneFor(hostname, domains)
+	if domain == nil {
+		return nil, "", errNotFound(fmt.Sprintf("no domain contains %s", hostname))
+	}
+	if err := r.checkWritable(domain); err != nil {
+		return nil, "", err
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	secret := base64.RawURLEncoding.EncodeToString(buf)
+	host := DynDNSHost{
+		DomainID:   int(domain.ID),
+		Hostname:   hostname,
+		Username:   username,
+		SecretHash: hashSecret(secret),
+		CreatedAt:  time.Now(),
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&DynDNSHost{}).Where("hostname = ? OR username = ?", hostname, username).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return errConflict(fmt.Sprintf("%s or user %s already has DynDNS credentials", hostname, username))
+		}
+		return tx.Create(&host).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &host, secret, nil
+}
+
+// deleteDynDNSHost revokes the credentials of hostname. Its records stay.
+func (r *Reloader) deleteDynDNSHost(ctx context.Context, hostname string) error {
+	if !r.dyndns {
+		return errConflict("the database has no dyndns_hosts table")
+	}
+	hostname = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(hostname)), ".")
+	result := r.db.WithContext(ctx).Where("hostname = ?", hostname).Delete(&DynDNSHost{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errNotFound(fmt.Sprintf("%s has no DynDNS credentials", hostname))
+	}
+	return nil
+}
+
+// dyndnsHost returns the host whose credentials req carries: basic auth,
+// as dyndns2 clients send it, or the secret alone as a bearer token.
+func (r *Reloader) dyndnsHost(req *http.Request) *DynDNSHost {
+	username, secret, ok := req.BasicAuth()
+	if !ok {
+		token, found := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !found {
+			return nil
+		}
+		username, secret = "", strings.TrimSpace(token)
+	}
+	if secret == "" {
+		return nil
+	}
+	query := r.db.WithContext(req.Context()).Where("secret_hash = ?", hashSecret(secret))
+	if username != "" {
+		query = query.Where("username = ?", username)
+	}
+	var host DynDNSHost
+	if err := query.First(&host).Error; err != nil {
+		return nil
+	}
+	return &host
+}
+
+// handleNicUpdate serves /nic/update with the dyndns2 protocol, so routers
+// and agents can point their own hostname at their address. The
+// credentials are scoped to one hostname. myip may hold an IPv4 and an
+// IPv6 address, separated by a comma; without a valid one the address of
+// the client is used. Each family given replaces the A or AAAA records at
+// the hostname. Answers are the plain text codes clients expect: good,
+// nochg, badauth, notfqdn, nohost, numhost, dnserr and 911.
+func (r *Reloader) handleNicUpdate(w http.ResponseWriter, req *http.Request) {
+	reply := func(status int, code string) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		fmt.Fprintln(w, code)
+	}
+	if !r.dyndns {
+		reply(http.StatusServiceUnavailable, "911")
+		return
+	}
+	host := r.dyndnsHost(req)
+	if host == nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="dyndns"`)
+		reply(http.StatusUnauthorized, "badauth")
+		return
+	}
+
+	query := req.URL.Query()
+	hostnames := parseList(query.Get("hostname"))
+	logger := r.logger.WithFields(logrus.Fields{"hostname": host.Hostname, "user": host.Username})
+	switch {
+	case len(hostnames) == 0:
+		reply(http.StatusOK, "notfqdn")
+		return
+	case len(hostnames) > 1:
+		reply(http.StatusOK, "numhost")
+		return
+	case strings.TrimSuffix(strings.ToLower(hostnames[0]), ".") != host.Hostname:
+		logger.WithField("requested", hostnames[0]).Warn("Refused DynDNS update for another hostname")
+		reply(http.StatusOK, "nohost")
+		return
+	}
+
+	addrs := dyndnsAddrs(query.Get("myip"), req.RemoteAddr)
+	changed, err := r.updateDynDNSHost(req.Context(), host, addrs)
+	if err != nil {
+		logger.WithError(err).Error("Failed to apply DynDNS update")
+		reply(http.StatusOK, "dnserr")
+		return
+	}
+	values := make([]string, len(addrs))
+	for i, addr := range addrs {
+		values[i] = addr.String()
+	}
+	if !changed {
+		reply(http.StatusOK, "nochg "+strings.Join(values, ","))
+		return
+	}
+	logger.WithField("addresses", values).Info("Applied DynDNS update")
+	reply(http.StatusOK, "good "+strings.Join(values, ","))
+}
+
+// dyndnsAddrs reads myip, keeping at most one address of each family, and
+// falls back to the client's address.
+func dyndnsAddrs(myip, remote string) []netip.Addr {
+	var v4, v6 *netip.Addr
+	for _, value := range parseList(myip) {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			continue
+		}
+		addr = addr.Unmap()
+		if addr.Is4() && v4 == nil {
+			v4 = &addr
+		} else if addr.Is6() && v6 == nil {
+			v6 = &addr
+		}
+	}
+	var addrs []netip.Addr
+	for _, addr := range []*netip.Addr{v4, v6} {
+		if addr != nil {
+			addrs = append(addrs, *addr)
+		}
+	}
+	if len(addrs) == 0 {
+		if addrPort, err := netip.ParseAddrPort(remote); err == nil {
+			addrs = append(addrs, addrPort.Addr().Unmap())
+		}
+	}
+	return addrs
+}
+
+// updateDynDNSHost points host's A or AAAA records at addrs in one
+// transaction, and reports whether anything changed.
+func (r *Reloader) updateDynDNSHost(ctx context.Context, host *DynDNSHost, addrs []netip.Addr) (bool, error) {
+	if len(addrs) == 0 {
+		return false, errors.New("no address to update to")
+	}
+	var domain Domain
+	if err := r.db.WithContext(ctx).First(&domain, host.DomainID).Error; err != nil {
+		return false, fmt.Errorf("failed to fetch domain: %w", err)
+	}
+	if err := r.checkWritable(&domain); err != nil {
+		return false, err
+	}
+
+	var changedTypes []string
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, addr := range addrs {
+			rrtype := "A"
+			if addr.Is6() {
+				rrtype = "AAAA"
+			}
+			var existing []Record
+			if err := tx.Where("domain_id = ? AND name = ? AND type = ?", domain.ID, host.Hostname, rrtype).Find(&existing).Error; err != nil {
+				return err
+			}
+			if len(existing) == 1 && existing[0].Content == addr.String() && !existing[0].Disabled {
+				continue
+			}
+			for _, record := range existing {
+				if err := tx.Delete(&Record{}, record.ID).Error; err != nil {
+					return err
+				}
+			}
+			ttl := r.config.DynDNSTTL
+			record := Record{CreatedBy: dyndnsOwner}
+			body := recordRequest{Name: host.Hostname + ".", Type: rrtype, Content: addr.String(), TTL: &ttl}
+			if err := applyRecordRequest(ctx, tx, &domain, &record, body); err != nil {
+				return err
+			}
+			if err := tx.Omit(clause.Associations).Create(&record).Error; err != nil {
+				return err
+			}
+			changedTypes = append(changedTypes, rrtype)
+		}
+		now := time.Now()
+		return tx.Model(host).Update("updated_at", &now).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, rrtype := range changedTypes {
+		r.apiChanged(&DNSChangeNotification{
+			Table:     "records",
+			Action:    "DYNDNS",
+			DomainID:  int(domain.ID),
+			Name:      host.Hostname,
+			Type:      rrtype,
+			Timestamp: time.Now(),
+		})
+	}
+	return len(changedTypes) > 0, nil
+}
+
+// dyndnsCommand runs the dyndns subcommands, add, list and delete.
+func (r *Reloader) dyndnsCommand(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "add":
+			return r.dyndnsAdd(args[1:])
+		case "list":
+			return r.dyndnsList()
+		case "delete":
+			if len(args) != 2 {
+				return errors.New("usage: reloader dyndns delete <hostname>")
+			}
+			if err := r.connectDB(); err != nil {
+				return err
+			}
+			if err := r.deleteDynDNSHost(r.ctx, args[1]); err != nil {
+				return err
+			}
+			r.logger.WithField("hostname", args[1]).Info("Deleted DynDNS credentials")
+			return nil
+		}
+	}
+	return errors.New("usage: reloader dyndns add [-user name] <hostname>\n       reloader dyndns list\n       reloader dyndns delete <hostname>")
+}
+
+// dyndnsAdd runs dyndns add and prints the credentials once.
+func (r *Reloader) dyndnsAdd(args []string) error {
+	fs := flag.NewFlagSet("dyndns add", flag.ContinueOnError)
+	user := fs.String("user", "", "username for basic auth (default the hostname)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: reloader dyndns add [flags] <hostname>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("dyndns add takes one hostname")
+	}
+	if err := r.connectDB(); err != nil {
+		return err
+	}
+
+	host, secret, err := r.createDynDNSHost(r.ctx, fs.Arg(0), *user)
+	if err != nil {
+		return err
+	}
+	r.logger.WithFields(logrus.Fields{
+		"hostname": host.Hostname,
+		"user":     host.Username,
+	}).Info("Created DynDNS credentials")
+	fmt.Printf("%s:%s\n", host.Username, secret)
+	return nil
+}
+
+// dyndnsList runs dyndns list. Secrets cannot be shown; only their hashes
+// are stored.
+func (r *Reloader) dyndnsList() error {
+	if err := r.connectDB(); err != nil {
+		return err
+	}
+	if !r.dyndns {
+		return errors.New("the database has no dyndns_hosts table")
+	}
+	var hosts []DynDNSHost
+	if err := r.db.WithContext(r.ctx).Order("hostname").Find(&hosts).Error; err != nil {
+		return fmt.Errorf("failed to read dyndns_hosts: %w", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOSTNAME\tUSER\tLAST UPDATE")
+	for _, host := range hosts {
+		updated := "never"
+		if host.UpdatedAt != nil {
+			updated = host.UpdatedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", host.Hostname, host.Username, updated)
+	}
+	return w.Flush()
+}
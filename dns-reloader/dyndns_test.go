@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNicUpdate(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		password string
+		reply    string
+		has      map[string]string
+	}{
+		{
+			name:  "IPv4 address",
+			query: "hostname=home.example.com&myip=192.0.2.7",
+			reply: "good 192.0.2.7",
+			has:   map[string]string{"A": "192.0.2.7"},
+		},
+		{
+			name:  "IPv6 address",
+			query: "hostname=home.example.com&myip=2001:db8::7",
+			reply: "good 2001:db8::7",
+			has:   map[string]string{"AAAA": "2001:db8::7"},
+		},
+		{
+			name:  "both families",
+			query: "hostname=home.example.com&myip=192.0.2.7,2001:db8::7",
+			reply: "good 192.0.2.7,2001:db8::7",
+			has:   map[string]string{"A": "192.0.2.7", "AAAA": "2001:db8::7"},
+		},
+		{
+			name:     "wrong password",
+			query:    "hostname=home.example.com&myip=192.0.2.7",
+			password: "wrong",
+			reply:    "badauth",
+		},
+		{
+			name:  "another hostname",
+			query: "hostname=work.example.com&myip=192.0.2.7",
+			reply: "nohost",
+		},
+		{
+			name:  "no hostname",
+			query: "myip=192.0.2.7",
+			reply: "notfqdn",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReloader(t, nil)
+			createTestZone(t, r, "example.com")
+			host, secret, err := r.createDynDNSHost(t.Context(), "home.example.com", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.password != "" {
+				secret = tt.password
+			}
+
+			req := httptest.NewRequest("GET", "/nic/update?"+tt.query, nil)
+			req.SetBasicAuth(host.Username, secret)
+			w := httptest.NewRecorder()
+			r.handleNicUpdate(w, req)
+			if reply := strings.TrimSpace(w.Body.String()); reply != tt.reply {
+				t.Fatalf("replied %q, want %q", reply, tt.reply)
+			}
+			applyLocalChanges(r)
+
+			if len(tt.has) == 0 {
+				return
+			}
+			zone := readZoneFile(t, r, "example.com")
+			for rrtype, value := range tt.has {
+				if !zoneHasRecord(zone, rrtype, value) {
+					t.Errorf("%s %s missing from the zone file:\n%s", rrtype, value, zone)
+				}
+			}
+		})
+	}
+}
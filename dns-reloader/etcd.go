@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// etcdOwner is the created_by of records mirrored from etcd.
+const etcdOwner = "etcd"
+
+// etcdDefaultTTL is used for services that do not set a ttl.
+const etcdDefaultTTL = 300
+
+// etcdSource is a CoreDNS etcd plugin tree: SkyDNS service entries under
+// ETCD_PATH, keyed by the reversed labels of their name, so
+// /skydns/test/lab/www holds www.lab.test.
+type etcdSource struct {
+	client *clientv3.Client
+	prefix string
+}
+
+func newEtcdSource(config *Config) (*etcdSource, error) {
+	var tlsConfig *tls.Config
+	if config.EtcdCACert != "" || config.EtcdCert != "" {
+		info := transport.TLSInfo{
+			CertFile:      config.EtcdCert,
+			KeyFile:       config.EtcdKey,
+			TrustedCAFile: config.EtcdCACert,
+		}
+		var err error
+		if tlsConfig, err = info.ClientConfig(); err != nil {
+			return nil, fmt.Errorf("failed to load etcd TLS config: %w", err)
+		}
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   config.EtcdEndpoints,
+		Username:    config.EtcdUsername,
+		Password:    config.EtcdPassword,
+		TLS:         tlsConfig,
+		DialTimeout: 10 * time.Second,
+		Logger:      zap.NewNop(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create etcd client: %w", err)
+	}
+	return &etcdSource{client: client, prefix: strings.TrimSuffix(config.EtcdPath, "/") + "/"}, nil
+}
+
+// skydnsService is the JSON value of one etcd key, as read by the CoreDNS
+// etcd plugin.
+type skydnsService struct {
+	Host     string `json:"host,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+	Weight   int    `json:"weight,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Mail     bool   `json:"mail,omitempty"`
+	TTL      int    `json:"ttl,omitempty"`
+}
+
+// records converts the service to the records the etcd plugin would answer
+// with for name, stored the way PowerDNS stores them: an IP host is an A or
+// AAAA record, a name host is a CNAME, or an MX or SRV when mail or port is
+// set, and text is a TXT record.
+func (s skydnsService) records(domainID int, name string) []Record {
+	ttl := s.TTL
+	if ttl <= 0 {
+		ttl = etcdDefaultTTL
+	}
+	record := func(rrtype, content string, prio *int) Record {
+		return Record{DomainID: domainID, Name: name, Type: rrtype, Content: content, TTL: ttl, Prio: prio, Auth: true}
+	}
+
+	var records []Record
+	if ip := net.ParseIP(s.Host); ip != nil {
+		if ip.To4() != nil {
+			records = append(records, record("A", ip.String(), nil))
+		} else {
+			records = append(records, record("AAAA", ip.String(), nil))
+		}
+	} else if s.Host != "" {
+		target := strings.ToLower(dns.Fqdn(s.Host))
+		priority := s.Priority
+		switch {
+		case s.Mail:
+			records = append(records, record("MX", target, &priority))
+		case s.Port > 0:
+			records = append(records, record("SRV", fmt.Sprintf("%d %d %s", s.Weight, s.Port, target), &priority))
+		default:
+			records = append(records, record("CNAME", target, nil))
+		}
+	}
+	if s.Text != "" {
+		records = append(records, record("TXT", s.Text, nil))
+	}
+	return records
+}
+
+// etcdKeyName converts a key under prefix to the domain name it holds.
+func etcdKeyName(prefix, key string) string {
+	labels := strings.Split(strings.Trim(strings.TrimPrefix(key, prefix), "/"), "/")
+	slices.Reverse(labels)
+	return strings.ToLower(strings.Join(labels, "."))
+}
+
+// syncEtcd reads the whole etcd tree and mirrors it into the records
+// table, returning the revision it read at. Keys outside every domain are
+// ignored.
+func (r *Reloader) syncEtcd(ctx context.Context) (int64, error) {
+	resp, err := r.etcd.client.Get(ctx, r.etcd.prefix, clientv3.WithPrefix())
+	if err != nil {
+		sourceSyncs.WithLabelValues(etcdOwner, "failed").Inc()
+		return 0, fmt.Errorf("failed to read etcd: %w", err)
+	}
+
+	var domains []Domain
+	if err := r.db.WithContext(ctx).Find(&domains).Error; err != nil {
+		sourceSyncs.WithLabelValues(etcdOwner, "failed").Inc()
+		return 0, fmt.Errorf("failed to fetch domains: %w", err)
+	}
+
+	var desired []Record
+	for _, kv := range resp.Kvs {
+		name := etcdKeyName(r.etcd.prefix, string(kv.Key))
+		domain := zoneFor(name, domains)
+		if domain == nil {
+			continue
+		}
+		var service skydnsService
+		if err := json.Unmarshal(kv.Value, &service); err != nil {
+			r.logger.WithError(err).WithField("key", string(kv.Key)).Warn("Ignoring etcd key that is not a SkyDNS service")
+			continue
+		}
+		desired = append(desired, service.records(int(domain.ID), name)...)
+	}
+
+	if err := r.mirrorRecords(ctx, etcdOwner, desired); err != nil {
+		return 0, err
+	}
+	return resp.Header.Revision, nil
+}
+
+// watchEtcd keeps the mirrored records in step with etcd: a full sync, then
+// a watch from the revision it read, resyncing on every batch of events.
+// It also resyncs every ETCD_RESYNC_INTERVAL so keys for domains created
+// since are picked up, and starts over after a failed sync or a broken
+// watch.
+func (r *Reloader) watchEtcd() {
+	r.logger.WithField("path", r.etcd.prefix).Info("Watching etcd for DNS changes")
+	for r.ctx.Err() == nil {
+		revision, err := r.syncEtcd(r.ctx)
+		if err != nil {
+			r.logger.WithError(err).Error("Failed to sync records from etcd")
+			select {
+			case <-r.ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+		r.followEtcd(revision)
+	}
+}
+
+// followEtcd watches from revision until the watch breaks, a sync fails or
+// the reloader stops.
+func (r *Reloader) followEtcd(revision int64) {
+	ctx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+	watch := r.etcd.client.Watch(clientv3.WithRequireLeader(ctx), r.etcd.prefix, clientv3.WithPrefix(), clientv3.WithRev(revision+1))
+	resync := time.NewTicker(r.config.EtcdResync)
+	defer resync.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case resp, ok := <-watch:
+			if !ok {
+				return
+			}
+			if err := resp.Err(); err != nil {
+				r.logger.WithError(err).Warn("etcd watch failed, resyncing")
+				return
+			}
+			if len(resp.Events) == 0 {
+				continue
+			}
+		case <-resync.C:
+		}
+		if _, err := r.syncEtcd(ctx); err != nil {
+			r.logger.WithError(err).Error("Failed to sync records from etcd")
+			return
+		}
+	}
+}
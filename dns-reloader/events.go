@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// broker fans events out to streaming API subscribers. Delivery never
+// blocks the change loop: a subscriber that falls more than its buffer
+// behind misses events and can catch up from ChangeHistory.
+type broker[T any] struct {
+	mu   sync.Mutex
+	subs map[chan T]struct{}
+}
+
+func newBroker[T any]() *broker[T] {
+	return &broker[T]{subs: make(map[chan T]struct{})}
+}
+
+// subscribe returns a channel of events and a function that closes it.
+func (b *broker[T]) subscribe() (<-chan T, func()) {
+	ch := make(chan T, 64)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (b *broker[T]) publish(event T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// touchesZone reports whether cs concerns zone, either by name or because
+// the zone was regenerated.
+func (cs ChangeSet) touchesZone(zone string) bool {
+	if zone == "" {
+		return true
+	}
+	for _, z := range cs.Zones {
+		if z == zone {
+			return true
+		}
+	}
+	return cs.Name == zone || strings.HasSuffix(cs.Name, "."+zone)
+}
+
+// Activity event types, in the order a change produces them.
+const (
+	activityChangeReceived = "change_received"
+	activityGeneration     = "generation"
+	activityReloadStarted  = "reload_started"
+	activityReload         = "reload"
+	activityChange         = "change"
+)
+
+// ActivityEvent is one step of applying a change, streamed to live feeds.
+// Change is the triggering change on change_received and the applied change
+// set on change.
+type ActivityEvent struct {
+	Type    string     `json:"type"`
+	Zone    string     `json:"zone,omitempty"`
+	Zones   []string   `json:"zones,omitempty"`
+	Serial  string     `json:"serial,omitempty"`
+	Records int        `json:"records,omitempty"`
+	Error   string     `json:"error,omitempty"`
+	Change  *ChangeSet `json:"change,omitempty"`
+	Time    time.Time  `json:"time"`
+}
+
+func (e ActivityEvent) touchesZone(zone string) bool {
+	if zone == "" || e.Zone == zone {
+		return true
+	}
+	for _, z := range e.Zones {
+		if z == zone {
+			return true
+		}
+	}
+	return e.Change != nil && e.Change.touchesZone(zone)
+}
+
+func (r *Reloader) publishActivity(event ActivityEvent) {
+	event.Time = time.Now()
+	r.activity.publish(event)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// sseKeepAlive is how often an idle event stream gets a comment line, so
+// proxies do not time it out.
+const sseKeepAlive = 15 * time.Second
+
+// handleEvents serves GET /api/v1/events as a Server-Sent Events stream of
+// ActivityEvents, optionally filtered with ?zone=. Applied change sets carry
+// their ID as the SSE id, so a reconnecting client that sends Last-Event-ID
+// (or ?since_id=) first gets the change sets it missed from history.
+func (r *Reloader) handleEvents(w http.ResponseWriter, req *http.Request) {
+	zone := req.URL.Query().Get("zone")
+	lastID := 0
+	for _, v := range []string{req.Header.Get("Last-Event-ID"), req.URL.Query().Get("since_id")} {
+		if v == "" {
+			continue
+		}
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 0 {
+			writeError(w, http.StatusBadRequest, "invalid event id")
+			return
+		}
+		lastID = id
+	}
+
+	// Subscribe before replaying so nothing applied in between is lost.
+	events, cancel := r.activity.subscribe()
+	defer cancel()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event ActivityEvent) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if event.Type == activityChange {
+			fmt.Fprintf(w, "id: %d\n", event.Change.ID)
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		return rc.Flush()
+	}
+
+	if lastID > 0 {
+		for _, cs := range r.changes.After(lastID) {
+			lastID = cs.ID
+			if !cs.touchesZone(zone) {
+				continue
+			}
+			if err := send(ActivityEvent{Type: activityChange, Change: &cs, Time: cs.AppliedAt}); err != nil {
+				return
+			}
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-r.ctx.Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Type == activityChange && event.Change.ID <= lastID {
+				continue
+			}
+			if !event.touchesZone(zone) {
+				continue
+			}
+			if err := send(event); err != nil {
+				return
+			}
+		}
+	}
+}
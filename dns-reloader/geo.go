@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// regionsDirectory is the directory of ZONES_DIRECTORY the region variants
+// of zones are written to, one subdirectory per region.
+const regionsDirectory = "regions"
+
+// validateRegions checks that each of REGIONS names a single directory.
+func validateRegions(regions []string) error {
+	for _, region := range regions {
+		if !viewPattern.MatchString(region) {
+			return fmt.Errorf("region %q must be letters, digits, - or _", region)
+		}
+	}
+	if len(slices.Compact(slices.Sorted(slices.Values(regions)))) != len(regions) {
+		return fmt.Errorf("regions must not repeat")
+	}
+	return nil
+}
+
+// recordsForRegion are the records of a zone as answered in region. An
+// RRset with records in the region replaces the records of the same name
+// and type without one, and records of other regions are left out. The
+// zone written outside any region has only the records without one.
+func recordsForRegion(records []Record, region string) []Record {
+	regional := make(map[string]bool)
+	for _, record := range records {
+		if recordRegion(record) != "" && strings.EqualFold(recordRegion(record), region) {
+			regional[strings.ToLower(record.Name)+"/"+strings.ToUpper(record.Type)] = true
+		}
+	}
+	var answered []Record
+	for _, record := range records {
+		switch tag := recordRegion(record); {
+		case tag == "":
+			if !regional[strings.ToLower(record.Name)+"/"+strings.ToUpper(record.Type)] {
+				answered = append(answered, record)
+			}
+		case strings.EqualFold(tag, region):
+			answered = append(answered, record)
+		}
+	}
+	return answered
+}
+
+func recordRegion(record Record) string {
+	if record.Region == nil {
+		return ""
+	}
+	return *record.Region
+}
+
+// setRecordRegion tags record with region, or clears its region when
+// region is empty.
+func (r *Reloader) setRecordRegion(ctx context.Context, db *gorm.DB, record *Record, region *string) error {
+	if region == nil {
+		return nil
+	}
+	if err := setRecordTag(ctx, db, record, "region", r.recordRegions, *region); err != nil {
+		return err
+	}
+	record.Region = tagValue(*region)
+	return nil
+}
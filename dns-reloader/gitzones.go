@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultGitMessage is the commit message template used without
+// ZONES_GIT_MESSAGE.
+const defaultGitMessage = `{{.Action}} {{.Table}} {{.Name}}{{if .Type}} {{.Type}}{{end}}
+
+Zones: {{join .Zones ", "}}
+`
+
+// zoneRepo keeps the zones directory in a git repository and commits it
+// after every regeneration that changed a zone, giving a diffable history
+// of everything served. With ZONES_GIT_REMOTE set the remote is added as
+// origin, and with ZONES_GIT_PUSH each commit is pushed to it.
+type zoneRepo struct {
+	dir     string
+	branch  string
+	name    string
+	email   string
+	remote  string
+	push    bool
+	message *template.Template
+}
+
+// gitCommitData is the data passed to the commit message template: the
+// change that triggered the regeneration and the zones it changed.
+type gitCommitData struct {
+	Action    string
+	Table     string
+	Name      string
+	Type      string
+	DomainID  int
+	Zones     []string
+	Timestamp time.Time
+}
+
+func newZoneRepo(ctx context.Context, config *Config) (*zoneRepo, error) {
+	if !config.ZonesGit {
+		return nil, nil
+	}
+	author, err := mail.ParseAddress(config.ZonesGitAuthor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ZONES_GIT_AUTHOR %q: %w", config.ZonesGitAuthor, err)
+	}
+	message, err := template.New("commit").Option("missingkey=error").
+		Funcs(template.FuncMap{"join": strings.Join}).
+		Parse(config.ZonesGitMessage)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ZONES_GIT_MESSAGE template: %w", err)
+	}
+	if config.ZonesGitPush && config.ZonesGitRemote == "" {
+		return nil, fmt.Errorf("ZONES_GIT_REMOTE is required with ZONES_GIT_PUSH")
+	}
+
+	repo := &zoneRepo{
+		dir:     config.ZonesDirectory,
+		branch:  config.ZonesGitBranch,
+		name:    author.Name,
+		email:   author.Address,
+		remote:  config.ZonesGitRemote,
+		push:    config.ZonesGitPush,
+		message: message,
+	}
+	if err := repo.init(ctx); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// init creates the repository if the zones directory is not one yet and
+// points origin at the configured remote.
+func (g *zoneRepo) init(ctx context.Context) error {
+	if err := os.MkdirAll(g.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create zones directory: %w", err)
+	}
+	if _, err := os.Stat(filepath.Join(g.dir, ".git")); os.IsNotExist(err) {
+		if _, err := g.git(ctx, "init", "-q", "-b", g.branch); err != nil {
+			return err
+		}
+		// Zone files are written through a temporary file and renamed.
+		if err := os.WriteFile(filepath.Join(g.dir, ".gitignore"), []byte("*.tmp\n"), 0644); err != nil {
+			return fmt.Errorf("failed to write .gitignore: %w", err)
+		}
+	}
+	if g.remote == "" {
+		return nil
+	}
+	if current, err := g.git(ctx, "remote", "get-url", "origin"); err != nil {
+		_, err = g.git(ctx, "remote", "add", "origin", g.remote)
+		return err
+	} else if current != g.remote {
+		_, err = g.git(ctx, "remote", "set-url", "origin", g.remote)
+		return err
+	}
+	return nil
+}
+
+// commit records the current state of the zones directory, if anything in
+// it changed, with a message rendered for change, and pushes it when
+// configured to. A failed push is retried with the next commit.
+func (g *zoneRepo) commit(ctx context.Context, change *DNSChangeNotification, zones []string) error {
+	if _, err := g.git(ctx, "add", "-A", "."); err != nil {
+		return err
+	}
+	if _, err := g.git(ctx, "diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+
+	data := gitCommitData{Zones: zones, Timestamp: time.Now()}
+	if change != nil {
+		data.Action, data.Table, data.Name, data.Type, data.DomainID = change.Action, change.Table, change.Name, change.Type, change.DomainID
+		if !change.Timestamp.IsZero() {
+			data.Timestamp = change.Timestamp
+		}
+	}
+	var message strings.Builder
+	if err := g.message.Execute(&message, data); err != nil {
+		return fmt.Errorf("failed to render commit message: %w", err)
+	}
+	cmd := g.command(ctx, "commit", "-q", "-F", "-")
+	cmd.Stdin = strings.NewReader(message.String())
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git commit failed: %w (output: %s)", err, strings.TrimSpace(string(output)))
+	}
+
+	if g.push {
+		if _, err := g.git(ctx, "push", "-q", "origin", "HEAD:refs/heads/"+g.branch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *zoneRepo) command(ctx context.Context, args ...string) *exec.Cmd {
+	args = append([]string{"-C", g.dir, "-c", "user.name=" + g.name, "-c", "user.email=" + g.email}, args...)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	return cmd
+}
+
+// git runs a git command in the zones directory and returns its trimmed
+// output.
+func (g *zoneRepo) git(ctx context.Context, args ...string) (string, error) {
+	output, err := g.command(ctx, args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w (output: %s)", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,388 @@
+package main
+
+//go:generate protoc -I api/v1 --go_out=api/v1 --go_opt=paths=source_relative --go-grpc_out=api/v1 --go-grpc_opt=paths=source_relative dnsreloader.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	pb "dns-reloader/api/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcServer implements the DNSReloader service on top of the same
+// operations as the REST API.
+type grpcServer struct {
+	pb.UnimplementedDNSReloaderServer
+	r *Reloader
+}
+
+func (r *Reloader) startGRPCServer() error {
+	if r.config.GRPCListenAddr == "" {
+		return nil
+	}
+
+	lis, err := net.Listen("tcp", r.config.GRPCListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for gRPC: %w", err)
+	}
+
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(r.grpcUnaryAuth),
+		grpc.ChainStreamInterceptor(r.grpcStreamAuth),
+	}
+	if r.tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(r.tlsConfig)))
+	}
+	server := grpc.NewServer(options...)
+	pb.RegisterDNSReloaderServer(server, &grpcServer{r: r})
+
+	go func() {
+		<-r.ctx.Done()
+		server.GracefulStop()
+	}()
+
+	go func() {
+		r.logger.WithField("addr", r.config.GRPCListenAddr).Info("gRPC server listening")
+		if err := server.Serve(lis); err != nil {
+			r.logger.WithError(err).Error("gRPC server failed")
+		}
+	}()
+	return nil
+}
+
+// grpcRoutes maps each gRPC method to the REST route it mirrors, whose
+// role, scope and account rules then apply to it.
+var grpcRoutes = map[string]string{
+	pb.DNSReloader_ListDomains_FullMethodName:  "GET /api/v1/domains",
+	pb.DNSReloader_GetDomain_FullMethodName:    "GET /api/v1/domains/{id}",
+	pb.DNSReloader_CreateDomain_FullMethodName: "POST /api/v1/domains",
+	pb.DNSReloader_UpdateDomain_FullMethodName: "PUT /api/v1/domains/{id}",
+	pb.DNSReloader_DeleteDomain_FullMethodName: "DELETE /api/v1/domains/{id}",
+	pb.DNSReloader_ListRecords_FullMethodName:  "GET /api/v1/domains/{id}/records",
+	pb.DNSReloader_CreateRecord_FullMethodName: "POST /api/v1/domains/{id}/records",
+	pb.DNSReloader_UpdateRecord_FullMethodName: "PUT /api/v1/domains/{id}/records/{recordID}",
+	pb.DNSReloader_DeleteRecord_FullMethodName: "DELETE /api/v1/domains/{id}/records/{recordID}",
+	pb.DNSReloader_WatchChanges_FullMethodName: "GET /api/v1/events",
+}
+
+// admitGRPC checks the bearer token in the authorization metadata of a
+// call, as authenticate does for HTTP requests, and admits it to the
+// route its method mirrors. Methods missing from grpcRoutes need
+// system-admin. req names the domain the call acts on, if any.
+func (r *Reloader) admitGRPC(ctx context.Context, method string, req interface{}) (context.Context, error) {
+	route, ok := grpcRoutes[method]
+	if !ok {
+		route = "gRPC " + method
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "an API token is required")
+	}
+	secret, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "the authorization metadata must be a bearer token")
+	}
+	token, err := r.findAPIToken(ctx, strings.TrimSpace(secret))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	var domain string
+	if named, ok := req.(interface{ GetDomain() string }); ok && routeRoles[route].domain != "" {
+		domain = named.GetDomain()
+	}
+	ctx, err = r.admit(ctx, token, route, domain)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if ok, _ := r.tokenLimits.allow(token, time.Now()); !ok {
+		return nil, status.Errorf(codes.ResourceExhausted, "token %s is limited to %d requests a minute", token.Name, token.RateLimit)
+	}
+	return ctx, nil
+}
+
+func (r *Reloader) grpcUnaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := r.admitGRPC(ctx, info.FullMethod, req)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// grpcStreamAuth admits streaming calls before their request is read, so
+// only routes that act on no single domain can be streams.
+func (r *Reloader) grpcStreamAuth(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := r.admitGRPC(stream.Context(), info.FullMethod, nil)
+	if err != nil {
+		return err
+	}
+	return handler(srv, admittedStream{ServerStream: stream, ctx: ctx})
+}
+
+// admittedStream is a server stream whose context carries its token.
+type admittedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s admittedStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcError maps an apiError to the matching gRPC status code.
+func grpcError(err error) error {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	message := apiErr.message
+	if len(apiErr.problems) > 0 {
+		message = fmt.Sprintf("%s: %v", apiErr.message, apiErr.problems)
+	}
+	switch apiErr.status {
+	case http.StatusUnauthorized:
+		return status.Error(codes.Unauthenticated, message)
+	case http.StatusForbidden:
+		return status.Error(codes.PermissionDenied, message)
+	case http.StatusTooManyRequests:
+		return status.Error(codes.ResourceExhausted, message)
+	case http.StatusNotFound:
+		return status.Error(codes.NotFound, message)
+	case http.StatusConflict:
+		return status.Error(codes.AlreadyExists, message)
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return status.Error(codes.InvalidArgument, message)
+	default:
+		return status.Error(codes.Internal, message)
+	}
+}
+
+func (s *grpcServer) ListDomains(ctx context.Context, _ *pb.ListDomainsRequest) (*pb.ListDomainsResponse, error) {
+	domains, err := s.r.listDomains(ctx)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &pb.ListDomainsResponse{}
+	for i := range domains {
+		resp.Domains = append(resp.Domains, domainToProto(&domains[i]))
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) GetDomain(ctx context.Context, req *pb.GetDomainRequest) (*pb.Domain, error) {
+	domain, err := s.r.findDomain(ctx, req.GetDomain())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return domainToProto(domain), nil
+}
+
+func (s *grpcServer) CreateDomain(ctx context.Context, req *pb.CreateDomainRequest) (*pb.CreateDomainResponse, error) {
+	domain, seeded, err := s.r.createDomain(ctx, domainCreateRequest{
+		Name:    req.GetName(),
+		Type:    req.GetType(),
+		Master:  req.Master,
+		Account: req.Account,
+		Seed:    req.Seed,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &pb.CreateDomainResponse{Domain: domainToProto(domain)}
+	for i := range seeded {
+		resp.Records = append(resp.Records, recordToProto(&seeded[i]))
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) UpdateDomain(ctx context.Context, req *pb.UpdateDomainRequest) (*pb.Domain, error) {
+	domain, err := s.r.updateDomain(ctx, req.GetDomain(), domainUpdateRequest{
+		Type:    req.GetType(),
+		Master:  req.Master,
+		Account: req.Account,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return domainToProto(domain), nil
+}
+
+func (s *grpcServer) DeleteDomain(ctx context.Context, req *pb.DeleteDomainRequest) (*emptypb.Empty, error) {
+	if err := s.r.deleteDomain(ctx, req.GetDomain()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *grpcServer) ListRecords(ctx context.Context, req *pb.ListRecordsRequest) (*pb.ListRecordsResponse, error) {
+	domain, records, err := s.r.listRecords(ctx, req.GetDomain())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &pb.ListRecordsResponse{Domain: domain.Name}
+	for i := range records {
+		resp.Records = append(resp.Records, recordToProto(&records[i]))
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) CreateRecord(ctx context.Context, req *pb.CreateRecordRequest) (*pb.Record, error) {
+	if req.GetRecord() == nil {
+		return nil, grpcError(errInvalid("record is required"))
+	}
+	record, err := s.r.createRecord(ctx, req.GetDomain(), recordInputFromProto(req.GetRecord()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return recordToProto(record), nil
+}
+
+func (s *grpcServer) UpdateRecord(ctx context.Context, req *pb.UpdateRecordRequest) (*pb.Record, error) {
+	if req.GetRecord() == nil {
+		return nil, grpcError(errInvalid("record is required"))
+	}
+	record, err := s.r.updateRecord(ctx, req.GetDomain(), int(req.GetId()), recordInputFromProto(req.GetRecord()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return recordToProto(record), nil
+}
+
+func (s *grpcServer) DeleteRecord(ctx context.Context, req *pb.DeleteRecordRequest) (*emptypb.Empty, error) {
+	if err := s.r.deleteRecord(ctx, req.GetDomain(), int(req.GetId())); err != nil {
+		return nil, grpcError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// WatchChanges subscribes before replaying history so nothing applied in
+// between is lost; replayed IDs are remembered to skip duplicates.
+func (s *grpcServer) WatchChanges(req *pb.WatchChangesRequest, stream grpc.ServerStreamingServer[pb.ChangeEvent]) error {
+	events, cancel := s.r.events.subscribe()
+	defer cancel()
+
+	lastID := int(req.GetSinceId())
+	if req.GetSinceId() > 0 {
+		for _, cs := range s.r.changes.After(lastID) {
+			lastID = cs.ID
+			if !cs.touchesZone(req.GetZone()) {
+				continue
+			}
+			if err := stream.Send(changeToProto(cs)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.r.ctx.Done():
+			return status.Error(codes.Unavailable, "server shutting down")
+		case cs, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if cs.ID <= lastID || !cs.touchesZone(req.GetZone()) {
+				continue
+			}
+			if err := stream.Send(changeToProto(cs)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func domainToProto(d *Domain) *pb.Domain {
+	return &pb.Domain{
+		Id:        int64(d.ID),
+		Name:      d.Name,
+		Type:      d.Type,
+		Master:    d.Master,
+		Account:   d.Account,
+		CreatedAt: timestamppb.New(d.CreatedAt),
+		UpdatedAt: timestamppb.New(d.UpdatedAt),
+	}
+}
+
+func recordToProto(rec *Record) *pb.Record {
+	return &pb.Record{
+		Id:        int64(rec.ID),
+		DomainId:  int64(rec.DomainID),
+		Name:      rec.Name,
+		Type:      rec.Type,
+		Content:   rec.Content,
+		Ttl:       int32(rec.TTL),
+		Prio:      int32Ptr(rec.Prio),
+		Weight:    int32Ptr(rec.Weight),
+		Disabled:  rec.Disabled,
+		Auth:      rec.Auth,
+		Comment:   rec.Comment,
+		CreatedBy: rec.CreatedBy,
+		CreatedAt: timestamppb.New(rec.CreatedAt),
+		UpdatedAt: timestamppb.New(rec.UpdatedAt),
+	}
+}
+
+func recordInputFromProto(in *pb.RecordInput) recordRequest {
+	return recordRequest{
+		Name:     in.GetName(),
+		Type:     in.GetType(),
+		Content:  in.GetContent(),
+		TTL:      intPtr(in.Ttl),
+		Prio:     intPtr(in.Prio),
+		Weight:   intPtr(in.Weight),
+		Disabled: in.GetDisabled(),
+		Comment:  in.Comment,
+	}
+}
+
+func changeToProto(cs ChangeSet) *pb.ChangeEvent {
+	return &pb.ChangeEvent{
+		Id:                int64(cs.ID),
+		Action:            cs.Action,
+		Table:             cs.Table,
+		DomainId:          int64(cs.DomainID),
+		Name:              cs.Name,
+		Type:              cs.Type,
+		Zones:             cs.Zones,
+		Backend:           cs.Backend,
+		Reloaded:          cs.Reloaded,
+		Error:             cs.Error,
+		Verification:      cs.Verification,
+		VerificationError: cs.VerificationError,
+		AppliedAt:         timestamppb.New(cs.AppliedAt),
+	}
+}
+
+func int32Ptr(v *int) *int32 {
+	if v == nil {
+		return nil
+	}
+	n := int32(*v)
+	return &n
+}
+
+func intPtr(v *int32) *int {
+	if v == nil {
+		return nil
+	}
+	n := int(*v)
+	return &n
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// healthCheckReload is how often the prober reads the health checks again,
+// picking up the ones added, changed and removed through the API.
+const healthCheckReload = 30 * time.Second
+
+// HealthCheck is a row of the health_checks table: a probe of the target of
+// one A, AAAA or CNAME record. Fall failures in a row withhold the record
+// from its zone and rise passes in a row restore it, so a target that
+// flaps is not published and withdrawn on every probe.
+type HealthCheck struct {
+	ID        int        `gorm:"primaryKey;column:id" json:"id"`
+	RecordID  int        `gorm:"column:record_id" json:"record_id"`
+	Type      string     `gorm:"column:type" json:"type"`
+	Port      *int       `gorm:"column:port" json:"port,omitempty"`
+	Path      *string    `gorm:"column:path" json:"path,omitempty"`
+	Interval  int        `gorm:"column:interval_seconds" json:"interval"`
+	Timeout   int        `gorm:"column:timeout_seconds" json:"timeout"`
+	Rise      int        `gorm:"column:rise" json:"rise"`
+	Fall      int        `gorm:"column:fall" json:"fall"`
+	Healthy   bool       `gorm:"column:healthy" json:"healthy"`
+	ChangedAt *time.Time `gorm:"column:changed_at" json:"changed_at,omitempty"`
+	LastError *string    `gorm:"column:last_error" json:"last_error,omitempty"`
+}
+
+func (HealthCheck) TableName() string {
+	return "health_checks"
+}
+
+// healthTarget is a health check with the record it probes, and the
+// passes or failures in a row since its state last changed.
+type healthTarget struct {
+	check    HealthCheck
+	record   Record
+	passes   int
+	failures int
+}
+
+// loadHealth reads which records their health checks have withheld, for
+// the zones rendered next.
+func (r *Reloader) loadHealth(ctx context.Context, db *gorm.DB) error {
+	if !r.healthchecks {
+		return nil
+	}
+	var ids []uint
+	if err := db.WithContext(ctx).Model(&HealthCheck{}).Where("healthy = ?", false).Pluck("record_id", &ids).Error; err != nil {
+		return fmt.Errorf("failed to read health checks: %w", err)
+	}
+	unhealthy := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		unhealthy[id] = true
+	}
+	r.healthMu.Lock()
+	r.unhealthy = unhealthy
+	r.healthMu.Unlock()
+	return nil
+}
+
+// withholdUnhealthy leaves out the records whose health checks failed.
+// An RRset whose every record failed is kept whole: answering with targets
+// that may be down beats answering with none.
+func (r *Reloader) withholdUnhealthy(records []Record) []Record {
+	r.healthMu.RLock()
+	defer r.healthMu.RUnlock()
+	if len(r.unhealthy) == 0 {
+		return records
+	}
+	healthy := make(map[string]bool)
+	for _, record := range records {
+		if !r.unhealthy[record.ID] && !record.Disabled {
+			healthy[strings.ToLower(record.Name)+"/"+strings.ToUpper(record.Type)] = true
+		}
+	}
+	published := make([]Record, 0, len(records))
+	for _, record := range records {
+		if r.unhealthy[record.ID] && healthy[strings.ToLower(record.Name)+"/"+strings.ToUpper(record.Type)] {
+			continue
+		}
+		published = append(published, record)
+	}
+	return published
+}
+
+// runHealthChecks probes every health check of this reloader's shard at
+// its interval while this reloader is the leader, and regenerates the
+// zones whenever a record is withheld or restored.
+func (r *Reloader) runHealthChecks() {
+	if !r.healthchecks {
+		return
+	}
+	logger := r.logger.WithField("component", "healthchecks")
+	type result struct {
+		target *healthTarget
+		err    error
+	}
+	results := make(chan result)
+	running := make(map[int]bool)
+	next := make(map[int]time.Time)
+	var targets []*healthTarget
+	var loaded time.Time
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case res := <-results:
+			delete(running, res.target.check.ID)
+			r.applyProbe(logger, res.target, res.err)
+		case now := <-ticker.C:
+			if !r.isLeader() {
+				loaded = time.Time{}
+				continue
+			}
+			if now.Sub(loaded) >= healthCheckReload {
+				fresh, err := r.loadHealthTargets(r.ctx, targets)
+				if err != nil {
+					logger.WithError(err).Warn("Failed to load health checks")
+				} else {
+					targets = fresh
+				}
+				loaded = now
+			}
+			for _, target := range targets {
+				id := target.check.ID
+				if running[id] || now.Before(next[id]) {
+					continue
+				}
+				running[id] = true
+				next[id] = now.Add(time.Duration(target.check.Interval) * time.Second)
+				check, record := target.check, target.record
+				go func() {
+					err := r.probe(r.ctx, check, record)
+					select {
+					case results <- result{target, err}:
+					case <-r.ctx.Done():
+					}
+				}()
+			}
+		}
+	}
+}
+
+// loadHealthTargets reads the health checks of enabled records in zones
+// this reloader generates. Those already loaded are kept, with their
+// streaks unless their state was changed elsewhere, so probes still
+// running count towards them.
+func (r *Reloader) loadHealthTargets(ctx context.Context, previous []*healthTarget) ([]*healthTarget, error) {
+	var checks []HealthCheck
+	if err := r.db.WithContext(ctx).Order("id").Find(&checks).Error; err != nil {
+		return nil, fmt.Errorf("failed to read health checks: %w", err)
+	}
+	if len(checks) == 0 {
+		return nil, nil
+	}
+	recordIDs := make([]int, len(checks))
+	for i, check := range checks {
+		recordIDs[i] = check.RecordID
+	}
+	var records []Record
+	if err := r.db.WithContext(ctx).Where("id IN ?", recordIDs).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to read checked records: %w", err)
+	}
+	byID := make(map[uint]Record, len(records))
+	domainIDs := make([]int, 0, len(records))
+	for _, record := range records {
+		byID[record.ID] = record
+		domainIDs = append(domainIDs, record.DomainID)
+	}
+	var domains []Domain
+	if err := r.db.WithContext(ctx).Where("id IN ?", domainIDs).Find(&domains).Error; err != nil {
+		return nil, fmt.Errorf("failed to read checked domains: %w", err)
+	}
+	if err := r.loadZoneOptions(ctx, r.db, domains); err != nil {
+		return nil, err
+	}
+	generated := make(map[int]bool, len(domains))
+	for _, domain := range domains {
+		generated[int(domain.ID)] = r.generates(domain)
+	}
+
+	loaded := make(map[int]*healthTarget, len(previous))
+	for _, target := range previous {
+		loaded[target.check.ID] = target
+	}
+	targets := make([]*healthTarget, 0, len(checks))
+	for _, check := range checks {
+		record, ok := byID[uint(check.RecordID)]
+		if !ok || record.Disabled || !generated[record.DomainID] {
+			continue
+		}
+		target, ok := loaded[check.ID]
+		if !ok {
+			target = &healthTarget{}
+		} else if target.check.Healthy != check.Healthy {
+			target.passes, target.failures = 0, 0
+		}
+		target.check, target.record = check, record
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// applyProbe counts a probe towards the target's rise or fall, and once
+// it reaches it records the new state and regenerates the zones.
+func (r *Reloader) applyProbe(logger *logrus.Entry, target *healthTarget, err error) {
+	result := "passed"
+	if err != nil {
+		result = "failed"
+		target.passes = 0
+		target.failures++
+	} else {
+		target.failures = 0
+		target.passes++
+	}
+	healthProbes.WithLabelValues(target.check.Type, result).Inc()
+
+	healthy := target.check.Healthy
+	switch {
+	case healthy && target.failures >= target.check.Fall:
+		healthy = false
+	case !healthy && target.passes >= target.check.Rise:
+		healthy = true
+	default:
+		return
+	}
+	if !r.isLeader() {
+		return
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"healthy": healthy, "changed_at": now, "last_error": nil}
+	if err != nil {
+		message := err.Error()
+		if len(message) > 255 {
+			message = message[:255]
+		}
+		updates["last_error"] = message
+	}
+	if dbErr := r.db.WithContext(r.ctx).Model(&HealthCheck{}).Where("id = ?", target.check.ID).Updates(updates).Error; dbErr != nil {
+		logger.WithError(dbErr).Error("Failed to record health check state")
+		return
+	}
+	target.check.Healthy = healthy
+	target.check.ChangedAt = &now
+	target.passes, target.failures = 0, 0
+
+	state, action := "up", "HEALTH_UP"
+	fields := logrus.Fields{"name": target.record.Name, "type": target.record.Type, "content": target.record.Content}
+	if healthy {
+		logger.WithFields(fields).Info("Health check passing again, restoring record")
+	} else {
+		state, action = "down", "HEALTH_DOWN"
+		logger.WithFields(fields).WithError(err).Warn("Health check failing, withholding record")
+	}
+	healthTransitions.WithLabelValues(state).Inc()
+
+	select {
+	case r.localChanges <- &DNSChangeNotification{
+		Table:     "health_checks",
+		Action:    action,
+		ID:        int(target.record.ID),
+		DomainID:  target.record.DomainID,
+		Name:      target.record.Name,
+		Type:      target.record.Type,
+		Timestamp: now,
+		internal:  true,
+	}:
+	case <-r.ctx.Done():
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// healthCheckRequest is the body accepted by the health check endpoint.
+// Port is required for tcp checks; Path applies to http and https ones.
+// Interval and Timeout are in seconds.
+type healthCheckRequest struct {
+	Type     string  `json:"type"`
+	Port     *int    `json:"port,omitempty"`
+	Path     *string `json:"path,omitempty"`
+	Interval *int    `json:"interval,omitempty"`
+	Timeout  *int    `json:"timeout,omitempty"`
+	Rise     *int    `json:"rise,omitempty"`
+	Fall     *int    `json:"fall,omitempty"`
+}
+
+// apply fills check from body and validates it for record.
+func (body healthCheckRequest) apply(check *HealthCheck, record *Record) error {
+	valueOr := func(value *int, fallback int) int {
+		if value != nil {
+			return *value
+		}
+		return fallback
+	}
+	check.Type = strings.ToLower(strings.TrimSpace(body.Type))
+	check.Port = body.Port
+	check.Path = body.Path
+	check.Interval = valueOr(body.Interval, 30)
+	check.Timeout = valueOr(body.Timeout, 5)
+	check.Rise = valueOr(body.Rise, 2)
+	check.Fall = valueOr(body.Fall, 3)
+
+	var problems []string
+	switch check.Type {
+	case healthHTTP, healthHTTPS, healthICMP:
+	case healthTCP:
+		if check.Port == nil {
+			problems = append(problems, "tcp checks need a port")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown type %q, expected http, https, tcp or icmp", body.Type))
+	}
+	switch strings.ToUpper(record.Type) {
+	case "A", "AAAA", "CNAME":
+	default:
+		problems = append(problems, fmt.Sprintf("%s records cannot be health checked; only A, AAAA and CNAME", record.Type))
+	}
+	if check.Port != nil && (*check.Port < 1 || *check.Port > 65535) {
+		problems = append(problems, "port must be 1 to 65535")
+	}
+	if check.Path != nil && (!strings.HasPrefix(*check.Path, "/") || (check.Type != healthHTTP && check.Type != healthHTTPS)) {
+		problems = append(problems, "path must start with / and is only for http and https checks")
+	}
+	if check.Interval < 1 || check.Timeout < 1 || check.Timeout > check.Interval {
+		problems = append(problems, "interval and timeout must be at least 1 second, and timeout no more than interval")
+	}
+	if check.Rise < 1 || check.Fall < 1 {
+		problems = append(problems, "rise and fall must be at least 1")
+	}
+	if len(problems) > 0 {
+		return errValidation(problems)
+	}
+	return nil
+}
+
+// checkedRecord finds a record of a domain the caller may write.
+func (r *Reloader) checkedRecord(ctx context.Context, ref string, recordID int) (*Domain, *Record, error) {
+	if !r.healthchecks {
+		return nil, nil, errConflict("the database has no health_checks table")
+	}
+	domain, err := r.findDomain(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := r.checkWritable(domain); err != nil {
+		return nil, nil, err
+	}
+	record, err := r.findRecord(ctx, domain, recordID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkScope(ctx, domain, record); err != nil {
+		return nil, nil, err
+	}
+	return domain, record, nil
+}
+
+// listHealthChecks returns the health checks of a domain's records.
+func (r *Reloader) listHealthChecks(ctx context.Context, ref string) (*Domain, []HealthCheck, error) {
+	if !r.healthchecks {
+		return nil, nil, errConflict("the database has no health_checks table")
+	}
+	domain, records, err := r.listRecords(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := make([]uint, len(records))
+	for i, record := range records {
+		ids[i] = record.ID
+	}
+	checks := []HealthCheck{}
+	if err := r.db.WithContext(ctx).Where("record_id IN ?", ids).Order("record_id").Find(&checks).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch health checks: %w", err)
+	}
+	return domain, checks, nil
+}
+
+// putHealthCheck creates or replaces the health check of a record. A
+// replaced check keeps its state; a new one starts healthy.
+func (r *Reloader) putHealthCheck(ctx context.Context, ref string, recordID int, body healthCheckRequest) (*HealthCheck, error) {
+	_, record, err := r.checkedRecord(ctx, ref, recordID)
+	if err != nil {
+		return nil, err
+	}
+	check := HealthCheck{RecordID: int(record.ID), Healthy: true}
+	if err := r.db.WithContext(ctx).Where("record_id = ?", record.ID).First(&check).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to fetch health check: %w", err)
+	}
+	if err := body.apply(&check, record); err != nil {
+		return nil, err
+	}
+	if err := r.db.WithContext(ctx).Save(&check).Error; err != nil {
+		return nil, fmt.Errorf("failed to save health check: %w", err)
+	}
+	return &check, nil
+}
+
+// deleteHealthCheck removes the health check of a record, restoring the
+// record if the check had withheld it.
+func (r *Reloader) deleteHealthCheck(ctx context.Context, ref string, recordID int) error {
+	domain, record, err := r.checkedRecord(ctx, ref, recordID)
+	if err != nil {
+		return err
+	}
+	var check HealthCheck
+	if err := r.db.WithContext(ctx).Where("record_id = ?", record.ID).First(&check).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errNotFound("record has no health check")
+		}
+		return err
+	}
+	if err := r.db.WithContext(ctx).Delete(&check).Error; err != nil {
+		return fmt.Errorf("failed to delete health check: %w", err)
+	}
+	if !check.Healthy {
+		r.enqueueChange(&DNSChangeNotification{
+			Table:     "health_checks",
+			Action:    "HEALTH_UP",
+			ID:        int(record.ID),
+			DomainID:  int(domain.ID),
+			Name:      record.Name,
+			Type:      record.Type,
+			Timestamp: time.Now(),
+			internal:  true,
+		})
+	}
+	return nil
+}
+
+func (r *Reloader) handleListHealthChecks(w http.ResponseWriter, req *http.Request) {
+	domain, checks, err := r.listHealthChecks(req.Context(), req.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"domain":        domain.Name,
+		"health_checks": checks,
+	})
+}
+
+func (r *Reloader) handlePutHealthCheck(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(req.PathValue("recordID"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid record id")
+		return
+	}
+
+	var body healthCheckRequest
+	if err := readJSON(req, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	check, err := r.putHealthCheck(req.Context(), req.PathValue("id"), id, body)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, check)
+}
+
+func (r *Reloader) handleDeleteHealthCheck(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(req.PathValue("recordID"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid record id")
+		return
+	}
+
+	if err := r.deleteHealthCheck(req.Context(), req.PathValue("id"), id); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
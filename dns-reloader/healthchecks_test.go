@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHealthTransitionsBypassOutbox(t *testing.T) {
+	r := newTestReloader(t, map[string]string{"CHANGE_OUTBOX": "true"})
+	ctx := t.Context()
+	createTestZone(t, r, "example.com")
+	var records []*Record
+	for _, address := range []string{"192.0.2.1", "192.0.2.2"} {
+		record, err := r.createRecord(ctx, "example.com", recordRequest{Name: "www", Type: "A", Content: address})
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	port, fall, rise := 80, 1, 1
+	check, err := r.putHealthCheck(ctx, "example.com", int(records[0].ID), healthCheckRequest{Type: healthTCP, Port: &port, Fall: &fall, Rise: &rise})
+	if err != nil {
+		t.Fatal(err)
+	}
+	applyLocalChanges(r)
+	if _, err := r.regenerateAllZones(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.drainOutbox(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	target := &healthTarget{check: *check, record: *records[0]}
+	r.applyProbe(r.logger.WithField("test", t.Name()), target, errors.New("connection refused"))
+	applyLocalChanges(r)
+	if zone := readZoneFile(t, r, "example.com"); zoneHasRecord(zone, "A", "192.0.2.1") || !zoneHasRecord(zone, "A", "192.0.2.2") {
+		t.Fatalf("failing target not withheld with the outbox on:\n%s", zone)
+	}
+
+	r.applyProbe(r.logger.WithField("test", t.Name()), target, nil)
+	applyLocalChanges(r)
+	if zone := readZoneFile(t, r, "example.com"); !zoneHasRecord(zone, "A", "192.0.2.1") {
+		t.Fatalf("recovered target not restored with the outbox on:\n%s", zone)
+	}
+}
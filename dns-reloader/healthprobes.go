@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Health check types.
+const (
+	healthHTTP  = "http"
+	healthHTTPS = "https"
+	healthTCP   = "tcp"
+	healthICMP  = "icmp"
+)
+
+// icmpSequence numbers echo requests, which is how replies are matched:
+// the kernel picks the identifier of unprivileged ICMP sockets.
+var icmpSequence atomic.Uint32
+
+// probe checks the target of record once, within the check's timeout.
+func (r *Reloader) probe(ctx context.Context, check HealthCheck, record Record) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(check.Timeout)*time.Second)
+	defer cancel()
+	host := strings.TrimSuffix(record.Content, ".")
+	switch check.Type {
+	case healthHTTP, healthHTTPS:
+		return probeHTTP(ctx, check, host, strings.TrimSuffix(record.Name, "."))
+	case healthTCP:
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(*check.Port)))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case healthICMP:
+		return probeICMP(ctx, host)
+	}
+	return fmt.Errorf("unknown health check type %q", check.Type)
+}
+
+// probeHTTP sends a GET to the record's target and expects a 2xx or 3xx
+// response. The request is for the record's name, in the Host header and
+// in TLS, so the target answers and is verified as it would be for
+// clients resolving the record.
+func probeHTTP(ctx context.Context, check HealthCheck, host, name string) error {
+	address := host
+	if check.Port != nil {
+		address = net.JoinHostPort(host, strconv.Itoa(*check.Port))
+	} else if strings.Contains(host, ":") {
+		address = "[" + host + "]"
+	}
+	path := "/"
+	if check.Path != nil {
+		path = *check.Path
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.Type+"://"+address+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Host = name
+	req.Header.Set("User-Agent", "dns-reloader health check")
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{ServerName: name},
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// probeICMP sends an echo request to host and waits for the reply. It uses
+// unprivileged ICMP sockets, which on Linux the process's group must be
+// allowed by net.ipv4.ping_group_range.
+func probeICMP(ctx context.Context, host string) error {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return err
+		}
+		if len(addrs) == 0 {
+			return fmt.Errorf("%s has no addresses", host)
+		}
+		addr = addrs[0]
+	}
+	addr = addr.Unmap()
+
+	network, protocol, listen := "udp4", 1, "0.0.0.0"
+	var echo, reply icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if addr.Is6() {
+		network, protocol, listen = "udp6", 58, "::"
+		echo, reply = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+	conn, err := icmp.ListenPacket(network, listen)
+	if err != nil {
+		return fmt.Errorf("failed to open ICMP socket: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	seq := int(icmpSequence.Add(1) & 0xffff)
+	message := icmp.Message{Type: echo, Body: &icmp.Echo{Seq: seq, Data: []byte("dns-reloader")}}
+	packet, err := message.Marshal(nil)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.WriteTo(packet, &net.UDPAddr{IP: addr.AsSlice(), Zone: addr.Zone()}); err != nil {
+		return err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return errors.New("no echo reply")
+			}
+			return err
+		}
+		received, err := icmp.ParseMessage(protocol, buf[:n])
+		if err != nil || received.Type != reply {
+			continue
+		}
+		if body, ok := received.Body.(*icmp.Echo); ok && body.Seq == seq {
+			return nil
+		}
+	}
+}
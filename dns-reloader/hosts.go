@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// renderHosts renders zones as a hosts file for the CoreDNS hosts plugin,
+// for trivial internal zones that need no zone file. The format holds only
+// addresses, so a zone with anything besides A and AAAA records, apart
+// from its SOA and apex NS, is refused rather than served incomplete. The
+// hosts plugin has one TTL for the whole file, set in the Corefile.
+func renderHosts(zones []outputZone) (string, error) {
+	var hosts strings.Builder
+	hosts.WriteString("# Generated by dns-reloader, do not edit\n")
+	for _, zone := range zones {
+		apex := dns.Fqdn(zone.Name)
+		var lines, rejected []string
+		for _, rr := range zone.RRs {
+			header := rr.Header()
+			name := strings.TrimSuffix(header.Name, ".")
+			switch v := rr.(type) {
+			case *dns.SOA:
+				continue
+			case *dns.NS:
+				if strings.EqualFold(header.Name, apex) {
+					continue
+				}
+			case *dns.A:
+				if !strings.HasPrefix(name, "*.") {
+					lines = append(lines, v.A.String()+"\t"+name)
+					continue
+				}
+			case *dns.AAAA:
+				if !strings.HasPrefix(name, "*.") {
+					lines = append(lines, v.AAAA.String()+"\t"+name)
+					continue
+				}
+			}
+			rejected = append(rejected, name+" "+dns.TypeToString[header.Rrtype])
+		}
+		if len(rejected) > 0 {
+			return "", fmt.Errorf("zone %s has records the hosts format cannot hold: %s", zone.Name, strings.Join(rejected, ", "))
+		}
+		hosts.WriteString("\n# " + zone.Name + "\n")
+		for _, line := range lines {
+			hosts.WriteString(line + "\n")
+		}
+	}
+	return hosts.String(), nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestRenderHosts(t *testing.T) {
+	tests := []struct {
+		name    string
+		rrs     []string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "addresses",
+			rrs: []string{
+				"internal.example. 300 IN SOA ns1.internal.example. hostmaster.internal.example. 1 3600 600 86400 300",
+				"internal.example. 300 IN NS ns1.internal.example.",
+				"db.internal.example. 300 IN A 10.0.0.5",
+				"www.internal.example. 300 IN A 10.0.0.6",
+			},
+			want: []string{"10.0.0.5\tdb.internal.example", "10.0.0.6\twww.internal.example"},
+		},
+		{
+			name:    "wildcard",
+			rrs:     []string{"*.internal.example. 300 IN A 10.0.0.5"},
+			wantErr: "*.internal.example A",
+		},
+		{
+			name:    "other types",
+			rrs:     []string{"internal.example. 300 IN MX 10 mail.internal.example."},
+			wantErr: "internal.example MX",
+		},
+		{
+			name:    "delegation",
+			rrs:     []string{"sub.internal.example. 300 IN NS ns1.sub.internal.example."},
+			wantErr: "sub.internal.example NS",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zone := outputZone{Name: "internal.example"}
+			for _, s := range tt.rrs {
+				rr, err := dns.NewRR(s)
+				if err != nil {
+					t.Fatal(err)
+				}
+				zone.RRs = append(zone.RRs, rr)
+			}
+			hosts, err := renderHosts([]outputZone{zone})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one naming %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, line := range tt.want {
+				if !strings.Contains(hosts, line+"\n") {
+					t.Errorf("hosts file lacks %q:\n%s", line, hosts)
+				}
+			}
+		})
+	}
+}
+
+func TestHostsOutputFromZoneFiles(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "hosts")
+	r := newTestReloader(t, map[string]string{"OUTPUT_FORMAT": "hosts", "OUTPUT_FILE": output})
+	ctx := t.Context()
+	createTestZone(t, r, "internal.example")
+	for _, body := range []recordRequest{
+		{Name: "db", Type: "A", Content: "10.0.0.5"},
+		{Name: "db", Type: "AAAA", Content: "fd00::5"},
+	} {
+		if _, err := r.createRecord(ctx, "internal.example", body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := r.regenerateAllZones(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"10.0.0.5\tdb.internal.example", "fd00::5\tdb.internal.example"} {
+		if !strings.Contains(string(content), line+"\n") {
+			t.Errorf("hosts file lacks %q:\n%s", line, content)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+func (r *Reloader) startHTTPServer() {
+	if r.config.HTTPListenAddr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /status", r.handleStatus)
+	mux.HandleFunc("GET /nic/update", r.handleNicUpdate)
+	mux.HandleFunc("GET /api/v1/openapi.yaml", r.handleOpenAPIYAML)
+	mux.HandleFunc("GET /api/v1/openapi.json", r.handleOpenAPIJSON)
+	mux.HandleFunc("GET /api/v1/stats", r.handleStats)
+	mux.HandleFunc("GET /api/v1/changes", r.handleChanges)
+	mux.HandleFunc("GET /api/v1/changes.ics", r.handleChangesICal)
+	mux.HandleFunc("GET /api/v1/events", r.handleEvents)
+	mux.HandleFunc("POST /api/v1/reload", r.handleReload)
+	mux.HandleFunc("POST /api/v1/onboarding", r.handleOnboarding)
+	mux.HandleFunc("GET /api/v1/zones.tar.gz", r.handleZonesArchive)
+	mux.HandleFunc("GET /api/v1/records", r.handleSearchRecords)
+	mux.HandleFunc("GET /api/v1/records/{id}/history", r.handleRecordHistory)
+	mux.HandleFunc("POST /api/v1/records/{id}/history/{version}/restore", r.handleRestoreRecordVersion)
+	mux.HandleFunc("GET /api/v1/tsig-keys", r.handleListTSIGKeys)
+	mux.HandleFunc("POST /api/v1/tsig-keys", r.handleCreateTSIGKey)
+	mux.HandleFunc("DELETE /api/v1/tsig-keys/{name}", r.handleDeleteTSIGKey)
+	mux.HandleFunc("GET /api/v1/tokens", r.handleListTokens)
+	mux.HandleFunc("POST /api/v1/tokens", r.handleCreateToken)
+	mux.HandleFunc("DELETE /api/v1/tokens/{name}", r.handleDeleteToken)
+	mux.HandleFunc("GET /api/v1/domains", r.handleListDomains)
+	mux.HandleFunc("POST /api/v1/domains", r.handleCreateDomain)
+	mux.HandleFunc("GET /api/v1/domains/{id}", r.handleGetDomain)
+	mux.HandleFunc("PUT /api/v1/domains/{id}", r.handleUpdateDomain)
+	mux.HandleFunc("DELETE /api/v1/domains/{id}", r.handleDeleteDomain)
+	mux.HandleFunc("POST /api/v1/domains/{name}/delegation", r.handleUpdateDelegation)
+	mux.HandleFunc("GET /api/v1/domains/{name}/generations", r.handleZoneGenerations)
+	mux.HandleFunc("GET /api/v1/domains/{id}/zone", r.handleZone)
+	mux.HandleFunc("GET /api/v1/domains/{id}/dnssec", r.handleDNSSEC)
+	mux.HandleFunc("POST /api/v1/domains/{id}/dnssec/keys", r.handleDNSSECKeygen)
+	mux.HandleFunc("GET /api/v1/domains/{id}/ds", r.handleDS)
+	mux.HandleFunc("GET /api/v1/domains/{id}/dnssec/rollovers", r.handleListRollovers)
+	mux.HandleFunc("POST /api/v1/domains/{id}/dnssec/rollovers", r.handleStartRollover)
+	mux.HandleFunc("POST /api/v1/domains/{id}/dnssec/rollovers/ksk/confirm", r.handleConfirmDS)
+	mux.HandleFunc("GET /api/v1/domains/{id}/records", r.handleListRecords)
+	mux.HandleFunc("POST /api/v1/domains/{id}/records", r.handleCreateRecord)
+	mux.HandleFunc("POST /api/v1/domains/{id}/records:batch", r.handleBatchRecords)
+	mux.HandleFunc("PUT /api/v1/domains/{id}/records/{recordID}", r.handleUpdateRecord)
+	mux.HandleFunc("DELETE /api/v1/domains/{id}/records/{recordID}", r.handleDeleteRecord)
+	mux.HandleFunc("GET /api/v1/domains/{id}/health-checks", r.handleListHealthChecks)
+	mux.HandleFunc("PUT /api/v1/domains/{id}/records/{recordID}/health-check", r.handlePutHealthCheck)
+	mux.HandleFunc("DELETE /api/v1/domains/{id}/records/{recordID}/health-check", r.handleDeleteHealthCheck)
+
+	server := &http.Server{
+		Addr:              r.config.HTTPListenAddr,
+		Handler:           r.authenticate(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-r.ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if r.config.APIAdminToken == "" && !r.apitokens {
+		r.logger.Warn("Neither API_ADMIN_TOKEN nor the api_tokens table is set up, so the HTTP API refuses every request")
+	}
+
+	go func() {
+		r.logger.WithFields(logrus.Fields{
+			"addr": r.config.HTTPListenAddr,
+			"tls":  r.tlsConfig != nil,
+		}).Info("HTTP server listening")
+		if err := r.serve(server); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			r.logger.WithError(err).Error("HTTP server failed")
+		}
+	}()
+}
+
+func readJSON(req *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, req.Body, 10<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// apiError is returned by the operations shared between the REST and gRPC
+// APIs so each transport can map it to its own status codes.
+type apiError struct {
+	status   int
+	message  string
+	problems []string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func errNotFound(message string) error {
+	return &apiError{status: http.StatusNotFound, message: message}
+}
+
+func errInvalid(message string) error {
+	return &apiError{status: http.StatusBadRequest, message: message}
+}
+
+func errForbidden(message string) error {
+	return &apiError{status: http.StatusForbidden, message: message}
+}
+
+func errConflict(message string) error {
+	return &apiError{status: http.StatusConflict, message: message}
+}
+
+func errValidation(problems []string) error {
+	return &apiError{status: http.StatusUnprocessableEntity, message: "validation failed", problems: problems}
+}
+
+// writeAPIError maps err to a response, treating anything other than an
+// apiError as an internal error.
+func writeAPIError(w http.ResponseWriter, err error) {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(apiErr.problems) > 0 {
+		writeJSON(w, apiErr.status, map[string]interface{}{
+			"error":    apiErr.message,
+			"problems": apiErr.problems,
+		})
+		return
+	}
+	writeError(w, apiErr.status, apiErr.message)
+}
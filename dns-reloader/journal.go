@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+)
+
+// ZoneJournal is a row of the zone_journal table: what changed in a zone's
+// file from one serial to the next. Deleted and Added are master-file
+// lines, the old and new SOA among them.
+type ZoneJournal struct {
+	ID         int       `gorm:"primaryKey;column:id"`
+	DomainID   int       `gorm:"column:domain_id"`
+	SerialFrom int64     `gorm:"column:serial_from"`
+	SerialTo   int64     `gorm:"column:serial_to"`
+	Deleted    string    `gorm:"column:deleted"`
+	Added      string    `gorm:"column:added"`
+	CreatedAt  time.Time `gorm:"column:created_at"`
+}
+
+func (ZoneJournal) TableName() string {
+	return "zone_journal"
+}
+
+// zoneDelta is one journal entry as IXFR sends it.
+type zoneDelta struct {
+	Deleted []dns.RR
+	Added   []dns.RR
+}
+
+// journalChange records the difference between the previous and the new
+// file of a zone, when the DNS server may be asked for IXFR. The newest
+// IXFR_JOURNAL_SIZE entries are kept. A file that changed without a new
+// serial, such as for a canary, breaks the chain, so the zone's journal is
+// dropped and secondaries fall back to AXFR. Failures are logged.
+func (r *Reloader) journalChange(ctx context.Context, domain Domain, previous, current string) {
+	if !r.journals || r.config.DNSListenAddr == "" || r.config.IXFRJournalSize <= 0 || previous == "" {
+		return
+	}
+	logger := r.logger.WithField("domain", domain.Name)
+	before, err := parseZone(strings.NewReader(previous), domain.Name, "previous")
+	if err != nil {
+		logger.WithError(err).Warn("Failed to read previous zone for the journal")
+		return
+	}
+	after, err := parseZone(strings.NewReader(current), domain.Name, "current")
+	if err != nil {
+		logger.WithError(err).Warn("Failed to read zone for the journal")
+		return
+	}
+	from, to := zoneSOA(before), zoneSOA(after)
+	if from == nil || to == nil {
+		return
+	}
+	db := r.db.WithContext(ctx)
+	if from.Serial == to.Serial {
+		if err := db.Where("domain_id = ?", domain.ID).Delete(&ZoneJournal{}).Error; err != nil {
+			logger.WithError(err).Warn("Failed to clear zone journal")
+		}
+		return
+	}
+
+	deleted, added := diffRRs(before, after)
+	entry := ZoneJournal{
+		DomainID:   int(domain.ID),
+		SerialFrom: int64(from.Serial),
+		SerialTo:   int64(to.Serial),
+		Deleted:    rrLines(deleted),
+		Added:      rrLines(added),
+		CreatedAt:  time.Now(),
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		logger.WithError(err).Warn("Failed to write zone journal")
+		return
+	}
+	var oldest []int
+	if err := db.Model(&ZoneJournal{}).Where("domain_id = ?", domain.ID).Order("id DESC").
+		Offset(r.config.IXFRJournalSize).Limit(1).Pluck("id", &oldest).Error; err == nil && len(oldest) > 0 {
+		db.Where("domain_id = ? AND id <= ?", domain.ID, oldest[0]).Delete(&ZoneJournal{})
+	}
+	logger.WithFields(logrus.Fields{
+		"serial":  to.Serial,
+		"deleted": len(deleted),
+		"added":   len(added),
+	}).Debug("Recorded zone journal entry")
+}
+
+// zoneSOA returns the first SOA of rrs.
+func zoneSOA(rrs []dns.RR) *dns.SOA {
+	for _, rr := range rrs {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa
+		}
+	}
+	return nil
+}
+
+// diffRRs returns the records only in before and those only in after,
+// with the SOA of each first as IXFR orders them.
+func diffRRs(before, after []dns.RR) (deleted, added []dns.RR) {
+	key := func(rr dns.RR) string {
+		return strings.ToLower(rr.Header().Name) + " " + strings.TrimPrefix(rr.String(), rr.Header().Name)
+	}
+	old := make(map[string]bool, len(before))
+	for _, rr := range before {
+		old[key(rr)] = true
+	}
+	current := make(map[string]bool, len(after))
+	for _, rr := range after {
+		current[key(rr)] = true
+		if !old[key(rr)] {
+			added = append(added, rr)
+		}
+	}
+	for _, rr := range before {
+		if !current[key(rr)] {
+			deleted = append(deleted, rr)
+		}
+	}
+	return soaFirst(deleted), soaFirst(added)
+}
+
+func soaFirst(rrs []dns.RR) []dns.RR {
+	for i, rr := range rrs {
+		if rr.Header().Rrtype == dns.TypeSOA {
+			rrs[0], rrs[i] = rrs[i], rrs[0]
+			break
+		}
+	}
+	return rrs
+}
+
+func rrLines(rrs []dns.RR) string {
+	var b strings.Builder
+	for _, rr := range rrs {
+		b.WriteString(rr.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// zoneDeltas returns the journal entries that take zone from serial to
+// current, or nil when the journal does not reach back that far.
+func (r *Reloader) zoneDeltas(ctx context.Context, zone string, serial, current uint32) ([]zoneDelta, error) {
+	if !r.journals {
+		return nil, nil
+	}
+	var entries []ZoneJournal
+	err := r.db.WithContext(ctx).
+		Where("domain_id IN (?)", r.db.Model(&Domain{}).Select("id").Where("name = ?", zone)).
+		Order("id").Find(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zone journal: %w", err)
+	}
+	next := make(map[int64]ZoneJournal, len(entries))
+	for _, entry := range entries {
+		next[entry.SerialFrom] = entry
+	}
+
+	var deltas []zoneDelta
+	for at := int64(serial); at != int64(current); {
+		entry, ok := next[at]
+		if !ok || len(deltas) > len(entries) {
+			return nil, nil
+		}
+		deleted, err := parseZone(strings.NewReader(entry.Deleted), zone, "journal")
+		if err != nil {
+			return nil, err
+		}
+		added, err := parseZone(strings.NewReader(entry.Added), zone, "journal")
+		if err != nil {
+			return nil, err
+		}
+		if zoneSOA(deleted) == nil || zoneSOA(added) == nil {
+			return nil, nil
+		}
+		deltas = append(deltas, zoneDelta{Deleted: deleted, Added: added})
+		at = entry.SerialTo
+	}
+	return deltas, nil
+}
+
+// incrementalRecords lays deltas out as an IXFR response: the current SOA,
+// each change as the deleted records and then the added ones, each led by
+// its SOA, and the current SOA again.
+func incrementalRecords(soa dns.RR, deltas []zoneDelta) []dns.RR {
+	records := []dns.RR{soa}
+	for _, delta := range deltas {
+		records = append(records, delta.Deleted...)
+		records = append(records, delta.Added...)
+	}
+	return append(records, soa)
+}
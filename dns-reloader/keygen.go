@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// encryptedKeyPrefix marks cryptokeys content encrypted with
+// DNSSEC_KEY_SECRET. PowerDNS cannot read such keys, so zones using them
+// must be signed here.
+const encryptedKeyPrefix = "encrypted:aes-256-gcm:"
+
+// keygenBits is the key size generated for each algorithm.
+var keygenBits = map[uint8]int{
+	dns.RSASHA256:       2048,
+	dns.RSASHA512:       2048,
+	dns.ECDSAP256SHA256: 256,
+	dns.ECDSAP384SHA384: 384,
+	dns.ED25519:         256,
+}
+
+// keySecret decodes DNSSEC_KEY_SECRET, a base64 AES-256 key.
+func (r *Reloader) keySecret() ([]byte, error) {
+	if r.config.DNSSECKeySecret == "" {
+		return nil, errors.New("DNSSEC_KEY_SECRET is not set")
+	}
+	secret, err := base64.StdEncoding.DecodeString(r.config.DNSSECKeySecret)
+	if err != nil || len(secret) != 32 {
+		return nil, errors.New("DNSSEC_KEY_SECRET must be 32 bytes, base64 encoded")
+	}
+	return secret, nil
+}
+
+func (r *Reloader) keyCipher() (cipher.AEAD, error) {
+	secret, err := r.keySecret()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptKeyContent seals a private key for the cryptokeys table.
+func (r *Reloader) encryptKeyContent(content string) (string, error) {
+	aead, err := r.keyCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(content), nil)
+	return encryptedKeyPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptKeyContent returns cryptokeys content as stored, or opened when
+// it was encrypted by encryptKeyContent.
+func (r *Reloader) decryptKeyContent(content string) (string, error) {
+	encoded, ok := strings.CutPrefix(content, encryptedKeyPrefix)
+	if !ok {
+		return content, nil
+	}
+	aead, err := r.keyCipher()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted key")
+	}
+	opened, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt key: %w", err)
+	}
+	return string(opened), nil
+}
+
+// parseAlgorithm reads a DNSSEC algorithm by mnemonic, as in
+// ECDSAP256SHA256, or number.
+func parseAlgorithm(name string) (uint8, error) {
+	algorithm, ok := dns.StringToAlgorithm[strings.ToUpper(strings.TrimSpace(name))]
+	if !ok {
+		for number := range dns.AlgorithmToString {
+			if fmt.Sprint(number) == strings.TrimSpace(name) {
+				algorithm, ok = number, true
+				break
+			}
+		}
+	}
+	if _, supported := keygenBits[algorithm]; !ok || !supported {
+		return 0, errInvalid(fmt.Sprintf("unsupported DNSSEC algorithm %q", name))
+	}
+	return algorithm, nil
+}
+
+// generateDNSSECKeys creates a key-signing and a zone-signing key for a
+// domain that has none, stores both in cryptokeys, encrypted with
+// DNSSEC_KEY_SECRET, and sets X-DNSSEC-SIGN so the zone is signed from its
+// next generation on. Callers notify the change themselves.
+func (r *Reloader) generateDNSSECKeys(ctx context.Context, ref, algorithmName string) (*Domain, []dnssecKey, error) {
+	if !r.cryptokeys {
+		return nil, nil, errConflict("the database has no cryptokeys table")
+	}
+	if algorithmName == "" {
+		algorithmName = r.config.DNSSECAlgorithm
+	}
+	algorithm, err := parseAlgorithm(algorithmName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := r.keySecret(); err != nil {
+		return nil, nil, errConflict(err.Error())
+	}
+	domain, err := r.findDomain(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var keys []dnssecKey
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&CryptoKey{}).Where("domain_id = ?", domain.ID).Count(&existing).Error; err != nil {
+			return fmt.Errorf("failed to read cryptokeys: %w", err)
+		}
+		if existing > 0 {
+			return errConflict(fmt.Sprintf("%s already has %d DNSSEC key(s)", domain.Name, existing))
+		}
+
+		for _, flags := range []uint16{dns.ZONE | dns.SEP, dns.ZONE} {
+			key, err := r.createKey(tx, domain, algorithm, flags, true, true)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, key)
+		}
+
+		kind, value := metaDNSSECSign, "true"
+		if err := tx.Where("domain_id = ? AND UPPER(kind) = ?", domain.ID, kind).Delete(&DomainMetadata{}).Error; err != nil {
+			return fmt.Errorf("failed to update domain metadata: %w", err)
+		}
+		meta := DomainMetadata{DomainID: int(domain.ID), Kind: &kind, Content: &value}
+		if err := tx.Omit(clause.Associations).Create(&meta).Error; err != nil {
+			return fmt.Errorf("failed to update domain metadata: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return domain, keys, nil
+}
+
+// createKey generates a key for domain and stores it in cryptokeys,
+// encrypted with DNSSEC_KEY_SECRET.
+func (r *Reloader) createKey(tx *gorm.DB, domain *Domain, algorithm uint8, flags uint16, active, published bool) (dnssecKey, error) {
+	dnskey := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: dns.Fqdn(domain.Name), Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET},
+		Flags:     flags,
+		Protocol:  3,
+		Algorithm: algorithm,
+	}
+	bits, ok := keygenBits[algorithm]
+	if !ok {
+		return dnssecKey{}, fmt.Errorf("unsupported DNSSEC algorithm %d", algorithm)
+	}
+	private, err := dnskey.Generate(bits)
+	if err != nil {
+		return dnssecKey{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	content, err := r.encryptKeyContent(dnskey.PrivateKeyString(private))
+	if err != nil {
+		return dnssecKey{}, err
+	}
+	row := CryptoKey{DomainID: int(domain.ID), Flags: int(flags), Active: &active, Published: &published, Content: content}
+	if err := tx.Create(&row).Error; err != nil {
+		return dnssecKey{}, fmt.Errorf("failed to store key: %w", err)
+	}
+	return dnssecKey{ID: row.ID, Active: active, Published: published, DNSKEY: dnskey}, nil
+}
+
+// dnssecCommand runs the dnssec subcommands, keygen and rollover.
+func (r *Reloader) dnssecCommand(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "keygen":
+			return r.dnssecKeygen(args[1:])
+		case "rollover":
+			return r.dnssecRollover(args[1:])
+		}
+	}
+	return errors.New("usage: reloader dnssec keygen [-algorithm name] <zone>\n       reloader dnssec rollover <zsk|ksk|confirm|status> <zone>")
+}
+
+// dnssecKeygen runs dnssec keygen.
+func (r *Reloader) dnssecKeygen(args []string) error {
+	fs := flag.NewFlagSet("dnssec keygen", flag.ContinueOnError)
+	algorithm := fs.String("algorithm", r.config.DNSSECAlgorithm, "algorithm of the generated keys")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: reloader dnssec keygen [flags] <zone>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("dnssec keygen takes one zone")
+	}
+	if err := r.connectDB(); err != nil {
+		return err
+	}
+
+	domain, keys, err := r.generateDNSSECKeys(r.ctx, fs.Arg(0), *algorithm)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		fmt.Printf("%s key %d: %s\n", domain.Name, key.ID, key.DNSKEY.String())
+		if key.DNSKEY.Flags&dns.SEP == 0 {
+			continue
+		}
+		if ds := key.DNSKEY.ToDS(dns.SHA256); ds != nil {
+			fmt.Printf("%s DS for the parent: %s\n", domain.Name, ds.String())
+		}
+	}
+	// Metadata changes do not notify the running reloader; the zone is
+	// signed on its next full regeneration.
+	r.logger.WithFields(logrus.Fields{
+		"domain":    domain.Name,
+		"algorithm": dns.AlgorithmToString[keys[0].DNSKEY.Algorithm],
+	}).Info("Generated DNSSEC keys")
+	return nil
+}
+
+type dnssecKeygenRequest struct {
+	Algorithm string `json:"algorithm"`
+}
+
+// handleDNSSECKeygen serves POST /api/v1/domains/{id}/dnssec/keys: generate
+// a key pair for a domain without keys and mark it for signing.
+func (r *Reloader) handleDNSSECKeygen(w http.ResponseWriter, req *http.Request) {
+	var body dnssecKeygenRequest
+	if req.ContentLength != 0 {
+		if err := readJSON(req, &body); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	domain, keys, err := r.generateDNSSECKeys(req.Context(), req.PathValue("id"), body.Algorithm)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	r.domainChanged(domain, "DNSSEC_KEYGEN")
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"domain": domain.Name,
+		"keys":   dnssecKeyResponses(keys),
+	})
+}
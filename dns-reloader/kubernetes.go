@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+const (
+	kubeReloadModeExec     = "exec"
+	kubeReloadModeAnnotate = "annotate"
+	kubeReloadModeRestart  = "restart"
+
+	kubeReloadAnnotation  = "core-admin/reloaded-at"
+	kubeRestartAnnotation = "kubectl.kubernetes.io/restartedAt"
+)
+
+// KubernetesBackend signals CoreDNS pods running in a Kubernetes cluster.
+type KubernetesBackend struct {
+	clientset  kubernetes.Interface
+	restConfig *rest.Config
+	namespace  string
+	selector   string
+	container  string
+	deployment string
+	mode       string
+	tracker    *targetTracker
+	logger     *logrus.Logger
+}
+
+func NewKubernetesBackend(config *Config, logger *logrus.Logger) (*KubernetesBackend, error) {
+	switch config.KubeReloadMode {
+	case kubeReloadModeExec, kubeReloadModeAnnotate, kubeReloadModeRestart:
+	default:
+		return nil, fmt.Errorf("unknown kubernetes reload mode %q", config.KubeReloadMode)
+	}
+
+	clientset, restConfig, err := kubernetesClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KubernetesBackend{
+		clientset:  clientset,
+		restConfig: restConfig,
+		namespace:  config.KubeNamespace,
+		selector:   config.KubeLabelSelector,
+		container:  config.KubeContainer,
+		deployment: config.KubeDeployment,
+		mode:       config.KubeReloadMode,
+		tracker:    newTargetTracker(),
+		logger:     logger,
+	}, nil
+}
+
+// kubernetesClient connects with KUBECONFIG, or the service account of the
+// pod when it is not set.
+func kubernetesClient(config *Config) (*kubernetes.Clientset, *rest.Config, error) {
+	var restConfig *rest.Config
+	var err error
+	if config.Kubeconfig != "" {
+		restConfig, err = clientcmd.BuildConfigFromFlags("", config.Kubeconfig)
+	} else {
+		restConfig, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load kubernetes config: %w", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	return clientset, restConfig, nil
+}
+
+func (k *KubernetesBackend) Name() string {
+	return "kubernetes"
+}
+
+func (k *KubernetesBackend) Reload(ctx context.Context, zones []string) error {
+	if k.mode == kubeReloadModeRestart {
+		return k.restartDeployment(ctx)
+	}
+
+	pods, err := k.clientset.CoreV1().Pods(k.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: k.selector,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list coredns pods: %w", err)
+	}
+	if len(pods.Items) == 0 {
+		return fmt.Errorf("no pods match selector %q in namespace %s", k.selector, k.namespace)
+	}
+
+	running := make(map[string]corev1.Pod)
+	var names []string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning {
+			running[pod.Name] = pod
+			names = append(names, pod.Name)
+		}
+	}
+
+	return k.tracker.reloadAll(ctx, names, func(ctx context.Context, name string) error {
+		if k.mode == kubeReloadModeAnnotate {
+			return k.annotatePod(ctx, name)
+		}
+		return k.execSignal(ctx, running[name])
+	})
+}
+
+func (k *KubernetesBackend) Targets() []TargetResult {
+	return k.tracker.Targets()
+}
+
+func (k *KubernetesBackend) execSignal(ctx context.Context, pod corev1.Pod) error {
+	container := k.container
+	if container == "" {
+		container = pod.Spec.Containers[0].Name
+	}
+
+	req := k.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   []string{"sh", "-c", "kill -USR1 1"},
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(k.restConfig, "POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("failed to create exec session: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}); err != nil {
+		return fmt.Errorf("exec failed: %w (output: %s)", err, stderr.String())
+	}
+	return nil
+}
+
+func (k *KubernetesBackend) annotatePod(ctx context.Context, name string) error {
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`,
+		kubeReloadAnnotation, time.Now().UTC().Format(time.RFC3339))
+
+	_, err := k.clientset.CoreV1().Pods(k.namespace).Patch(
+		ctx, name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to annotate pod: %w", err)
+	}
+	return nil
+}
+
+// restartDeployment mirrors `kubectl rollout restart` by bumping the pod
+// template annotation, which rolls every replica.
+func (k *KubernetesBackend) restartDeployment(ctx context.Context) error {
+	patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`,
+		kubeRestartAnnotation, time.Now().UTC().Format(time.RFC3339))
+
+	_, err := k.clientset.AppsV1().Deployments(k.namespace).Patch(
+		ctx, k.deployment, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to restart deployment %s: %w", k.deployment, err)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Ways of electing the leader for LEADER_ELECTION.
+const (
+	leaderElectionDatabase   = "database"
+	leaderElectionKubernetes = "kubernetes"
+)
+
+// errStandby is returned to callers waiting for a change to be applied by
+// a reloader that is not the leader.
+var errStandby = errors.New("this reloader is on standby; the leader applies changes")
+
+// tryLocker is implemented by drivers with session-level named locks that
+// can be tried without waiting.
+type tryLocker interface {
+	tryLock(ctx context.Context, conn *sql.Conn, name string) (bool, error)
+}
+
+func (p *PostgresStorage) tryLock(ctx context.Context, conn *sql.Conn, name string) (bool, error) {
+	var acquired bool
+	err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", name).Scan(&acquired)
+	return acquired, err
+}
+
+func (m *MySQLStorage) tryLock(ctx context.Context, conn *sql.Conn, name string) (bool, error) {
+	var acquired sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", name).Scan(&acquired)
+	return acquired.Int64 == 1, err
+}
+
+// isLeader reports whether this reloader writes zone files and signals
+// CoreDNS. Without LEADER_ELECTION every reloader does.
+func (r *Reloader) isLeader() bool {
+	return r.config.LeaderElection == "" || r.leader.Load()
+}
+
+// setLeader records a change of leadership. A new leader regenerates and
+// reloads every zone, since the previous one may have stopped half way.
+func (r *Reloader) setLeader(leading bool) {
+	if r.leader.Swap(leading) == leading {
+		return
+	}
+	logger := r.logger.WithFields(logrus.Fields{
+		"election": r.config.LeaderElection,
+		"identity": r.config.LeaderIdentity,
+	})
+	if !leading {
+		leaderGauge.Set(0)
+		if r.ctx.Err() == nil {
+			logger.Warn("Lost leadership, standing by")
+		}
+		return
+	}
+	leaderGauge.Set(1)
+	logger.Info("Became leader")
+	select {
+	case r.localChanges <- &DNSChangeNotification{Table: "leader", Action: "RESYNC", Timestamp: time.Now(), force: true}:
+	case <-r.ctx.Done():
+	}
+}
+
+// startLeaderElection campaigns for leadership until shutdown, with
+// LEADER_ELECTION=database holding the LEADER_ELECTION_NAME lock of the
+// database and with LEADER_ELECTION=kubernetes a Lease of that name. A
+// standby serves the API and transfers as usual, but leaves zone files,
+// reloads, the outbox and periodic upkeep to the leader.
+func (r *Reloader) startLeaderElection() error {
+	switch r.config.LeaderElection {
+	case "":
+		return nil
+	case leaderElectionDatabase:
+		locker, ok := r.storage.(tryLocker)
+		if !ok {
+			return fmt.Errorf("LEADER_ELECTION=database is not supported with the %s driver", r.storage.Name())
+		}
+		go r.electWithDatabase(locker)
+	case leaderElectionKubernetes:
+		elector, err := r.kubernetesElector()
+		if err != nil {
+			return err
+		}
+		go func() {
+			for r.ctx.Err() == nil {
+				elector.Run(r.ctx)
+			}
+		}()
+	default:
+		return fmt.Errorf("unknown LEADER_ELECTION %q, expected database or kubernetes", r.config.LeaderElection)
+	}
+	leaderGauge.Set(0)
+	r.logger.WithFields(logrus.Fields{
+		"election": r.config.LeaderElection,
+		"name":     r.config.LeaderElectionName,
+		"identity": r.config.LeaderIdentity,
+	}).Info("Standing by for leader election")
+	return nil
+}
+
+// electWithDatabase tries for the lock every LEADER_RETRY_PERIOD on a
+// connection of its own, and once it holds it checks that connection as
+// often. The lock goes with the session, so a leader that loses its
+// connection steps down and another reloader takes the lock.
+func (r *Reloader) electWithDatabase(locker tryLocker) {
+	var conn *sql.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+		r.setLeader(false)
+	}()
+	ticker := time.NewTicker(r.config.LeaderRetryPeriod)
+	defer ticker.Stop()
+	for {
+		ctx, cancel := context.WithTimeout(r.ctx, r.config.LeaderRetryPeriod)
+		if conn == nil {
+			var err error
+			if conn, err = r.rawDB.Conn(ctx); err != nil {
+				r.logger.WithError(err).Warn("Failed to get connection for leader election")
+			}
+		}
+		if conn != nil && r.leader.Load() {
+			if err := conn.PingContext(ctx); err != nil {
+				r.logger.WithError(err).Error("Lost leader election connection")
+				conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+				conn.Close()
+				conn = nil
+				r.setLeader(false)
+			}
+		} else if conn != nil {
+			acquired, err := locker.tryLock(ctx, conn, r.config.LeaderElectionName)
+			if err != nil {
+				r.logger.WithError(err).Warn("Failed to try leader election lock")
+				conn.Close()
+				conn = nil
+			}
+			if acquired {
+				r.setLeader(true)
+			}
+		}
+		cancel()
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// kubernetesElector campaigns for a coordination.k8s.io Lease in
+// LEADER_ELECTION_NAMESPACE, renewed every LEADER_RETRY_PERIOD and taken
+// over LEADER_LEASE_DURATION after its holder stops renewing it.
+func (r *Reloader) kubernetesElector() (*leaderelection.LeaderElector, error) {
+	clientset, _, err := kubernetesClient(r.config)
+	if err != nil {
+		return nil, err
+	}
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: r.config.LeaderElectionName, Namespace: r.config.LeaderElectionNamespace},
+		Client:     clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: r.config.LeaderIdentity},
+	}
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   r.config.LeaderLeaseDuration,
+		RenewDeadline:   r.config.LeaderLeaseDuration * 2 / 3,
+		RetryPeriod:     r.config.LeaderRetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            r.config.LeaderElectionName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) { r.setLeader(true) },
+			OnStoppedLeading: func() { r.setLeader(false) },
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid leader election configuration: %w", err)
+	}
+	return elector, nil
+}
+
+// leaderIdentity names this reloader in the election: the pod name on
+// Kubernetes, otherwise the hostname.
+func leaderIdentity() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		return hostname
+	}
+	return fmt.Sprintf("dns-reloader-%d", os.Getpid())
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// advisoryLocker is implemented by drivers with session-level named locks.
+// The lock belongs to conn and is released by unlock or when its session
+// ends.
+type advisoryLocker interface {
+	lock(ctx context.Context, conn *sql.Conn, name string) error
+	unlock(ctx context.Context, conn *sql.Conn, name string) error
+}
+
+// lock waits without DB_STATEMENT_TIMEOUT and DB_LOCK_TIMEOUT, which would
+// otherwise cut the wait short; REGENERATION_LOCK_TIMEOUT bounds it instead.
+func (p *PostgresStorage) lock(ctx context.Context, conn *sql.Conn, name string) error {
+	if _, err := conn.ExecContext(ctx, "SET statement_timeout = 0"); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "SET lock_timeout = 0"); err != nil {
+		return err
+	}
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock(hashtext($1))", name)
+	return err
+}
+
+func (p *PostgresStorage) unlock(ctx context.Context, conn *sql.Conn, name string) error {
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock(hashtext($1))", name); err != nil {
+		return err
+	}
+	_, err := conn.ExecContext(ctx, "RESET statement_timeout")
+	if err == nil {
+		_, err = conn.ExecContext(ctx, "RESET lock_timeout")
+	}
+	return err
+}
+
+func (m *MySQLStorage) lock(ctx context.Context, conn *sql.Conn, name string) error {
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, -1)", name).Scan(&acquired); err != nil {
+		return err
+	}
+	if acquired.Int64 != 1 {
+		return fmt.Errorf("GET_LOCK(%q) was not granted", name)
+	}
+	return nil
+}
+
+func (m *MySQLStorage) unlock(ctx context.Context, conn *sql.Conn, name string) error {
+	_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", name)
+	return err
+}
+
+// lockRegeneration takes the REGENERATION_LOCK database lock so reloaders
+// sharing a zones volume regenerate one at a time. The second instance then
+// finds the files already current and does not reload again. It returns a
+// no-op when the lock is disabled or the driver has none (SQLite is
+// single-node anyway).
+func (r *Reloader) lockRegeneration(ctx context.Context) (func(), error) {
+	locker, ok := r.storage.(advisoryLocker)
+	if !ok || r.config.RegenLock == "" || r.rawDB == nil {
+		return func() {}, nil
+	}
+
+	conn, err := r.rawDB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection for regeneration lock: %w", err)
+	}
+	lockCtx, cancel := context.WithTimeout(ctx, r.config.RegenLockTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := locker.lock(lockCtx, conn, r.config.RegenLock); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to acquire regeneration lock %q: %w", r.config.RegenLock, err)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		r.logger.WithFields(logrus.Fields{
+			"lock":   r.config.RegenLock,
+			"waited": waited.Round(time.Millisecond),
+		}).Info("Waited for another instance to finish regenerating")
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := locker.unlock(ctx, conn, r.config.RegenLock); err != nil {
+			r.logger.WithError(err).Warn("Failed to release regeneration lock")
+			// The lock lives as long as the session, so drop the
+			// connection rather than return it to the pool.
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// configureLogOutput applies LOG_FORMAT (text or json) and LOG_OUTPUT
+// (stderr, stdout or file:/path). File output is rotated by size, keeping
+// LOG_MAX_BACKUPS compressed files for up to LOG_MAX_AGE_DAYS. On error the
+// logger is left unchanged.
+func configureLogOutput(logger *logrus.Logger, config *Config) error {
+	var formatter logrus.Formatter
+	switch strings.ToLower(config.LogFormat) {
+	case "", "text":
+		formatter = &logrus.TextFormatter{}
+	case "json":
+		formatter = &logrus.JSONFormatter{
+			TimestampFormat: time.RFC3339Nano,
+			FieldMap: logrus.FieldMap{
+				logrus.FieldKeyTime: "timestamp",
+				logrus.FieldKeyMsg:  "message",
+			},
+		}
+	default:
+		return fmt.Errorf("unknown LOG_FORMAT %q", config.LogFormat)
+	}
+
+	var output io.Writer
+	switch {
+	case config.LogOutput == "" || config.LogOutput == "stderr":
+		output = os.Stderr
+	case config.LogOutput == "stdout":
+		output = os.Stdout
+	case strings.HasPrefix(config.LogOutput, "file:"):
+		path := strings.TrimPrefix(config.LogOutput, "file:")
+		if path == "" {
+			return fmt.Errorf("LOG_OUTPUT file path is empty")
+		}
+		output = &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    config.LogMaxSizeMB,
+			MaxBackups: config.LogMaxBackups,
+			MaxAge:     config.LogMaxAgeDays,
+			Compress:   true,
+		}
+	default:
+		return fmt.Errorf("unknown LOG_OUTPUT %q", config.LogOutput)
+	}
+
+	logger.SetFormatter(formatter)
+	logger.SetOutput(output)
+	return nil
+}
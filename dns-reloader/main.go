@@ -2,32 +2,236 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/lib/pq"
+	"github.com/miekg/dns"
 	"github.com/sirupsen/logrus"
-	"gorm.io/driver/postgres"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
 type Config struct {
-	PostgresHost     string
-	PostgresDB       string
-	PostgresUser     string
-	PostgresPassword string
-	CoreDNSContainer string
-	ZonesDirectory   string
-	LogLevel         string
-	PollInterval     time.Duration
+	DBDriver                string
+	DatabaseURL             string
+	ReplicaURL              string
+	ReplicaMaxLag           time.Duration
+	NotifyChannels          []string
+	InstallTriggers         bool
+	SoftDelete              bool
+	Outbox                  bool
+	OutboxConsumer          string
+	OutboxBatchSize         int
+	OutboxRetention         time.Duration
+	RegenLock               string
+	RegenLockTimeout        time.Duration
+	LeaderElection          string
+	LeaderElectionName      string
+	LeaderElectionNamespace string
+	LeaderIdentity          string
+	LeaderLeaseDuration     time.Duration
+	LeaderRetryPeriod       time.Duration
+	ShardCount              int
+	ShardIndex              int
+	EtcdEndpoints           []string
+	EtcdPath                string
+	EtcdUsername            string
+	EtcdPassword            string
+	EtcdCACert              string
+	EtcdCert                string
+	EtcdKey                 string
+	EtcdResync              time.Duration
+	ConsulAddress           string
+	ConsulToken             string
+	ConsulPrefix            string
+	ConsulWait              time.Duration
+	ConsulCACert            string
+	ChangeSource            string
+	NATSURL                 string
+	NATSSubject             string
+	NATSQueue               string
+	NATSCredentials         string
+	KafkaBrokers            []string
+	KafkaTopic              string
+	KafkaGroupID            string
+	RedisURL                string
+	RedisChannel            string
+
+	DBMaxOpenConns          int
+	DBMaxIdleConns          int
+	DBConnMaxLifetime       time.Duration
+	DBStatementTimeout      time.Duration
+	DBLockTimeout           time.Duration
+	PostgresHost            string
+	PostgresDB              string
+	PostgresUser            string
+	PostgresPassword        string
+	PostgresPort            int
+	PostgresSSLMode         string
+	PostgresSSLCert         string
+	PostgresSSLKey          string
+	PostgresSSLCA           string
+	VaultDBCredsPath        string
+	SecretRefreshInterval   time.Duration
+	MySQLHost               string
+	MySQLPort               int
+	MySQLDB                 string
+	MySQLUser               string
+	MySQLPassword           string
+	SQLitePath              string
+	CoreDNSContainer        string
+	CoreDNSLabel            string
+	ZonesDirectory          string
+	TenantDirectories       []string
+	Views                   []string
+	Regions                 []string
+	GenerateTypes           []string
+	AlsoNotify              []string
+	NotifyRetries           int
+	NotifyRetryDelay        time.Duration
+	WeightsFile             string
+	WeightRotationInterval  time.Duration
+	OutputFormat            string
+	OutputFile              string
+	OutputZones             []string
+	DNSSECKeyDirectory      string
+	DNSSECSignatureValidity time.Duration
+	DNSSECRefresh           time.Duration
+	DNSSECAlgorithm         string
+	DNSSECKeySecret         string
+	DNSSECZSKLifetime       time.Duration
+	DNSSECRolloverMargin    time.Duration
+	DNSSECParentDSTTL       time.Duration
+	DNSSECSignerCommand     string
+	DNSSECSignerTimeout     time.Duration
+	ZonesGit                bool
+	ZonesGitRemote          string
+	ZonesGitBranch          string
+	ZonesGitAuthor          string
+	ZonesGitMessage         string
+	ZonesGitPush            bool
+	BackupS3Bucket          string
+	BackupS3Endpoint        string
+	BackupS3Region          string
+	BackupS3Prefix          string
+	BackupInterval          time.Duration
+	BackupRetention         time.Duration
+	CanaryRecord            string
+	CanaryZones             []string
+	CanaryInterval          time.Duration
+	LogLevel                string
+	PollInterval            time.Duration
+
+	DriftCheckInterval time.Duration
+	DriftAutoHeal      bool
+
+	LogFormat        string
+	LogOutput        string
+	LogMaxSizeMB     int
+	LogMaxBackups    int
+	LogMaxAgeDays    int
+	AuditGenerations bool
+
+	VerifyAddress  string
+	VerifyAttempts int
+	VerifyInterval time.Duration
+
+	HTTPListenAddr        string
+	GRPCListenAddr        string
+	APIAdminToken         string
+	PprofListenAddr       string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSClientCAFile       string
+	TLSClientAuth         string
+	DNSListenAddr         string
+	AXFRAllowFrom         []string
+	AXFRTSIGKeys          []string
+	IXFRJournalSize       int
+	SecondaryMode         bool
+	CatalogZone           string
+	UpdateAllowFrom       []string
+	UpdateTSIGKeys        []string
+	DynDNSTTL             int
+	OTLPEndpoint          string
+	ChangeCalendarDays    int
+	OnboardingNameservers []string
+	DomainNameservers     []string
+	DomainHostmaster      string
+	DomainSOATimers       string
+	DomainDefaultTTL      int
+
+	Registrar           string
+	RegistrarAPIKey     string
+	RegistrarAutoUpdate bool
+	NamecheapAPIUser    string
+	NamecheapClientIP   string
+
+	SyncProviders   []string
+	Route53Zones    []string
+	CloudflareToken string
+	CloudflareZones []string
+	RFC2136Servers  []string
+	RFC2136TSIG     string
+	RFC2136Zones    []string
+
+	ShutdownWebhookURL  string
+	AlertWebhookURL     string
+	AlertWebhookFormat  string
+	AlertThreshold      int
+	AlertRepeatInterval time.Duration
+	AlertEmailTo        []string
+	AlertEmailKinds     []string
+	DatabaseAlertAfter  time.Duration
+	SMTPHost            string
+	SMTPPort            string
+	SMTPUsername        string
+	SMTPPassword        string
+	SMTPFrom            string
+	SMTPSecurity        string
+
+	ReloadBackend     string
+	ReloadMaxAttempts int
+	ReloadRetryLimit  int
+	ReloadBackoff     time.Duration
+	ReloadBackoffMax  time.Duration
+	ReloadCommand     string
+	ReloadPID         int
+	ReloadPIDFile     string
+	ReloadProcessName string
+	ReloadSignal      string
+	PodmanSocket      string
+	ControlArgs       string
+	SystemdUnit       string
+	SystemdReloadMode string
+	SSHHosts          []string
+	SSHUser           string
+	SSHKeyFile        string
+	SSHKnownHosts     string
+	SSHReloadCommand  string
+	SSHSyncDir        string
+	Kubeconfig        string
+	KubeNamespace     string
+	KubeLabelSelector string
+	KubeContainer     string
+	KubeDeployment    string
+	KubeReloadMode    string
 }
 
 type DNSChangeNotification struct {
@@ -38,38 +242,56 @@ type DNSChangeNotification struct {
 	Name      string    `json:"name"`
 	Type      string    `json:"type"`
 	Timestamp time.Time `json:"timestamp"`
+
+	// force reloads the named zone, or every zone when Name is empty, even
+	// if regeneration left it unchanged. done receives the outcome.
+	force bool
+	done  chan ChangeSet
+	// internal marks a change the reloader made itself rather than one
+	// written to the database, so the outbox has no row for it.
+	internal bool
 }
 
 // GORM Models matching existing schema
 type Record struct {
-	ID        uint      `gorm:"primaryKey;column:id" json:"id"`
-	DomainID  int       `gorm:"column:domain_id;index" json:"domain_id"`
-	Name      string    `gorm:"column:name;index" json:"name"`
-	Type      string    `gorm:"column:type;index" json:"type"`
-	Content   string    `gorm:"column:content" json:"content"`
-	TTL       int       `gorm:"column:ttl" json:"ttl"`
-	Prio      *int      `gorm:"column:prio" json:"prio,omitempty"`
-	Disabled  bool      `gorm:"column:disabled;default:false" json:"disabled"`
-	Ordername *string   `gorm:"column:ordername" json:"ordername,omitempty"`
-	Auth      bool      `gorm:"column:auth;default:true" json:"auth"`
-	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
-	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
-	CreatedBy string    `gorm:"column:created_by" json:"created_by"`
-	Comment   *string   `gorm:"column:comment" json:"comment,omitempty"`
-	Domain    Domain    `gorm:"foreignKey:DomainID;references:ID" json:"domain,omitempty"`
+	ID        uint          `gorm:"primaryKey;column:id" json:"id"`
+	DomainID  int           `gorm:"column:domain_id;index" json:"domain_id"`
+	Name      string        `gorm:"column:name;index" json:"name"`
+	Type      string        `gorm:"column:type;index" json:"type"`
+	Content   string        `gorm:"column:content" json:"content"`
+	TTL       int           `gorm:"column:ttl" json:"ttl"`
+	Prio      *int          `gorm:"column:prio" json:"prio,omitempty"`
+	Weight    *int          `gorm:"column:weight;->" json:"weight,omitempty"`
+	Disabled  bool          `gorm:"column:disabled;default:false" json:"disabled"`
+	Ordername *string       `gorm:"column:ordername" json:"ordername,omitempty"`
+	Auth      bool          `gorm:"column:auth;default:true" json:"auth"`
+	CreatedAt time.Time     `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt time.Time     `gorm:"column:updated_at" json:"updated_at"`
+	CreatedBy string        `gorm:"column:created_by" json:"created_by"`
+	Comment   *string       `gorm:"column:comment" json:"comment,omitempty"`
+	View      *string       `gorm:"column:view;->" json:"view,omitempty"`
+	Region    *string       `gorm:"column:region;->" json:"region,omitempty"`
+	NotBefore *time.Time    `gorm:"column:not_before;->" json:"not_before,omitempty"`
+	NotAfter  *time.Time    `gorm:"column:not_after;->" json:"not_after,omitempty"`
+	Comments  []Comment     `gorm:"-" json:"comments,omitempty"`
+	DeletedAt SoftDeletedAt `gorm:"column:deleted_at;->" json:"-"`
+	Domain    Domain        `gorm:"foreignKey:DomainID;references:ID" json:"domain,omitempty"`
 }
 
 type Domain struct {
-	ID             uint      `gorm:"primaryKey;column:id" json:"id"`
-	Name           string    `gorm:"column:name;uniqueIndex" json:"name"`
-	Master         *string   `gorm:"column:master" json:"master,omitempty"`
-	LastCheck      *int      `gorm:"column:last_check" json:"last_check,omitempty"`
-	Type           string    `gorm:"column:type" json:"type"`
-	NotifiedSerial *int      `gorm:"column:notified_serial" json:"notified_serial,omitempty"`
-	Account        *string   `gorm:"column:account" json:"account,omitempty"`
-	CreatedAt      time.Time `gorm:"column:created_at" json:"created_at"`
-	UpdatedAt      time.Time `gorm:"column:updated_at" json:"updated_at"`
-	Records        []Record  `gorm:"foreignKey:DomainID;references:ID" json:"records,omitempty"`
+	ID             uint          `gorm:"primaryKey;column:id" json:"id"`
+	Name           string        `gorm:"column:name;uniqueIndex" json:"name"`
+	Master         *string       `gorm:"column:master" json:"master,omitempty"`
+	LastCheck      *int          `gorm:"column:last_check" json:"last_check,omitempty"`
+	Type           string        `gorm:"column:type" json:"type"`
+	NotifiedSerial *int          `gorm:"column:notified_serial" json:"notified_serial,omitempty"`
+	Account        *string       `gorm:"column:account" json:"account,omitempty"`
+	CreatedAt      time.Time     `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      time.Time     `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt      SoftDeletedAt `gorm:"column:deleted_at;->" json:"-"`
+	Records        []Record      `gorm:"foreignKey:DomainID;references:ID" json:"records,omitempty"`
+	Options        zoneOptions   `gorm:"-" json:"-"`
+	Keys           []dnssecKey   `gorm:"-" json:"-"`
 }
 
 // Table names to match existing schema
@@ -82,25 +304,282 @@ func (Domain) TableName() string {
 }
 
 type Reloader struct {
-	config   *Config
-	db       *gorm.DB
-	rawDB    *sql.DB
-	listener *pq.Listener
-	logger   *logrus.Logger
-	ctx      context.Context
-	cancel   context.CancelFunc
+	config          *Config
+	db              *gorm.DB
+	rawDB           *sql.DB
+	replica         *gorm.DB
+	comments        bool
+	cryptokeys      bool
+	rollovers       bool
+	tsigkeys        bool
+	journals        bool
+	dyndns          bool
+	apitokens       bool
+	accounts        bool
+	roles           bool
+	recordWeights   bool
+	recordViews     bool
+	recordRegions   bool
+	healthchecks    bool
+	recordSchedules bool
+	scheduleMu      sync.Mutex
+	scheduleTimer   *time.Timer
+	healthMu        sync.RWMutex
+	unhealthy       map[uint]bool
+	etcd            *etcdSource
+	consul          *consulSource
+	source          ChangeSource
+	storage         Storage
+	listener        *pq.Listener
+	tlsConfig       *tls.Config
+	dbCredentials   *dbCredentials
+	backend         ReloadBackend
+	changes         *ChangeHistory
+	events          *broker[ChangeSet]
+	activity        *broker[ActivityEvent]
+	registrar       Registrar
+	providers       []ZoneProvider
+	zoneRepo        *zoneRepo
+	backup          *zoneBackup
+	alerts          *alertManager
+	pending         *pendingReload
+	stats           *runStats
+	status          *statusTracker
+	logger          *logrus.Logger
+	ctx             context.Context
+	cancel          context.CancelFunc
+
+	localChanges       chan *DNSChangeNotification
+	credentialsRotated chan struct{}
+	lastCanaryRefresh  time.Time
+	lastWeightRotation time.Time
+	weightedGroups     atomic.Bool
+	lastDriftCheck     time.Time
+	lastBackup         time.Time
+	lastResign         time.Time
+	lastRolloverCheck  time.Time
+	lastOutboxPrune    time.Time
+	zoneOptsMu         sync.RWMutex
+	zoneOpts           map[string]zoneOptions
+	notifiedMu         sync.Mutex
+	notified           map[string]string
+	tsigMu             sync.Mutex
+	tsigCache          map[string]*tsigKey
+	tsigLoaded         time.Time
+	secondaryMu        sync.Mutex
+	secondaries        map[string]*secondaryZone
+	tokenLimits        tokenLimiter
+	dbDownSince        time.Time
+	leader             atomic.Bool
 }
 
 func NewReloader() *Reloader {
+	hostname, _ := os.Hostname()
 	config := &Config{
-		PostgresHost:     getEnv("POSTGRES_HOST", "postgres"),
-		PostgresDB:       getEnv("POSTGRES_DB", "coredns"),
-		PostgresUser:     getEnv("POSTGRES_USER", "coredns"),
-		PostgresPassword: getEnv("POSTGRES_PASSWORD", ""),
-		CoreDNSContainer: getEnv("COREDNS_CONTAINER", "coredns-server"),
-		ZonesDirectory:   getEnv("ZONES_DIRECTORY", "/etc/coredns/zones"),
-		LogLevel:         getEnv("LOG_LEVEL", "info"),
-		PollInterval:     parseDuration(getEnv("POLL_INTERVAL", "5s")),
+		DBDriver:                getEnv("DB_DRIVER", "postgres"),
+		DatabaseURL:             getSecret("DATABASE_URL", ""),
+		ReplicaURL:              getSecret("DATABASE_REPLICA_URL", ""),
+		ReplicaMaxLag:           parseDuration(getEnv("REPLICA_MAX_LAG", "2s")),
+		NotifyChannels:          parseList(getEnv("NOTIFY_CHANNELS", "dns_records_changed")),
+		InstallTriggers:         parseBool(getEnv("AUTO_INSTALL_TRIGGERS", "false")),
+		SoftDelete:              parseBool(getEnv("SOFT_DELETE", "false")),
+		Outbox:                  parseBool(getEnv("CHANGE_OUTBOX", "false")),
+		OutboxConsumer:          getEnv("OUTBOX_CONSUMER", hostname),
+		OutboxBatchSize:         parseInt(getEnv("OUTBOX_BATCH_SIZE", "1000")),
+		OutboxRetention:         parseDuration(getEnv("OUTBOX_RETENTION", "168h")),
+		RegenLock:               getEnv("REGENERATION_LOCK", "dns-reloader"),
+		RegenLockTimeout:        parseDuration(getEnv("REGENERATION_LOCK_TIMEOUT", "5m")),
+		LeaderElection:          strings.ToLower(getEnv("LEADER_ELECTION", "")),
+		LeaderElectionName:      getEnv("LEADER_ELECTION_NAME", "dns-reloader-leader"),
+		LeaderElectionNamespace: getEnv("LEADER_ELECTION_NAMESPACE", getEnv("POD_NAMESPACE", "default")),
+		LeaderIdentity:          leaderIdentity(),
+		LeaderLeaseDuration:     parseDuration(getEnv("LEADER_LEASE_DURATION", "15s")),
+		LeaderRetryPeriod:       parseDuration(getEnv("LEADER_RETRY_PERIOD", "2s")),
+		ShardCount:              parseInt(getEnv("SHARD_COUNT", "1")),
+		ShardIndex:              shardIndex(leaderIdentity()),
+		EtcdEndpoints:           parseList(getEnv("ETCD_ENDPOINTS", "")),
+		EtcdPath:                getEnv("ETCD_PATH", "/skydns"),
+		EtcdUsername:            getEnv("ETCD_USERNAME", ""),
+		EtcdPassword:            getSecret("ETCD_PASSWORD", ""),
+		EtcdCACert:              getEnv("ETCD_CACERT", ""),
+		EtcdCert:                getEnv("ETCD_CERT", ""),
+		EtcdKey:                 getEnv("ETCD_KEY", ""),
+		EtcdResync:              parseDuration(getEnv("ETCD_RESYNC_INTERVAL", "5m")),
+		ConsulAddress:           getEnv("CONSUL_HTTP_ADDR", ""),
+		ConsulToken:             getSecret("CONSUL_HTTP_TOKEN", ""),
+		ConsulPrefix:            getEnv("CONSUL_KV_PREFIX", "dns"),
+		ConsulWait:              parseDuration(getEnv("CONSUL_WAIT", "5m")),
+		ConsulCACert:            getEnv("CONSUL_CACERT", ""),
+		ChangeSource:            getEnv("CHANGE_SOURCE", ""),
+		NATSURL:                 getSecret("NATS_URL", "nats://127.0.0.1:4222"),
+		NATSSubject:             getEnv("NATS_SUBJECT", "dns.changes"),
+		NATSQueue:               getEnv("NATS_QUEUE", ""),
+		NATSCredentials:         getEnv("NATS_CREDS", ""),
+		KafkaBrokers:            parseList(getEnv("KAFKA_BROKERS", "")),
+		KafkaTopic:              getEnv("KAFKA_TOPIC", "dns-changes"),
+		KafkaGroupID:            getEnv("KAFKA_GROUP_ID", hostname),
+		RedisURL:                getSecret("REDIS_URL", "redis://127.0.0.1:6379/0"),
+		RedisChannel:            getEnv("REDIS_CHANNEL", "dns_changes"),
+
+		DBMaxOpenConns:          parseInt(getEnv("DB_MAX_OPEN_CONNS", "5")),
+		DBMaxIdleConns:          parseInt(getEnv("DB_MAX_IDLE_CONNS", "2")),
+		DBConnMaxLifetime:       parseDuration(getEnv("DB_CONN_MAX_LIFETIME", "1h")),
+		DBStatementTimeout:      parseDuration(getEnv("DB_STATEMENT_TIMEOUT", "0s")),
+		DBLockTimeout:           parseDuration(getEnv("DB_LOCK_TIMEOUT", "0s")),
+		PostgresHost:            getEnv("POSTGRES_HOST", "postgres"),
+		PostgresDB:              getEnv("POSTGRES_DB", "coredns"),
+		PostgresUser:            getEnv("POSTGRES_USER", "coredns"),
+		PostgresPassword:        getSecret("POSTGRES_PASSWORD", ""),
+		PostgresPort:            parseInt(getEnv("POSTGRES_PORT", "5432")),
+		PostgresSSLMode:         getEnv("POSTGRES_SSLMODE", "disable"),
+		PostgresSSLCert:         getEnv("POSTGRES_SSLCERT", ""),
+		PostgresSSLKey:          getEnv("POSTGRES_SSLKEY", ""),
+		PostgresSSLCA:           getEnv("POSTGRES_SSLROOTCERT", ""),
+		VaultDBCredsPath:        getEnv("VAULT_DB_CREDS_PATH", ""),
+		SecretRefreshInterval:   parseDuration(getEnv("SECRET_REFRESH_INTERVAL", "5m")),
+		MySQLHost:               getEnv("MYSQL_HOST", "mysql"),
+		MySQLPort:               parseInt(getEnv("MYSQL_PORT", "3306")),
+		MySQLDB:                 getEnv("MYSQL_DB", "powerdns"),
+		MySQLUser:               getEnv("MYSQL_USER", "powerdns"),
+		MySQLPassword:           getSecret("MYSQL_PASSWORD", ""),
+		SQLitePath:              getEnv("SQLITE_PATH", "/var/lib/dns-reloader/dns.db"),
+		CoreDNSContainer:        getEnv("COREDNS_CONTAINER", "coredns-server"),
+		CoreDNSLabel:            getEnv("COREDNS_CONTAINER_LABEL", ""),
+		ZonesDirectory:          getEnv("ZONES_DIRECTORY", "/etc/coredns/zones"),
+		TenantDirectories:       parseList(getEnv("TENANT_DIRECTORIES", "")),
+		Views:                   parseList(getEnv("VIEWS", "")),
+		Regions:                 parseList(getEnv("REGIONS", "")),
+		GenerateTypes:           parseList(strings.ToUpper(getEnv("GENERATE_DOMAIN_TYPES", "NATIVE,MASTER"))),
+		AlsoNotify:              parseList(getEnv("ALSO_NOTIFY", "")),
+		NotifyRetries:           parseInt(getEnv("NOTIFY_RETRIES", "3")),
+		NotifyRetryDelay:        parseDuration(getEnv("NOTIFY_RETRY_DELAY", "10s")),
+		WeightsFile:             getEnv("WEIGHTS_FILE", ""),
+		WeightRotationInterval:  parseDuration(getEnv("WEIGHT_ROTATION_INTERVAL", "5m")),
+		OutputFormat:            getEnv("OUTPUT_FORMAT", ""),
+		OutputFile:              getEnv("OUTPUT_FILE", ""),
+		OutputZones:             parseList(getEnv("OUTPUT_ZONES", "")),
+		DNSSECKeyDirectory:      getEnv("DNSSEC_KEY_DIRECTORY", ""),
+		DNSSECSignatureValidity: parseDuration(getEnv("DNSSEC_SIGNATURE_VALIDITY", "336h")),
+		DNSSECRefresh:           parseDuration(getEnv("DNSSEC_REFRESH", "168h")),
+		DNSSECAlgorithm:         getEnv("DNSSEC_ALGORITHM", "ECDSAP256SHA256"),
+		DNSSECKeySecret:         getSecret("DNSSEC_KEY_SECRET", ""),
+		DNSSECZSKLifetime:       parseDuration(getEnv("DNSSEC_ZSK_LIFETIME", "2160h")),
+		DNSSECRolloverMargin:    parseDuration(getEnv("DNSSEC_ROLLOVER_MARGIN", "1h")),
+		DNSSECParentDSTTL:       parseDuration(getEnv("DNSSEC_PARENT_DS_TTL", "24h")),
+		DNSSECSignerCommand:     getEnv("DNSSEC_SIGNER_COMMAND", ""),
+		DNSSECSignerTimeout:     parseDuration(getEnv("DNSSEC_SIGNER_TIMEOUT", "5m")),
+		ZonesGit:                parseBool(getEnv("ZONES_GIT", "false")),
+		ZonesGitRemote:          getEnv("ZONES_GIT_REMOTE", ""),
+		ZonesGitBranch:          getEnv("ZONES_GIT_BRANCH", "main"),
+		ZonesGitAuthor:          getEnv("ZONES_GIT_AUTHOR", "dns-reloader <dns-reloader@localhost>"),
+		ZonesGitMessage:         getEnv("ZONES_GIT_MESSAGE", defaultGitMessage),
+		ZonesGitPush:            parseBool(getEnv("ZONES_GIT_PUSH", "false")),
+		BackupS3Bucket:          getEnv("BACKUP_S3_BUCKET", ""),
+		BackupS3Endpoint:        getEnv("BACKUP_S3_ENDPOINT", ""),
+		BackupS3Region:          getEnv("BACKUP_S3_REGION", "us-east-1"),
+		BackupS3Prefix:          getEnv("BACKUP_S3_PREFIX", "dns-reloader/"),
+		BackupInterval:          parseDuration(getEnv("BACKUP_INTERVAL", "24h")),
+		BackupRetention:         parseDuration(getEnv("BACKUP_RETENTION", "720h")),
+		CanaryRecord:            getEnv("CANARY_RECORD", ""),
+		CanaryZones:             parseList(getEnv("CANARY_ZONES", "")),
+		CanaryInterval:          parseDuration(getEnv("CANARY_INTERVAL", "5m")),
+		LogLevel:                getEnv("LOG_LEVEL", "info"),
+		PollInterval:            parseDuration(getEnv("POLL_INTERVAL", "5s")),
+
+		DriftCheckInterval: parseDuration(getEnv("DRIFT_CHECK_INTERVAL", "10m")),
+		DriftAutoHeal:      parseBool(getEnv("DRIFT_AUTO_HEAL", "false")),
+
+		LogFormat:        getEnv("LOG_FORMAT", "text"),
+		LogOutput:        getEnv("LOG_OUTPUT", "stderr"),
+		LogMaxSizeMB:     parseInt(getEnv("LOG_MAX_SIZE_MB", "100")),
+		LogMaxBackups:    parseInt(getEnv("LOG_MAX_BACKUPS", "5")),
+		LogMaxAgeDays:    parseInt(getEnv("LOG_MAX_AGE_DAYS", "28")),
+		AuditGenerations: parseBool(getEnv("AUDIT_GENERATIONS", "true")),
+
+		VerifyAddress:  getEnv("VERIFY_DNS_ADDRESS", ""),
+		VerifyAttempts: parseInt(getEnv("VERIFY_ATTEMPTS", "5")),
+		VerifyInterval: parseDuration(getEnv("VERIFY_INTERVAL", "2s")),
+
+		HTTPListenAddr:        getEnv("HTTP_LISTEN_ADDR", ""),
+		GRPCListenAddr:        getEnv("GRPC_LISTEN_ADDR", ""),
+		APIAdminToken:         getSecret("API_ADMIN_TOKEN", ""),
+		PprofListenAddr:       getEnv("PPROF_LISTEN_ADDR", ""),
+		TLSCertFile:           getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:            getEnv("TLS_KEY_FILE", ""),
+		TLSClientCAFile:       getEnv("TLS_CLIENT_CA_FILE", ""),
+		TLSClientAuth:         strings.ToLower(getEnv("TLS_CLIENT_AUTH", "")),
+		DNSListenAddr:         getEnv("DNS_LISTEN_ADDR", ""),
+		AXFRAllowFrom:         parseList(getEnv("AXFR_ALLOW_FROM", "")),
+		AXFRTSIGKeys:          parseList(getEnv("AXFR_TSIG_KEYS", "")),
+		IXFRJournalSize:       parseInt(getEnv("IXFR_JOURNAL_SIZE", "100")),
+		SecondaryMode:         parseBool(getEnv("SECONDARY_MODE", "false")),
+		CatalogZone:           getEnv("CATALOG_ZONE", ""),
+		UpdateAllowFrom:       parseList(getEnv("UPDATE_ALLOW_FROM", "")),
+		UpdateTSIGKeys:        parseList(getEnv("UPDATE_TSIG_KEYS", "")),
+		DynDNSTTL:             parseInt(getEnv("DYNDNS_TTL", "60")),
+		OTLPEndpoint:          getEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "")),
+		ChangeCalendarDays:    parseInt(getEnv("CHANGE_CALENDAR_DAYS", "30")),
+		OnboardingNameservers: parseList(getEnv("ONBOARDING_NAMESERVERS", "")),
+		DomainNameservers:     parseList(getEnv("DOMAIN_NAMESERVERS", getEnv("ONBOARDING_NAMESERVERS", ""))),
+		DomainHostmaster:      getEnv("DOMAIN_SOA_HOSTMASTER", ""),
+		DomainSOATimers:       getEnv("DOMAIN_SOA_TIMERS", "7200 3600 1209600 3600"),
+		DomainDefaultTTL:      parseInt(getEnv("DOMAIN_DEFAULT_TTL", "3600")),
+
+		Registrar:           getEnv("REGISTRAR", ""),
+		RegistrarAPIKey:     getSecret("REGISTRAR_API_KEY", ""),
+		RegistrarAutoUpdate: parseBool(getEnv("REGISTRAR_AUTO_UPDATE", "false")),
+		NamecheapAPIUser:    getEnv("NAMECHEAP_API_USER", ""),
+		NamecheapClientIP:   getEnv("NAMECHEAP_CLIENT_IP", ""),
+
+		SyncProviders:   parseList(getEnv("SYNC_PROVIDERS", "")),
+		Route53Zones:    parseList(getEnv("ROUTE53_ZONES", "")),
+		CloudflareToken: getSecret("CLOUDFLARE_API_TOKEN", ""),
+		CloudflareZones: parseList(getEnv("CLOUDFLARE_ZONES", "")),
+		RFC2136Servers:  parseList(getEnv("RFC2136_SERVERS", "")),
+		RFC2136TSIG:     getSecret("RFC2136_TSIG", ""),
+		RFC2136Zones:    parseList(getEnv("RFC2136_ZONES", "")),
+
+		ShutdownWebhookURL:  getSecret("SHUTDOWN_WEBHOOK_URL", ""),
+		AlertWebhookURL:     getSecret("ALERT_WEBHOOK_URL", ""),
+		AlertWebhookFormat:  getEnv("ALERT_WEBHOOK_FORMAT", "json"),
+		AlertThreshold:      parseInt(getEnv("ALERT_THRESHOLD", "3")),
+		AlertRepeatInterval: parseDuration(getEnv("ALERT_REPEAT_INTERVAL", "1h")),
+		AlertEmailTo:        parseList(getEnv("ALERT_EMAIL_TO", "")),
+		AlertEmailKinds:     parseList(getEnv("ALERT_EMAIL_KINDS", "database,validation,reload")),
+		DatabaseAlertAfter:  parseDuration(getEnv("DATABASE_ALERT_AFTER", "30s")),
+		SMTPHost:            getEnv("SMTP_HOST", ""),
+		SMTPPort:            getEnv("SMTP_PORT", "587"),
+		SMTPUsername:        getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:        getSecret("SMTP_PASSWORD", ""),
+		SMTPFrom:            getEnv("SMTP_FROM", ""),
+		SMTPSecurity:        getEnv("SMTP_SECURITY", "starttls"),
+
+		ReloadBackend:     getEnv("RELOAD_BACKEND", ""),
+		ReloadMaxAttempts: parseInt(getEnv("RELOAD_MAX_ATTEMPTS", "5")),
+		ReloadRetryLimit:  parseInt(getEnv("RELOAD_RETRY_LIMIT", "100")),
+		ReloadBackoff:     parseDuration(getEnv("RELOAD_BACKOFF", "1s")),
+		ReloadBackoffMax:  parseDuration(getEnv("RELOAD_BACKOFF_MAX", "30s")),
+		ReloadCommand:     getEnv("RELOAD_CMD", getEnv("RELOAD_COMMAND", "")),
+		ReloadPID:         parseInt(getEnv("RELOAD_PID", "0")),
+		ReloadPIDFile:     getEnv("RELOAD_PID_FILE", ""),
+		ReloadProcessName: getEnv("RELOAD_PROCESS_NAME", "coredns"),
+		ReloadSignal:      getEnv("RELOAD_SIGNAL", "USR1"),
+		PodmanSocket:      getEnv("PODMAN_SOCKET", ""),
+		ControlArgs:       getEnv("RELOAD_CONTROL_ARGS", ""),
+		SystemdUnit:       getEnv("SYSTEMD_UNIT", "coredns.service"),
+		SystemdReloadMode: getEnv("SYSTEMD_RELOAD_MODE", "kill"),
+		SSHHosts:          parseList(getEnv("SSH_HOSTS", "")),
+		SSHUser:           getEnv("SSH_USER", "root"),
+		SSHKeyFile:        getEnv("SSH_KEY_FILE", "/root/.ssh/id_ed25519"),
+		SSHKnownHosts:     getEnv("SSH_KNOWN_HOSTS", "/root/.ssh/known_hosts"),
+		SSHReloadCommand:  getEnv("SSH_RELOAD_COMMAND", "pkill -USR1 -x coredns"),
+		SSHSyncDir:        getEnv("SSH_SYNC_DIR", ""),
+		Kubeconfig:        getEnv("KUBECONFIG", ""),
+		KubeNamespace:     getEnv("COREDNS_NAMESPACE", "kube-system"),
+		KubeLabelSelector: getEnv("COREDNS_LABEL_SELECTOR", "k8s-app=kube-dns"),
+		KubeContainer:     getEnv("COREDNS_POD_CONTAINER", ""),
+		KubeDeployment:    getEnv("COREDNS_DEPLOYMENT", "coredns"),
+		KubeReloadMode:    getEnv("KUBERNETES_RELOAD_MODE", "exec"),
 	}
 
 	logrusLogger := logrus.New()
@@ -109,25 +588,48 @@ func NewReloader() *Reloader {
 		level = logrus.InfoLevel
 	}
 	logrusLogger.SetLevel(level)
+	if err := configureLogOutput(logrusLogger, config); err != nil {
+		logrusLogger.WithError(err).Warn("Invalid log configuration, using text output on stderr")
+	}
+	if err := errors.Join(secretErrors...); err != nil {
+		logrusLogger.WithError(err).Fatal("Failed to read secrets")
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &Reloader{
-		config: config,
-		logger: logrusLogger,
-		ctx:    ctx,
-		cancel: cancel,
+		config:   config,
+		logger:   logrusLogger,
+		changes:  NewChangeHistory(1000),
+		events:   newBroker[ChangeSet](),
+		activity: newBroker[ActivityEvent](),
+		stats:    &runStats{startedAt: time.Now()},
+		status:   newStatusTracker(),
+
+		localChanges:       make(chan *DNSChangeNotification, 64),
+		credentialsRotated: make(chan struct{}, 1),
+		ctx:                ctx,
+		cancel:             cancel,
+	}
+}
+
+// initStorage selects the storage driver from DB_DRIVER, once.
+func (r *Reloader) initStorage() error {
+	if r.storage != nil {
+		return nil
 	}
+	storage, err := NewStorage(r.config)
+	if err != nil {
+		return err
+	}
+	r.storage = storage
+	return nil
 }
 
 func (r *Reloader) connectDB() error {
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=5432 sslmode=disable TimeZone=UTC",
-		r.config.PostgresHost,
-		r.config.PostgresUser,
-		r.config.PostgresPassword,
-		r.config.PostgresDB,
-	)
+	if err := r.initStorage(); err != nil {
+		return err
+	}
 
 	var gormLogLevel logger.LogLevel
 	switch r.config.LogLevel {
@@ -137,6 +639,11 @@ func (r *Reloader) connectDB() error {
 		gormLogLevel = logger.Silent
 	}
 
+	lease, err := r.setupDBCredentials()
+	if err != nil {
+		return err
+	}
+
 	gormLogger := logger.New(
 		r.logger,
 		logger.Config{
@@ -147,7 +654,7 @@ func (r *Reloader) connectDB() error {
 		},
 	)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+	db, err := gorm.Open(r.storage.Dialector(), &gorm.Config{
 		Logger:                                   gormLogger,
 		DisableForeignKeyConstraintWhenMigrating: true,
 	})
@@ -160,67 +667,233 @@ func (r *Reloader) connectDB() error {
 		return fmt.Errorf("failed to get underlying sql.DB: %w", err)
 	}
 
-	sqlDB.SetMaxOpenConns(5)
-	sqlDB.SetMaxIdleConns(2)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	configurePool(sqlDB, r.config, r.storage)
 
 	if err := sqlDB.Ping(); err != nil {
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
+	if err := registerDBMetrics(db); err != nil {
+		return fmt.Errorf("failed to register database metrics: %w", err)
+	}
+
+	if r.config.SoftDelete {
+		if err := enableSoftDeletes(db); err != nil {
+			return err
+		}
+	}
+	r.comments = db.Migrator().HasTable(&Comment{})
+	r.cryptokeys = db.Migrator().HasTable(&CryptoKey{})
+	r.rollovers = db.Migrator().HasTable(&DNSSECRollover{})
+	r.tsigkeys = db.Migrator().HasTable(&TSIGKey{})
+	r.journals = db.Migrator().HasTable(&ZoneJournal{})
+	r.dyndns = db.Migrator().HasTable(&DynDNSHost{})
+	r.apitokens = db.Migrator().HasTable(&APIToken{})
+	r.accounts = r.apitokens && db.Migrator().HasColumn(&APIToken{}, "account")
+	r.roles = r.apitokens && db.Migrator().HasTable(&TokenGrant{})
+	r.recordWeights = db.Migrator().HasColumn(&Record{}, "weight")
+	r.recordViews = db.Migrator().HasColumn(&Record{}, "view")
+	r.recordRegions = db.Migrator().HasColumn(&Record{}, "region")
+	r.healthchecks = db.Migrator().HasTable(&HealthCheck{})
+	r.recordSchedules = db.Migrator().HasColumn(&Record{}, "not_before")
+
 	r.db = db
 	r.rawDB = sqlDB
-	r.logger.Info("Connected to PostgreSQL database with GORM")
+	r.logger.WithField("driver", r.storage.Name()).Info("Connected to database with GORM")
+	if lease != nil && lease.id != "" && lease.duration > 0 {
+		go r.renewDBCredentials(*lease)
+	}
+	r.connectReplica()
 	return nil
 }
 
 func (r *Reloader) setupListener() error {
-	connStr := fmt.Sprintf(
-		"host=%s dbname=%s user=%s password=%s sslmode=disable",
-		r.config.PostgresHost,
-		r.config.PostgresDB,
-		r.config.PostgresUser,
-		r.config.PostgresPassword,
-	)
+	pg, ok := r.storage.(*PostgresStorage)
+	if !ok {
+		return errNotifyUnsupported
+	}
+	if len(r.config.NotifyChannels) == 0 {
+		return errors.New("no NOTIFY_CHANNELS configured")
+	}
 
-	listener := pq.NewListener(connStr, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
-		if err != nil {
-			r.logger.WithError(err).Error("PostgreSQL listener error")
+	// pq reconnects on its own with this backoff and re-issues LISTEN; a nil
+	// notification on Notify then tells listenForNotifications to resync.
+	listener := pq.NewListener(pg.listenerDSN(), 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		switch ev {
+		case pq.ListenerEventDisconnected:
+			r.logger.WithError(err).Warn("PostgreSQL listener disconnected, reconnecting")
+		case pq.ListenerEventReconnected:
+			r.logger.Info("PostgreSQL listener reconnected")
+		case pq.ListenerEventConnectionAttemptFailed:
+			r.logger.WithError(err).Error("PostgreSQL listener reconnection failed")
 		}
 	})
 
-	if err := listener.Listen("dns_records_changed"); err != nil {
-		return fmt.Errorf("failed to listen on channel: %w", err)
+	for _, channel := range r.config.NotifyChannels {
+		if err := listener.Listen(channel); err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to listen on channel %s: %w", channel, err)
+		}
 	}
 
 	r.listener = listener
-	r.logger.Info("PostgreSQL listener setup complete")
+	r.logger.WithField("channels", r.config.NotifyChannels).Info("PostgreSQL listener setup complete")
 	return nil
 }
 
-func (r *Reloader) generateZoneFile(domain Domain, records []Record) error {
-	zonePath := filepath.Join(r.config.ZonesDirectory, fmt.Sprintf("db.%s", domain.Name))
-	
+// generatedZone describes one rendered zone.
+type generatedZone struct {
+	Changed bool
+	Serial  string
+	Records int
+	Hash    string
+}
+
+// generateZoneFile renders and writes the zone for domain, reporting whether
+// the file on disk changed.
+func (r *Reloader) generateZoneFile(ctx context.Context, domain Domain, records []Record) (zone generatedZone, err error) {
+	zonePath := r.viewZoneFilePath(domain.Name, domain.Options)
+	_, span := tracer.Start(ctx, "zone.write", trace.WithAttributes(
+		attribute.String("dns.zone", domain.Name),
+		attribute.Int("dns.records", len(records)),
+	))
+	defer func() {
+		span.SetAttributes(
+			attribute.Bool("dns.zone.changed", zone.Changed),
+			attribute.String("dns.serial", zone.Serial),
+		)
+		endSpan(span, err)
+	}()
+
 	r.logger.WithFields(logrus.Fields{
-		"domain": domain.Name,
-		"path":   zonePath,
+		"domain":  domain.Name,
+		"path":    zonePath,
 		"records": len(records),
 	}).Debug("Generating zone file")
 
+	var body string
+	var published, expires time.Time
+	var signedWith string
+	existing, readErr := os.ReadFile(zonePath)
+	if readErr == nil {
+		var unsigned string
+		unsigned, expires, signedWith = splitSignatures(string(existing))
+		body, published = r.splitCanary(unsigned)
+	}
+	content, zone := r.renderZoneFile(domain, records, body)
 	var zoneContent strings.Builder
-	
+	zoneContent.WriteString(content)
+
+	// Create zones directory if it doesn't exist
+	if err := os.MkdirAll(filepath.Dir(zonePath), 0755); err != nil {
+		return zone, fmt.Errorf("failed to create zones directory: %w", err)
+	}
+
+	canary := r.canaryEnabled(domain.Name)
+	signed := !expires.IsZero()
+	if readErr == nil && signed == domain.Options.Sign && (!signed || signedWith == signingState(domain)) {
+		if body == zoneContent.String() && (!canary || time.Since(published) < r.config.CanaryInterval) &&
+			(!signed || time.Until(expires) > r.config.DNSSECRefresh) {
+			r.logger.WithField("domain", domain.Name).Debug("Zone file unchanged")
+			return zone, nil
+		}
+	}
+
+	if canary {
+		zoneContent.WriteString(r.canaryLine(time.Now()))
+	}
+	if domain.Options.Sign {
+		sign := r.signZone
+		if domain.Options.Signer == signerExternal {
+			sign = func(domain Domain, content string, now time.Time) (string, error) {
+				return r.externalSign(ctx, domain, content, now)
+			}
+		}
+		signatures, err := sign(domain, zoneContent.String(), time.Now())
+		if err != nil {
+			return zone, fmt.Errorf("failed to sign zone: %w", err)
+		}
+		zoneContent.WriteString(signatures)
+	}
+
+	// Write zone file atomically
+	tempPath := zonePath + ".tmp"
+	if err := os.WriteFile(tempPath, []byte(zoneContent.String()), 0644); err != nil {
+		return zone, fmt.Errorf("failed to write temporary zone file: %w", err)
+	}
+
+	if err := os.Rename(tempPath, zonePath); err != nil {
+		return zone, fmt.Errorf("failed to move zone file: %w", err)
+	}
+	if primaryView(domain.Options) {
+		r.journalChange(ctx, domain, string(existing), zoneContent.String())
+		if err := r.exportDS(domain); err != nil {
+			r.logger.WithError(err).WithField("domain", domain.Name).Warn("Failed to export DS records")
+		}
+	}
+
+	recordsRendered.Add(float64(zone.Records))
+
+	r.logger.WithFields(logrus.Fields{
+		"domain":  domain.Name,
+		"path":    zonePath,
+		"records": len(records),
+		"size":    len(zoneContent.String()),
+	}).Info("Generated zone file successfully")
+
+	zone.Changed = true
+	return zone, nil
+}
+
+// publishedRecords are the records of a zone file that are served at now:
+// enabled and authoritative, inside their not_before and not_after, not
+// withheld by their health checks, and chosen by weight.
+func (r *Reloader) publishedRecords(records []Record, now time.Time) []Record {
+	var published []Record
+	for _, record := range r.selectWeighted(r.withholdUnhealthy(withholdScheduled(records, now)), now) {
+		if !record.Disabled && record.Auth {
+			published = append(published, record)
+		}
+	}
+	return published
+}
+
+// renderZone builds the zone file content for domain, without the canary
+// line.
+func (r *Reloader) renderZone(domain Domain, records []Record) (string, generatedZone) {
+	var zone generatedZone
+	var zoneContent strings.Builder
+
 	// Zone header
 	zoneContent.WriteString(fmt.Sprintf("$ORIGIN %s.\n", domain.Name))
-	zoneContent.WriteString("$TTL 300\n\n")
-	
+	ttl := 300
+	if domain.Options.TTL > 0 {
+		ttl = domain.Options.TTL
+	}
+	zoneContent.WriteString(fmt.Sprintf("$TTL %d\n\n", ttl))
+
 	// Group records by type for better organization
 	recordsByType := make(map[string][]Record)
-	for _, record := range records {
-		if !record.Disabled && record.Auth {
-			recordsByType[strings.ToUpper(record.Type)] = append(recordsByType[strings.ToUpper(record.Type)], record)
+	for _, record := range r.publishedRecords(records, time.Now()) {
+		recordsByType[strings.ToUpper(record.Type)] = append(recordsByType[strings.ToUpper(record.Type)], record)
+	}
+
+	// Records carry comments only on export paths; each RRset's comments
+	// are written once, above its first record.
+	annotated := make(map[string]bool)
+	annotate := func(record Record) {
+		key := strings.ToLower(record.Name) + "/" + strings.ToUpper(record.Type)
+		if len(record.Comments) == 0 || annotated[key] {
+			return
+		}
+		annotated[key] = true
+		for _, comment := range record.Comments {
+			for _, line := range strings.Split(comment.Comment, "\n") {
+				zoneContent.WriteString("; " + strings.TrimRight(line, "\r") + "\n")
+			}
 		}
 	}
-	
+
 	// Helper function to clean record names
 	cleanRecordName := func(name string, domainName string) string {
 		if name == domainName {
@@ -234,133 +907,335 @@ func (r *Reloader) generateZoneFile(domain Domain, records []Record) error {
 		}
 		return name
 	}
-	
+
 	// Write SOA record first (required)
 	if soaRecords, exists := recordsByType["SOA"]; exists {
 		for _, record := range soaRecords {
 			name := cleanRecordName(record.Name, domain.Name)
-			zoneContent.WriteString(fmt.Sprintf("%-20s %d IN SOA %s\n", 
+			annotate(record)
+			zoneContent.WriteString(fmt.Sprintf("%-20s %d IN SOA %s\n",
 				name, record.TTL, record.Content))
+			zone.Serial = soaSerial(record.Content)
 		}
 		zoneContent.WriteString("\n")
 	} else {
 		// Generate default SOA if missing
 		serial := time.Now().Format("2006010215")
-		defaultSOA := fmt.Sprintf("ns1.%s. admin.%s. %s 7200 3600 1209600 3600", 
+		defaultSOA := fmt.Sprintf("ns1.%s. admin.%s. %s 7200 3600 1209600 3600",
 			domain.Name, domain.Name, serial)
 		zoneContent.WriteString(fmt.Sprintf("%-20s %d IN SOA %s\n", "@", 3600, defaultSOA))
+		zone.Serial = serial
 		zoneContent.WriteString("\n")
 	}
-	
+
 	// Write NS records
 	if nsRecords, exists := recordsByType["NS"]; exists {
 		for _, record := range nsRecords {
 			name := cleanRecordName(record.Name, domain.Name)
-			zoneContent.WriteString(fmt.Sprintf("%-20s %d IN NS  %s\n", 
+			annotate(record)
+			zoneContent.WriteString(fmt.Sprintf("%-20s %d IN NS  %s\n",
 				name, record.TTL, record.Content))
 		}
 		zoneContent.WriteString("\n")
 	}
-	
+
+	// Write DNSKEY records for published keys, each followed by the DS
+	// records the parent needs for it if it is a key-signing key
+	published := false
+	for _, key := range domain.Keys {
+		if !key.Published {
+			continue
+		}
+		published = true
+		zoneContent.WriteString(fmt.Sprintf("%-20s %d IN DNSKEY %d %d %d %s\n",
+			"@", ttl, key.DNSKEY.Flags, key.DNSKEY.Protocol, key.DNSKEY.Algorithm, key.DNSKEY.PublicKey))
+		if key.DNSKEY.Flags&dns.SEP == 0 {
+			continue
+		}
+		for _, digestType := range dsDigestTypes {
+			if ds := key.DNSKEY.ToDS(digestType); ds != nil {
+				zoneContent.WriteString(fmt.Sprintf("; DS %d %d %d %s\n",
+					ds.KeyTag, ds.Algorithm, ds.DigestType, strings.ToUpper(ds.Digest)))
+			}
+		}
+	}
+	if published {
+		zoneContent.WriteString("\n")
+	}
+
 	// Write A records
 	if aRecords, exists := recordsByType["A"]; exists {
 		for _, record := range aRecords {
 			name := cleanRecordName(record.Name, domain.Name)
-			zoneContent.WriteString(fmt.Sprintf("%-20s %d IN A   %s\n", 
+			annotate(record)
+			zoneContent.WriteString(fmt.Sprintf("%-20s %d IN A   %s\n",
+				name, record.TTL, record.Content))
+		}
+		zoneContent.WriteString("\n")
+	}
+
+	// Write AAAA records
+	if aaaaRecords, exists := recordsByType["AAAA"]; exists {
+		for _, record := range aaaaRecords {
+			name := cleanRecordName(record.Name, domain.Name)
+			annotate(record)
+			zoneContent.WriteString(fmt.Sprintf("%-20s %d IN AAAA %s\n",
 				name, record.TTL, record.Content))
 		}
 		zoneContent.WriteString("\n")
 	}
-	
+
 	// Write CNAME records
 	if cnameRecords, exists := recordsByType["CNAME"]; exists {
 		for _, record := range cnameRecords {
 			name := cleanRecordName(record.Name, domain.Name)
-			zoneContent.WriteString(fmt.Sprintf("%-20s %d IN CNAME %s\n", 
+			annotate(record)
+			zoneContent.WriteString(fmt.Sprintf("%-20s %d IN CNAME %s\n",
 				name, record.TTL, record.Content))
 		}
 		zoneContent.WriteString("\n")
 	}
-	
+
 	// Write MX records
 	if mxRecords, exists := recordsByType["MX"]; exists {
 		for _, record := range mxRecords {
 			name := cleanRecordName(record.Name, domain.Name)
+			annotate(record)
 			priority := 10
 			if record.Prio != nil {
 				priority = *record.Prio
 			}
-			zoneContent.WriteString(fmt.Sprintf("%-20s %d IN MX  %d %s\n", 
+			zoneContent.WriteString(fmt.Sprintf("%-20s %d IN MX  %d %s\n",
 				name, record.TTL, priority, record.Content))
 		}
 		zoneContent.WriteString("\n")
 	}
-	
+
 	// Write TXT records
 	if txtRecords, exists := recordsByType["TXT"]; exists {
 		for _, record := range txtRecords {
 			name := cleanRecordName(record.Name, domain.Name)
+			annotate(record)
 			content := record.Content
 			if !strings.HasPrefix(content, "\"") {
 				content = fmt.Sprintf("\"%s\"", content)
 			}
-			zoneContent.WriteString(fmt.Sprintf("%-20s %d IN TXT %s\n", 
+			zoneContent.WriteString(fmt.Sprintf("%-20s %d IN TXT %s\n",
 				name, record.TTL, content))
 		}
 		zoneContent.WriteString("\n")
 	}
-	
-	// Create zones directory if it doesn't exist
-	if err := os.MkdirAll(r.config.ZonesDirectory, 0755); err != nil {
-		return fmt.Errorf("failed to create zones directory: %w", err)
+
+	// Write every other type as stored, in type order. Like MX, SRV keeps
+	// its priority in the prio column.
+	var otherTypes []string
+	for rtype := range recordsByType {
+		switch rtype {
+		case "SOA", "NS", "A", "AAAA", "CNAME", "MX", "TXT":
+		default:
+			otherTypes = append(otherTypes, rtype)
+		}
 	}
-	
-	// Write zone file atomically
-	tempPath := zonePath + ".tmp"
-	if err := os.WriteFile(tempPath, []byte(zoneContent.String()), 0644); err != nil {
-		return fmt.Errorf("failed to write temporary zone file: %w", err)
+	slices.Sort(otherTypes)
+	for _, rtype := range otherTypes {
+		for _, record := range recordsByType[rtype] {
+			name := cleanRecordName(record.Name, domain.Name)
+			annotate(record)
+			content := record.Content
+			if rtype == "SRV" && record.Prio != nil {
+				content = fmt.Sprintf("%d %s", *record.Prio, content)
+			}
+			zoneContent.WriteString(fmt.Sprintf("%-20s %d IN %s %s\n",
+				name, record.TTL, rtype, content))
+		}
+		zoneContent.WriteString("\n")
 	}
-	
-	if err := os.Rename(tempPath, zonePath); err != nil {
-		return fmt.Errorf("failed to move zone file: %w", err)
+
+	for _, typed := range recordsByType {
+		zone.Records += len(typed)
 	}
-	
-	r.logger.WithFields(logrus.Fields{
-		"domain": domain.Name,
-		"path":   zonePath,
-		"records": len(records),
-		"size": len(zoneContent.String()),
-	}).Info("Generated zone file successfully")
-	
-	return nil
+	zone.Hash = zoneHash(zoneContent.String())
+	return zoneContent.String(), zone
 }
 
-func (r *Reloader) regenerateAllZones() error {
+func zoneHash(content string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+}
+
+func (r *Reloader) zoneFilePath(zone string) string {
+	return r.viewZoneFilePath(zone, r.zoneOptions(zone))
+}
+
+// regenerateAllZones rewrites every zone file and returns the names of the
+// zones whose content changed. trigger is the change that caused this pass,
+// or nil at startup.
+func (r *Reloader) regenerateAllZones(ctx context.Context, trigger *DNSChangeNotification) (changed []string, err error) {
 	r.logger.Info("Regenerating all zone files")
-	
+	ctx, span := tracer.Start(ctx, "zones.regenerate")
+	defer func() {
+		span.SetAttributes(attribute.StringSlice("dns.zones.changed", changed))
+		endSpan(span, err)
+	}()
+
+	unlock, err := r.lockRegeneration(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	db := r.generationDB(ctx)
 	var domains []Domain
-	if err := r.db.WithContext(r.ctx).Find(&domains).Error; err != nil {
-		return fmt.Errorf("failed to fetch domains: %w", err)
+	if err := db.WithContext(ctx).Find(&domains).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch domains: %w", err)
+	}
+	if err := r.loadZoneOptions(ctx, db, domains); err != nil {
+		return nil, err
 	}
-	
+	if err := r.loadDNSSECKeys(ctx, db, domains); err != nil {
+		return nil, err
+	}
+	if err := r.loadHealth(ctx, db); err != nil {
+		return nil, err
+	}
+	if err := r.scheduleNextBoundary(ctx, db); err != nil {
+		r.logger.WithError(err).Warn("Failed to schedule the next record publication")
+	}
+
+	// Weighted CNAME groups are counted again on every pass, so rotation
+	// stops once the last one is gone.
+	r.weightedGroups.Store(false)
+	var generated []Domain
+	var weighted []Record
 	for _, domain := range domains {
+		if !r.generates(domain) {
+			r.logger.WithFields(logrus.Fields{
+				"domain": domain.Name,
+				"type":   domain.Type,
+			}).Debug("Skipping zone not generated by this reloader")
+			zonesRegenerated.WithLabelValues("skipped").Inc()
+			continue
+		}
+		start := time.Now()
 		var records []Record
-		if err := r.db.WithContext(r.ctx).Where("domain_id = ?", domain.ID).Find(&records).Error; err != nil {
+		fetchCtx, fetchSpan := tracer.Start(ctx, "records.fetch", trace.WithAttributes(attribute.String("dns.zone", domain.Name)))
+		if err := db.WithContext(fetchCtx).Where("domain_id = ?", domain.ID).Find(&records).Error; err != nil {
+			endSpan(fetchSpan, err)
 			r.logger.WithError(err).WithField("domain", domain.Name).Error("Failed to fetch records for domain")
+			zonesRegenerated.WithLabelValues("failed").Inc()
+			r.status.recordGeneration(domain.Name, generatedZone{}, err)
+			r.alertFailure("generation", domain.Name, err)
+			r.auditGeneration(ctx, domain, generatedZone{}, time.Since(start), trigger, err)
 			continue
 		}
-		
-		if err := r.generateZoneFile(domain, records); err != nil {
+		fetchSpan.SetAttributes(attribute.Int("dns.records", len(records)))
+		fetchSpan.End()
+
+		if problems := validateZoneRecords(domain.Name, records); len(problems) > 0 {
+			r.logger.WithField("domain", domain.Name).WithField("problems", problems).Debug("Zone has validation problems")
+			r.alertFailure("validation", domain.Name, errors.New(strings.Join(problems, "; ")))
+		} else {
+			r.alertRecovered("validation", domain.Name)
+		}
+
+		zone, err := r.generateVariants(ctx, domain, records)
+		elapsed := time.Since(start)
+		zoneGenerationDuration.WithLabelValues(domain.Name).Observe(elapsed.Seconds())
+		r.status.recordGeneration(domain.Name, zone, err)
+		if err != nil || zone.Changed {
+			event := ActivityEvent{Type: activityGeneration, Zone: domain.Name, Serial: zone.Serial, Records: zone.Records}
+			if err != nil {
+				event.Error = err.Error()
+			}
+			r.publishActivity(event)
+		}
+		if err != nil {
 			r.logger.WithError(err).WithField("domain", domain.Name).Error("Failed to generate zone file")
+			r.stats.generationFailures.Add(1)
+			zonesRegenerated.WithLabelValues("failed").Inc()
+			r.auditGeneration(ctx, domain, zone, elapsed, trigger, err)
+			r.alertFailure("generation", domain.Name, err)
 			continue
 		}
+		r.alertRecovered("generation", domain.Name)
+		generated = append(generated, domain)
+		weighted = append(weighted, r.publishedRecords(zoneRecords(records, domain.Options), time.Now())...)
+		if previous := r.zoneFilePath(domain.Name); previous != r.viewZoneFilePath(domain.Name, domain.Options) {
+			// X-VIEW or the tenant directory changed; the zone must not be
+			// served from both places.
+			if err := os.Remove(previous); err != nil && !errors.Is(err, os.ErrNotExist) {
+				r.logger.WithError(err).WithField("path", previous).Warn("Failed to remove zone file from previous view")
+			}
+		}
+		if zone.Changed {
+			changed = append(changed, domain.Name)
+			r.stats.zonesPublished.Add(1)
+			zonesRegenerated.WithLabelValues("changed").Inc()
+			r.auditGeneration(ctx, domain, zone, elapsed, trigger, nil)
+		} else {
+			zonesRegenerated.WithLabelValues("unchanged").Inc()
+		}
 	}
-	
-	r.logger.WithField("domains", len(domains)).Info("Zone regeneration completed")
-	return nil
+
+	names := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		names = append(names, domain.Name)
+	}
+	r.status.retainZones(names)
+	r.rememberZoneOptions(domains)
+	r.writeCatalogZone(domains)
+
+	if err := r.writeWeightsFile(weighted); err != nil {
+		r.logger.WithError(err).Error("Failed to generate weights file")
+	}
+	if written, err := r.writeOutputFile(generated); err != nil {
+		r.logger.WithError(err).Error("Failed to generate output file")
+		r.alertFailure("output", r.config.OutputFile, err)
+	} else {
+		r.alertRecovered("output", r.config.OutputFile)
+		if written && len(changed) == 0 {
+			// The server reads only the output file, so it has to be
+			// reloaded even though no zone file changed.
+			for _, domain := range generated {
+				changed = append(changed, domain.Name)
+			}
+		}
+	}
+
+	r.logger.WithFields(logrus.Fields{
+		"domains": len(domains),
+		"changed": len(changed),
+	}).Info("Zone regeneration completed")
+	return changed, nil
 }
 
-func (r *Reloader) triggerCoreReload(change *DNSChangeNotification) error {
+func (r *Reloader) triggerCoreReload(ctx context.Context, change *DNSChangeNotification) (err error) {
+	ctx, span := tracer.Start(ctx, "change.apply", trace.WithAttributes(changeAttributes(change)...))
+	defer func() { endSpan(span, err) }()
+
+	changeSet := ChangeSet{
+		Action:   change.Action,
+		Table:    change.Table,
+		DomainID: change.DomainID,
+		Name:     change.Name,
+		Type:     change.Type,
+		Backend:  r.backend.Name(),
+	}
+	if change.done != nil {
+		defer func() {
+			if err != nil {
+				changeSet.Error = err.Error()
+			}
+			change.done <- changeSet
+		}()
+	}
+	if !r.isLeader() {
+		if change.done != nil {
+			return errStandby
+		}
+		r.logger.WithField("action", change.Action).Debug("Leaving change to the leader")
+		return nil
+	}
+
 	r.logger.WithFields(logrus.Fields{
 		"action":    change.Action,
 		"table":     change.Table,
@@ -368,92 +1243,205 @@ func (r *Reloader) triggerCoreReload(change *DNSChangeNotification) error {
 		"name":      change.Name,
 		"type":      change.Type,
 	}).Info("Triggering CoreDNS reload")
+	received := changeSet
+	r.publishActivity(ActivityEvent{Type: activityChangeReceived, Change: &received})
 
-	if err := r.regenerateAllZones(); err != nil {
+	changedZones, err := r.regenerateAllZones(ctx, change)
+	if err != nil {
 		r.logger.WithError(err).Error("Failed to regenerate zone files")
 		return err
 	}
+	if change.force {
+		forced, err := r.forcedZones(ctx, change.Name)
+		if err != nil {
+			return err
+		}
+		changedZones = mergeZones(changedZones, forced)
+	}
 
-	cmd := exec.CommandContext(r.ctx, "docker", "exec", r.config.CoreDNSContainer, "sh", "-c", "kill -USR1 1")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		r.logger.WithError(err).WithField("output", string(output)).Warn("Failed to send SIGUSR1, relying on auto-reload")
+	if r.registrar != nil && r.config.RegistrarAutoUpdate && change.Table == "domains" && change.Action == "INSERT" {
+		if err := r.updateDelegation(ctx, change.Name, nil); err != nil {
+			r.logger.WithError(err).WithField("domain", change.Name).Error("Failed to update delegation for new domain")
+		}
+	}
+
+	changeSet.Zones = changedZones
+	changeSet.AppliedAt = time.Now()
+
+	if err := r.reloadCoreDNS(ctx, changedZones); err != nil {
+		changeSet.Error = err.Error()
 	} else {
-		r.logger.Info("CoreDNS reload signal sent successfully")
+		changeSet.Reloaded = true
+		r.sendAlsoNotify(changedZones)
+	}
+	r.syncProviders(ctx, changedZones)
+	if r.zoneRepo != nil && len(changedZones) > 0 {
+		if err := r.zoneRepo.commit(ctx, change, changedZones); err != nil {
+			r.logger.WithError(err).Error("Failed to commit zones to git")
+			r.alertFailure("git", r.config.ZonesDirectory, err)
+		} else {
+			r.alertRecovered("git", r.config.ZonesDirectory)
+		}
+	}
+	if len(changedZones) > 0 {
+		r.backupZones(ctx, change.Action)
+	}
+
+	if r.config.VerifyAddress != "" && len(changedZones) > 0 {
+		if err := r.verifyReload(ctx, changedZones, change); err != nil {
+			r.logger.WithError(err).WithField("zones", changedZones).Error("CoreDNS is not serving the regenerated zones")
+			changeSet.Verification = "failed"
+			changeSet.VerificationError = err.Error()
+		} else {
+			r.logger.WithField("zones", changedZones).Info("Verified CoreDNS is serving the regenerated zones")
+			changeSet.Verification = "passed"
+		}
 	}
+	changeSet = r.changes.Add(changeSet)
+	r.events.publish(changeSet)
+	applied := changeSet
+	r.publishActivity(ActivityEvent{Type: activityChange, Change: &applied})
 
 	return nil
 }
 
 func (r *Reloader) listenForNotifications() error {
 	r.logger.Info("Listening for DNS record change notifications...")
+	r.status.setMode("listener")
 
 	// ADD THIS: Generate initial zones on startup
-	if err := r.regenerateAllZones(); err != nil {
-		r.logger.WithError(err).Error("Failed initial zone generation")
+	if r.isLeader() {
+		if _, err := r.regenerateAllZones(r.ctx, nil); err != nil {
+			r.logger.WithError(err).Error("Failed initial zone generation")
+		}
 	}
+	r.drainOutboxIfEnabled()
 
 	for {
 		select {
 		case <-r.ctx.Done():
 			return nil
 		case notification := <-r.listener.Notify:
-			if notification != nil {
-				r.logger.WithField("payload", notification.Extra).Info("Received notification")
-				
-				change := &DNSChangeNotification{
-					Table:     "records",
-					Action:    "NOTIFICATION",
+			if notification == nil && r.config.Outbox {
+				// The outbox kept everything sent while the listener was
+				// down.
+				r.databaseUp()
+				r.status.setMode("listener")
+				r.drainOutboxIfEnabled()
+			} else if notification == nil {
+				// The listener reconnected; anything sent while it was down
+				// is lost, so rebuild every zone.
+				r.logger.Info("Resyncing all zones after listener reconnect")
+				r.databaseUp()
+				r.status.setMode("listener")
+				err := r.triggerCoreReload(r.ctx, &DNSChangeNotification{
+					Table:     "listener",
+					Action:    "RESYNC",
 					Timestamp: time.Now(),
+				})
+				if err != nil {
+					r.logger.WithError(err).Error("Failed to resync after listener reconnect")
+				}
+			}
+			if notification != nil && r.config.Outbox {
+				// Only a wake-up; the outbox holds the changes.
+				r.stats.notifications.Add(1)
+				notificationsReceived.Inc()
+				r.drainOutboxIfEnabled()
+			} else if notification != nil {
+				r.stats.notifications.Add(1)
+				notificationsReceived.Inc()
+				r.logger.WithFields(logrus.Fields{
+					"channel": notification.Channel,
+					"payload": notification.Extra,
+				}).Info("Received notification")
+
+				change, err := handlerForChannel(notification.Channel)(notification.Extra)
+				if err != nil {
+					r.logger.WithError(err).Debug("Notification payload is not a change record")
 				}
 
-				if err := r.triggerCoreReload(change); err != nil {
+				ctx, span := tracer.Start(r.ctx, "notification.receive", trace.WithAttributes(
+					attribute.String("db.notification.channel", notification.Channel),
+					attribute.Int64("dns.change.lag_ms", time.Since(change.Timestamp).Milliseconds()),
+				))
+				err = r.triggerCoreReload(ctx, change)
+				endSpan(span, err)
+				if err != nil {
 					r.logger.WithError(err).Error("Failed to handle notification")
 				}
 			}
+		case change := <-r.localChanges:
+			r.handleLocalChange(change)
+		case <-r.credentialsRotated:
+			r.reconnectListener()
 		case <-time.After(30 * time.Second):
 			if err := r.listener.Ping(); err != nil {
-				r.logger.WithError(err).Error("Lost connection to PostgreSQL")
-				return err
+				r.logger.WithError(err).Error("Lost connection to PostgreSQL, waiting for the listener to reconnect")
+				r.status.setMode("reconnecting")
+				r.databaseDown(err)
+				continue
+			}
+			if r.isLeader() {
+				r.retryPendingReload()
+				r.refreshCanaries()
+				r.rotateWeights()
+				r.checkDrift()
+				r.checkBackup()
+				r.refreshSignatures()
+				r.checkRollovers()
+				r.checkSecondaries()
 			}
+			r.checkDatabase()
+			r.drainOutboxIfEnabled()
 		}
 	}
 }
 
 func (r *Reloader) pollForChanges() error {
 	r.logger.Info("Starting polling mode for DNS changes")
-	
+	r.status.setMode("polling")
+
 	lastCheck := time.Now().Add(-1 * time.Minute)
 	ticker := time.NewTicker(r.config.PollInterval)
 	defer ticker.Stop()
 
 	// Initial zone generation
-	if err := r.regenerateAllZones(); err != nil {
-		r.logger.WithError(err).Error("Failed initial zone generation")
+	if r.isLeader() {
+		if _, err := r.regenerateAllZones(r.ctx, nil); err != nil {
+			r.logger.WithError(err).Error("Failed initial zone generation")
+		}
 	}
+	r.drainOutboxIfEnabled()
 
 	for {
 		select {
 		case <-r.ctx.Done():
 			return nil
+		case change := <-r.localChanges:
+			r.handleLocalChange(change)
 		case <-ticker.C:
-			var count int64
-			result := r.db.WithContext(r.ctx).Model(&Record{}).Where(
-				"updated_at > ? OR created_at > ?", lastCheck, lastCheck,
-			).Count(&count)
-			
-			if result.Error != nil {
-				r.logger.WithError(result.Error).Error("Failed to check for changes")
+			if r.isLeader() {
+				r.retryPendingReload()
+				r.refreshCanaries()
+				r.rotateWeights()
+				r.checkDrift()
+				r.checkBackup()
+				r.refreshSignatures()
+				r.checkRollovers()
+				r.checkSecondaries()
+			}
+			r.checkDatabase()
+
+			if r.config.Outbox {
+				r.drainOutboxIfEnabled()
 				continue
 			}
 
-			var domainCount int64
-			domainResult := r.db.WithContext(r.ctx).Model(&Domain{}).Where(
-				"updated_at > ? OR created_at > ?", lastCheck, lastCheck,
-			).Count(&domainCount)
-			
-			if domainResult.Error != nil {
-				r.logger.WithError(domainResult.Error).Error("Failed to check for domain changes")
+			count, domainCount, err := r.storage.DetectChanges(r.ctx, r.db, lastCheck)
+			if err != nil {
+				r.logger.WithError(err).Error("Failed to check for changes")
+				continue
 			}
 
 			totalChanges := count + domainCount
@@ -462,20 +1450,20 @@ func (r *Reloader) pollForChanges() error {
 				r.logger.WithFields(logrus.Fields{
 					"record_changes": count,
 					"domain_changes": domainCount,
-					"total_changes": totalChanges,
+					"total_changes":  totalChanges,
 				}).Info("Detected DNS changes via polling")
-				
+
 				change := &DNSChangeNotification{
 					Table:     "records",
 					Action:    "POLL_DETECTED",
 					Timestamp: time.Now(),
 				}
-				
-				if err := r.triggerCoreReload(change); err != nil {
+
+				if err := r.triggerCoreReload(r.ctx, change); err != nil {
 					r.logger.WithError(err).Error("Failed to trigger CoreDNS reload")
 				}
 			}
-			
+
 			lastCheck = time.Now()
 		}
 	}
@@ -484,21 +1472,21 @@ func (r *Reloader) pollForChanges() error {
 func (r *Reloader) getRecordStats() error {
 	var totalRecords int64
 	var activeDomains int64
-	
+
 	if err := r.db.Model(&Record{}).Count(&totalRecords).Error; err != nil {
 		return fmt.Errorf("failed to count records: %w", err)
 	}
-	
+
 	if err := r.db.Model(&Domain{}).Count(&activeDomains).Error; err != nil {
 		return fmt.Errorf("failed to count domains: %w", err)
 	}
-	
+
 	r.logger.WithFields(logrus.Fields{
-		"total_records": totalRecords,
-		"active_domains": activeDomains,
+		"total_records":   totalRecords,
+		"active_domains":  activeDomains,
 		"zones_directory": r.config.ZonesDirectory,
 	}).Info("DNS database statistics")
-	
+
 	return nil
 }
 
@@ -509,10 +1497,48 @@ func (r *Reloader) cleanup() {
 	if r.rawDB != nil {
 		r.rawDB.Close()
 	}
+	if r.etcd != nil {
+		r.etcd.client.Close()
+	}
+	if r.source != nil {
+		r.source.Close()
+	}
+	if r.replica != nil {
+		if sqlDB, err := r.replica.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}
 }
 
-func (r *Reloader) Run() error {
+// waitForDatabase connects to the database, retrying for up to 10 attempts.
+func (r *Reloader) waitForDatabase() error {
+	if err := r.initStorage(); err != nil {
+		return err
+	}
+	for i := 0; i < 10; i++ {
+		if err := r.connectDB(); err != nil {
+			r.logger.WithError(err).Warnf("Failed to connect to database (attempt %d/10)", i+1)
+			r.databaseDown(err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		r.databaseUp()
+		return nil
+	}
+	return fmt.Errorf("failed to connect to database after 10 attempts")
+}
+
+func (r *Reloader) Run() (err error) {
 	defer r.cleanup()
+	defer func() {
+		r.emitShutdownReport(err)
+	}()
+
+	shutdownTracing, err := r.setupTracing()
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	defer shutdownTracing()
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -523,19 +1549,86 @@ func (r *Reloader) Run() error {
 		r.cancel()
 	}()
 
-	var dbConnected bool
-	for i := 0; i < 10; i++ {
-		if err := r.connectDB(); err != nil {
-			r.logger.WithError(err).Warnf("Failed to connect to database (attempt %d/10)", i+1)
-			time.Sleep(5 * time.Second)
-			continue
-		}
-		dbConnected = true
-		break
+	backend, err := NewReloadBackend(r.config, r.logger, r.zoneFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize reload backend: %w", err)
+	}
+	r.backend = backend
+	r.logger.WithField("backend", backend.Name()).Info("Reload backend initialized")
+
+	registrar, err := NewRegistrar(r.config)
+	if err != nil {
+		return fmt.Errorf("failed to initialize registrar: %w", err)
+	}
+	r.registrar = registrar
+
+	providers, err := NewZoneProviders(r.config)
+	if err != nil {
+		return fmt.Errorf("failed to initialize sync providers: %w", err)
+	}
+	r.providers = providers
+
+	zoneRepo, err := newZoneRepo(r.ctx, r.config)
+	if err != nil {
+		return fmt.Errorf("failed to initialize zones repository: %w", err)
+	}
+	r.zoneRepo = zoneRepo
+
+	backup, err := newZoneBackup(r.config)
+	if err != nil {
+		return fmt.Errorf("failed to initialize zone backups: %w", err)
+	}
+	r.backup = backup
+
+	alerts, err := newAlertManager(r.config, r.logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize alerting: %w", err)
+	}
+	r.alerts = alerts
+
+	if err := r.waitForDatabase(); err != nil {
+		return err
+	}
+
+	go r.refreshSecrets()
+	go r.runHealthChecks()
+	if err := r.startLeaderElection(); err != nil {
+		return err
+	}
+
+	if err := validateSharding(r.config); err != nil {
+		return fmt.Errorf("invalid sharding: %w", err)
+	}
+	if r.config.ShardCount > 1 {
+		r.logger.WithFields(logrus.Fields{
+			"shard":  r.config.ShardIndex,
+			"shards": r.config.ShardCount,
+		}).Info("Generating only the zones of this shard")
+	}
+
+	if err := validateTenantDirectories(r.config.TenantDirectories); err != nil {
+		return fmt.Errorf("invalid TENANT_DIRECTORIES: %w", err)
+	}
+	if err := validateViews(r.config.Views); err != nil {
+		return fmt.Errorf("invalid VIEWS: %w", err)
+	}
+	if err := validateRegions(r.config.Regions); err != nil {
+		return fmt.Errorf("invalid REGIONS: %w", err)
+	}
+
+	tlsConfig, err := serverTLSConfig(r.config)
+	if err != nil {
+		return fmt.Errorf("invalid TLS configuration: %w", err)
 	}
+	r.tlsConfig = tlsConfig
 
-	if !dbConnected {
-		return fmt.Errorf("failed to connect to database after 10 attempts")
+	r.startHTTPServer()
+	if err := r.startGRPCServer(); err != nil {
+		return err
+	}
+	r.startPprofServer()
+	if err := r.startDNSServer(); err != nil {
+		return err
 	}
 
 	// Skip auto-migration since we have existing schema
@@ -545,8 +1638,46 @@ func (r *Reloader) Run() error {
 		r.logger.WithError(err).Warn("Failed to get database statistics")
 	}
 
+	if r.config.InstallTriggers {
+		if err := r.installTriggers(); err != nil {
+			r.logger.WithError(err).Error("Failed to install change notification triggers")
+		}
+	}
+
+	if len(r.config.EtcdEndpoints) > 0 {
+		etcd, err := newEtcdSource(r.config)
+		if err != nil {
+			return fmt.Errorf("failed to initialize etcd source: %w", err)
+		}
+		r.etcd = etcd
+		go r.watchEtcd()
+	}
+
+	if r.config.ConsulAddress != "" {
+		consul, err := newConsulSource(r.config)
+		if err != nil {
+			return fmt.Errorf("failed to initialize consul source: %w", err)
+		}
+		r.consul = consul
+		go r.watchConsul()
+	}
+
+	if r.config.ChangeSource != "" {
+		source, err := NewChangeSource(r.config, r.logger)
+		if err != nil {
+			r.logger.WithError(err).Warn("Failed to setup change source, falling back to polling")
+			return r.pollForChanges()
+		}
+		r.source = source
+		return r.listenForEvents()
+	}
+
 	if err := r.setupListener(); err != nil {
-		r.logger.WithError(err).Warn("Failed to setup PostgreSQL listener, falling back to polling")
+		if errors.Is(err, errNotifyUnsupported) {
+			r.logger.WithField("driver", r.storage.Name()).Info("Database cannot push changes, polling")
+		} else {
+			r.logger.WithError(err).Warn("Failed to setup PostgreSQL listener, falling back to polling")
+		}
 		return r.pollForChanges()
 	}
 
@@ -568,14 +1699,48 @@ func parseDuration(s string) time.Duration {
 	return d
 }
 
+func parseInt(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func parseBool(s string) bool {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false
+	}
+	return b
+}
+
+func parseList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func main() {
+	command, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	if command != "run" {
+		os.Exit(runCommand(command, args))
+	}
+
 	reloader := NewReloader()
-	
+
 	reloader.logger.Info("DNS Zone File Generator starting...")
-	
+
 	if err := reloader.Run(); err != nil {
 		reloader.logger.WithError(err).Fatal("Zone file generator failed")
 	}
-	
+
 	reloader.logger.Info("DNS Zone File Generator stopped")
-}
\ No newline at end of file
+}
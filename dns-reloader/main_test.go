@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestReloader returns a reloader on a migrated SQLite database in a
+// temporary directory, writing its zones there and reloading nothing.
+// env is applied before the configuration is read.
+func newTestReloader(t *testing.T, env map[string]string) *Reloader {
+	t.Helper()
+	r := openTestReloader(t, env)
+	if err := r.migrate(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.connectDB(); err != nil {
+		t.Fatal(err)
+	}
+	backend, err := NewReloadBackend(r.config, r.logger, r.zoneFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.backend = backend
+	return r
+}
+
+// openTestReloader returns a reloader connected to an empty SQLite
+// database in a temporary directory.
+func openTestReloader(t *testing.T, env map[string]string) *Reloader {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("SQLITE_PATH", filepath.Join(dir, "dns.sqlite"))
+	t.Setenv("ZONES_DIRECTORY", dir)
+	t.Setenv("RELOAD_BACKEND", "noop")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+
+	r := NewReloader()
+	t.Cleanup(r.cancel)
+	if err := r.connectDB(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// baselineSchema is the domains and records tables of database/init.sql as
+// first shipped, before the weight column, in SQLite's dialect.
+const baselineSchema = `
+CREATE TABLE domains (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    master VARCHAR(128) DEFAULT NULL,
+    last_check INTEGER DEFAULT NULL,
+    type VARCHAR(6) NOT NULL DEFAULT 'NATIVE',
+    notified_serial INTEGER DEFAULT NULL,
+    account VARCHAR(40) DEFAULT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE records (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    domain_id INTEGER REFERENCES domains(id) ON DELETE CASCADE,
+    name VARCHAR(255) DEFAULT NULL,
+    type VARCHAR(10) DEFAULT NULL,
+    content VARCHAR(65000) DEFAULT NULL,
+    ttl INTEGER DEFAULT 300,
+    prio INTEGER DEFAULT NULL,
+    disabled BOOLEAN DEFAULT 0,
+    ordername VARCHAR(255) DEFAULT NULL,
+    auth BOOLEAN DEFAULT 1,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by VARCHAR(100) DEFAULT 'system',
+    comment TEXT DEFAULT NULL
+);
+CREATE TABLE domainmetadata (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    domain_id INTEGER REFERENCES domains(id) ON DELETE CASCADE,
+    kind VARCHAR(32) DEFAULT NULL,
+    content TEXT DEFAULT NULL
+);
+`
+
+// createTestZone creates the NATIVE domain name and returns it.
+func createTestZone(t *testing.T, r *Reloader, name string) *Domain {
+	t.Helper()
+	domain, _, err := r.createDomain(t.Context(), domainCreateRequest{Name: name, Type: "NATIVE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return domain
+}
+
+// readZoneFile returns the zone file of zone as last written.
+func readZoneFile(t *testing.T, r *Reloader, zone string) string {
+	t.Helper()
+	content, err := os.ReadFile(r.zoneFilePath(zone))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// applyLocalChanges applies the changes queued for the main loop.
+func applyLocalChanges(r *Reloader) {
+	for len(r.localChanges) > 0 {
+		r.handleLocalChange(<-r.localChanges)
+	}
+}
+
+// zoneHasRecord reports whether content has a record of rrtype with value.
+func zoneHasRecord(content, rrtype, value string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 5 && fields[3] == rrtype && strings.Join(fields[4:], " ") == value {
+			return true
+		}
+	}
+	return false
+}
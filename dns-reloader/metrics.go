@@ -0,0 +1,159 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+var (
+	notificationsReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dns_reloader_notifications_received_total",
+		Help: "PostgreSQL change notifications received.",
+	})
+	sourceEventsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_reloader_change_events_received_total",
+		Help: "Change events received from CHANGE_SOURCE by source (nats, kafka, redis).",
+	}, []string{"source"})
+	zonesRegenerated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_reloader_zones_regenerated_total",
+		Help: "Zone generations by result (changed, unchanged, skipped, failed).",
+	}, []string{"result"})
+	sourceSyncs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_reloader_source_syncs_total",
+		Help: "Syncs of records from external change sources by source and result (changed, unchanged, failed).",
+	}, []string{"source", "result"})
+	providerSyncs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_reloader_provider_syncs_total",
+		Help: "Zone syncs to SYNC_PROVIDERS by provider and result (changed, unchanged, skipped, failed).",
+	}, []string{"provider", "result"})
+	zoneBackups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_reloader_zone_backups_total",
+		Help: "Zone backups to BACKUP_S3_BUCKET by result (uploaded, failed).",
+	}, []string{"result"})
+	vaultLeaseRenewals = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_reloader_vault_lease_renewals_total",
+		Help: "Renewals of the database credentials leased from Vault by result (renewed, rotated, failed).",
+	}, []string{"result"})
+	secretRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_reloader_secret_refreshes_total",
+		Help: "Reads of secret references after startup by result (unchanged, changed, failed).",
+	}, []string{"result"})
+	leaderGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "dns_reloader_leader",
+		Help: "1 while this reloader is the LEADER_ELECTION leader, 0 on standby.",
+	})
+	healthProbes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_reloader_health_probes_total",
+		Help: "Health check probes of record targets by type and result (passed, failed).",
+	}, []string{"type", "result"})
+	healthTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_reloader_health_transitions_total",
+		Help: "Records withheld (down) and restored (up) by their health checks.",
+	}, []string{"state"})
+	zoneTransfers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_reloader_zone_transfers_total",
+		Help: "Outgoing AXFRs and IXFRs on DNS_LISTEN_ADDR by result (served, incremental, refused, failed).",
+	}, []string{"result"})
+	dnsUpdates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_reloader_dns_updates_total",
+		Help: "RFC 2136 UPDATEs received on DNS_LISTEN_ADDR by result (applied, rejected, refused, failed).",
+	}, []string{"result"})
+	secondaryChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_reloader_secondary_checks_total",
+		Help: "Refresh checks of secondary zones against their masters by result (current, transferred, failed).",
+	}, []string{"result"})
+	notifiesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_reloader_notifies_sent_total",
+		Help: "DNS NOTIFYs sent to secondaries by result (acknowledged, failed).",
+	}, []string{"result"})
+	zoneGenerationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dns_reloader_zone_generation_duration_seconds",
+		Help:    "Time to fetch records for and write one zone.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"zone"})
+	recordsRendered = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dns_reloader_records_rendered_total",
+		Help: "Records written into zone files.",
+	})
+	reloadAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_reloader_reload_attempts_total",
+		Help: "DNS server reload attempts, including retries.",
+	}, []string{"backend"})
+	reloadFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_reloader_reload_failures_total",
+		Help: "DNS server reload attempts that failed.",
+	}, []string{"backend"})
+	reloadsAbandoned = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_reloader_reloads_abandoned_total",
+		Help: "Pending reloads no longer retried on ticks after RELOAD_RETRY_LIMIT.",
+	}, []string{"backend"})
+	lastSuccessfulReload = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "dns_reloader_last_successful_reload_timestamp_seconds",
+		Help: "Unix time of the last successful DNS server reload.",
+	})
+	zoneDrift = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dns_reloader_zone_drift",
+		Help: "1 if the zone file on disk differs from what the database renders to.",
+	}, []string{"zone"})
+	zoneDriftDetected = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dns_reloader_zone_drift_detected_total",
+		Help: "Drifted zone files found by the periodic integrity check.",
+	})
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dns_reloader_db_query_duration_seconds",
+		Help:    "Database statement latency by operation and table.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation", "table"})
+	replicaFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_reloader_replica_fallbacks_total",
+		Help: "Zone generations that read from the primary instead of the replica, by reason (lag, error).",
+	}, []string{"reason"})
+)
+
+const metricsStartKey = "metrics:start"
+
+// registerDBMetrics times every GORM statement through callbacks.
+func registerDBMetrics(db *gorm.DB) error {
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(metricsStartKey, time.Now())
+	}
+	after := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			start, ok := tx.InstanceGet(metricsStartKey)
+			if !ok {
+				return
+			}
+			dbQueryDuration.WithLabelValues(operation, tx.Statement.Table).Observe(time.Since(start.(time.Time)).Seconds())
+		}
+	}
+
+	type registrar interface {
+		Register(string, func(*gorm.DB)) error
+	}
+
+	cb := db.Callback()
+	hooks := []struct {
+		operation     string
+		before, after registrar
+	}{
+		{"query", cb.Query().Before("gorm:query"), cb.Query().After("gorm:query")},
+		{"create", cb.Create().Before("gorm:create"), cb.Create().After("gorm:create")},
+		{"update", cb.Update().Before("gorm:update"), cb.Update().After("gorm:update")},
+		{"delete", cb.Delete().Before("gorm:delete"), cb.Delete().After("gorm:delete")},
+		{"row", cb.Row().Before("gorm:row"), cb.Row().After("gorm:row")},
+		{"raw", cb.Raw().Before("gorm:raw"), cb.Raw().After("gorm:raw")},
+	}
+
+	for _, hook := range hooks {
+		if err := hook.before.Register("metrics:before_"+hook.operation, before); err != nil {
+			return err
+		}
+		if err := hook.after.Register("metrics:after_"+hook.operation, after(hook.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"flag"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationFiles holds the versioned schema for each driver as
+// migrations/<driver>/<version>_<name>.sql. Applied versions are recorded in
+// schema_migrations; a file is never edited once released, only followed
+// by a new one.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+type migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// schemaMigration is one applied migration.
+type schemaMigration struct {
+	Version   int       `gorm:"primaryKey;column:version"`
+	Name      string    `gorm:"column:name"`
+	AppliedAt time.Time `gorm:"column:applied_at"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// loadMigrations returns the migrations for driver in version order.
+func loadMigrations(driver string) ([]migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for driver %s: %w", driver, err)
+	}
+
+	var migrations []migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		file := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(file, ".sql") {
+			continue
+		}
+		prefix, name, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s is not named <version>_<name>.sql", file)
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, file, version)
+		}
+		seen[version] = file
+
+		content, err := migrationFiles.ReadFile(path.Join(dir, file))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		migrations = append(migrations, migration{Version: version, Name: name, SQL: string(content)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// appliedMigrations creates schema_migrations if needed and returns the
+// versions already applied.
+func (r *Reloader) appliedMigrations(ctx context.Context) (map[int]schemaMigration, error) {
+	if err := r.db.WithContext(ctx).Exec(
+		"CREATE TABLE IF NOT EXISTS schema_migrations (" +
+			"version INT PRIMARY KEY, " +
+			"name VARCHAR(255) NOT NULL, " +
+			"applied_at TIMESTAMP NOT NULL)",
+	).Error; err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var rows []schemaMigration
+	if err := r.db.WithContext(ctx).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	applied := make(map[int]schemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// addColumnIfMissing matches ALTER TABLE ... ADD COLUMN IF NOT EXISTS, which
+// MySQL and SQLite lack, capturing the table and column.
+var addColumnIfMissing = regexp.MustCompile("(?i)ALTER TABLE\\s+[`\"]?(\\w+)[`\"]?\\s+ADD COLUMN IF NOT EXISTS\\s+([`\"]?(\\w+)[`\"]?[^;]*;)")
+
+// guardAddColumns rewrites the ADD COLUMN IF NOT EXISTS statements of a
+// migration for drivers without them: dropped when the column is already
+// there, and run as a plain ADD COLUMN otherwise. The check is made before
+// the migration runs, so the column must not also be in a CREATE TABLE of
+// the same file.
+func guardAddColumns(db *gorm.DB, driver, sql string) string {
+	if driver == "postgres" {
+		return sql
+	}
+	return addColumnIfMissing.ReplaceAllStringFunc(sql, func(stmt string) string {
+		m := addColumnIfMissing.FindStringSubmatch(stmt)
+		if db.Migrator().HasColumn(m[1], m[3]) {
+			return ""
+		}
+		return "ALTER TABLE " + m[1] + " ADD COLUMN " + m[2]
+	})
+}
+
+// migrateSchema applies every pending migration in order, each in its own
+// transaction, and returns the ones it applied. The schema files use
+// IF NOT EXISTS throughout, emulated by guardAddColumns where the driver
+// lacks it for columns, so databases created from any database/init.sql,
+// including the first one without records.weight, are adopted rather than
+// failing.
+func (r *Reloader) migrateSchema(ctx context.Context) ([]migration, error) {
+	migrations, err := loadMigrations(r.storage.Name())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := r.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []migration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(guardAddColumns(tx, r.storage.Name(), m.SQL)).Error; err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("failed to apply migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		r.logger.WithField("version", m.Version).WithField("name", m.Name).Info("Applied schema migration")
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// migrate brings the schema up to date, then installs the change
+// notification triggers on Postgres. With -status it only lists the
+// migrations and whether each has been applied.
+func (r *Reloader) migrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	status := flags.Bool("status", false, "list migrations and exit without applying them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := r.connectDB(); err != nil {
+		return err
+	}
+
+	if *status {
+		migrations, err := loadMigrations(r.storage.Name())
+		if err != nil {
+			return err
+		}
+		applied, err := r.appliedMigrations(r.ctx)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			state := "pending"
+			if row, ok := applied[m.Version]; ok {
+				state = "applied " + row.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", m.Version, m.Name, state)
+		}
+		return nil
+	}
+
+	done, err := r.migrateSchema(r.ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := r.storage.(*PostgresStorage); ok {
+		if err := r.installTriggers(); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%d migration(s) applied\n", len(done))
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestMigrateSchema(t *testing.T) {
+	migrations, err := loadMigrations("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all []int
+	for _, m := range migrations {
+		all = append(all, m.Version)
+	}
+
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, r *Reloader)
+		want  []int
+	}{
+		{
+			name: "empty database",
+			want: all,
+		},
+		{
+			name: "up to date",
+			setup: func(t *testing.T, r *Reloader) {
+				if _, err := r.migrateSchema(t.Context()); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "created from init.sql",
+			setup: func(t *testing.T, r *Reloader) {
+				schema, err := os.ReadFile("../database/sqlite/init.sql")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := r.db.Exec(string(schema)).Error; err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: all,
+		},
+		{
+			name: "created from the baseline init.sql",
+			setup: func(t *testing.T, r *Reloader) {
+				if err := r.db.Exec(baselineSchema).Error; err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: all,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := openTestReloader(t, nil)
+			if tt.setup != nil {
+				tt.setup(t, r)
+			}
+			done, err := r.migrateSchema(t.Context())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var versions []int
+			for _, m := range done {
+				versions = append(versions, m.Version)
+			}
+			if !slices.Equal(versions, tt.want) {
+				t.Fatalf("applied %v, want %v", versions, tt.want)
+			}
+
+			applied, err := r.appliedMigrations(t.Context())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(applied) != len(all) {
+				t.Fatalf("%d migrations recorded, want %d", len(applied), len(all))
+			}
+
+			if !r.db.Migrator().HasColumn(&Record{}, "weight") {
+				t.Fatal("records has no weight column")
+			}
+
+			// The record_versions triggers see every column of records.
+			if err := r.db.Exec("INSERT INTO domains (name, type) VALUES ('example.com', 'NATIVE')").Error; err != nil {
+				t.Fatal(err)
+			}
+			if err := r.db.Exec("INSERT INTO records (domain_id, name, type, content, ttl) SELECT id, 'www.example.com', 'A', '192.0.2.1', 300 FROM domains").Error; err != nil {
+				t.Fatal(err)
+			}
+			var journaled int64
+			if err := r.db.Table("record_versions").Count(&journaled).Error; err != nil {
+				t.Fatal(err)
+			}
+			if journaled != 1 {
+				t.Fatalf("%d record versions journaled, want 1", journaled)
+			}
+		})
+	}
+}
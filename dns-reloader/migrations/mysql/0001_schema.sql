@@ -0,0 +1,46 @@
+-- PowerDNS compatible domains and records with the management extensions.
+
+CREATE TABLE IF NOT EXISTS domains (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    master VARCHAR(128) DEFAULT NULL,
+    last_check INT DEFAULT NULL,
+    type VARCHAR(6) NOT NULL DEFAULT 'NATIVE',
+    notified_serial INT UNSIGNED DEFAULT NULL,
+    account VARCHAR(40) CHARACTER SET 'utf8' DEFAULT NULL,
+    created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) Engine=InnoDB CHARACTER SET 'latin1';
+
+CREATE TABLE IF NOT EXISTS records (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    domain_id INT DEFAULT NULL,
+    name VARCHAR(255) DEFAULT NULL,
+    type VARCHAR(10) DEFAULT NULL,
+    content VARCHAR(64000) DEFAULT NULL,
+    ttl INT DEFAULT 300,
+    prio INT DEFAULT NULL,
+    disabled TINYINT(1) DEFAULT 0,
+    ordername VARCHAR(255) BINARY DEFAULT NULL,
+    auth TINYINT(1) DEFAULT 1,
+    created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    created_by VARCHAR(100) DEFAULT 'system',
+    comment TEXT DEFAULT NULL,
+    INDEX records_name_type_index (name, type),
+    INDEX records_domain_id_index (domain_id),
+    INDEX records_disabled_index (disabled),
+    CONSTRAINT records_domain_id_fk FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE
+) Engine=InnoDB CHARACTER SET 'latin1';
+
+-- weight came after the first init.sql, whose databases lack it; the
+-- record_versions triggers of 0003 read it.
+ALTER TABLE records ADD COLUMN IF NOT EXISTS weight INT DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS domainmetadata (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    domain_id INT NOT NULL,
+    kind VARCHAR(32),
+    content TEXT,
+    CONSTRAINT domainmetadata_domain_id_fk FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE
+) Engine=InnoDB CHARACTER SET 'latin1';
@@ -0,0 +1,20 @@
+-- Zone generation audit log, written by dns-reloader.
+
+CREATE TABLE IF NOT EXISTS zone_generations (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    domain_id INT DEFAULT NULL,
+    domain_name VARCHAR(255) NOT NULL,
+    serial VARCHAR(20) DEFAULT NULL,
+    record_count INT NOT NULL DEFAULT 0,
+    content_hash CHAR(64) DEFAULT NULL,
+    duration_ms INT NOT NULL DEFAULT 0,
+    trigger_table VARCHAR(32) DEFAULT NULL,
+    trigger_action VARCHAR(20) DEFAULT NULL,
+    trigger_record_id INT DEFAULT NULL,
+    trigger_name VARCHAR(255) DEFAULT NULL,
+    trigger_type VARCHAR(10) DEFAULT NULL,
+    result VARCHAR(10) NOT NULL, -- changed, failed
+    error TEXT DEFAULT NULL,
+    created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX zone_generations_domain_name_created_at_index (domain_name, created_at)
+) Engine=InnoDB;
@@ -0,0 +1,70 @@
+-- Before/after images of every record change, written by triggers. No
+-- foreign keys so history outlives deleted records and domains.
+
+CREATE TABLE IF NOT EXISTS record_versions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    record_id BIGINT NOT NULL,
+    domain_id INT DEFAULT NULL,
+    action VARCHAR(10) NOT NULL, -- INSERT, UPDATE, DELETE
+    old_data JSON DEFAULT NULL,
+    new_data JSON DEFAULT NULL,
+    changed_by VARCHAR(100) DEFAULT NULL,
+    changed_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX record_versions_record_id_index (record_id, id)
+) Engine=InnoDB;
+
+-- Record images use the same keys and types as the PostgreSQL schema:
+-- booleans as JSON booleans and timestamps as RFC 3339 UTC.
+CREATE TRIGGER IF NOT EXISTS records_version_insert AFTER INSERT ON records FOR EACH ROW
+BEGIN
+    INSERT INTO record_versions (record_id, domain_id, action, new_data, changed_by)
+    VALUES (NEW.id, NEW.domain_id, 'INSERT', JSON_OBJECT(
+        'id', NEW.id, 'domain_id', NEW.domain_id, 'name', NEW.name, 'type', NEW.type,
+        'content', NEW.content, 'ttl', NEW.ttl, 'prio', NEW.prio, 'weight', NEW.weight,
+        'disabled', IF(NEW.disabled, CAST('true' AS JSON), CAST('false' AS JSON)),
+        'ordername', NEW.ordername,
+        'auth', IF(NEW.auth, CAST('true' AS JSON), CAST('false' AS JSON)),
+        'created_at', DATE_FORMAT(CONVERT_TZ(NEW.created_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%sZ'),
+        'updated_at', DATE_FORMAT(CONVERT_TZ(NEW.updated_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%sZ'),
+        'created_by', NEW.created_by, 'comment', NEW.comment
+    ), CURRENT_USER());
+END;
+
+CREATE TRIGGER IF NOT EXISTS records_version_update AFTER UPDATE ON records FOR EACH ROW
+BEGIN
+    INSERT INTO record_versions (record_id, domain_id, action, old_data, new_data, changed_by)
+    VALUES (NEW.id, NEW.domain_id, 'UPDATE', JSON_OBJECT(
+        'id', OLD.id, 'domain_id', OLD.domain_id, 'name', OLD.name, 'type', OLD.type,
+        'content', OLD.content, 'ttl', OLD.ttl, 'prio', OLD.prio, 'weight', OLD.weight,
+        'disabled', IF(OLD.disabled, CAST('true' AS JSON), CAST('false' AS JSON)),
+        'ordername', OLD.ordername,
+        'auth', IF(OLD.auth, CAST('true' AS JSON), CAST('false' AS JSON)),
+        'created_at', DATE_FORMAT(CONVERT_TZ(OLD.created_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%sZ'),
+        'updated_at', DATE_FORMAT(CONVERT_TZ(OLD.updated_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%sZ'),
+        'created_by', OLD.created_by, 'comment', OLD.comment
+    ), JSON_OBJECT(
+        'id', NEW.id, 'domain_id', NEW.domain_id, 'name', NEW.name, 'type', NEW.type,
+        'content', NEW.content, 'ttl', NEW.ttl, 'prio', NEW.prio, 'weight', NEW.weight,
+        'disabled', IF(NEW.disabled, CAST('true' AS JSON), CAST('false' AS JSON)),
+        'ordername', NEW.ordername,
+        'auth', IF(NEW.auth, CAST('true' AS JSON), CAST('false' AS JSON)),
+        'created_at', DATE_FORMAT(CONVERT_TZ(NEW.created_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%sZ'),
+        'updated_at', DATE_FORMAT(CONVERT_TZ(NEW.updated_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%sZ'),
+        'created_by', NEW.created_by, 'comment', NEW.comment
+    ), CURRENT_USER());
+END;
+
+CREATE TRIGGER IF NOT EXISTS records_version_delete AFTER DELETE ON records FOR EACH ROW
+BEGIN
+    INSERT INTO record_versions (record_id, domain_id, action, old_data, changed_by)
+    VALUES (OLD.id, OLD.domain_id, 'DELETE', JSON_OBJECT(
+        'id', OLD.id, 'domain_id', OLD.domain_id, 'name', OLD.name, 'type', OLD.type,
+        'content', OLD.content, 'ttl', OLD.ttl, 'prio', OLD.prio, 'weight', OLD.weight,
+        'disabled', IF(OLD.disabled, CAST('true' AS JSON), CAST('false' AS JSON)),
+        'ordername', OLD.ordername,
+        'auth', IF(OLD.auth, CAST('true' AS JSON), CAST('false' AS JSON)),
+        'created_at', DATE_FORMAT(CONVERT_TZ(OLD.created_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%sZ'),
+        'updated_at', DATE_FORMAT(CONVERT_TZ(OLD.updated_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%sZ'),
+        'created_by', OLD.created_by, 'comment', OLD.comment
+    ), CURRENT_USER());
+END;
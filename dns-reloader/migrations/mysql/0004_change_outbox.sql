@@ -0,0 +1,72 @@
+-- Append-only change log read by the reloader with CHANGE_OUTBOX=true, and
+-- each consumer's position in it. Rows are written in the same transaction
+-- as the change, so nothing is lost while the reloader is down.
+
+CREATE TABLE IF NOT EXISTS dns_changes (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    table_name VARCHAR(32) NOT NULL,
+    action VARCHAR(10) NOT NULL, -- INSERT, UPDATE, DELETE
+    row_id BIGINT NOT NULL,
+    domain_id INT DEFAULT NULL,
+    name VARCHAR(255) DEFAULT NULL,
+    type VARCHAR(10) DEFAULT NULL,
+    created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX dns_changes_created_at_index (created_at)
+) Engine=InnoDB;
+
+CREATE TABLE IF NOT EXISTS dns_change_cursors (
+    consumer VARCHAR(100) PRIMARY KEY,
+    last_id BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP
+) Engine=InnoDB;
+
+-- Every trigger locks this row until commit, so rows become visible in id
+-- order and a consumer's cursor never passes a row that is still
+-- uncommitted.
+CREATE TABLE IF NOT EXISTS dns_change_lock (
+    id INT PRIMARY KEY
+) Engine=InnoDB;
+
+INSERT IGNORE INTO dns_change_lock (id) VALUES (1);
+
+CREATE TRIGGER IF NOT EXISTS records_outbox_insert AFTER INSERT ON records FOR EACH ROW
+BEGIN
+    UPDATE dns_change_lock SET id = id WHERE id = 1;
+    INSERT INTO dns_changes (table_name, action, row_id, domain_id, name, type)
+    VALUES ('records', 'INSERT', NEW.id, NEW.domain_id, NEW.name, NEW.type);
+END;
+
+CREATE TRIGGER IF NOT EXISTS records_outbox_update AFTER UPDATE ON records FOR EACH ROW
+BEGIN
+    UPDATE dns_change_lock SET id = id WHERE id = 1;
+    INSERT INTO dns_changes (table_name, action, row_id, domain_id, name, type)
+    VALUES ('records', 'UPDATE', NEW.id, NEW.domain_id, NEW.name, NEW.type);
+END;
+
+CREATE TRIGGER IF NOT EXISTS records_outbox_delete AFTER DELETE ON records FOR EACH ROW
+BEGIN
+    UPDATE dns_change_lock SET id = id WHERE id = 1;
+    INSERT INTO dns_changes (table_name, action, row_id, domain_id, name, type)
+    VALUES ('records', 'DELETE', OLD.id, OLD.domain_id, OLD.name, OLD.type);
+END;
+
+CREATE TRIGGER IF NOT EXISTS domains_outbox_insert AFTER INSERT ON domains FOR EACH ROW
+BEGIN
+    UPDATE dns_change_lock SET id = id WHERE id = 1;
+    INSERT INTO dns_changes (table_name, action, row_id, domain_id, name, type)
+    VALUES ('domains', 'INSERT', NEW.id, NEW.id, NEW.name, NEW.type);
+END;
+
+CREATE TRIGGER IF NOT EXISTS domains_outbox_update AFTER UPDATE ON domains FOR EACH ROW
+BEGIN
+    UPDATE dns_change_lock SET id = id WHERE id = 1;
+    INSERT INTO dns_changes (table_name, action, row_id, domain_id, name, type)
+    VALUES ('domains', 'UPDATE', NEW.id, NEW.id, NEW.name, NEW.type);
+END;
+
+CREATE TRIGGER IF NOT EXISTS domains_outbox_delete AFTER DELETE ON domains FOR EACH ROW
+BEGIN
+    UPDATE dns_change_lock SET id = id WHERE id = 1;
+    INSERT INTO dns_changes (table_name, action, row_id, domain_id, name, type)
+    VALUES ('domains', 'DELETE', OLD.id, OLD.id, OLD.name, OLD.type);
+END;
@@ -0,0 +1,16 @@
+-- PowerDNS per-RRset comments, keyed by domain_id, name and type rather
+-- than by record. Same layout as the PowerDNS schema so an existing table
+-- is adopted as is.
+
+CREATE TABLE IF NOT EXISTS comments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    domain_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(10) NOT NULL,
+    modified_at INT NOT NULL,
+    account VARCHAR(40) CHARACTER SET 'utf8' DEFAULT NULL,
+    comment TEXT CHARACTER SET 'utf8' NOT NULL,
+    INDEX comments_name_type_idx (name, type),
+    INDEX comments_order_idx (domain_id, modified_at),
+    CONSTRAINT comments_domain_id_fk FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE
+) Engine=InnoDB CHARACTER SET 'latin1';
@@ -0,0 +1,13 @@
+-- PowerDNS DNSSEC keys. The reloader publishes the DNSKEY of every
+-- published key; signing is left to whatever holds the private keys.
+
+CREATE TABLE IF NOT EXISTS cryptokeys (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    domain_id INT NOT NULL,
+    flags INT NOT NULL,
+    active BOOL,
+    published BOOL DEFAULT 1,
+    content TEXT,
+    INDEX domainidindex (domain_id),
+    CONSTRAINT cryptokeys_domain_id_fk FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE
+) Engine=InnoDB CHARACTER SET 'latin1';
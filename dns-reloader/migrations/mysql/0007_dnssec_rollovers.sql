@@ -0,0 +1,17 @@
+-- Key rollover state, one row per domain and key kind (ZSK or KSK). The
+-- reloader moves a rollover to its next phase once next_at has passed;
+-- rolled_at is when the last one finished, or when the row was created.
+
+CREATE TABLE IF NOT EXISTS dnssec_rollovers (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    domain_id INT NOT NULL,
+    kind VARCHAR(3) NOT NULL,
+    phase VARCHAR(20) NOT NULL,
+    old_key_id INT DEFAULT NULL,
+    new_key_id INT DEFAULT NULL,
+    next_at DATETIME DEFAULT NULL,
+    rolled_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    UNIQUE INDEX dnssec_rollovers_domain_kind_idx (domain_id, kind),
+    CONSTRAINT dnssec_rollovers_domain_id_fk FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE
+) Engine=InnoDB CHARACTER SET 'latin1';
@@ -0,0 +1,11 @@
+-- PowerDNS TSIG keys, by name, for authenticating zone transfers and
+-- NOTIFYs. Secrets created by the reloader are encrypted with
+-- DNSSEC_KEY_SECRET; plain base64 ones written by pdnsutil still work.
+
+CREATE TABLE IF NOT EXISTS tsigkeys (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255),
+    algorithm VARCHAR(50),
+    secret VARCHAR(255),
+    UNIQUE INDEX namealgoindex (name, algorithm)
+) Engine=InnoDB CHARACTER SET 'latin1';
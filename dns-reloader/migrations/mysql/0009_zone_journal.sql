@@ -0,0 +1,15 @@
+-- Changes between consecutive serials of each generated zone, so the DNS
+-- server can answer IXFR with the difference instead of the whole zone.
+-- deleted and added hold master-file lines, the old and new SOA among them.
+
+CREATE TABLE IF NOT EXISTS zone_journal (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    domain_id INT NOT NULL,
+    serial_from BIGINT NOT NULL,
+    serial_to BIGINT NOT NULL,
+    deleted LONGTEXT NOT NULL,
+    added LONGTEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    INDEX zone_journal_domain_id_idx (domain_id, id),
+    CONSTRAINT zone_journal_domain_id_fk FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE
+) Engine=InnoDB CHARACTER SET 'latin1';
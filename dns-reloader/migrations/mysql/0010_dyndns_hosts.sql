@@ -0,0 +1,16 @@
+-- Hostnames that routers and agents may point at their own address with
+-- the dyndns2 protocol on /nic/update. secret_hash is the SHA-256 of the
+-- generated secret, which is only shown when the host is added.
+
+CREATE TABLE IF NOT EXISTS dyndns_hosts (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    domain_id INT NOT NULL,
+    hostname VARCHAR(255) NOT NULL,
+    username VARCHAR(255) NOT NULL,
+    secret_hash VARCHAR(64) NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NULL,
+    UNIQUE INDEX dyndns_hosts_hostname_idx (hostname),
+    UNIQUE INDEX dyndns_hosts_username_idx (username),
+    CONSTRAINT dyndns_hosts_domain_id_fk FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE
+) Engine=InnoDB CHARACTER SET 'latin1';
@@ -0,0 +1,8 @@
+-- Tenant scoping by domains.account. An API token with an account only
+-- sees and changes the domains of that account; zone_generations records
+-- the account of each generated zone.
+
+ALTER TABLE api_tokens ADD COLUMN account VARCHAR(40) NULL;
+ALTER TABLE zone_generations ADD COLUMN account VARCHAR(40) NULL,
+    ADD INDEX zone_generations_account_created_at_index (account, created_at);
+ALTER TABLE domains ADD INDEX domains_account_idx (account);
@@ -0,0 +1,17 @@
+-- Roles of API tokens, from least to most: viewer, editor, zone-admin and
+-- system-admin. api_token_grants raises a token's role on single domains.
+-- Existing tokens keep what they could do: scoped tokens become editors,
+-- the others system admins; new tokens default to viewer.
+
+ALTER TABLE api_tokens ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'viewer';
+UPDATE api_tokens SET role = CASE WHEN names IS NULL OR names = '' THEN 'system-admin' ELSE 'editor' END;
+
+CREATE TABLE IF NOT EXISTS api_token_grants (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    token_id INT NOT NULL,
+    domain_id INT NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    UNIQUE INDEX api_token_grants_token_domain_idx (token_id, domain_id),
+    CONSTRAINT api_token_grants_token_id_fk FOREIGN KEY (token_id) REFERENCES api_tokens(id) ON DELETE CASCADE,
+    CONSTRAINT api_token_grants_domain_id_fk FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE
+) Engine=InnoDB CHARACTER SET 'latin1';
@@ -0,0 +1,5 @@
+-- The split-horizon view a record belongs to. Records without one are in
+-- every view; the others only in theirs, so an internal address never
+-- reaches the external zone files.
+
+ALTER TABLE records ADD COLUMN `view` VARCHAR(64) DEFAULT NULL;
@@ -0,0 +1,5 @@
+-- The region a record is the answer for. In the zone files written for a
+-- region, its records replace those of the same name and type without a
+-- region, and the records of other regions are left out.
+
+ALTER TABLE records ADD COLUMN region VARCHAR(64) DEFAULT NULL;
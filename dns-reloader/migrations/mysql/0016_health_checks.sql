@@ -0,0 +1,22 @@
+-- Probes of the targets of records. A record whose check has failed fall
+-- times in a row is withheld from its zone until it passes rise times in a
+-- row; healthy and changed_at are written by the leader as that happens.
+-- An RRset is never withheld whole: with every target down, all of them
+-- stay published.
+
+CREATE TABLE IF NOT EXISTS health_checks (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    record_id BIGINT NOT NULL,
+    type VARCHAR(10) NOT NULL,
+    port INT DEFAULT NULL,
+    path VARCHAR(255) DEFAULT NULL,
+    interval_seconds INT NOT NULL DEFAULT 30,
+    timeout_seconds INT NOT NULL DEFAULT 5,
+    rise INT NOT NULL DEFAULT 2,
+    fall INT NOT NULL DEFAULT 3,
+    healthy TINYINT(1) NOT NULL DEFAULT 1,
+    changed_at DATETIME NULL,
+    last_error VARCHAR(255) DEFAULT NULL,
+    UNIQUE INDEX health_checks_record_id_idx (record_id),
+    CONSTRAINT health_checks_record_id_fk FOREIGN KEY (record_id) REFERENCES records(id) ON DELETE CASCADE
+) Engine=InnoDB CHARACTER SET 'latin1';
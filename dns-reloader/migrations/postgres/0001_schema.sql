@@ -0,0 +1,63 @@
+-- PowerDNS compatible domains and records with the management extensions.
+
+CREATE TABLE IF NOT EXISTS domains (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    master VARCHAR(128) DEFAULT NULL,
+    last_check INT DEFAULT NULL,
+    type VARCHAR(6) NOT NULL DEFAULT 'NATIVE',
+    notified_serial INT DEFAULT NULL,
+    account VARCHAR(40) DEFAULT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS domains_name_index ON domains(name);
+
+CREATE TABLE IF NOT EXISTS records (
+    id SERIAL PRIMARY KEY,
+    domain_id INT REFERENCES domains(id) ON DELETE CASCADE,
+    name VARCHAR(255) DEFAULT NULL,
+    type VARCHAR(10) DEFAULT NULL,
+    content VARCHAR(65000) DEFAULT NULL,
+    ttl INT DEFAULT 300,
+    prio INT DEFAULT NULL,
+    disabled BOOLEAN DEFAULT FALSE,
+    ordername VARCHAR(255) DEFAULT NULL,
+    auth BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    created_by VARCHAR(100) DEFAULT 'system',
+    comment TEXT DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS records_name_type_index ON records(name, type);
+CREATE INDEX IF NOT EXISTS records_domain_id_index ON records(domain_id);
+CREATE INDEX IF NOT EXISTS records_disabled_index ON records(disabled);
+
+-- weight came after the first init.sql, whose databases lack it; the
+-- record_versions triggers of 0003 read it.
+ALTER TABLE records ADD COLUMN IF NOT EXISTS weight INT DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS domainmetadata (
+    id SERIAL PRIMARY KEY,
+    domain_id INT REFERENCES domains(id) ON DELETE CASCADE,
+    kind VARCHAR(32) DEFAULT NULL,
+    content TEXT DEFAULT NULL
+);
+
+CREATE OR REPLACE FUNCTION update_updated_at_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS update_domains_updated_at ON domains;
+CREATE TRIGGER update_domains_updated_at BEFORE UPDATE ON domains
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+DROP TRIGGER IF EXISTS update_records_updated_at ON records;
+CREATE TRIGGER update_records_updated_at BEFORE UPDATE ON records
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,51 @@
+-- Before/after images of every record change, written by a trigger. No
+-- foreign keys so history outlives deleted records and domains.
+
+CREATE TABLE IF NOT EXISTS record_versions (
+    id SERIAL PRIMARY KEY,
+    record_id INT NOT NULL,
+    domain_id INT DEFAULT NULL,
+    action VARCHAR(10) NOT NULL, -- INSERT, UPDATE, DELETE
+    old_data JSONB DEFAULT NULL,
+    new_data JSONB DEFAULT NULL,
+    changed_by VARCHAR(100) DEFAULT NULL,
+    changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS record_versions_record_id_index ON record_versions(record_id, id);
+
+-- Timestamps are stored as UTC with an offset so the images decode as
+-- RFC 3339.
+CREATE OR REPLACE FUNCTION record_version()
+RETURNS TRIGGER AS $$
+DECLARE
+    old_data JSONB;
+    new_data JSONB;
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        old_data = to_jsonb(OLD) || jsonb_build_object(
+            'created_at', OLD.created_at AT TIME ZONE 'UTC',
+            'updated_at', OLD.updated_at AT TIME ZONE 'UTC'
+        );
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        new_data = to_jsonb(NEW) || jsonb_build_object(
+            'created_at', NEW.created_at AT TIME ZONE 'UTC',
+            'updated_at', NEW.updated_at AT TIME ZONE 'UTC'
+        );
+    END IF;
+
+    INSERT INTO record_versions (record_id, domain_id, action, old_data, new_data, changed_by)
+    VALUES (COALESCE(NEW.id, OLD.id), COALESCE(NEW.domain_id, OLD.domain_id), TG_OP, old_data, new_data, current_user);
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS records_version_trigger ON records;
+CREATE TRIGGER records_version_trigger
+    AFTER INSERT OR UPDATE OR DELETE ON records
+    FOR EACH ROW EXECUTE FUNCTION record_version();
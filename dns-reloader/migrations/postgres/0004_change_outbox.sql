@@ -0,0 +1,52 @@
+-- Append-only change log read by the reloader with CHANGE_OUTBOX=true, and
+-- each consumer's position in it. Rows are written in the same transaction
+-- as the change, so nothing is lost while the reloader is down.
+
+CREATE TABLE IF NOT EXISTS dns_changes (
+    id BIGSERIAL PRIMARY KEY,
+    table_name VARCHAR(32) NOT NULL,
+    action VARCHAR(10) NOT NULL, -- INSERT, UPDATE, DELETE
+    row_id INT NOT NULL,
+    domain_id INT DEFAULT NULL,
+    name VARCHAR(255) DEFAULT NULL,
+    type VARCHAR(10) DEFAULT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS dns_changes_created_at_index ON dns_changes(created_at);
+
+CREATE TABLE IF NOT EXISTS dns_change_cursors (
+    consumer VARCHAR(100) PRIMARY KEY,
+    last_id BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE OR REPLACE FUNCTION record_dns_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    -- Hold a lock until commit so rows become visible in id order and a
+    -- consumer's cursor never passes a row that is still uncommitted.
+    PERFORM pg_advisory_xact_lock(hashtext('dns_changes'));
+
+    IF TG_TABLE_NAME = 'domains' THEN
+        INSERT INTO dns_changes (table_name, action, row_id, domain_id, name, type)
+        VALUES (TG_TABLE_NAME, TG_OP, COALESCE(NEW.id, OLD.id), COALESCE(NEW.id, OLD.id),
+                COALESCE(NEW.name, OLD.name), COALESCE(NEW.type, OLD.type));
+    ELSE
+        INSERT INTO dns_changes (table_name, action, row_id, domain_id, name, type)
+        VALUES (TG_TABLE_NAME, TG_OP, COALESCE(NEW.id, OLD.id), COALESCE(NEW.domain_id, OLD.domain_id),
+                COALESCE(NEW.name, OLD.name), COALESCE(NEW.type, OLD.type));
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS records_outbox_trigger ON records;
+CREATE TRIGGER records_outbox_trigger
+    AFTER INSERT OR UPDATE OR DELETE ON records
+    FOR EACH ROW EXECUTE FUNCTION record_dns_change();
+
+DROP TRIGGER IF EXISTS domains_outbox_trigger ON domains;
+CREATE TRIGGER domains_outbox_trigger
+    AFTER INSERT OR UPDATE OR DELETE ON domains
+    FOR EACH ROW EXECUTE FUNCTION record_dns_change();
@@ -0,0 +1,18 @@
+-- PowerDNS per-RRset comments, keyed by domain_id, name and type rather
+-- than by record. Same layout as the PowerDNS schema so an existing table
+-- is adopted as is.
+
+CREATE TABLE IF NOT EXISTS comments (
+    id SERIAL PRIMARY KEY,
+    domain_id INT NOT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(10) NOT NULL,
+    modified_at INT NOT NULL,
+    account VARCHAR(40) DEFAULT NULL,
+    comment VARCHAR(65535) NOT NULL,
+    CONSTRAINT c_lowercase_name CHECK (((name)::TEXT = LOWER((name)::TEXT)))
+);
+
+CREATE INDEX IF NOT EXISTS comments_domain_id_idx ON comments(domain_id);
+CREATE INDEX IF NOT EXISTS comments_name_type_idx ON comments(name, type);
+CREATE INDEX IF NOT EXISTS comments_order_idx ON comments(domain_id, modified_at);
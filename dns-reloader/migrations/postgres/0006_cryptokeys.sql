@@ -0,0 +1,13 @@
+-- PowerDNS DNSSEC keys. The reloader publishes the DNSKEY of every
+-- published key; signing is left to whatever holds the private keys.
+
+CREATE TABLE IF NOT EXISTS cryptokeys (
+    id SERIAL PRIMARY KEY,
+    domain_id INT REFERENCES domains(id) ON DELETE CASCADE,
+    flags INT NOT NULL,
+    active BOOL,
+    published BOOL DEFAULT TRUE,
+    content TEXT
+);
+
+CREATE INDEX IF NOT EXISTS domainidindex ON cryptokeys(domain_id);
@@ -0,0 +1,13 @@
+-- PowerDNS TSIG keys, by name, for authenticating zone transfers and
+-- NOTIFYs. Secrets created by the reloader are encrypted with
+-- DNSSEC_KEY_SECRET; plain base64 ones written by pdnsutil still work.
+
+CREATE TABLE IF NOT EXISTS tsigkeys (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255),
+    algorithm VARCHAR(50),
+    secret VARCHAR(255),
+    CONSTRAINT c_lowercase_name CHECK (((name)::TEXT = LOWER((name)::TEXT)))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS namealgoindex ON tsigkeys(name, algorithm);
@@ -0,0 +1,16 @@
+-- Hostnames that routers and agents may point at their own address with
+-- the dyndns2 protocol on /nic/update. secret_hash is the SHA-256 of the
+-- generated secret, which is only shown when the host is added.
+
+CREATE TABLE IF NOT EXISTS dyndns_hosts (
+    id SERIAL PRIMARY KEY,
+    domain_id INT NOT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    hostname VARCHAR(255) NOT NULL,
+    username VARCHAR(255) NOT NULL,
+    secret_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS dyndns_hosts_hostname_idx ON dyndns_hosts(hostname);
+CREATE UNIQUE INDEX IF NOT EXISTS dyndns_hosts_username_idx ON dyndns_hosts(username);
@@ -0,0 +1,9 @@
+-- Tenant scoping by domains.account. An API token with an account only
+-- sees and changes the domains of that account; zone_generations records
+-- the account of each generated zone.
+
+ALTER TABLE api_tokens ADD COLUMN IF NOT EXISTS account VARCHAR(40) NULL;
+ALTER TABLE zone_generations ADD COLUMN IF NOT EXISTS account VARCHAR(40) NULL;
+
+CREATE INDEX IF NOT EXISTS domains_account_idx ON domains(account);
+CREATE INDEX IF NOT EXISTS zone_generations_account_created_at_index ON zone_generations(account, created_at);
@@ -0,0 +1,16 @@
+-- Roles of API tokens, from least to most: viewer, editor, zone-admin and
+-- system-admin. api_token_grants raises a token's role on single domains.
+-- Existing tokens keep what they could do: scoped tokens become editors,
+-- the others system admins; new tokens default to viewer.
+
+ALTER TABLE api_tokens ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'viewer';
+UPDATE api_tokens SET role = CASE WHEN names IS NULL OR names = '' THEN 'system-admin' ELSE 'editor' END;
+
+CREATE TABLE IF NOT EXISTS api_token_grants (
+    id SERIAL PRIMARY KEY,
+    token_id INT NOT NULL REFERENCES api_tokens(id) ON DELETE CASCADE,
+    domain_id INT NOT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS api_token_grants_token_domain_idx ON api_token_grants(token_id, domain_id);
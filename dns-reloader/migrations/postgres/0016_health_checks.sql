@@ -0,0 +1,22 @@
+-- Probes of the targets of records. A record whose check has failed fall
+-- times in a row is withheld from its zone until it passes rise times in a
+-- row; healthy and changed_at are written by the leader as that happens.
+-- An RRset is never withheld whole: with every target down, all of them
+-- stay published.
+
+CREATE TABLE IF NOT EXISTS health_checks (
+    id SERIAL PRIMARY KEY,
+    record_id INT NOT NULL REFERENCES records(id) ON DELETE CASCADE,
+    type VARCHAR(10) NOT NULL,
+    port INT DEFAULT NULL,
+    path VARCHAR(255) DEFAULT NULL,
+    interval_seconds INT NOT NULL DEFAULT 30,
+    timeout_seconds INT NOT NULL DEFAULT 5,
+    rise INT NOT NULL DEFAULT 2,
+    fall INT NOT NULL DEFAULT 3,
+    healthy BOOLEAN NOT NULL DEFAULT TRUE,
+    changed_at TIMESTAMP NULL,
+    last_error VARCHAR(255) DEFAULT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS health_checks_record_id_idx ON health_checks(record_id);
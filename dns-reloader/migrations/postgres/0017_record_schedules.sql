@@ -0,0 +1,6 @@
+-- When a record is published: from not_before, if set, until not_after,
+-- if set, both in UTC. Outside that window the record is left out of its
+-- zone, so cutovers and temporary records need no one at the keyboard.
+
+ALTER TABLE records ADD COLUMN IF NOT EXISTS not_before TIMESTAMP NULL;
+ALTER TABLE records ADD COLUMN IF NOT EXISTS not_after TIMESTAMP NULL;
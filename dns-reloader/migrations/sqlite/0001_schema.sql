@@ -0,0 +1,45 @@
+-- PowerDNS compatible domains and records with the management extensions.
+
+CREATE TABLE IF NOT EXISTS domains (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL UNIQUE COLLATE NOCASE,
+    master VARCHAR(128) DEFAULT NULL,
+    last_check INTEGER DEFAULT NULL,
+    type VARCHAR(6) NOT NULL DEFAULT 'NATIVE',
+    notified_serial INTEGER DEFAULT NULL,
+    account VARCHAR(40) DEFAULT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS records (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    domain_id INTEGER DEFAULT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    name VARCHAR(255) DEFAULT NULL,
+    type VARCHAR(10) DEFAULT NULL,
+    content VARCHAR(65000) DEFAULT NULL,
+    ttl INTEGER DEFAULT 300,
+    prio INTEGER DEFAULT NULL,
+    disabled BOOLEAN DEFAULT 0,
+    ordername VARCHAR(255) DEFAULT NULL,
+    auth BOOLEAN DEFAULT 1,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_by VARCHAR(100) DEFAULT 'system',
+    comment TEXT DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS records_name_type_index ON records(name, type);
+CREATE INDEX IF NOT EXISTS records_domain_id_index ON records(domain_id);
+CREATE INDEX IF NOT EXISTS records_disabled_index ON records(disabled);
+
+-- weight came after the first init.sql, whose databases lack it; the
+-- record_versions triggers of 0003 read it.
+ALTER TABLE records ADD COLUMN IF NOT EXISTS weight INTEGER DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS domainmetadata (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    domain_id INTEGER NOT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    kind VARCHAR(32),
+    content TEXT
+);
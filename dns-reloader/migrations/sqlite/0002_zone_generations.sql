@@ -0,0 +1,21 @@
+-- Zone generation audit log, written by dns-reloader.
+
+CREATE TABLE IF NOT EXISTS zone_generations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    domain_id INTEGER DEFAULT NULL,
+    domain_name VARCHAR(255) NOT NULL,
+    serial VARCHAR(20) DEFAULT NULL,
+    record_count INTEGER NOT NULL DEFAULT 0,
+    content_hash CHAR(64) DEFAULT NULL,
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    trigger_table VARCHAR(32) DEFAULT NULL,
+    trigger_action VARCHAR(20) DEFAULT NULL,
+    trigger_record_id INTEGER DEFAULT NULL,
+    trigger_name VARCHAR(255) DEFAULT NULL,
+    trigger_type VARCHAR(10) DEFAULT NULL,
+    result VARCHAR(10) NOT NULL, -- changed, failed
+    error TEXT DEFAULT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS zone_generations_domain_name_created_at_index ON zone_generations(domain_name, created_at);
@@ -0,0 +1,71 @@
+-- Before/after images of every record change, written by triggers. No
+-- foreign keys so history outlives deleted records and domains.
+
+CREATE TABLE IF NOT EXISTS record_versions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    record_id INTEGER NOT NULL,
+    domain_id INTEGER DEFAULT NULL,
+    action VARCHAR(10) NOT NULL, -- INSERT, UPDATE, DELETE
+    old_data TEXT DEFAULT NULL,
+    new_data TEXT DEFAULT NULL,
+    changed_by VARCHAR(100) DEFAULT NULL,
+    changed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS record_versions_record_id_index ON record_versions(record_id, id);
+
+-- Record images use the same keys and types as the PostgreSQL schema:
+-- booleans as JSON booleans and timestamps as RFC 3339 UTC.
+CREATE TRIGGER IF NOT EXISTS records_version_insert AFTER INSERT ON records
+BEGIN
+    INSERT INTO record_versions (record_id, domain_id, action, new_data)
+    VALUES (NEW.id, NEW.domain_id, 'INSERT', json_object(
+        'id', NEW.id, 'domain_id', NEW.domain_id, 'name', NEW.name, 'type', NEW.type,
+        'content', NEW.content, 'ttl', NEW.ttl, 'prio', NEW.prio, 'weight', NEW.weight,
+        'disabled', json(CASE WHEN NEW.disabled THEN 'true' ELSE 'false' END),
+        'ordername', NEW.ordername,
+        'auth', json(CASE WHEN NEW.auth THEN 'true' ELSE 'false' END),
+        'created_at', strftime('%Y-%m-%dT%H:%M:%SZ', NEW.created_at),
+        'updated_at', strftime('%Y-%m-%dT%H:%M:%SZ', NEW.updated_at),
+        'created_by', NEW.created_by, 'comment', NEW.comment
+    ));
+END;
+
+CREATE TRIGGER IF NOT EXISTS records_version_update AFTER UPDATE ON records
+BEGIN
+    INSERT INTO record_versions (record_id, domain_id, action, old_data, new_data)
+    VALUES (NEW.id, NEW.domain_id, 'UPDATE', json_object(
+        'id', OLD.id, 'domain_id', OLD.domain_id, 'name', OLD.name, 'type', OLD.type,
+        'content', OLD.content, 'ttl', OLD.ttl, 'prio', OLD.prio, 'weight', OLD.weight,
+        'disabled', json(CASE WHEN OLD.disabled THEN 'true' ELSE 'false' END),
+        'ordername', OLD.ordername,
+        'auth', json(CASE WHEN OLD.auth THEN 'true' ELSE 'false' END),
+        'created_at', strftime('%Y-%m-%dT%H:%M:%SZ', OLD.created_at),
+        'updated_at', strftime('%Y-%m-%dT%H:%M:%SZ', OLD.updated_at),
+        'created_by', OLD.created_by, 'comment', OLD.comment
+    ), json_object(
+        'id', NEW.id, 'domain_id', NEW.domain_id, 'name', NEW.name, 'type', NEW.type,
+        'content', NEW.content, 'ttl', NEW.ttl, 'prio', NEW.prio, 'weight', NEW.weight,
+        'disabled', json(CASE WHEN NEW.disabled THEN 'true' ELSE 'false' END),
+        'ordername', NEW.ordername,
+        'auth', json(CASE WHEN NEW.auth THEN 'true' ELSE 'false' END),
+        'created_at', strftime('%Y-%m-%dT%H:%M:%SZ', NEW.created_at),
+        'updated_at', strftime('%Y-%m-%dT%H:%M:%SZ', NEW.updated_at),
+        'created_by', NEW.created_by, 'comment', NEW.comment
+    ));
+END;
+
+CREATE TRIGGER IF NOT EXISTS records_version_delete AFTER DELETE ON records
+BEGIN
+    INSERT INTO record_versions (record_id, domain_id, action, old_data)
+    VALUES (OLD.id, OLD.domain_id, 'DELETE', json_object(
+        'id', OLD.id, 'domain_id', OLD.domain_id, 'name', OLD.name, 'type', OLD.type,
+        'content', OLD.content, 'ttl', OLD.ttl, 'prio', OLD.prio, 'weight', OLD.weight,
+        'disabled', json(CASE WHEN OLD.disabled THEN 'true' ELSE 'false' END),
+        'ordername', OLD.ordername,
+        'auth', json(CASE WHEN OLD.auth THEN 'true' ELSE 'false' END),
+        'created_at', strftime('%Y-%m-%dT%H:%M:%SZ', OLD.created_at),
+        'updated_at', strftime('%Y-%m-%dT%H:%M:%SZ', OLD.updated_at),
+        'created_by', OLD.created_by, 'comment', OLD.comment
+    ));
+END;
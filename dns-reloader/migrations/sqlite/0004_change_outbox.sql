@@ -0,0 +1,60 @@
+-- Append-only change log read by the reloader with CHANGE_OUTBOX=true, and
+-- each consumer's position in it. Rows are written in the same transaction
+-- as the change, so nothing is lost while the reloader is down.
+
+CREATE TABLE IF NOT EXISTS dns_changes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    table_name VARCHAR(32) NOT NULL,
+    action VARCHAR(10) NOT NULL, -- INSERT, UPDATE, DELETE
+    row_id INTEGER NOT NULL,
+    domain_id INTEGER DEFAULT NULL,
+    name VARCHAR(255) DEFAULT NULL,
+    type VARCHAR(10) DEFAULT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS dns_changes_created_at_index ON dns_changes(created_at);
+
+CREATE TABLE IF NOT EXISTS dns_change_cursors (
+    consumer VARCHAR(100) PRIMARY KEY,
+    last_id INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- SQLite has a single writer, so ids are always committed in order.
+
+CREATE TRIGGER IF NOT EXISTS records_outbox_insert AFTER INSERT ON records
+BEGIN
+    INSERT INTO dns_changes (table_name, action, row_id, domain_id, name, type)
+    VALUES ('records', 'INSERT', NEW.id, NEW.domain_id, NEW.name, NEW.type);
+END;
+
+CREATE TRIGGER IF NOT EXISTS records_outbox_update AFTER UPDATE ON records
+BEGIN
+    INSERT INTO dns_changes (table_name, action, row_id, domain_id, name, type)
+    VALUES ('records', 'UPDATE', NEW.id, NEW.domain_id, NEW.name, NEW.type);
+END;
+
+CREATE TRIGGER IF NOT EXISTS records_outbox_delete AFTER DELETE ON records
+BEGIN
+    INSERT INTO dns_changes (table_name, action, row_id, domain_id, name, type)
+    VALUES ('records', 'DELETE', OLD.id, OLD.domain_id, OLD.name, OLD.type);
+END;
+
+CREATE TRIGGER IF NOT EXISTS domains_outbox_insert AFTER INSERT ON domains
+BEGIN
+    INSERT INTO dns_changes (table_name, action, row_id, domain_id, name, type)
+    VALUES ('domains', 'INSERT', NEW.id, NEW.id, NEW.name, NEW.type);
+END;
+
+CREATE TRIGGER IF NOT EXISTS domains_outbox_update AFTER UPDATE ON domains
+BEGIN
+    INSERT INTO dns_changes (table_name, action, row_id, domain_id, name, type)
+    VALUES ('domains', 'UPDATE', NEW.id, NEW.id, NEW.name, NEW.type);
+END;
+
+CREATE TRIGGER IF NOT EXISTS domains_outbox_delete AFTER DELETE ON domains
+BEGIN
+    INSERT INTO dns_changes (table_name, action, row_id, domain_id, name, type)
+    VALUES ('domains', 'DELETE', OLD.id, OLD.id, OLD.name, OLD.type);
+END;
@@ -0,0 +1,16 @@
+-- PowerDNS per-RRset comments, keyed by domain_id, name and type rather
+-- than by record. Same layout as the PowerDNS schema so an existing table
+-- is adopted as is.
+
+CREATE TABLE IF NOT EXISTS comments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    domain_id INTEGER NOT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL COLLATE NOCASE,
+    type VARCHAR(10) NOT NULL,
+    modified_at INT NOT NULL,
+    account VARCHAR(40) DEFAULT NULL,
+    comment VARCHAR(65535) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS comments_idx ON comments(domain_id, name, type);
+CREATE INDEX IF NOT EXISTS comments_order_idx ON comments(domain_id, modified_at);
@@ -0,0 +1,13 @@
+-- PowerDNS DNSSEC keys. The reloader publishes the DNSKEY of every
+-- published key; signing is left to whatever holds the private keys.
+
+CREATE TABLE IF NOT EXISTS cryptokeys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    domain_id INTEGER NOT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    flags INTEGER NOT NULL,
+    active BOOL,
+    published BOOL DEFAULT 1,
+    content TEXT
+);
+
+CREATE INDEX IF NOT EXISTS domainidindex ON cryptokeys(domain_id);
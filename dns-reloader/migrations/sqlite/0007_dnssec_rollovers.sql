@@ -0,0 +1,17 @@
+-- Key rollover state, one row per domain and key kind (ZSK or KSK). The
+-- reloader moves a rollover to its next phase once next_at has passed;
+-- rolled_at is when the last one finished, or when the row was created.
+
+CREATE TABLE IF NOT EXISTS dnssec_rollovers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    domain_id INTEGER NOT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    kind VARCHAR(3) NOT NULL,
+    phase VARCHAR(20) NOT NULL,
+    old_key_id INTEGER DEFAULT NULL,
+    new_key_id INTEGER DEFAULT NULL,
+    next_at TIMESTAMP DEFAULT NULL,
+    rolled_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS dnssec_rollovers_domain_kind_idx ON dnssec_rollovers(domain_id, kind);
@@ -0,0 +1,12 @@
+-- PowerDNS TSIG keys, by name, for authenticating zone transfers and
+-- NOTIFYs. Secrets created by the reloader are encrypted with
+-- DNSSEC_KEY_SECRET; plain base64 ones written by pdnsutil still work.
+
+CREATE TABLE IF NOT EXISTS tsigkeys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) COLLATE NOCASE,
+    algorithm VARCHAR(50) COLLATE NOCASE,
+    secret VARCHAR(255)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS namealgoindex ON tsigkeys(name, algorithm);
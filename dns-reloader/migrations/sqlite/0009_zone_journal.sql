@@ -0,0 +1,15 @@
+-- Changes between consecutive serials of each generated zone, so the DNS
+-- server can answer IXFR with the difference instead of the whole zone.
+-- deleted and added hold master-file lines, the old and new SOA among them.
+
+CREATE TABLE IF NOT EXISTS zone_journal (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    domain_id INTEGER NOT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    serial_from BIGINT NOT NULL,
+    serial_to BIGINT NOT NULL,
+    deleted TEXT NOT NULL,
+    added TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS zone_journal_domain_id_idx ON zone_journal(domain_id, id);
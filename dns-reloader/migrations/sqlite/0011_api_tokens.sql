@@ -0,0 +1,22 @@
+-- API tokens. A token with names is scoped: it may only read and change
+-- records whose names match one of the comma separated patterns, of types
+-- and in domain_id when they are set. A token without names is not
+-- restricted. rate_limit caps requests per minute, 0 for no cap.
+-- secret_hash is the SHA-256 of the generated secret, which is only shown
+-- when the token is created.
+
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    secret_hash VARCHAR(64) NOT NULL,
+    domain_id INTEGER NULL REFERENCES domains(id) ON DELETE CASCADE,
+    names TEXT NULL,
+    types VARCHAR(255) NULL,
+    rate_limit INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS api_tokens_name_idx ON api_tokens(name);
+CREATE UNIQUE INDEX IF NOT EXISTS api_tokens_secret_hash_idx ON api_tokens(secret_hash);
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// External change sources (etcd, Consul) are mirrored into the records
+// table rather than read at generation time, so everything downstream of
+// the database, from change detection to the API, works unchanged. Each
+// source owns the records whose created_by is its name and leaves all
+// others alone.
+
+// zoneFor returns the domain with the longest name that name falls in.
+func zoneFor(name string, domains []Domain) *Domain {
+	var best *Domain
+	for i := range domains {
+		d := &domains[i]
+		if (name == d.Name || strings.HasSuffix(name, "."+d.Name)) && (best == nil || len(d.Name) > len(best.Name)) {
+			best = d
+		}
+	}
+	return best
+}
+
+// mirrorRecordKey identifies a mirrored record by everything that is
+// written into the zone.
+func mirrorRecordKey(record Record) string {
+	prio := ""
+	if record.Prio != nil {
+		prio = strconv.Itoa(*record.Prio)
+	}
+	return fmt.Sprintf("%d|%s|%s|%s|%d|%s", record.DomainID, record.Name, record.Type, record.Content, record.TTL, prio)
+}
+
+// mirrorRecords makes the records owned by source match desired, deleting
+// and inserting only what differs, and hands a change on like any other
+// database write so the zones are regenerated the same way as for the
+// Postgres path. A standby leaves it to the leader, which reads the same
+// source.
+func (r *Reloader) mirrorRecords(ctx context.Context, source string, desired []Record) error {
+	if !r.isLeader() {
+		return nil
+	}
+	want := make(map[string]Record, len(desired))
+	for _, record := range desired {
+		record.CreatedBy = source
+		want[mirrorRecordKey(record)] = record
+	}
+
+	var existing []Record
+	if err := r.db.WithContext(ctx).Where("created_by = ?", source).Find(&existing).Error; err != nil {
+		sourceSyncs.WithLabelValues(source, "failed").Inc()
+		return fmt.Errorf("failed to fetch records mirrored from %s: %w", source, err)
+	}
+	var stale []uint
+	for _, record := range existing {
+		key := mirrorRecordKey(record)
+		if _, ok := want[key]; ok {
+			delete(want, key)
+			continue
+		}
+		stale = append(stale, record.ID)
+	}
+	added := make([]Record, 0, len(want))
+	for _, record := range want {
+		added = append(added, record)
+	}
+
+	if len(stale) == 0 && len(added) == 0 {
+		sourceSyncs.WithLabelValues(source, "unchanged").Inc()
+		return nil
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(stale) > 0 {
+			if err := tx.Delete(&Record{}, stale).Error; err != nil {
+				return err
+			}
+		}
+		if len(added) > 0 {
+			return tx.CreateInBatches(added, 500).Error
+		}
+		return nil
+	})
+	if err != nil {
+		sourceSyncs.WithLabelValues(source, "failed").Inc()
+		return fmt.Errorf("failed to apply %s records: %w", source, err)
+	}
+
+	sourceSyncs.WithLabelValues(source, "changed").Inc()
+	r.logger.WithFields(logrus.Fields{
+		"source":  source,
+		"added":   len(added),
+		"removed": len(stale),
+	}).Info("Applied changes from external source")
+	r.apiChanged(&DNSChangeNotification{
+		Table:     "records",
+		Action:    strings.ToUpper(source) + "_SYNC",
+		Timestamp: time.Now(),
+	})
+	return nil
+}
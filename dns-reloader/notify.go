@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// notifyHandler turns the payload of a NOTIFY on one channel into the change
+// to apply. It always returns a change; the error only reports a payload
+// that could not be decoded, in which case the change carries defaults.
+type notifyHandler func(payload string) (*DNSChangeNotification, error)
+
+// notifyHandlers are the handlers for the channels named in NOTIFY_CHANNELS.
+// Channels without an entry are treated as record changes whose table is
+// the channel name.
+var notifyHandlers = map[string]notifyHandler{
+	"dns_records_changed": changeHandler("records"),
+	"dns_domains_changed": changeHandler("domains"),
+	"dnssec_keys_changed": keysChangedHandler,
+}
+
+func handlerForChannel(channel string) notifyHandler {
+	if handler, ok := notifyHandlers[channel]; ok {
+		return handler
+	}
+	return changeHandler(channel)
+}
+
+// changeHandler decodes the JSON change written by the notify_*_change
+// triggers, defaulting the table to table.
+func changeHandler(table string) notifyHandler {
+	return func(payload string) (*DNSChangeNotification, error) {
+		change := &DNSChangeNotification{
+			Table:     table,
+			Action:    "NOTIFICATION",
+			Timestamp: time.Now(),
+		}
+		err := json.Unmarshal([]byte(payload), change)
+		return change, err
+	}
+}
+
+// keysChangedHandler handles signing key changes, which do not alter the
+// records, so regeneration alone would leave the zone untouched. The zone
+// named in the payload, or every zone if none is named, is reloaded
+// regardless.
+func keysChangedHandler(payload string) (*DNSChangeNotification, error) {
+	change, err := changeHandler("cryptokeys")(payload)
+	change.force = true
+	return change, err
+}
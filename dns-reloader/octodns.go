@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+	"sigs.k8s.io/yaml"
+)
+
+// octodnsValue converts rr to its octoDNS value: a string for types with a
+// single field, a map of octoDNS's field names otherwise. ok is false for
+// types octoDNS does not model.
+func octodnsValue(rr dns.RR) (value interface{}, ok bool) {
+	switch v := rr.(type) {
+	case *dns.A:
+		return v.A.String(), true
+	case *dns.AAAA:
+		return v.AAAA.String(), true
+	case *dns.CNAME:
+		return v.Target, true
+	case *dns.DNAME:
+		return v.Target, true
+	case *dns.NS:
+		return v.Ns, true
+	case *dns.PTR:
+		return v.Ptr, true
+	case *dns.TXT:
+		return octodnsText(v.Txt), true
+	case *dns.SPF:
+		return octodnsText(v.Txt), true
+	case *dns.MX:
+		return map[string]interface{}{"preference": v.Preference, "exchange": v.Mx}, true
+	case *dns.SRV:
+		return map[string]interface{}{"priority": v.Priority, "weight": v.Weight, "port": v.Port, "target": v.Target}, true
+	case *dns.CAA:
+		return map[string]interface{}{"flags": v.Flag, "tag": v.Tag, "value": v.Value}, true
+	case *dns.NAPTR:
+		return map[string]interface{}{
+			"order": v.Order, "preference": v.Preference, "flags": v.Flags,
+			"service": v.Service, "regexp": v.Regexp, "replacement": v.Replacement,
+		}, true
+	case *dns.SSHFP:
+		return map[string]interface{}{"algorithm": v.Algorithm, "fingerprint_type": v.Type, "fingerprint": v.FingerPrint}, true
+	case *dns.TLSA:
+		return map[string]interface{}{
+			"certificate_usage": v.Usage, "selector": v.Selector, "matching_type": v.MatchingType,
+			"certificate_association_data": v.Certificate,
+		}, true
+	case *dns.DS:
+		return map[string]interface{}{"key_tag": v.KeyTag, "algorithm": v.Algorithm, "digest_type": v.DigestType, "digest": v.Digest}, true
+	}
+	return nil, false
+}
+
+// octodnsText joins TXT strings, escaping semicolons as octoDNS requires.
+func octodnsText(txt []string) string {
+	return strings.ReplaceAll(strings.Join(txt, ""), ";", `\;`)
+}
+
+// renderOctoDNS converts a rendered zone to an octoDNS YAML zone file. Names
+// are relative to the zone, the apex being the empty string. The SOA and
+// apex NS records are left to the octoDNS providers; types octoDNS does not
+// model are returned as skipped.
+func renderOctoDNS(zone renderedZone) (content string, skipped []string, err error) {
+	rrs, err := parseZone(strings.NewReader(zone.Content), zone.Domain.Name, zone.Domain.Name+".zone")
+	if err != nil {
+		return "", nil, err
+	}
+
+	type rrset struct {
+		ttl    uint32
+		values []interface{}
+	}
+	apex := dns.Fqdn(zone.Domain.Name)
+	names := make(map[string]map[string]*rrset)
+	for _, rr := range rrs {
+		header := rr.Header()
+		name := strings.ToLower(header.Name)
+		rrtype := dns.TypeToString[header.Rrtype]
+		if rrtype == "SOA" || (rrtype == "NS" && name == apex) {
+			continue
+		}
+		value, ok := octodnsValue(rr)
+		if !ok {
+			skipped = append(skipped, fmt.Sprintf("%s %s", name, rrtype))
+			continue
+		}
+		label := strings.TrimSuffix(strings.TrimSuffix(name, apex), ".")
+		if names[label] == nil {
+			names[label] = make(map[string]*rrset)
+		}
+		set := names[label][rrtype]
+		if set == nil {
+			set = &rrset{ttl: header.Ttl}
+			names[label][rrtype] = set
+		}
+		set.ttl = min(set.ttl, header.Ttl)
+		set.values = append(set.values, value)
+	}
+
+	doc := make(map[string]interface{}, len(names))
+	for label, types := range names {
+		rrtypes := make([]string, 0, len(types))
+		for rrtype := range types {
+			rrtypes = append(rrtypes, rrtype)
+		}
+		sort.Strings(rrtypes)
+
+		records := make([]map[string]interface{}, 0, len(rrtypes))
+		for _, rrtype := range rrtypes {
+			set := types[rrtype]
+			record := map[string]interface{}{"type": rrtype, "ttl": set.ttl}
+			if len(set.values) == 1 {
+				record["value"] = set.values[0]
+			} else {
+				record["values"] = set.values
+			}
+			records = append(records, record)
+		}
+		if len(records) == 1 {
+			doc[label] = records[0]
+		} else {
+			doc[label] = records
+		}
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return "", nil, err
+	}
+	return "---\n" + string(out), skipped, nil
+}
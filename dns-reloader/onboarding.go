@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+)
+
+type OnboardingRequest struct {
+	Domain     string `json:"domain"`
+	AXFRServer string `json:"axfr_server,omitempty"`
+}
+
+type OnboardingDelegation struct {
+	Nameservers []string `json:"nameservers"`
+	Error       string   `json:"error,omitempty"`
+}
+
+type OnboardingImport struct {
+	Method  string   `json:"method"`
+	Source  string   `json:"source,omitempty"`
+	Records []Record `json:"records"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+type OnboardingRegistrar struct {
+	NS []string `json:"ns"`
+	DS []string `json:"ds"`
+}
+
+type OnboardingReport struct {
+	Domain            string               `json:"domain"`
+	AlreadyManaged    bool                 `json:"already_managed"`
+	CurrentDelegation OnboardingDelegation `json:"current_delegation"`
+	Import            OnboardingImport     `json:"import"`
+	Validation        []string             `json:"validation"`
+	Registrar         OnboardingRegistrar  `json:"registrar"`
+	RegistrarName     string               `json:"registrar_name,omitempty"`
+	Checklist         []string             `json:"checklist"`
+}
+
+func (r *Reloader) handleOnboarding(w http.ResponseWriter, req *http.Request) {
+	var body OnboardingRequest
+	if err := readJSON(req, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(body.Domain)), ".")
+	if err := validateDomainName(domain); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := r.buildOnboardingReport(req.Context(), domain, body.AXFRServer)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// buildOnboardingReport inspects a zone's current public delegation, proposes
+// its records via AXFR, validates them and lists the steps to cut over. It
+// does not write anything.
+func (r *Reloader) buildOnboardingReport(ctx context.Context, domain, axfrServer string) (*OnboardingReport, error) {
+	report := &OnboardingReport{
+		Domain: domain,
+		Import: OnboardingImport{Method: "axfr", Records: []Record{}},
+	}
+
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&Domain{}).Where("name = ?", domain).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to look up domain: %w", err)
+	}
+	report.AlreadyManaged = count > 0
+
+	lookupCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	nameservers, err := net.DefaultResolver.LookupNS(lookupCtx, domain)
+	if err != nil {
+		report.CurrentDelegation.Error = err.Error()
+	}
+	for _, ns := range nameservers {
+		report.CurrentDelegation.Nameservers = append(report.CurrentDelegation.Nameservers, strings.TrimSuffix(ns.Host, "."))
+	}
+
+	sources := report.CurrentDelegation.Nameservers
+	if axfrServer != "" {
+		sources = []string{axfrServer}
+	}
+	for _, source := range sources {
+		rrs, err := transferZone(domain, source, nil)
+		if err != nil {
+			report.Import.Errors = append(report.Import.Errors, fmt.Sprintf("%s: %v", source, err))
+			continue
+		}
+		report.Import.Source = source
+		for _, rr := range rrs {
+			report.Import.Records = append(report.Import.Records, recordFromRR(rr, 0))
+		}
+		break
+	}
+
+	report.Validation = validateZoneRecords(domain, report.Import.Records)
+	report.Registrar = OnboardingRegistrar{NS: r.config.OnboardingNameservers, DS: []string{}}
+	if r.registrar != nil {
+		report.RegistrarName = r.registrar.Name()
+	}
+	report.Checklist = onboardingChecklist(report)
+
+	r.logger.WithFields(logrus.Fields{
+		"domain":  domain,
+		"source":  report.Import.Source,
+		"records": len(report.Import.Records),
+		"issues":  len(report.Validation),
+	}).Info("Built onboarding report")
+
+	return report, nil
+}
+
+func onboardingChecklist(report *OnboardingReport) []string {
+	var steps []string
+
+	if report.AlreadyManaged {
+		steps = append(steps, fmt.Sprintf("%s already exists in core-admin; review its records instead of importing", report.Domain))
+	} else {
+		steps = append(steps, fmt.Sprintf("Create domain %s in core-admin", report.Domain))
+	}
+
+	if report.Import.Source != "" {
+		steps = append(steps, fmt.Sprintf("Import %d records transferred from %s", len(report.Import.Records), report.Import.Source))
+	} else {
+		steps = append(steps, "AXFR was refused by every source; allow transfers from this host or export the zone from the current provider")
+	}
+
+	if len(report.Validation) > 0 {
+		steps = append(steps, fmt.Sprintf("Resolve %d validation issue(s) before cut-over", len(report.Validation)))
+	}
+
+	steps = append(steps, "Lower the NS TTL at the current provider and wait for the old TTL to expire")
+
+	if len(report.Registrar.NS) > 0 {
+		steps = append(steps, fmt.Sprintf("Set NS at the registrar to: %s", strings.Join(report.Registrar.NS, ", ")))
+	} else {
+		steps = append(steps, "Set NS at the registrar to this deployment's nameservers (configure ONBOARDING_NAMESERVERS to list them here)")
+	}
+
+	if len(report.Registrar.DS) > 0 {
+		steps = append(steps, fmt.Sprintf("Publish DS at the registrar: %s", strings.Join(report.Registrar.DS, "; ")))
+	} else {
+		steps = append(steps, "Remove any DS record at the registrar; the zone will be served unsigned")
+	}
+
+	if report.RegistrarName != "" {
+		steps = append(steps, fmt.Sprintf("Or push the delegation through %s: POST /api/v1/domains/%s/delegation", report.RegistrarName, report.Domain))
+	}
+
+	steps = append(steps, fmt.Sprintf("Verify delegation with: dig +trace NS %s", report.Domain))
+	steps = append(steps, "Decommission the zone at the old provider once queries have drained")
+
+	return steps
+}
+
+// transferZone performs an AXFR of zone from server, which may omit the port,
+// signing the request with key when it is not nil.
+func transferZone(zone, server string, key *tsigKey) ([]dns.RR, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	msg := new(dns.Msg)
+	msg.SetAxfr(dns.Fqdn(zone))
+
+	transfer := &dns.Transfer{
+		DialTimeout: 10 * time.Second,
+		ReadTimeout: 30 * time.Second,
+	}
+	if key != nil {
+		msg.SetTsig(key.Name, key.Algorithm, 300, time.Now().Unix())
+		transfer.TsigSecret = map[string]string{key.Name: key.Secret}
+	}
+	envelopes, err := transfer.In(msg, server)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transfer: %w", err)
+	}
+
+	var rrs []dns.RR
+	for envelope := range envelopes {
+		if envelope.Error != nil {
+			return nil, fmt.Errorf("transfer failed: %w", envelope.Error)
+		}
+		rrs = append(rrs, envelope.RR...)
+	}
+	if len(rrs) == 0 {
+		return nil, fmt.Errorf("transfer returned no records")
+	}
+
+	// AXFR repeats the SOA as the final record.
+	if _, ok := rrs[len(rrs)-1].(*dns.SOA); ok && len(rrs) > 1 {
+		rrs = rrs[:len(rrs)-1]
+	}
+	return rrs, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+
+	"sigs.k8s.io/yaml"
+)
+
+// openAPISpec describes the HTTP API. Keep it in step with the routes in
+// startHTTPServer.
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+func (r *Reloader) handleOpenAPIYAML(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPISpec)
+}
+
+func (r *Reloader) handleOpenAPIJSON(w http.ResponseWriter, req *http.Request) {
+	spec, err := yaml.YAMLToJSON(openAPISpec)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to convert OpenAPI document")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DNSChange is one row of the dns_changes outbox, appended by triggers in
+// the same transaction as the change itself. The triggers serialize
+// writers, so ids become visible in order and a cursor never skips a row.
+type DNSChange struct {
+	ID        int64     `gorm:"primaryKey;column:id"`
+	Table     string    `gorm:"column:table_name"`
+	Action    string    `gorm:"column:action"`
+	RowID     int       `gorm:"column:row_id"`
+	DomainID  *int      `gorm:"column:domain_id"`
+	Name      *string   `gorm:"column:name"`
+	Type      *string   `gorm:"column:type"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+func (DNSChange) TableName() string {
+	return "dns_changes"
+}
+
+// notification converts the row to the change the reloader applies.
+func (c DNSChange) notification() *DNSChangeNotification {
+	change := &DNSChangeNotification{
+		Table:     c.Table,
+		Action:    c.Action,
+		ID:        c.RowID,
+		Timestamp: c.CreatedAt,
+	}
+	if c.DomainID != nil {
+		change.DomainID = *c.DomainID
+	}
+	if c.Name != nil {
+		change.Name = *c.Name
+	}
+	if c.Type != nil {
+		change.Type = *c.Type
+	}
+	return change
+}
+
+// ChangeCursor is the id of the last outbox row a consumer has applied.
+type ChangeCursor struct {
+	Consumer  string    `gorm:"primaryKey;column:consumer"`
+	LastID    int64     `gorm:"column:last_id"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+func (ChangeCursor) TableName() string {
+	return "dns_change_cursors"
+}
+
+// outboxCursor returns the consumer's cursor. A consumer seen for the first
+// time starts at the end of the outbox, since the startup regeneration
+// already covers everything before it.
+func (r *Reloader) outboxCursor(ctx context.Context) (int64, error) {
+	var cursor ChangeCursor
+	err := r.db.WithContext(ctx).Where("consumer = ?", r.config.OutboxConsumer).First(&cursor).Error
+	if err == nil {
+		return cursor.LastID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, fmt.Errorf("failed to read outbox cursor: %w", err)
+	}
+
+	var last *int64
+	if err := r.db.WithContext(ctx).Model(&DNSChange{}).Select("MAX(id)").Scan(&last).Error; err != nil {
+		return 0, fmt.Errorf("failed to read outbox position: %w", err)
+	}
+	if last == nil {
+		return 0, r.saveOutboxCursor(ctx, 0)
+	}
+	return *last, r.saveOutboxCursor(ctx, *last)
+}
+
+func (r *Reloader) saveOutboxCursor(ctx context.Context, lastID int64) error {
+	cursor := ChangeCursor{Consumer: r.config.OutboxConsumer, LastID: lastID, UpdatedAt: time.Now().UTC()}
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "consumer"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_id", "updated_at"}),
+	}).Create(&cursor).Error
+	if err != nil {
+		return fmt.Errorf("failed to save outbox cursor: %w", err)
+	}
+	return nil
+}
+
+// drainOutbox applies every outbox row after the cursor. Record changes in
+// a run are coalesced into one regeneration; domain changes are applied one
+// by one so per-domain handling such as delegation updates still sees them.
+// The cursor moves past a group only once it has been applied, so a change
+// interrupted by a crash or failed reload is retried rather than lost.
+func (r *Reloader) drainOutbox(ctx context.Context) error {
+	cursor, err := r.outboxCursor(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		var rows []DNSChange
+		if err := r.db.WithContext(ctx).Where("id > ?", cursor).Order("id").Limit(r.config.OutboxBatchSize).Find(&rows).Error; err != nil {
+			return fmt.Errorf("failed to read outbox: %w", err)
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		for len(rows) > 0 {
+			n := 1
+			if rows[0].Table != "domains" {
+				for n < len(rows) && rows[n].Table != "domains" {
+					n++
+				}
+			}
+			group := rows[:n]
+			rows = rows[n:]
+			last := group[len(group)-1]
+
+			change := last.notification()
+			if len(group) > 1 {
+				change = &DNSChangeNotification{
+					Table:     "records",
+					Action:    "OUTBOX_BATCH",
+					Timestamp: last.CreatedAt,
+				}
+			}
+			r.logger.WithFields(logrus.Fields{
+				"from":    group[0].ID,
+				"to":      last.ID,
+				"changes": len(group),
+			}).Info("Applying outbox changes")
+			if err := r.triggerCoreReload(ctx, change); err != nil {
+				return err
+			}
+			if err := r.saveOutboxCursor(ctx, last.ID); err != nil {
+				return err
+			}
+			cursor = last.ID
+		}
+	}
+}
+
+// pruneOutbox deletes rows every consumer has applied that are older than
+// OUTBOX_RETENTION.
+func (r *Reloader) pruneOutbox(ctx context.Context) {
+	var minID *int64
+	if err := r.db.WithContext(ctx).Model(&ChangeCursor{}).Select("MIN(last_id)").Scan(&minID).Error; err != nil || minID == nil {
+		return
+	}
+	result := r.db.WithContext(ctx).Where("id <= ? AND created_at < ?", *minID, time.Now().UTC().Add(-r.config.OutboxRetention)).Delete(&DNSChange{})
+	if result.Error != nil {
+		r.logger.WithError(result.Error).Warn("Failed to prune outbox")
+		return
+	}
+	if result.RowsAffected > 0 {
+		r.logger.WithField("rows", result.RowsAffected).Debug("Pruned outbox")
+	}
+}
+
+// drainOutboxIfEnabled drains the outbox when CHANGE_OUTBOX is on, and
+// prunes it at most hourly. Only the leader consumes it.
+func (r *Reloader) drainOutboxIfEnabled() {
+	if !r.config.Outbox || !r.isLeader() {
+		return
+	}
+	if err := r.drainOutbox(r.ctx); err != nil {
+		r.logger.WithError(err).Error("Failed to apply outbox changes")
+	}
+	if time.Since(r.lastOutboxPrune) >= time.Hour {
+		r.lastOutboxPrune = time.Now()
+		r.pruneOutbox(r.ctx)
+	}
+}
+
+// handleLocalChange applies a change queued by the API. With the outbox on,
+// the triggers have already appended it, so the outbox is drained instead
+// to keep every change applied exactly once. Forced reloads, callers
+// waiting for the outcome and internal changes, which the outbox never
+// sees, are applied directly.
+func (r *Reloader) handleLocalChange(change *DNSChangeNotification) {
+	if r.config.Outbox && !change.force && change.done == nil && !change.internal {
+		r.drainOutboxIfEnabled()
+		return
+	}
+	if err := r.triggerCoreReload(r.ctx, change); err != nil {
+		r.logger.WithError(err).Error("Failed to handle local change")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDrainOutboxOrder(t *testing.T) {
+	record := func(action, name string) DNSChange {
+		return DNSChange{Table: "records", Action: action, Name: &name}
+	}
+	domain := func(action, name string) DNSChange {
+		return DNSChange{Table: "domains", Action: action, Name: &name}
+	}
+
+	tests := []struct {
+		name      string
+		batchSize string
+		rows      []DNSChange
+		want      []string
+	}{
+		{
+			name: "single record change",
+			rows: []DNSChange{record("DELETE", "www.example.com")},
+			want: []string{"DELETE records www.example.com"},
+		},
+		{
+			name: "record changes coalesce",
+			rows: []DNSChange{record("INSERT", "a.example.com"), record("UPDATE", "b.example.com")},
+			want: []string{"OUTBOX_BATCH records "},
+		},
+		{
+			name: "domain changes apply one by one in order",
+			rows: []DNSChange{
+				record("INSERT", "a.example.com"),
+				domain("INSERT", "example.org"),
+				domain("DELETE", "example.net"),
+				record("INSERT", "b.example.org"),
+				record("INSERT", "c.example.org"),
+			},
+			want: []string{
+				"INSERT records a.example.com",
+				"INSERT domains example.org",
+				"DELETE domains example.net",
+				"OUTBOX_BATCH records ",
+			},
+		},
+		{
+			name:      "runs end at the batch size",
+			batchSize: "2",
+			rows:      []DNSChange{record("INSERT", "a.example.com"), record("INSERT", "b.example.com"), record("INSERT", "c.example.com")},
+			want:      []string{"OUTBOX_BATCH records ", "INSERT records c.example.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"CHANGE_OUTBOX": "true"}
+			if tt.batchSize != "" {
+				env["OUTBOX_BATCH_SIZE"] = tt.batchSize
+			}
+			r := newTestReloader(t, env)
+			ctx := t.Context()
+			if _, err := r.outboxCursor(ctx); err != nil {
+				t.Fatal(err)
+			}
+			rows := slices.Clone(tt.rows)
+			if err := r.db.Create(&rows).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			if err := r.drainOutbox(ctx); err != nil {
+				t.Fatal(err)
+			}
+			applied := r.changes.Since(time.Time{})
+			slices.Reverse(applied)
+			var got []string
+			for _, cs := range applied {
+				got = append(got, cs.Action+" "+cs.Table+" "+cs.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("applied %q, want %q", got, tt.want)
+			}
+
+			cursor, err := r.outboxCursor(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if last := rows[len(rows)-1].ID; cursor != last {
+				t.Fatalf("cursor at %d, want %d", cursor, last)
+			}
+		})
+	}
+}
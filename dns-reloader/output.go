@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+)
+
+// outputZone is a generated zone as read back from its zone file.
+type outputZone struct {
+	Name string
+	RRs  []dns.RR
+}
+
+// outputRenderer renders zones into the configuration of a DNS server that
+// does not read zone files. It fails for zones the format cannot hold.
+type outputRenderer func(zones []outputZone) (string, error)
+
+// outputRenderers are the formats OUTPUT_FORMAT selects from.
+var outputRenderers = map[string]outputRenderer{
+	"tinydns": renderTinydns,
+	"dnsmasq": renderDnsmasq,
+	"unbound": renderUnbound,
+	"hosts":   renderHosts,
+}
+
+// writeOutputFile renders the zone files of domains, or of those listed in
+// OUTPUT_ZONES, into OUTPUT_FILE in the OUTPUT_FORMAT, for deployments
+// whose DNS server does not read zone files. The zone files stay the
+// source of truth, so drift checks and verification work as usual. It
+// reports whether the file changed.
+func (r *Reloader) writeOutputFile(domains []Domain) (bool, error) {
+	if r.config.OutputFormat == "" {
+		return false, nil
+	}
+	render, ok := outputRenderers[r.config.OutputFormat]
+	if !ok {
+		return false, fmt.Errorf("unknown OUTPUT_FORMAT %q", r.config.OutputFormat)
+	}
+	if r.config.OutputFile == "" {
+		return false, errors.New("OUTPUT_FILE is required with OUTPUT_FORMAT")
+	}
+
+	var zones []outputZone
+	for _, domain := range domains {
+		if len(r.config.OutputZones) > 0 && !slices.Contains(r.config.OutputZones, domain.Name) {
+			continue
+		}
+		rrs, err := parseZoneFile(r.viewZoneFilePath(domain.Name, domain.Options), domain.Name)
+		if err != nil {
+			return false, fmt.Errorf("failed to read zone %s: %w", domain.Name, err)
+		}
+		zones = append(zones, outputZone{Name: domain.Name, RRs: rrs})
+	}
+	sort.Slice(zones, func(i, j int) bool { return zones[i].Name < zones[j].Name })
+
+	rendered, err := render(zones)
+	if err != nil {
+		return false, err
+	}
+	content := []byte(rendered)
+	if existing, err := os.ReadFile(r.config.OutputFile); err == nil && bytes.Equal(existing, content) {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.config.OutputFile), 0755); err != nil {
+		return false, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	tempPath := r.config.OutputFile + ".tmp"
+	if err := os.WriteFile(tempPath, content, 0644); err != nil {
+		return false, fmt.Errorf("failed to write temporary output file: %w", err)
+	}
+	if err := os.Rename(tempPath, r.config.OutputFile); err != nil {
+		return false, fmt.Errorf("failed to move output file: %w", err)
+	}
+
+	r.logger.WithFields(logrus.Fields{
+		"path":   r.config.OutputFile,
+		"format": r.config.OutputFormat,
+		"zones":  len(zones),
+	}).Info("Generated output file")
+	return true, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// PodmanBackend sends SIGUSR1 to each CoreDNS container through the libpod
+// REST API when a socket is configured, or the podman CLI otherwise.
+type PodmanBackend struct {
+	containers []string
+	label      string
+	socket     string
+	client     *http.Client
+	tracker    *targetTracker
+}
+
+func NewPodmanBackend(containers []string, label, socket string) *PodmanBackend {
+	p := &PodmanBackend{
+		containers: containers,
+		label:      label,
+		socket:     strings.TrimPrefix(socket, "unix://"),
+		tracker:    newTargetTracker(),
+	}
+
+	if p.socket != "" {
+		p.client = &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", p.socket)
+				},
+			},
+		}
+	}
+	return p
+}
+
+func (p *PodmanBackend) Name() string {
+	return "podman"
+}
+
+func (p *PodmanBackend) Reload(ctx context.Context, zones []string) error {
+	containers := p.containers
+	if p.label != "" {
+		var err error
+		containers, err = p.listByLabel(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list containers with label %s: %w", p.label, err)
+		}
+	}
+	return p.tracker.reloadAll(ctx, containers, p.signal)
+}
+
+func (p *PodmanBackend) Targets() []TargetResult {
+	return p.tracker.Targets()
+}
+
+func (p *PodmanBackend) signal(ctx context.Context, container string) error {
+	if p.client == nil {
+		cmd := exec.CommandContext(ctx, "podman", "kill", "--signal", "USR1", container)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("podman kill failed: %w (output: %s)", err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+
+	endpoint := fmt.Sprintf("http://d/v4.0.0/libpod/containers/%s/kill?signal=SIGUSR1", url.PathEscape(container))
+	resp, err := p.request(ctx, http.MethodPost, endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("podman API returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (p *PodmanBackend) listByLabel(ctx context.Context) ([]string, error) {
+	if p.client == nil {
+		cmd := exec.CommandContext(ctx, "podman", "ps", "--filter", "label="+p.label, "--format", "{{.Names}}")
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, err
+		}
+		return strings.Fields(string(output)), nil
+	}
+
+	filters, _ := json.Marshal(map[string][]string{"label": {p.label}})
+	endpoint := "http://d/v4.0.0/libpod/containers/json?filters=" + url.QueryEscape(string(filters))
+	resp, err := p.request(ctx, http.MethodGet, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("podman API returned %s", resp.Status)
+	}
+
+	var containers []struct {
+		Names []string `json:"Names"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, fmt.Errorf("failed to decode container list: %w", err)
+	}
+
+	var names []string
+	for _, c := range containers {
+		if len(c.Names) > 0 {
+			names = append(names, c.Names[0])
+		}
+	}
+	return names, nil
+}
+
+func (p *PodmanBackend) request(ctx context.Context, method, endpoint string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build podman request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("podman API request failed: %w", err)
+	}
+	return resp, nil
+}
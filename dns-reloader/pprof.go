@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// startPprofServer serves net/http/pprof on its own listener so profiling is
+// never exposed on the API port. It is off unless PPROF_LISTEN_ADDR is set.
+func (r *Reloader) startPprofServer() {
+	if r.config.PprofListenAddr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	server := &http.Server{
+		Addr:              r.config.PprofListenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-r.ctx.Done()
+		server.Close()
+	}()
+
+	go func() {
+		r.logger.WithField("addr", r.config.PprofListenAddr).Warn("pprof debug server listening")
+		if err := r.serve(server); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			r.logger.WithError(err).Error("pprof server failed")
+		}
+	}()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// LocalProcessBackend finds the DNS server by process name under /proc and
+// signals it directly. This works when the reloader runs as a sidecar with
+// shareProcessNamespace enabled, without needing docker or kubectl.
+type LocalProcessBackend struct {
+	processName string
+	procDir     string
+	signal      syscall.Signal
+}
+
+func NewLocalProcessBackend(processName string, signal syscall.Signal) *LocalProcessBackend {
+	return &LocalProcessBackend{processName: processName, procDir: "/proc", signal: signal}
+}
+
+func (l *LocalProcessBackend) Name() string {
+	return "local-process"
+}
+
+func (l *LocalProcessBackend) Reload(ctx context.Context, zones []string) error {
+	pids, err := l.findProcesses()
+	if err != nil {
+		return err
+	}
+	if len(pids) == 0 {
+		return fmt.Errorf("no process named %q found in %s", l.processName, l.procDir)
+	}
+
+	var failed []string
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, l.signal); err != nil {
+			failed = append(failed, fmt.Sprintf("pid %d: %v", pid, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to signal %d of %d processes: %s", len(failed), len(pids), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// findProcesses matches on /proc/<pid>/comm, falling back to the basename of
+// argv[0] since comm is truncated to 15 characters.
+func (l *LocalProcessBackend) findProcesses() ([]int, error) {
+	entries, err := os.ReadDir(l.procDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", l.procDir, err)
+	}
+
+	self := os.Getpid()
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == self {
+			continue
+		}
+
+		comm, err := os.ReadFile(filepath.Join(l.procDir, entry.Name(), "comm"))
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(comm)) == l.processName {
+			pids = append(pids, pid)
+			continue
+		}
+
+		cmdline, err := os.ReadFile(filepath.Join(l.procDir, entry.Name(), "cmdline"))
+		if err != nil || len(cmdline) == 0 {
+			continue
+		}
+		argv0 := strings.SplitN(string(cmdline), "\x00", 2)[0]
+		if filepath.Base(argv0) == l.processName {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+)
+
+// errZoneNotHosted is returned by a provider for a zone it does not hold a
+// copy of.
+var errZoneNotHosted = errors.New("zone is not hosted at provider")
+
+// providerZone is a zone as rendered into the local zone file, with the
+// options read from its metadata.
+type providerZone struct {
+	Name    string
+	Options zoneOptions
+	RRsets  []providerRRset
+}
+
+// providerRRset is one RRset of a providerZone. Names are fully qualified
+// and lower case; values are the presentation form of each record's data.
+// Comments are the PowerDNS comments on the RRset.
+type providerRRset struct {
+	Name     string
+	Type     string
+	TTL      uint32
+	Values   []string
+	Comments []string
+}
+
+func (s providerRRset) key() string {
+	return s.Name + "/" + s.Type
+}
+
+// equal reports whether s and other would serve the same answers.
+func (s providerRRset) equal(other providerRRset) bool {
+	return s.TTL == other.TTL && slices.Equal(s.Values, other.Values)
+}
+
+// ZoneProvider keeps a copy of zones at an external DNS provider. Sync makes
+// the provider's copy of zone match it, changing only what differs. The
+// SOA and apex NS records belong to the provider and are never passed.
+type ZoneProvider interface {
+	Name() string
+	Sync(ctx context.Context, zone providerZone) (providerSyncResult, error)
+}
+
+// providerSyncResult counts what a sync changed, in the provider's own
+// units: RRsets for Route53 and RFC 2136, records for Cloudflare.
+type providerSyncResult struct {
+	Upserted int
+	Deleted  int
+}
+
+func NewZoneProviders(config *Config) ([]ZoneProvider, error) {
+	var providers []ZoneProvider
+	for _, name := range config.SyncProviders {
+		switch name {
+		case "route53":
+			provider, err := NewRoute53Provider(config)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, provider)
+		case "cloudflare":
+			provider, err := NewCloudflareProvider(config)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, provider)
+		case "rfc2136":
+			provider, err := NewRFC2136Provider(config)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, provider)
+		default:
+			return nil, fmt.Errorf("unknown sync provider %q", name)
+		}
+	}
+	return providers, nil
+}
+
+// renderProviderZone renders zone as it is written locally, in its first
+// view, and groups its records into RRsets, leaving out the SOA and apex
+// NS records.
+func (r *Reloader) renderProviderZone(ctx context.Context, zone string) (providerZone, error) {
+	domains := make([]Domain, 1)
+	if err := r.db.WithContext(ctx).Where("name = ?", zone).First(&domains[0]).Error; err != nil {
+		return providerZone{}, fmt.Errorf("failed to fetch domain: %w", err)
+	}
+	if err := r.loadZoneOptions(ctx, r.db, domains); err != nil {
+		return providerZone{}, err
+	}
+	if err := r.loadDNSSECKeys(ctx, r.db, domains); err != nil {
+		return providerZone{}, err
+	}
+	var records []Record
+	if err := r.db.WithContext(ctx).Where("domain_id = ?", domains[0].ID).Find(&records).Error; err != nil {
+		return providerZone{}, fmt.Errorf("failed to fetch records: %w", err)
+	}
+	if err := r.attachComments(ctx, records); err != nil {
+		return providerZone{}, err
+	}
+	records = zoneRecords(records, domains[0].Options)
+	content, _ := r.renderZone(domains[0], records)
+	rrs, err := parseZone(strings.NewReader(content), zone, zone+".zone")
+	if err != nil {
+		return providerZone{}, err
+	}
+	comments := make(map[string][]string)
+	for _, record := range records {
+		key := dns.Fqdn(strings.ToLower(record.Name)) + "/" + strings.ToUpper(record.Type)
+		if _, ok := comments[key]; ok {
+			continue
+		}
+		for _, comment := range record.Comments {
+			comments[key] = append(comments[key], comment.Comment)
+		}
+	}
+
+	apex := dns.Fqdn(zone)
+	byKey := make(map[string]*providerRRset)
+	rendered := providerZone{Name: zone, Options: domains[0].Options}
+	var order []string
+	for _, rr := range rrs {
+		header := rr.Header()
+		name := strings.ToLower(header.Name)
+		rrtype := dns.TypeToString[header.Rrtype]
+		if rrtype == "SOA" || (rrtype == "NS" && name == apex) {
+			continue
+		}
+		set := providerRRset{Name: name, Type: rrtype}
+		existing, ok := byKey[set.key()]
+		if !ok {
+			set.TTL = header.Ttl
+			set.Comments = comments[set.key()]
+			byKey[set.key()] = &set
+			order = append(order, set.key())
+			existing = &set
+		}
+		// One TTL per RRset; the lowest is the one resolvers would honour.
+		existing.TTL = min(existing.TTL, header.Ttl)
+		value := rdataString(rr)
+		if !slices.Contains(existing.Values, value) {
+			existing.Values = append(existing.Values, value)
+		}
+	}
+	for _, key := range order {
+		set := byKey[key]
+		slices.Sort(set.Values)
+		rendered.RRsets = append(rendered.RRsets, *set)
+	}
+	return rendered, nil
+}
+
+// syncProviders pushes zones to every configured provider. Failures are
+// logged; the next change to the zone, or sync-providers, retries.
+func (r *Reloader) syncProviders(ctx context.Context, zones []string) {
+	if len(r.providers) == 0 {
+		return
+	}
+	for _, zone := range zones {
+		rendered, err := r.renderProviderZone(ctx, zone)
+		if err != nil {
+			r.logger.WithError(err).WithField("domain", zone).Error("Failed to render zone for providers")
+			continue
+		}
+		for _, provider := range r.providers {
+			r.syncProvider(ctx, provider, rendered)
+		}
+	}
+}
+
+func (r *Reloader) syncProvider(ctx context.Context, provider ZoneProvider, zone providerZone) error {
+	fields := logrus.Fields{"domain": zone.Name, "provider": provider.Name()}
+	result, err := provider.Sync(ctx, zone)
+	switch {
+	case errors.Is(err, errZoneNotHosted):
+		providerSyncs.WithLabelValues(provider.Name(), "skipped").Inc()
+		r.logger.WithFields(fields).Debug("Zone is not hosted at provider")
+		return nil
+	case err != nil:
+		providerSyncs.WithLabelValues(provider.Name(), "failed").Inc()
+		r.logger.WithError(err).WithFields(fields).Error("Failed to sync zone to provider")
+		r.alertFailure("provider", zone.Name, err)
+		return err
+	}
+	r.alertRecovered("provider", zone.Name)
+	if result.Upserted == 0 && result.Deleted == 0 {
+		providerSyncs.WithLabelValues(provider.Name(), "unchanged").Inc()
+		return nil
+	}
+	providerSyncs.WithLabelValues(provider.Name(), "changed").Inc()
+	fields["upserted"] = result.Upserted
+	fields["deleted"] = result.Deleted
+	r.logger.WithFields(fields).Info("Synced zone to provider")
+	return nil
+}
+
+// syncProvidersCommand pushes every zone, or the zones named as arguments,
+// to the configured providers, for the first sync or after a provider was
+// changed by hand.
+func (r *Reloader) syncProvidersCommand(args []string) error {
+	fs := flag.NewFlagSet("sync-providers", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	providers, err := NewZoneProviders(r.config)
+	if err != nil {
+		return err
+	}
+	if len(providers) == 0 {
+		return errors.New("no SYNC_PROVIDERS configured")
+	}
+	if err := r.connectDB(); err != nil {
+		return err
+	}
+
+	zones := fs.Args()
+	if len(zones) == 0 {
+		domains, err := r.listDomains(r.ctx)
+		if err != nil {
+			return fmt.Errorf("failed to fetch domains: %w", err)
+		}
+		for _, domain := range domains {
+			zones = append(zones, domain.Name)
+		}
+	}
+
+	failed := 0
+	for _, zone := range zones {
+		zone = strings.TrimSuffix(strings.ToLower(zone), ".")
+		rendered, err := r.renderProviderZone(r.ctx, zone)
+		if err != nil {
+			return fmt.Errorf("failed to render %s: %w", zone, err)
+		}
+		for _, provider := range providers {
+			if err := r.syncProvider(r.ctx, provider, rendered); err != nil {
+				failed++
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d zone sync(s) failed", failed)
+	}
+	return nil
+}
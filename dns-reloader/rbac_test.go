@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb "dns-reloader/api/v1"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestRouteRoles(t *testing.T) {
+	r := newTestReloader(t, map[string]string{"API_ADMIN_TOKEN": "admin-secret"})
+	ctx := t.Context()
+	createTestZone(t, r, "example.com")
+	createTestZone(t, r, "example.org")
+	secrets := map[string]string{"admin": "admin-secret"}
+	for _, body := range []apiTokenRequest{
+		{Name: "viewer"},
+		{Name: "editor", Role: "editor"},
+		{Name: "zone-admin", Role: "zone-admin"},
+		{Name: "granted", Grants: map[string]string{"example.com": "editor"}},
+	} {
+		token, err := r.createAPIToken(ctx, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		secrets[body.Name] = token.Secret
+	}
+
+	mux := http.NewServeMux()
+	for pattern := range routeRoles {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+	mux.HandleFunc("POST /api/v1/tokens", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := r.authenticate(mux)
+
+	tests := []struct {
+		token  string
+		method string
+		path   string
+		want   int
+	}{
+		{"viewer", "GET", "/api/v1/domains/example.com/records", http.StatusNoContent},
+		{"viewer", "POST", "/api/v1/domains/example.com/records", http.StatusForbidden},
+		{"editor", "POST", "/api/v1/domains/example.com/records", http.StatusNoContent},
+		{"editor", "DELETE", "/api/v1/domains/example.com", http.StatusForbidden},
+		{"zone-admin", "DELETE", "/api/v1/domains/example.com", http.StatusNoContent},
+		{"zone-admin", "POST", "/api/v1/tokens", http.StatusForbidden},
+		{"admin", "POST", "/api/v1/tokens", http.StatusNoContent},
+		{"granted", "POST", "/api/v1/domains/example.com/records", http.StatusNoContent},
+		{"granted", "POST", "/api/v1/domains/example.org/records", http.StatusForbidden},
+		{"granted", "DELETE", "/api/v1/domains/example.com", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token+" "+tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+secrets[tt.token])
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("answered %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+
+	grpcTests := []struct {
+		name   string
+		token  string
+		method string
+		req    interface{}
+		want   codes.Code
+	}{
+		{"no token", "", pb.DNSReloader_ListDomains_FullMethodName, &pb.ListDomainsRequest{}, codes.Unauthenticated},
+		{"unknown token", "wrong", pb.DNSReloader_ListDomains_FullMethodName, &pb.ListDomainsRequest{}, codes.Unauthenticated},
+		{"viewer reads", secrets["viewer"], pb.DNSReloader_ListRecords_FullMethodName, &pb.ListRecordsRequest{Domain: "example.com"}, codes.OK},
+		{"viewer writes", secrets["viewer"], pb.DNSReloader_CreateRecord_FullMethodName, &pb.CreateRecordRequest{Domain: "example.com"}, codes.PermissionDenied},
+		{"granted domain", secrets["granted"], pb.DNSReloader_CreateRecord_FullMethodName, &pb.CreateRecordRequest{Domain: "example.com"}, codes.OK},
+		{"other domain", secrets["granted"], pb.DNSReloader_CreateRecord_FullMethodName, &pb.CreateRecordRequest{Domain: "example.org"}, codes.PermissionDenied},
+		{"unmapped method", secrets["zone-admin"], "/dnsreloader.v1.DNSReloader/Unknown", nil, codes.PermissionDenied},
+		{"admin", "admin-secret", "/dnsreloader.v1.DNSReloader/Unknown", nil, codes.OK},
+	}
+	for _, tt := range grpcTests {
+		t.Run("gRPC "+tt.name, func(t *testing.T) {
+			callCtx := ctx
+			if tt.token != "" {
+				callCtx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+tt.token))
+			}
+			_, err := r.admitGRPC(callCtx, tt.method, tt.req)
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("answered %s, want %s: %v", got, tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RecordVersion is one row of the record_versions journal, written by a
+// database trigger on every insert, update and delete of a record.
+type RecordVersion struct {
+	ID        uint      `gorm:"primaryKey;column:id" json:"id"`
+	RecordID  int       `gorm:"column:record_id" json:"record_id"`
+	DomainID  *int      `gorm:"column:domain_id" json:"domain_id,omitempty"`
+	Action    string    `gorm:"column:action" json:"action"`
+	OldData   *string   `gorm:"column:old_data" json:"-"`
+	NewData   *string   `gorm:"column:new_data" json:"-"`
+	ChangedBy string    `gorm:"column:changed_by" json:"changed_by,omitempty"`
+	ChangedAt time.Time `gorm:"column:changed_at" json:"changed_at"`
+
+	Before *Record `gorm:"-" json:"before"`
+	After  *Record `gorm:"-" json:"after"`
+}
+
+func (RecordVersion) TableName() string {
+	return "record_versions"
+}
+
+// decode fills Before and After from the stored JSON images.
+func (v *RecordVersion) decode() error {
+	for _, image := range []struct {
+		data *string
+		dst  **Record
+	}{{v.OldData, &v.Before}, {v.NewData, &v.After}} {
+		if image.data == nil {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal([]byte(*image.data), &record); err != nil {
+			return fmt.Errorf("failed to decode record version %d: %w", v.ID, err)
+		}
+		*image.dst = &record
+	}
+	return nil
+}
+
+// recordHistory returns a record's versions, newest first. It works for
+// deleted records too.
+func (r *Reloader) recordHistory(ctx context.Context, recordID, limit int) ([]RecordVersion, error) {
+	var versions []RecordVersion
+	if err := r.scopeAccountRecords(ctx, r.db.WithContext(ctx)).Where("record_id = ?", recordID).Order("id DESC").Limit(limit).Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch record history: %w", err)
+	}
+	if len(versions) == 0 {
+		return nil, errNotFound("no history for record")
+	}
+	for i := range versions {
+		if err := versions[i].decode(); err != nil {
+			return nil, err
+		}
+	}
+	return versions, nil
+}
+
+// restoreRecordVersion puts a record back the way it was right after the
+// given version. Restoring a DELETE version brings the deleted record back
+// with its original ID. The restore itself is journaled as a new version.
+func (r *Reloader) restoreRecordVersion(ctx context.Context, recordID, versionID int) (*Record, error) {
+	var version RecordVersion
+	if err := r.db.WithContext(ctx).Where("id = ? AND record_id = ?", versionID, recordID).First(&version).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errNotFound("version not found")
+		}
+		return nil, err
+	}
+	if err := version.decode(); err != nil {
+		return nil, err
+	}
+	image := version.After
+	if image == nil {
+		image = version.Before
+	}
+
+	var domain Domain
+	if err := r.db.WithContext(ctx).First(&domain, image.DomainID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errConflict("the record's domain no longer exists")
+		}
+		return nil, err
+	}
+	if err := checkAccount(ctx, &domain); err != nil {
+		return nil, err
+	}
+	if err := r.checkWritable(&domain); err != nil {
+		return nil, err
+	}
+
+	body := recordRequest{
+		Name:     image.Name + ".",
+		Type:     image.Type,
+		Content:  image.Content,
+		TTL:      &image.TTL,
+		Prio:     image.Prio,
+		Weight:   image.Weight,
+		Disabled: image.Disabled,
+		Comment:  image.Comment,
+	}
+
+	var record Record
+	action := "UPDATE"
+	// Unscoped so a soft-deleted record is brought back in place.
+	err := r.db.WithContext(ctx).Unscoped().Where("id = ?", recordID).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		action = "INSERT"
+		record = Record{ID: uint(recordID), CreatedBy: image.CreatedBy}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := applyRecordRequest(ctx, r.db, &domain, &record, body); err != nil {
+		return nil, err
+	}
+	if action == "INSERT" {
+		err = r.db.WithContext(ctx).Omit(clause.Associations).Create(&record).Error
+	} else {
+		err = r.db.WithContext(ctx).Unscoped().Omit(clause.Associations, "CreatedAt", "CreatedBy").Save(&record).Error
+	}
+	if err == nil && record.DeletedAt.Valid {
+		err = r.db.WithContext(ctx).Exec("UPDATE records SET deleted_at = NULL WHERE id = ?", record.ID).Error
+		record.DeletedAt = SoftDeletedAt{}
+	}
+	if err == nil {
+		err = r.setRecordWeight(ctx, r.db, &record, body.Weight)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r.logger.WithField("record_id", recordID).WithField("version", versionID).Info("Restored record version")
+	r.recordChanged(&domain, &record, action)
+	return &record, nil
+}
+
+func (r *Reloader) handleRecordHistory(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(req.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid record id")
+		return
+	}
+	limit := 100
+	if v := req.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = n
+	}
+
+	versions, err := r.recordHistory(req.Context(), id, limit)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"record_id": id,
+		"versions":  versions,
+	})
+}
+
+func (r *Reloader) handleRestoreRecordVersion(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(req.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid record id")
+		return
+	}
+	versionID, err := strconv.Atoi(req.PathValue("version"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid version id")
+		return
+	}
+
+	record, err := r.restoreRecordVersion(req.Context(), id, versionID)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// recordRequest is the body accepted by the record create and update
+// endpoints. Name may be relative to the zone, "@" for the apex, or fully
+// qualified. View puts the record in one split-horizon view, "" in all of
+// them, and Region tags it as the answer of one region, "" of all.
+// NotBefore and NotAfter, RFC 3339 or "" for none, bound when it is
+// published. Left out, an update keeps what the record had.
+type recordRequest struct {
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	Content   string  `json:"content"`
+	TTL       *int    `json:"ttl,omitempty"`
+	Prio      *int    `json:"prio,omitempty"`
+	Weight    *int    `json:"weight,omitempty"`
+	Disabled  bool    `json:"disabled"`
+	Comment   *string `json:"comment,omitempty"`
+	View      *string `json:"view,omitempty"`
+	Region    *string `json:"region,omitempty"`
+	NotBefore *string `json:"not_before,omitempty"`
+	NotAfter  *string `json:"not_after,omitempty"`
+}
+
+// findDomain resolves a domain reference, which may be a numeric ID or a
+// domain name.
+func (r *Reloader) findDomain(ctx context.Context, ref string) (*Domain, error) {
+	var domain Domain
+	query := r.db.WithContext(ctx)
+	if id, err := strconv.Atoi(ref); err == nil {
+		query = query.Where("id = ?", id)
+	} else {
+		query = query.Where("name = ?", strings.TrimSuffix(strings.ToLower(ref), "."))
+	}
+
+	if err := query.First(&domain).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errNotFound("domain not found")
+		}
+		return nil, err
+	}
+	if err := checkAccount(ctx, &domain); err != nil {
+		return nil, err
+	}
+	return &domain, nil
+}
+
+func (r *Reloader) findRecord(ctx context.Context, domain *Domain, id int) (*Record, error) {
+	var record Record
+	if err := r.db.WithContext(ctx).Where("id = ? AND domain_id = ?", id, domain.ID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errNotFound("record not found")
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// qualifyName turns a record name from the API into the stored form: lower
+// case, no trailing dot, inside the zone.
+func qualifyName(name, domain string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == "@" {
+		return domain
+	}
+	if strings.HasSuffix(name, ".") {
+		return strings.TrimSuffix(name, ".")
+	}
+	if name == domain || strings.HasSuffix(name, "."+domain) {
+		return name
+	}
+	return name + "." + domain
+}
+
+// applyRecordRequest fills record from body and validates the result,
+// including CNAME exclusivity against the other records at the same name as
+// seen through db.
+func applyRecordRequest(ctx context.Context, db *gorm.DB, domain *Domain, record *Record, body recordRequest) error {
+	record.DomainID = int(domain.ID)
+	record.Name = qualifyName(body.Name, domain.Name)
+	record.Type = strings.ToUpper(strings.TrimSpace(body.Type))
+	record.Content = strings.TrimSpace(body.Content)
+	record.Prio = body.Prio
+	record.Weight = body.Weight
+	record.Disabled = body.Disabled
+	record.Comment = body.Comment
+	if body.TTL != nil {
+		record.TTL = *body.TTL
+	} else if record.TTL == 0 {
+		record.TTL = 300
+	}
+	record.Auth = true
+
+	var problems []string
+	if _, ok := dns.StringToType[record.Type]; !ok || record.Type == "" {
+		problems = append(problems, fmt.Sprintf("unknown record type %q", body.Type))
+	}
+	if record.Content == "" {
+		problems = append(problems, "content is required")
+	}
+	problems = append(problems, validateRecord(domain.Name, *record)...)
+
+	var siblings []Record
+	if err := db.WithContext(ctx).
+		Where("domain_id = ? AND name = ? AND id <> ? AND disabled = ?", domain.ID, record.Name, record.ID, false).
+		Find(&siblings).Error; err != nil {
+		return fmt.Errorf("failed to check existing records: %w", err)
+	}
+	if !record.Disabled {
+		for _, sibling := range siblings {
+			if (record.Type == "CNAME" || strings.EqualFold(sibling.Type, "CNAME")) && !weightedCNAMEs(*record, sibling) {
+				problems = append(problems, fmt.Sprintf("%s cannot have a CNAME alongside other record types", record.Name))
+				break
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return errValidation(problems)
+	}
+	return nil
+}
+
+func (r *Reloader) listRecords(ctx context.Context, ref string) (*Domain, []Record, error) {
+	domain, err := r.findDomain(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var records []Record
+	if err := r.db.WithContext(ctx).Where("domain_id = ?", domain.ID).Order("name, type, id").Find(&records).Error; err != nil {
+		return nil, nil, err
+	}
+	return domain, scopeRecords(ctx, domain, records), nil
+}
+
+func (r *Reloader) createRecord(ctx context.Context, ref string, body recordRequest) (*Record, error) {
+	domain, err := r.findDomain(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkWritable(domain); err != nil {
+		return nil, err
+	}
+
+	record := Record{CreatedBy: "api"}
+	if err := applyRecordRequest(ctx, r.db, domain, &record, body); err != nil {
+		return nil, err
+	}
+	if err := checkScope(ctx, domain, &record); err != nil {
+		return nil, err
+	}
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&record).Error; err != nil {
+			return err
+		}
+		return r.setRecordTags(ctx, tx, &record, body)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.recordChanged(domain, &record, "INSERT")
+	return &record, nil
+}
+
+func (r *Reloader) updateRecord(ctx context.Context, ref string, id int, body recordRequest) (*Record, error) {
+	domain, err := r.findDomain(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkWritable(domain); err != nil {
+		return nil, err
+	}
+	record, err := r.findRecord(ctx, domain, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkScope(ctx, domain, record); err != nil {
+		return nil, err
+	}
+
+	if err := applyRecordRequest(ctx, r.db, domain, record, body); err != nil {
+		return nil, err
+	}
+	if err := checkScope(ctx, domain, record); err != nil {
+		return nil, err
+	}
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations, "CreatedAt", "CreatedBy").Save(record).Error; err != nil {
+			return err
+		}
+		return r.setRecordTags(ctx, tx, record, body)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.recordChanged(domain, record, "UPDATE")
+	return record, nil
+}
+
+func (r *Reloader) deleteRecord(ctx context.Context, ref string, id int) error {
+	domain, err := r.findDomain(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if err := r.checkWritable(domain); err != nil {
+		return err
+	}
+	record, err := r.findRecord(ctx, domain, id)
+	if err != nil {
+		return err
+	}
+	if err := checkScope(ctx, domain, record); err != nil {
+		return err
+	}
+
+	if err := r.db.WithContext(ctx).Delete(&Record{}, record.ID).Error; err != nil {
+		return err
+	}
+	r.recordChanged(domain, record, "DELETE")
+	return nil
+}
+
+func (r *Reloader) handleListRecords(w http.ResponseWriter, req *http.Request) {
+	domain, records, err := r.listRecords(req.Context(), req.PathValue("id"))
+	if err == nil {
+		err = r.attachComments(req.Context(), records)
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"domain":  domain.Name,
+		"records": records,
+	})
+}
+
+func (r *Reloader) handleCreateRecord(w http.ResponseWriter, req *http.Request) {
+	var body recordRequest
+	if err := readJSON(req, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	record, err := r.createRecord(req.Context(), req.PathValue("id"), body)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, record)
+}
+
+func (r *Reloader) handleUpdateRecord(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(req.PathValue("recordID"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid record id")
+		return
+	}
+
+	var body recordRequest
+	if err := readJSON(req, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	record, err := r.updateRecord(req.Context(), req.PathValue("id"), id, body)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+func (r *Reloader) handleDeleteRecord(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(req.PathValue("recordID"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid record id")
+		return
+	}
+
+	if err := r.deleteRecord(req.Context(), req.PathValue("id"), id); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (r *Reloader) recordChanged(domain *Domain, record *Record, action string) {
+	r.apiChanged(&DNSChangeNotification{
+		Table:     "records",
+		Action:    action,
+		ID:        int(record.ID),
+		DomainID:  int(domain.ID),
+		Name:      record.Name,
+		Type:      record.Type,
+		Timestamp: time.Now(),
+	})
+}
+
+// apiChanged hands an API write to the main loop for regeneration. In
+// listener mode the database trigger already delivers the change, so nothing
+// is queued.
+func (r *Reloader) apiChanged(change *DNSChangeNotification) {
+	if r.status.currentMode() == "listener" {
+		return
+	}
+	r.enqueueChange(change)
+}
+
+// enqueueChange queues a change for the main loop without blocking the
+// caller. A full queue is harmless: the poller will find the change anyway.
+func (r *Reloader) enqueueChange(change *DNSChangeNotification) {
+	select {
+	case r.localChanges <- change:
+	default:
+		r.logger.WithField("name", change.Name).Warn("Local change queue full, leaving change to the poller")
+	}
+}
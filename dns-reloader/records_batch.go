@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxBatchRecords caps a single records:batch request.
+const maxBatchRecords = 10000
+
+type recordBatchRequest struct {
+	Records []recordRequest `json:"records"`
+}
+
+// createRecords validates and inserts records in one transaction. Every row
+// is checked, so a failed batch reports all of its problems prefixed with
+// the 1-based row number, and nothing is written. The per-row database
+// notifications are suppressed and a single change is queued instead.
+func (r *Reloader) createRecords(ctx context.Context, ref string, rows []recordRequest) (*Domain, []Record, error) {
+	domain, err := r.findDomain(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := r.checkWritable(domain); err != nil {
+		return nil, nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil, errInvalid("no records in batch")
+	}
+	if len(rows) > maxBatchRecords {
+		return nil, nil, errInvalid(fmt.Sprintf("batch exceeds %d records", maxBatchRecords))
+	}
+
+	records := make([]Record, 0, len(rows))
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, ok := r.storage.(*PostgresStorage); ok {
+			if err := tx.Exec("SET LOCAL dns_reloader.suppress_notify = 'on'").Error; err != nil {
+				return fmt.Errorf("failed to suppress notifications: %w", err)
+			}
+		}
+
+		var problems []string
+		for i, row := range rows {
+			record := Record{CreatedBy: "api"}
+			if err := applyRecordRequest(ctx, tx, domain, &record, row); err != nil {
+				var apiErr *apiError
+				if !errors.As(err, &apiErr) {
+					return err
+				}
+				for _, problem := range apiErr.problems {
+					problems = append(problems, fmt.Sprintf("row %d: %s", i+1, problem))
+				}
+				continue
+			}
+			if err := checkScope(ctx, domain, &record); err != nil {
+				return err
+			}
+			if err := tx.Omit(clause.Associations).Create(&record).Error; err != nil {
+				return fmt.Errorf("failed to insert row %d: %w", i+1, err)
+			}
+			if err := r.setRecordTags(ctx, tx, &record, row); err != nil {
+				return err
+			}
+			records = append(records, record)
+		}
+		if len(problems) > 0 {
+			return errValidation(problems)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.enqueueChange(&DNSChangeNotification{
+		Table:     "records",
+		Action:    "BATCH_INSERT",
+		DomainID:  int(domain.ID),
+		Name:      domain.Name,
+		Timestamp: time.Now(),
+	})
+	return domain, records, nil
+}
+
+// readRecordsCSV parses a CSV body whose header row names the columns:
+// name, type and content are required; ttl, prio, weight, disabled and
+// comment are optional.
+func readRecordsCSV(body io.Reader) ([]recordRequest, error) {
+	rows, _, err := parseRecordsCSV(body, false, maxBatchRecords)
+	return rows, err
+}
+
+// parseRecordsCSV reads the rows of a records CSV. With withDomain the
+// domain column is required too and each row's domain is returned
+// alongside it. limit caps the number of rows when it is not zero.
+func parseRecordsCSV(body io.Reader, withDomain bool, limit int) ([]recordRequest, []string, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "name", "type", "content", "ttl", "prio", "weight", "disabled", "comment":
+			columns[name] = i
+		case "domain":
+			if withDomain {
+				columns[name] = i
+				continue
+			}
+			fallthrough
+		default:
+			return nil, nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+	}
+	required := []string{"name", "type", "content"}
+	if withDomain {
+		required = append([]string{"domain"}, required...)
+	}
+	for _, column := range required {
+		if _, ok := columns[column]; !ok {
+			return nil, nil, fmt.Errorf("CSV header is missing the %q column", column)
+		}
+	}
+
+	var rows []recordRequest
+	var domains []string
+	for line := 2; ; line++ {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return rows, domains, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		if limit > 0 && len(rows) == limit {
+			return nil, nil, fmt.Errorf("batch exceeds %d records", limit)
+		}
+
+		get := func(column string) string {
+			if i, ok := columns[column]; ok {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+		row := recordRequest{
+			Name:    get("name"),
+			Type:    get("type"),
+			Content: get("content"),
+		}
+		for column, dst := range map[string]**int{"ttl": &row.TTL, "prio": &row.Prio, "weight": &row.Weight} {
+			if v := get(column); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil {
+					return nil, nil, fmt.Errorf("line %d: invalid %s %q", line, column, v)
+				}
+				*dst = &n
+			}
+		}
+		if v := get("disabled"); v != "" {
+			if row.Disabled, err = strconv.ParseBool(v); err != nil {
+				return nil, nil, fmt.Errorf("line %d: invalid disabled %q", line, v)
+			}
+		}
+		if v := get("comment"); v != "" {
+			row.Comment = &v
+		}
+		rows = append(rows, row)
+		if withDomain {
+			domains = append(domains, strings.TrimSuffix(strings.ToLower(get("domain")), "."))
+		}
+	}
+}
+
+// handleBatchRecords serves POST /api/v1/domains/{id}/records:batch with
+// either a JSON {"records": [...]} body or, for Content-Type text/csv, a
+// CSV file.
+func (r *Reloader) handleBatchRecords(w http.ResponseWriter, req *http.Request) {
+	var rows []recordRequest
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		parsed, err := readRecordsCSV(http.MaxBytesReader(w, req.Body, 10<<20))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		rows = parsed
+	} else {
+		var body recordBatchRequest
+		if err := readJSON(req, &body); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		rows = body.Records
+	}
+
+	domain, records, err := r.createRecords(req.Context(), req.PathValue("id"), rows)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"domain":  domain.Name,
+		"created": len(records),
+		"records": records,
+	})
+}
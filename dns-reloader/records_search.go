@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// recordSortColumns maps the sort keys accepted by the search API to
+// columns.
+var recordSortColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"type":       "type",
+	"content":    "content",
+	"ttl":        "ttl",
+	"domain_id":  "domain_id",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+type recordSearch struct {
+	Name     string
+	Type     string
+	Content  string
+	Domain   string
+	Disabled *bool
+	Sort     string
+	Limit    int
+	Offset   int
+}
+
+type recordSearchResult struct {
+	Records []Record `json:"records"`
+	Total   int64    `json:"total"`
+	Limit   int      `json:"limit"`
+	Offset  int      `json:"offset"`
+}
+
+// parseRecordSearch reads the search filters from a query string. Sort is a
+// column name, prefixed with "-" for descending order.
+func parseRecordSearch(q url.Values) (recordSearch, error) {
+	search := recordSearch{
+		Name:    q.Get("name"),
+		Type:    strings.ToUpper(q.Get("type")),
+		Content: q.Get("content"),
+		Domain:  q.Get("domain"),
+		Sort:    q.Get("sort"),
+		Limit:   100,
+	}
+	if search.Sort == "" {
+		search.Sort = "name"
+	}
+	if _, ok := recordSortColumns[strings.TrimPrefix(search.Sort, "-")]; !ok {
+		return search, errInvalid(fmt.Sprintf("cannot sort by %q", search.Sort))
+	}
+	if v := q.Get("disabled"); v != "" {
+		disabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return search, errInvalid("invalid disabled parameter")
+		}
+		search.Disabled = &disabled
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			return search, errInvalid("limit must be between 1 and 1000")
+		}
+		search.Limit = n
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return search, errInvalid("offset must be a non-negative integer")
+		}
+		search.Offset = n
+	}
+	return search, nil
+}
+
+// likePattern builds a case-insensitive substring pattern, escaping LIKE
+// wildcards in s with "!", which unlike backslash means the same in every
+// supported database.
+func likePattern(s string) string {
+	s = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(strings.ToLower(s))
+	return "%" + s + "%"
+}
+
+// searchRecords finds records across all domains. Name and content match as
+// case-insensitive substrings; type and domain match exactly.
+func (r *Reloader) searchRecords(ctx context.Context, search recordSearch) (*recordSearchResult, error) {
+	query := r.scopeAccountRecords(ctx, r.db.WithContext(ctx).Model(&Record{}))
+	if search.Domain != "" {
+		domain, err := r.findDomain(ctx, search.Domain)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("domain_id = ?", domain.ID)
+	}
+	if search.Name != "" {
+		query = query.Where("LOWER(name) LIKE ? ESCAPE '!'", likePattern(search.Name))
+	}
+	if search.Type != "" {
+		query = query.Where("UPPER(type) = ?", search.Type)
+	}
+	if search.Content != "" {
+		query = query.Where("LOWER(content) LIKE ? ESCAPE '!'", likePattern(search.Content))
+	}
+	if search.Disabled != nil {
+		query = query.Where("disabled = ?", *search.Disabled)
+	}
+
+	result := &recordSearchResult{Records: []Record{}, Limit: search.Limit, Offset: search.Offset}
+	if err := query.Count(&result.Total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count records: %w", err)
+	}
+
+	order := recordSortColumns[strings.TrimPrefix(search.Sort, "-")]
+	if strings.HasPrefix(search.Sort, "-") {
+		order += " DESC"
+	}
+	if err := query.Order(order + ", id").Limit(search.Limit).Offset(search.Offset).Find(&result.Records).Error; err != nil {
+		return nil, fmt.Errorf("failed to search records: %w", err)
+	}
+	if err := r.attachComments(ctx, result.Records); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *Reloader) handleSearchRecords(w http.ResponseWriter, req *http.Request) {
+	search, err := parseRecordSearch(req.URL.Query())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	result, err := r.searchRecords(req.Context(), search)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/publicsuffix"
+)
+
+var errRegistrarUnsupported = errors.New("operation not supported by registrar")
+
+// DelegationKey is a DNSSEC key to be published at the parent, carrying both
+// the DS digest and the DNSKEY fields since registrars accept either.
+type DelegationKey struct {
+	KeyTag     uint16 `json:"key_tag"`
+	Algorithm  uint8  `json:"algorithm"`
+	Flags      uint16 `json:"flags"`
+	PublicKey  string `json:"public_key"`
+	DigestType uint8  `json:"digest_type"`
+	Digest     string `json:"digest"`
+}
+
+// Registrar pushes delegation data for a domain to its registrar.
+type Registrar interface {
+	Name() string
+	SetNameservers(ctx context.Context, domain string, nameservers []string) error
+	SetDelegationSigners(ctx context.Context, domain string, keys []DelegationKey) error
+}
+
+func NewRegistrar(config *Config) (Registrar, error) {
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+
+	switch config.Registrar {
+	case "":
+		return nil, nil
+	case "gandi":
+		if config.RegistrarAPIKey == "" {
+			return nil, fmt.Errorf("REGISTRAR_API_KEY is required for gandi")
+		}
+		return &GandiRegistrar{token: config.RegistrarAPIKey, client: httpClient}, nil
+	case "namecheap":
+		if config.RegistrarAPIKey == "" || config.NamecheapAPIUser == "" || config.NamecheapClientIP == "" {
+			return nil, fmt.Errorf("REGISTRAR_API_KEY, NAMECHEAP_API_USER and NAMECHEAP_CLIENT_IP are required for namecheap")
+		}
+		return &NamecheapRegistrar{
+			apiUser:  config.NamecheapAPIUser,
+			apiKey:   config.RegistrarAPIKey,
+			clientIP: config.NamecheapClientIP,
+			client:   httpClient,
+		}, nil
+	case "route53domains":
+		awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), awsconfig.WithRegion("us-east-1"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		}
+		return &Route53DomainsRegistrar{aws: awsCfg, client: httpClient}, nil
+	default:
+		return nil, fmt.Errorf("unknown registrar %q", config.Registrar)
+	}
+}
+
+// updateDelegation pushes the configured nameservers, and any DS keys, for
+// domain to the registrar.
+func (r *Reloader) updateDelegation(ctx context.Context, domain string, keys []DelegationKey) error {
+	if r.registrar == nil {
+		return fmt.Errorf("no registrar configured")
+	}
+	if len(r.config.OnboardingNameservers) == 0 {
+		return fmt.Errorf("ONBOARDING_NAMESERVERS is not configured")
+	}
+
+	if err := r.registrar.SetNameservers(ctx, domain, r.config.OnboardingNameservers); err != nil {
+		return fmt.Errorf("failed to set nameservers: %w", err)
+	}
+
+	if len(keys) > 0 {
+		if err := r.registrar.SetDelegationSigners(ctx, domain, keys); err != nil {
+			return fmt.Errorf("failed to set DS records: %w", err)
+		}
+	}
+
+	r.logger.WithFields(logrus.Fields{
+		"domain":      domain,
+		"registrar":   r.registrar.Name(),
+		"nameservers": r.config.OnboardingNameservers,
+		"ds":          len(keys),
+	}).Info("Updated delegation at registrar")
+	return nil
+}
+
+func (r *Reloader) handleUpdateDelegation(w http.ResponseWriter, req *http.Request) {
+	domain := strings.TrimSuffix(strings.ToLower(req.PathValue("name")), ".")
+
+	row, err := r.findDomain(req.Context(), domain)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	keys, err := r.delegationKeys(req.Context(), *row)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := r.updateDelegation(req.Context(), domain, keys); err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"domain":      domain,
+		"registrar":   r.registrar.Name(),
+		"nameservers": r.config.OnboardingNameservers,
+		"ds":          keys,
+	})
+}
+
+// GandiRegistrar uses the Gandi v5 domain API with a personal access token.
+type GandiRegistrar struct {
+	token  string
+	client *http.Client
+}
+
+func (g *GandiRegistrar) Name() string {
+	return "gandi"
+}
+
+func (g *GandiRegistrar) SetNameservers(ctx context.Context, domain string, nameservers []string) error {
+	body := map[string][]string{"nameservers": nameservers}
+	return g.do(ctx, http.MethodPut, fmt.Sprintf("/v5/domain/domains/%s/nameservers", domain), body)
+}
+
+func (g *GandiRegistrar) SetDelegationSigners(ctx context.Context, domain string, keys []DelegationKey) error {
+	for _, key := range keys {
+		keyType := "zsk"
+		if key.Flags&1 == 1 {
+			keyType = "ksk"
+		}
+		body := map[string]interface{}{
+			"algorithm":  key.Algorithm,
+			"type":       keyType,
+			"public_key": key.PublicKey,
+		}
+		if err := g.do(ctx, http.MethodPost, fmt.Sprintf("/v5/domain/domains/%s/dnskeys", domain), body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *GandiRegistrar) do(ctx context.Context, method, path string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, "https://api.gandi.net"+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+g.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("gandi request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("gandi returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// NamecheapRegistrar uses the Namecheap XML API, which only supports
+// nameserver changes; DS records must be managed in their dashboard.
+type NamecheapRegistrar struct {
+	apiUser  string
+	apiKey   string
+	clientIP string
+	client   *http.Client
+}
+
+func (n *NamecheapRegistrar) Name() string {
+	return "namecheap"
+}
+
+func (n *NamecheapRegistrar) SetNameservers(ctx context.Context, domain string, nameservers []string) error {
+	suffix, _ := publicsuffix.PublicSuffix(domain)
+	sld := strings.TrimSuffix(strings.TrimSuffix(domain, suffix), ".")
+	if sld == "" || strings.Contains(sld, ".") {
+		return fmt.Errorf("%s is not a registrable domain", domain)
+	}
+
+	params := url.Values{
+		"ApiUser":     {n.apiUser},
+		"ApiKey":      {n.apiKey},
+		"UserName":    {n.apiUser},
+		"ClientIp":    {n.clientIP},
+		"Command":     {"namecheap.domains.dns.setCustom"},
+		"SLD":         {sld},
+		"TLD":         {suffix},
+		"Nameservers": {strings.Join(nameservers, ",")},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.namecheap.com/xml.response", strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("namecheap request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Status string   `xml:"Status,attr"`
+		Errors []string `xml:"Errors>Error"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode namecheap response: %w", err)
+	}
+	if result.Status != "OK" {
+		return fmt.Errorf("namecheap returned %s: %s", result.Status, strings.Join(result.Errors, "; "))
+	}
+	return nil
+}
+
+func (n *NamecheapRegistrar) SetDelegationSigners(ctx context.Context, domain string, keys []DelegationKey) error {
+	return errRegistrarUnsupported
+}
+
+// Route53DomainsRegistrar calls the Route 53 Domains JSON API directly,
+// signed with the default AWS credential chain.
+type Route53DomainsRegistrar struct {
+	aws    aws.Config
+	client *http.Client
+}
+
+func (r *Route53DomainsRegistrar) Name() string {
+	return "route53domains"
+}
+
+func (r *Route53DomainsRegistrar) SetNameservers(ctx context.Context, domain string, nameservers []string) error {
+	var ns []map[string]string
+	for _, name := range nameservers {
+		ns = append(ns, map[string]string{"Name": name})
+	}
+	return r.call(ctx, "UpdateDomainNameservers", map[string]interface{}{
+		"DomainName":  domain,
+		"Nameservers": ns,
+	})
+}
+
+func (r *Route53DomainsRegistrar) SetDelegationSigners(ctx context.Context, domain string, keys []DelegationKey) error {
+	for _, key := range keys {
+		err := r.call(ctx, "AssociateDelegationSignerToDomain", map[string]interface{}{
+			"DomainName": domain,
+			"SigningAttributes": map[string]interface{}{
+				"Algorithm": key.Algorithm,
+				"Flags":     key.Flags,
+				"PublicKey": key.PublicKey,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Route53DomainsRegistrar) call(ctx context.Context, operation string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://route53domains.us-east-1.amazonaws.com/", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Route53Domains_v20140515."+operation)
+
+	credentials, err := r.aws.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	hash := sha256.Sum256(payload)
+	if err := v4.NewSigner().SignHTTP(ctx, credentials, req, hex.EncodeToString(hash[:]), "route53domains", "us-east-1", time.Now()); err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("route53domains request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("route53domains %s returned %s: %s", operation, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
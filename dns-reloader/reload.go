@@ -0,0 +1,499 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ReloadBackend tells the DNS server to pick up regenerated zone files. zones
+// lists the zones whose files changed in this cycle and may be empty.
+type ReloadBackend interface {
+	Name() string
+	Reload(ctx context.Context, zones []string) error
+}
+
+// pendingReload records a reload that exhausted its retries, so the next tick
+// tries again instead of leaving CoreDNS serving stale zones. Once attempts
+// reaches RELOAD_RETRY_LIMIT the ticks stop retrying; the zones stay
+// pending and go out with the next reload a change triggers.
+type pendingReload struct {
+	zones     []string
+	since     time.Time
+	attempts  int
+	abandoned bool
+}
+
+// reloadCoreDNS signals the backend, retrying with exponential backoff. If
+// every attempt fails the zones are kept as pending and merged into the next
+// reload.
+func (r *Reloader) reloadCoreDNS(ctx context.Context, zones []string) error {
+	return r.reload(ctx, zones, r.config.ReloadMaxAttempts)
+}
+
+// retryPendingReload is called on every listener or polling tick. It makes a
+// single attempt so a backend that stays down never holds up polling for a
+// whole backoff.
+func (r *Reloader) retryPendingReload() {
+	if r.pending == nil || r.pending.abandoned {
+		return
+	}
+	r.logger.WithField("zones", r.pending.zones).Info("Retrying pending CoreDNS reload")
+	r.reload(r.ctx, nil, 1)
+}
+
+func (r *Reloader) reload(ctx context.Context, zones []string, attempts int) (err error) {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if r.pending != nil {
+		zones = mergeZones(r.pending.zones, zones)
+	}
+
+	ctx, span := tracer.Start(ctx, "dns.reload", trace.WithAttributes(
+		attribute.String("dns.reload.backend", r.backend.Name()),
+		attribute.StringSlice("dns.zones", zones),
+	))
+	defer func() { endSpan(span, err) }()
+
+	r.stats.reloads.Add(1)
+	r.publishActivity(ActivityEvent{Type: activityReloadStarted, Zones: zones})
+	err = r.reloadWithBackoff(ctx, zones, attempts)
+	reloaded := ActivityEvent{Type: activityReload, Zones: zones}
+	if err != nil {
+		reloaded.Error = err.Error()
+	}
+	r.publishActivity(reloaded)
+	defer func() { r.status.recordReload(zones, r.pending, err) }()
+	if err == nil {
+		if r.pending != nil {
+			r.logger.WithField("pending_since", r.pending.since).Info("Pending CoreDNS reload completed")
+			if r.pending.abandoned {
+				r.alertRecovered("reload-abandoned", r.backend.Name())
+			}
+		}
+		r.pending = nil
+		r.alertRecovered("reload", r.backend.Name())
+		lastSuccessfulReload.SetToCurrentTime()
+		r.logger.WithField("backend", r.backend.Name()).Info("CoreDNS reload signal sent successfully")
+		return nil
+	}
+
+	r.stats.reloadFailures.Add(1)
+	if r.pending == nil {
+		r.pending = &pendingReload{since: time.Now()}
+	}
+	r.pending.zones = zones
+	r.pending.attempts += attempts
+
+	r.logger.WithError(err).WithFields(logrus.Fields{
+		"backend":       r.backend.Name(),
+		"zones":         zones,
+		"pending_since": r.pending.since,
+		"attempts":      r.pending.attempts,
+	}).Error("CoreDNS reload failed, will retry on next tick")
+	r.alertFailure("reload", r.backend.Name(), err)
+
+	if max := r.config.ReloadRetryLimit; max > 0 && r.pending.attempts >= max && !r.pending.abandoned {
+		r.pending.abandoned = true
+		reloadsAbandoned.WithLabelValues(r.backend.Name()).Inc()
+		r.logger.WithFields(logrus.Fields{
+			"backend":       r.backend.Name(),
+			"zones":         zones,
+			"pending_since": r.pending.since,
+			"attempts":      r.pending.attempts,
+		}).Error("Giving up on retrying the pending CoreDNS reload until the next change")
+		r.raiseAlert("reload-abandoned", r.backend.Name(), fmt.Errorf("gave up after %d attempts: %w", r.pending.attempts, err), 1)
+	}
+	return err
+}
+
+func (r *Reloader) reloadWithBackoff(ctx context.Context, zones []string, attempts int) error {
+	backoff := r.config.ReloadBackoff
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		reloadAttempts.WithLabelValues(r.backend.Name()).Inc()
+		if err = r.backend.Reload(ctx, zones); err == nil {
+			return nil
+		}
+		reloadFailures.WithLabelValues(r.backend.Name()).Inc()
+		if attempt == attempts {
+			break
+		}
+
+		r.logger.WithError(err).WithFields(logrus.Fields{
+			"backend": r.backend.Name(),
+			"attempt": attempt,
+			"backoff": backoff,
+		}).Warn("CoreDNS reload attempt failed")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > r.config.ReloadBackoffMax {
+			backoff = r.config.ReloadBackoffMax
+		}
+	}
+	return fmt.Errorf("reload failed after %d attempts: %w", attempts, err)
+}
+
+func mergeZones(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var merged []string
+	for _, zone := range append(append([]string{}, a...), b...) {
+		if !seen[zone] {
+			seen[zone] = true
+			merged = append(merged, zone)
+		}
+	}
+	return merged
+}
+
+// NewReloadBackend builds the RELOAD_BACKEND. zoneFile gives the path a
+// zone is written to, which depends on its tenant and views.
+func NewReloadBackend(config *Config, logger *logrus.Logger, zoneFile func(zone string) string) (ReloadBackend, error) {
+	backend := config.ReloadBackend
+	if backend == "" {
+		backend = "docker"
+		if config.ReloadCommand != "" {
+			backend = "command"
+		}
+	}
+
+	switch backend {
+	case "docker":
+		return &DockerBackend{
+			containers: parseList(config.CoreDNSContainer),
+			label:      config.CoreDNSLabel,
+			tracker:    newTargetTracker(),
+		}, nil
+	case "podman":
+		return NewPodmanBackend(parseList(config.CoreDNSContainer), config.CoreDNSLabel, config.PodmanSocket), nil
+	case "rndc", "nsd", "knot":
+		return NewZoneControlBackend(backend, strings.Fields(config.ControlArgs)), nil
+	case "systemd":
+		return NewSystemdBackend(config.SystemdUnit, config.SystemdReloadMode)
+	case "ssh":
+		return NewSSHBackend(config)
+	case "kubernetes":
+		return NewKubernetesBackend(config, logger)
+	case "command":
+		if config.ReloadCommand == "" {
+			return nil, fmt.Errorf("RELOAD_CMD is required for the command backend")
+		}
+		return NewCommandBackend(config.ReloadCommand, config.ZonesDirectory, zoneFile)
+	case "signal-local-pid":
+		if config.ReloadPID == 0 && config.ReloadPIDFile == "" {
+			return nil, fmt.Errorf("RELOAD_PID or RELOAD_PID_FILE is required for the signal-local-pid backend")
+		}
+		signal, err := parseSignal(config.ReloadSignal)
+		if err != nil {
+			return nil, err
+		}
+		return &PIDSignalBackend{pid: config.ReloadPID, pidFile: config.ReloadPIDFile, signal: signal}, nil
+	case "local-process":
+		signal, err := parseSignal(config.ReloadSignal)
+		if err != nil {
+			return nil, err
+		}
+		return NewLocalProcessBackend(config.ReloadProcessName, signal), nil
+	case "noop":
+		return NoopBackend{}, nil
+	default:
+		return nil, fmt.Errorf("unknown reload backend %q", backend)
+	}
+}
+
+// DockerBackend sends SIGUSR1 to PID 1 of each CoreDNS container through the
+// docker CLI. Containers are either listed by name or matched by label.
+type DockerBackend struct {
+	containers []string
+	label      string
+	tracker    *targetTracker
+}
+
+func (d *DockerBackend) Name() string {
+	return "docker"
+}
+
+func (d *DockerBackend) Reload(ctx context.Context, zones []string) error {
+	containers := d.containers
+	if d.label != "" {
+		cmd := exec.CommandContext(ctx, "docker", "ps", "--filter", "label="+d.label, "--format", "{{.Names}}")
+		output, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("failed to list containers with label %s: %w", d.label, err)
+		}
+		containers = strings.Fields(string(output))
+	}
+
+	return d.tracker.reloadAll(ctx, containers, func(ctx context.Context, container string) error {
+		cmd := exec.CommandContext(ctx, "docker", "exec", container, "sh", "-c", "kill -USR1 1")
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("docker exec failed: %w (output: %s)", err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	})
+}
+
+func (d *DockerBackend) Targets() []TargetResult {
+	return d.tracker.Targets()
+}
+
+// CommandBackend runs an operator-supplied shell command rendered from a
+// text/template. Templates that reference {{.Zone}} or {{.ZoneFile}} run
+// once per changed zone; all others run once per cycle with {{.Zones}}
+// holding the changed set. {{.ZoneFile}} is where the zone was written, in
+// its tenant's directory and, for a split-horizon zone, its first view's.
+// Every value the template prints is shell-quoted, so a zone name cannot
+// inject commands.
+type CommandBackend struct {
+	tmpl           *template.Template
+	perZone        bool
+	zonesDirectory string
+	zoneFile       func(zone string) string
+}
+
+// CommandData is the data passed to the reload command template.
+type CommandData struct {
+	Zone           string
+	ZoneFile       string
+	Zones          []string
+	ZonesDirectory string
+}
+
+func NewCommandBackend(command, zonesDirectory string, zoneFile func(zone string) string) (*CommandBackend, error) {
+	tmpl, err := template.New("reload").
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"shellquote": shellQuote}).
+		Parse(command)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reload command template: %w", err)
+	}
+
+	// Templates defined inside the command are walked too, as they may be
+	// invoked from it.
+	perZone := false
+	for _, defined := range tmpl.Templates() {
+		if defined.Tree == nil {
+			continue
+		}
+		walkTemplate(defined.Tree.Root, func(node parse.Node) {
+			switch node := node.(type) {
+			case *parse.ActionNode:
+				quoteAction(defined.Tree, node)
+			case *parse.FieldNode:
+				perZone = perZone || zoneField(node.Ident)
+			case *parse.VariableNode:
+				perZone = perZone || (len(node.Ident) > 1 && zoneField(node.Ident[1:]))
+			}
+		})
+	}
+
+	return &CommandBackend{
+		tmpl:           tmpl,
+		perZone:        perZone,
+		zonesDirectory: zonesDirectory,
+		zoneFile:       zoneFile,
+	}, nil
+}
+
+// walkTemplate calls visit on node and every node below it.
+func walkTemplate(node parse.Node, visit func(parse.Node)) {
+	if node == nil {
+		return
+	}
+	visit(node)
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, child := range node.Nodes {
+			walkTemplate(child, visit)
+		}
+	case *parse.ActionNode:
+		walkTemplate(node.Pipe, visit)
+	case *parse.PipeNode:
+		if node == nil {
+			return
+		}
+		for _, cmd := range node.Cmds {
+			walkTemplate(cmd, visit)
+		}
+	case *parse.CommandNode:
+		for _, arg := range node.Args {
+			walkTemplate(arg, visit)
+		}
+	case *parse.IfNode:
+		walkBranch(&node.BranchNode, visit)
+	case *parse.RangeNode:
+		walkBranch(&node.BranchNode, visit)
+	case *parse.WithNode:
+		walkBranch(&node.BranchNode, visit)
+	}
+}
+
+func walkBranch(node *parse.BranchNode, visit func(parse.Node)) {
+	walkTemplate(node.Pipe, visit)
+	walkTemplate(node.List, visit)
+	walkTemplate(node.ElseList, visit)
+}
+
+// zoneField reports whether a field chain starts at .Zone or .ZoneFile,
+// which only per-zone runs have.
+func zoneField(ident []string) bool {
+	return len(ident) > 0 && (ident[0] == "Zone" || ident[0] == "ZoneFile")
+}
+
+// quoteAction pipes what action prints through shellquote, unless it
+// assigns a variable, which prints nothing, or already ends in shellquote.
+func quoteAction(tree *parse.Tree, action *parse.ActionNode) {
+	pipe := action.Pipe
+	if len(pipe.Decl) > 0 || len(pipe.Cmds) == 0 {
+		return
+	}
+	last := pipe.Cmds[len(pipe.Cmds)-1]
+	if ident, ok := last.Args[0].(*parse.IdentifierNode); ok && ident.Ident == "shellquote" {
+		return
+	}
+	pipe.Cmds = append(pipe.Cmds, &parse.CommandNode{
+		NodeType: parse.NodeCommand,
+		Pos:      last.Pos,
+		Args:     []parse.Node{parse.NewIdentifier("shellquote").SetTree(tree).SetPos(last.Pos)},
+	})
+}
+
+// shellQuote renders value as one sh word, or a list of strings as one
+// word each. Words of only safe characters are left bare so commands read
+// as written; anything else is single-quoted.
+func shellQuote(value interface{}) string {
+	if list, ok := value.([]string); ok {
+		words := make([]string, len(list))
+		for i, item := range list {
+			words[i] = shellQuote(item)
+		}
+		return strings.Join(words, " ")
+	}
+	word := fmt.Sprint(value)
+	if word != "" && strings.Trim(word, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-.,/:@%+=") == "" {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+func (c *CommandBackend) Name() string {
+	return "command"
+}
+
+func (c *CommandBackend) Reload(ctx context.Context, zones []string) error {
+	if !c.perZone {
+		return c.run(ctx, CommandData{Zones: zones, ZonesDirectory: c.zonesDirectory})
+	}
+
+	var failed []string
+	for _, zone := range zones {
+		data := CommandData{
+			Zone:           zone,
+			ZoneFile:       c.zoneFile(zone),
+			Zones:          zones,
+			ZonesDirectory: c.zonesDirectory,
+		}
+		if err := c.run(ctx, data); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", zone, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("reload command failed for %d zone(s): %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (c *CommandBackend) run(ctx context.Context, data CommandData) error {
+	var command strings.Builder
+	if err := c.tmpl.Execute(&command, data); err != nil {
+		return fmt.Errorf("failed to render reload command: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command.String())
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("reload command failed: %w (output: %s)", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// PIDSignalBackend sends RELOAD_SIGNAL to a process on the same host,
+// either by a fixed PID or one read from a pid file at reload time.
+type PIDSignalBackend struct {
+	pid     int
+	pidFile string
+	signal  syscall.Signal
+}
+
+func (p *PIDSignalBackend) Name() string {
+	return "signal-local-pid"
+}
+
+func (p *PIDSignalBackend) Reload(ctx context.Context, zones []string) error {
+	pid := p.pid
+	if p.pidFile != "" {
+		data, err := os.ReadFile(p.pidFile)
+		if err != nil {
+			return fmt.Errorf("failed to read pid file: %w", err)
+		}
+		pid, err = strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return fmt.Errorf("invalid pid in %s: %w", p.pidFile, err)
+		}
+	}
+
+	if err := syscall.Kill(pid, p.signal); err != nil {
+		return fmt.Errorf("failed to signal pid %d: %w", pid, err)
+	}
+	return nil
+}
+
+// reloadSignals are the signals RELOAD_SIGNAL may name: SIGUSR1 for
+// CoreDNS, SIGHUP for dnsmasq, unbound and most other daemons.
+var reloadSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+func parseSignal(name string) (syscall.Signal, error) {
+	signal, ok := reloadSignals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return 0, fmt.Errorf("unsupported RELOAD_SIGNAL %q", name)
+	}
+	return signal, nil
+}
+
+// NoopBackend does nothing and relies on the CoreDNS auto plugin's own reload
+// interval.
+type NoopBackend struct{}
+
+func (NoopBackend) Name() string {
+	return "noop"
+}
+
+func (NoopBackend) Reload(ctx context.Context, zones []string) error {
+	return nil
+}
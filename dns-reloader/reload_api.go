@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// manualReloadTimeout bounds how long a waiting POST /api/v1/reload blocks
+// for the main loop to finish regenerating and reloading.
+const manualReloadTimeout = 2 * time.Minute
+
+// forcedZones lists the zones a forced reload covers: the named zone, or
+// every zone in the database when name is empty.
+func (r *Reloader) forcedZones(ctx context.Context, name string) ([]string, error) {
+	if name != "" {
+		return []string{name}, nil
+	}
+	var names []string
+	if err := r.db.WithContext(ctx).Model(&Domain{}).Order("name").Pluck("name", &names).Error; err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// handleReload forces a regeneration and CoreDNS reload, optionally scoped
+// to ?domain=. The request waits for the outcome unless ?wait=false, in
+// which case it returns 202 as soon as the reload is queued.
+func (r *Reloader) handleReload(w http.ResponseWriter, req *http.Request) {
+	wait := true
+	if v := req.URL.Query().Get("wait"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid wait parameter")
+			return
+		}
+		wait = parsed
+	}
+
+	change := &DNSChangeNotification{
+		Table:     "api",
+		Action:    "MANUAL_RELOAD",
+		Timestamp: time.Now(),
+		force:     true,
+		done:      make(chan ChangeSet, 1),
+	}
+	if ref := req.URL.Query().Get("domain"); ref != "" {
+		domain, err := r.findDomain(req.Context(), ref)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		change.DomainID = int(domain.ID)
+		change.Name = domain.Name
+	}
+
+	r.logger.WithField("domain", change.Name).Info("Manual reload requested")
+
+	// Unlike record writes this is queued in listener mode too, since no
+	// database trigger will ever deliver it.
+	select {
+	case r.localChanges <- change:
+	case <-req.Context().Done():
+		return
+	case <-time.After(5 * time.Second):
+		writeError(w, http.StatusServiceUnavailable, "reload queue is full")
+		return
+	}
+
+	if !wait {
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{
+			"status": "queued",
+			"domain": change.Name,
+		})
+		return
+	}
+
+	select {
+	case cs := <-change.done:
+		status := http.StatusOK
+		if cs.Error != "" {
+			status = http.StatusInternalServerError
+		}
+		writeJSON(w, status, cs)
+	case <-req.Context().Done():
+	case <-time.After(manualReloadTimeout):
+		writeError(w, http.StatusGatewayTimeout, "timed out waiting for reload")
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommandBackendTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		perZone bool
+		want    []string
+	}{
+		{
+			name:    "once for all zones",
+			command: `echo {{range .Zones}}{{.}} {{end}}>> {{.ZonesDirectory}}/out`,
+			want:    []string{"example.com example.org"},
+		},
+		{
+			name:    "once per zone",
+			command: `echo {{.Zone}} >> {{.ZonesDirectory}}/out`,
+			perZone: true,
+			want:    []string{"example.com", "example.org"},
+		},
+		{
+			name:    "zone with spaces in the action",
+			command: `echo {{ .Zone }} >> {{.ZonesDirectory}}/out`,
+			perZone: true,
+			want:    []string{"example.com", "example.org"},
+		},
+		{
+			name:    "zone through the root variable",
+			command: `echo {{$.Zone}} >> {{.ZonesDirectory}}/out`,
+			perZone: true,
+			want:    []string{"example.com", "example.org"},
+		},
+		{
+			name:    "zones as a list",
+			command: `echo {{.Zones}} >> {{.ZonesDirectory}}/out`,
+			want:    []string{"example.com example.org"},
+		},
+		{
+			name:    "zone file per zone",
+			command: `echo {{.ZoneFile}} >> {{.ZonesDirectory}}/out`,
+			perZone: true,
+			want:    []string{"db.example.com", "db.example.org"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			backend, err := NewCommandBackend(tt.command, dir, func(zone string) string {
+				return filepath.Join(dir, "db."+zone)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if backend.perZone != tt.perZone {
+				t.Fatalf("perZone = %t, want %t", backend.perZone, tt.perZone)
+			}
+			if err := backend.Reload(t.Context(), []string{"example.com", "example.org"}); err != nil {
+				t.Fatal(err)
+			}
+
+			output, err := os.ReadFile(filepath.Join(dir, "out"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+			if len(lines) != len(tt.want) {
+				t.Fatalf("ran %d times, want %d:\n%s", len(lines), len(tt.want), output)
+			}
+			for i, line := range lines {
+				if want := tt.want[i]; strings.TrimSpace(strings.TrimPrefix(line, dir+"/")) != want {
+					t.Errorf("run %d printed %q, want %q", i, line, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCommandBackendQuoting(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		zone    string
+		want    string
+	}{
+		{
+			name:    "command separator",
+			command: `echo {{.Zone}} > {{.ZonesDirectory}}/out`,
+			zone:    "example.com; touch {{dir}}/pwned",
+			want:    "example.com; touch {{dir}}/pwned",
+		},
+		{
+			name:    "command substitution",
+			command: `echo {{.Zone}} > {{.ZonesDirectory}}/out`,
+			zone:    "$(touch {{dir}}/pwned)",
+			want:    "$(touch {{dir}}/pwned)",
+		},
+		{
+			name:    "single quote",
+			command: `echo {{.Zone}} > {{.ZonesDirectory}}/out`,
+			zone:    "it's'; touch {{dir}}/pwned; '",
+			want:    "it's'; touch {{dir}}/pwned; '",
+		},
+		{
+			name:    "explicit shellquote",
+			command: `echo {{.Zone | shellquote}} > {{.ZonesDirectory}}/out`,
+			zone:    "a b",
+			want:    "a b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			backend, err := NewCommandBackend(tt.command, dir, func(zone string) string {
+				return filepath.Join(dir, "db."+zone)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			zone := strings.ReplaceAll(tt.zone, "{{dir}}", dir)
+			if err := backend.Reload(t.Context(), []string{zone}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "pwned")); err == nil {
+				t.Fatal("the zone name ran a command")
+			}
+			output, err := os.ReadFile(filepath.Join(dir, "out"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := strings.ReplaceAll(tt.want, "{{dir}}", dir); strings.TrimSpace(string(output)) != want {
+				t.Fatalf("printed %q, want %q", output, want)
+			}
+		})
+	}
+}
+
+func TestCommandBackendFailure(t *testing.T) {
+	backend, err := NewCommandBackend(`test {{.Zone}} != example.org`, t.TempDir(), func(zone string) string {
+		return "db." + zone
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = backend.Reload(t.Context(), []string{"example.com", "example.org"})
+	if err == nil || !strings.Contains(err.Error(), "failed for 1 zone(s): example.org") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestCommandBackendZoneFilePaths(t *testing.T) {
+	r := newTestReloader(t, map[string]string{
+		"RELOAD_BACKEND":     "command",
+		"RELOAD_CMD":         `echo {{.Zone}} {{.ZoneFile}} >> {{.ZonesDirectory}}/out`,
+		"VIEWS":              "internal,external",
+		"TENANT_DIRECTORIES": "alpha=tenant-alpha",
+	})
+	ctx := t.Context()
+	alpha := "alpha"
+	if _, _, err := r.createDomain(ctx, domainCreateRequest{Name: "alpha.example", Type: "NATIVE", Account: &alpha}); err != nil {
+		t.Fatal(err)
+	}
+	createTestZone(t, r, "example.com")
+	if _, err := r.regenerateAllZones(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.backend.Reload(ctx, []string{"alpha.example", "example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := os.ReadFile(filepath.Join(r.config.ZonesDirectory, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		zone, file, _ := strings.Cut(line, " ")
+		files[zone] = file
+	}
+	tests := []struct {
+		zone string
+		want string
+	}{
+		{"alpha.example", "tenant-alpha/internal/db.alpha.example"},
+		{"example.com", "internal/db.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.zone, func(t *testing.T) {
+			want := filepath.Join(r.config.ZonesDirectory, tt.want)
+			if files[tt.zone] != want {
+				t.Fatalf("ZoneFile = %q, want %q", files[tt.zone], want)
+			}
+			if _, err := os.Stat(want); err != nil {
+				t.Fatalf("the zone was not written there: %v", err)
+			}
+		})
+	}
+}
+
+func TestPendingReloadRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		limit         string
+		ticks         int
+		wantCalls     int
+		wantAbandoned bool
+	}{
+		{
+			name:      "one attempt per tick",
+			limit:     "0",
+			ticks:     3,
+			wantCalls: 2 + 3,
+		},
+		{
+			name:          "gives up at the limit",
+			limit:         "4",
+			ticks:         5,
+			wantCalls:     4,
+			wantAbandoned: true,
+		},
+		{
+			name:          "limit reached by the first reload",
+			limit:         "2",
+			ticks:         3,
+			wantCalls:     2,
+			wantAbandoned: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReloader(t, map[string]string{
+				"RELOAD_BACKEND":      "command",
+				"RELOAD_CMD":          `echo >> {{.ZonesDirectory}}/calls; false`,
+				"RELOAD_MAX_ATTEMPTS": "2",
+				"RELOAD_BACKOFF":      "1ms",
+				"RELOAD_RETRY_LIMIT":  tt.limit,
+			})
+			if err := r.reloadCoreDNS(t.Context(), []string{"example.com"}); err == nil {
+				t.Fatal("the failing command reloaded")
+			}
+			for range tt.ticks {
+				r.retryPendingReload()
+			}
+
+			output, err := os.ReadFile(filepath.Join(r.config.ZonesDirectory, "calls"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if calls := strings.Count(string(output), "\n"); calls != tt.wantCalls {
+				t.Fatalf("backend called %d times, want %d", calls, tt.wantCalls)
+			}
+			if r.pending == nil {
+				t.Fatal("no pending reload")
+			}
+			if r.pending.abandoned != tt.wantAbandoned {
+				t.Fatalf("abandoned = %t, want %t", r.pending.abandoned, tt.wantAbandoned)
+			}
+			if r.pending.attempts != tt.wantCalls {
+				t.Fatalf("attempts = %d, want %d", r.pending.attempts, tt.wantCalls)
+			}
+
+			// The next change still carries the pending zones out.
+			r.backend = NoopBackend{}
+			if err := r.reloadCoreDNS(t.Context(), []string{"example.org"}); err != nil {
+				t.Fatal(err)
+			}
+			if r.pending != nil {
+				t.Fatal("the reload stayed pending after succeeding")
+			}
+		})
+	}
+}
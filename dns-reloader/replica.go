@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// connectReplica opens DATABASE_REPLICA_URL, a streaming replica of the
+// primary used for the SELECTs of zone generation. A replica that cannot be
+// reached is logged and skipped; generation then reads from the primary.
+func (r *Reloader) connectReplica() {
+	if r.config.ReplicaURL == "" || r.replica != nil {
+		return
+	}
+	logger := r.logger.WithField("component", "replica")
+	pg, ok := r.storage.(*PostgresStorage)
+	if !ok {
+		logger.Warnf("DATABASE_REPLICA_URL is only supported with the postgres driver, ignoring it")
+		return
+	}
+
+	u, err := parsePostgresURL("DATABASE_REPLICA_URL", r.config.ReplicaURL)
+	if err != nil {
+		logger.WithError(err).Error("Invalid replica configuration, reading from the primary")
+		return
+	}
+	db, err := gorm.Open(pg.urlDialector(u), &gorm.Config{Logger: r.db.Logger})
+	if err != nil {
+		logger.WithError(err).Error("Failed to connect to replica, reading from the primary")
+		return
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		logger.WithError(err).Error("Failed to get underlying sql.DB for replica")
+		return
+	}
+	configurePool(sqlDB, r.config, r.storage)
+	if err := registerDBMetrics(db); err != nil {
+		logger.WithError(err).Warn("Failed to register replica database metrics")
+	}
+
+	r.replica = db
+	logger.WithField("host", u.Hostname()).Info("Connected to read replica")
+}
+
+// generationDB returns the handle zone generation reads from: the replica
+// once it has replayed everything the primary has written so far, or the
+// primary if the replica is missing, failing or still behind after
+// REPLICA_MAX_LAG.
+func (r *Reloader) generationDB(ctx context.Context) *gorm.DB {
+	if r.replica == nil {
+		return r.db
+	}
+	logger := r.logger.WithField("component", "replica")
+
+	var lsn string
+	if err := r.db.WithContext(ctx).Raw("SELECT pg_current_wal_lsn()::text").Scan(&lsn).Error; err != nil {
+		logger.WithError(err).Warn("Failed to read primary WAL position, reading from the primary")
+		replicaFallbacks.WithLabelValues("error").Inc()
+		return r.db
+	}
+
+	deadline := time.Now().Add(r.config.ReplicaMaxLag)
+	for {
+		caughtUp, err := r.replicaCaughtUp(ctx, lsn)
+		if err != nil {
+			logger.WithError(err).Warn("Replica check failed, reading from the primary")
+			replicaFallbacks.WithLabelValues("error").Inc()
+			return r.db
+		}
+		if caughtUp {
+			return r.replica
+		}
+		if time.Now().After(deadline) {
+			logger.WithField("lsn", lsn).Warn("Replica is lagging, reading from the primary")
+			replicaFallbacks.WithLabelValues("lag").Inc()
+			return r.db
+		}
+		select {
+		case <-ctx.Done():
+			return r.db
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// replicaCaughtUp reports whether the replica has replayed WAL up to lsn.
+func (r *Reloader) replicaCaughtUp(ctx context.Context, lsn string) (bool, error) {
+	var caughtUp *bool
+	if err := r.replica.WithContext(ctx).Raw("SELECT pg_last_wal_replay_lsn() >= ?::pg_lsn", lsn).Scan(&caughtUp).Error; err != nil {
+		return false, err
+	}
+	if caughtUp == nil {
+		return false, fmt.Errorf("replica is not in recovery, so its lag cannot be checked")
+	}
+	return *caughtUp, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// rfc2136Skipped are the types a downstream server manages itself and that
+// are never updated: its own DNSSEC records, should it sign the zone.
+var rfc2136Skipped = map[string]bool{
+	"RRSIG": true, "NSEC": true, "NSEC3": true, "NSEC3PARAM": true, "DNSKEY": true, "CDS": true, "CDNSKEY": true,
+}
+
+// RFC2136Provider pushes zones to downstream BIND, Knot or other servers
+// with RFC 2136 dynamic updates, so they follow changes incrementally
+// without zone files being copied to them. Each sync reads the server's
+// copy over AXFR and sends one UPDATE with the difference, signed with the
+// RFC2136_TSIG key when one is set. With RFC2136_ZONES set only those
+// zones are pushed; every server must be primary for each zone pushed.
+type RFC2136Provider struct {
+	servers []string
+	zones   []string
+	key     *tsigKey
+	client  *dns.Client
+}
+
+func NewRFC2136Provider(config *Config) (*RFC2136Provider, error) {
+	if len(config.RFC2136Servers) == 0 {
+		return nil, fmt.Errorf("RFC2136_SERVERS is required for the rfc2136 provider")
+	}
+	p := &RFC2136Provider{
+		zones:  config.RFC2136Zones,
+		client: &dns.Client{Net: "tcp", Timeout: 30 * time.Second},
+	}
+	for _, server := range config.RFC2136Servers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		p.servers = append(p.servers, server)
+	}
+	if config.RFC2136TSIG != "" {
+		key, err := parseTSIG(config.RFC2136TSIG)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RFC2136_TSIG: %w", err)
+		}
+		p.key = key
+		p.client.TsigSecret = map[string]string{key.Name: key.Secret}
+	}
+	return p, nil
+}
+
+func (p *RFC2136Provider) Name() string {
+	return "rfc2136"
+}
+
+// Sync updates every server, carrying on past a failed one so the others
+// still get the change. The result adds up the RRsets changed on each.
+func (p *RFC2136Provider) Sync(ctx context.Context, zone providerZone) (providerSyncResult, error) {
+	var result providerSyncResult
+	if len(p.zones) > 0 && !slices.Contains(p.zones, zone.Name) {
+		return result, errZoneNotHosted
+	}
+	var errs []error
+	for _, server := range p.servers {
+		synced, err := p.syncServer(ctx, server, zone)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", server, err))
+			continue
+		}
+		result.Upserted += synced.Upserted
+		result.Deleted += synced.Deleted
+	}
+	return result, errors.Join(errs...)
+}
+
+func (p *RFC2136Provider) syncServer(ctx context.Context, server string, zone providerZone) (providerSyncResult, error) {
+	var result providerSyncResult
+	rrs, err := transferZone(zone.Name, server, p.key)
+	if err != nil {
+		return result, fmt.Errorf("failed to read current zone: %w", err)
+	}
+
+	apex := dns.Fqdn(zone.Name)
+	existing := make(map[string]*providerRRset)
+	for _, rr := range rrs {
+		header := rr.Header()
+		set := providerRRset{Name: strings.ToLower(header.Name), Type: dns.TypeToString[header.Rrtype]}
+		if set.Type == "SOA" || rfc2136Skipped[set.Type] || (set.Type == "NS" && set.Name == apex) {
+			continue
+		}
+		current, ok := existing[set.key()]
+		if !ok {
+			set.TTL = header.Ttl
+			existing[set.key()] = &set
+			current = &set
+		}
+		current.TTL = min(current.TTL, header.Ttl)
+		current.Values = append(current.Values, rdataString(rr))
+	}
+
+	update := new(dns.Msg)
+	update.SetUpdate(apex)
+	for _, set := range zone.RRsets {
+		if rfc2136Skipped[set.Type] {
+			continue
+		}
+		current, ok := existing[set.key()]
+		delete(existing, set.key())
+		if ok {
+			slices.Sort(current.Values)
+			if current.equal(set) {
+				continue
+			}
+			update.RemoveRRset([]dns.RR{rrsetHeader(set)})
+		}
+		records := make([]dns.RR, 0, len(set.Values))
+		for _, value := range set.Values {
+			rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", set.Name, set.TTL, set.Type, value))
+			if err != nil {
+				return result, fmt.Errorf("failed to parse %s %s %s: %w", set.Name, set.Type, value, err)
+			}
+			records = append(records, rr)
+		}
+		update.Insert(records)
+		result.Upserted++
+	}
+	for _, set := range existing {
+		update.RemoveRRset([]dns.RR{rrsetHeader(*set)})
+		result.Deleted++
+	}
+	if len(update.Ns) == 0 {
+		return result, nil
+	}
+
+	if p.key != nil {
+		update.SetTsig(p.key.Name, p.key.Algorithm, 300, time.Now().Unix())
+	}
+	resp, _, err := p.client.ExchangeContext(ctx, update, server)
+	if err != nil {
+		return result, fmt.Errorf("update failed: %w", err)
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return result, fmt.Errorf("update refused: %s", dns.RcodeToString[resp.Rcode])
+	}
+	return result, nil
+}
+
+// rrsetHeader returns a record naming set's owner and type, for deleting
+// the whole RRset.
+func rrsetHeader(set providerRRset) dns.RR {
+	return &dns.ANY{Hdr: dns.RR_Header{Name: set.Name, Rrtype: dns.StringToType[set.Type], Class: dns.ClassINET}}
+}
@@ -0,0 +1,571 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// rolloverCheckInterval is how often the reloader looks for rollovers due
+// to move on. Their timers are hours long; this only bounds the delay.
+const rolloverCheckInterval = 5 * time.Minute
+
+// Rollover phases. A ZSK rollover pre-publishes (RFC 7583 section 3.2.1):
+// the new key is published, then signs while the old one stays published,
+// then the old one goes. A KSK rollover uses double-DS (section 3.3.2):
+// the new key's DS goes to the parent first, confirmed by the operator,
+// then the keys are swapped, then the old DS can go.
+const (
+	rolloverIdle         = "idle"
+	rolloverRequested    = "requested"
+	rolloverZSKPublished = "zsk-published"
+	rolloverZSKActive    = "zsk-active"
+	rolloverDSPending    = "ds-pending"
+	rolloverDSWait       = "ds-wait"
+	rolloverKSKActive    = "ksk-active"
+)
+
+// DNSSECRollover is a row of dnssec_rollovers: the rollover state of one
+// kind of key, ZSK or KSK, of a domain. NextAt is when the current phase
+// may end; it is unset while the operator has to act.
+type DNSSECRollover struct {
+	ID        int        `gorm:"primaryKey;column:id" json:"-"`
+	DomainID  int        `gorm:"column:domain_id" json:"-"`
+	Kind      string     `gorm:"column:kind" json:"kind"`
+	Phase     string     `gorm:"column:phase" json:"phase"`
+	OldKeyID  *int       `gorm:"column:old_key_id" json:"old_key_id,omitempty"`
+	NewKeyID  *int       `gorm:"column:new_key_id" json:"new_key_id,omitempty"`
+	NextAt    *time.Time `gorm:"column:next_at" json:"next_at,omitempty"`
+	RolledAt  time.Time  `gorm:"column:rolled_at" json:"rolled_at"`
+	UpdatedAt time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (DNSSECRollover) TableName() string {
+	return "dnssec_rollovers"
+}
+
+// due reports whether the reloader should move the rollover on at now.
+func (ro *DNSSECRollover) due(now time.Time) bool {
+	switch ro.Phase {
+	case rolloverIdle, rolloverDSPending:
+		return false
+	case rolloverRequested:
+		return true
+	}
+	return ro.NextAt != nil && !now.Before(*ro.NextAt)
+}
+
+// markParentDS flags the keys of domains whose DS the parent must hold
+// although they do not sign: a new KSK waiting for its DS, and an old one
+// whose DS may still be cached after the swap.
+func (r *Reloader) markParentDS(ctx context.Context, db *gorm.DB, domains []Domain) error {
+	if !r.rollovers || len(domains) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(domains))
+	for i, domain := range domains {
+		ids[i] = domain.ID
+	}
+	var rows []DNSSECRollover
+	if err := db.WithContext(ctx).Where("domain_id IN ? AND kind = ? AND phase IN ?", ids, "KSK",
+		[]string{rolloverDSPending, rolloverDSWait, rolloverKSKActive}).Find(&rows).Error; err != nil {
+		return fmt.Errorf("failed to read DNSSEC rollovers: %w", err)
+	}
+	parentDS := make(map[int]bool)
+	for _, row := range rows {
+		id := row.NewKeyID
+		if row.Phase == rolloverKSKActive {
+			id = row.OldKeyID
+		}
+		if id != nil {
+			parentDS[*id] = true
+		}
+	}
+	for i := range domains {
+		for j := range domains[i].Keys {
+			domains[i].Keys[j].ParentDS = parentDS[domains[i].Keys[j].ID]
+		}
+	}
+	return nil
+}
+
+// checkRollovers starts scheduled ZSK rollovers and moves every rollover
+// whose timer has run out to its next phase, then regenerates the zones
+// that changed. Called on every listener or polling tick.
+func (r *Reloader) checkRollovers() {
+	if !r.rollovers || !r.cryptokeys || time.Since(r.lastRolloverCheck) < rolloverCheckInterval {
+		return
+	}
+	r.lastRolloverCheck = time.Now()
+
+	advanced, err := r.advanceRollovers(r.ctx, time.Now())
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to check DNSSEC rollovers")
+	}
+	if len(advanced) == 0 {
+		return
+	}
+	change := &DNSChangeNotification{
+		Table:     "dnssec",
+		Action:    "ROLLOVER",
+		Timestamp: time.Now(),
+	}
+	if err := r.triggerCoreReload(r.ctx, change); err != nil {
+		r.logger.WithError(err).Error("Failed to regenerate zones after DNSSEC rollover")
+	}
+	// A KSK rollover changes the DS set without always changing the zone.
+	for _, domain := range advanced {
+		if err := r.refreshDS(r.ctx, domain); err != nil {
+			r.logger.WithError(err).WithField("domain", domain.Name).Warn("Failed to export DS records")
+		}
+	}
+}
+
+// upcomingRollovers lists the rollover phases whose timers run out after
+// now and no later than until. Phases waiting for the operator have none.
+func (r *Reloader) upcomingRollovers(ctx context.Context, now, until time.Time) ([]UpcomingChange, error) {
+	if !r.rollovers {
+		return nil, nil
+	}
+	var rows []DNSSECRollover
+	if err := r.db.WithContext(ctx).Where("next_at > ? AND next_at <= ?", now, until).Order("next_at").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read DNSSEC rollovers: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	ids := make([]int, len(rows))
+	for i, ro := range rows {
+		ids[i] = ro.DomainID
+	}
+	var domains []Domain
+	if err := r.db.WithContext(ctx).Select("id", "name").Where("id IN ?", ids).Find(&domains).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch domains: %w", err)
+	}
+	names := make(map[int]string, len(domains))
+	for _, domain := range domains {
+		names[int(domain.ID)] = domain.Name
+	}
+	upcoming := make([]UpcomingChange, 0, len(rows))
+	for _, ro := range rows {
+		upcoming = append(upcoming, UpcomingChange{
+			Action:      "ROLLOVER",
+			Table:       "dnssec",
+			DomainID:    ro.DomainID,
+			Zone:        names[ro.DomainID],
+			Type:        ro.Kind,
+			Description: fmt.Sprintf("%s rollover leaves the %s phase", ro.Kind, ro.Phase),
+			DueAt:       *ro.NextAt,
+		})
+	}
+	return upcoming, nil
+}
+
+// advanceRollovers does the work of checkRollovers for the signed zones
+// with keys in cryptokeys and returns the domains whose keys changed. A
+// rollover that fails is logged and retried on the next check.
+func (r *Reloader) advanceRollovers(ctx context.Context, now time.Time) ([]Domain, error) {
+	var names []string
+	r.zoneOptsMu.RLock()
+	for name, opts := range r.zoneOpts {
+		if opts.Sign && opts.Signer != signerExternal {
+			names = append(names, name)
+		}
+	}
+	r.zoneOptsMu.RUnlock()
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	var domains []Domain
+	if err := r.db.WithContext(ctx).Where("name IN ?", names).Find(&domains).Error; err != nil {
+		return nil, fmt.Errorf("failed to read domains: %w", err)
+	}
+	if err := r.loadDNSSECKeys(ctx, r.db, domains); err != nil {
+		return nil, err
+	}
+	ids := make([]uint, len(domains))
+	for i, domain := range domains {
+		ids[i] = domain.ID
+	}
+	var rows []DNSSECRollover
+	if err := r.db.WithContext(ctx).Where("domain_id IN ?", ids).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read DNSSEC rollovers: %w", err)
+	}
+	byDomain := make(map[int][]*DNSSECRollover)
+	for i := range rows {
+		byDomain[rows[i].DomainID] = append(byDomain[rows[i].DomainID], &rows[i])
+	}
+
+	var advanced []Domain
+	for i := range domains {
+		domain := &domains[i]
+		if len(domain.Keys) == 0 {
+			continue
+		}
+		rollovers := byDomain[int(domain.ID)]
+
+		if r.config.DNSSECZSKLifetime > 0 && activeKey(*domain, false) != nil {
+			var zsk *DNSSECRollover
+			for _, row := range rollovers {
+				if row.Kind == "ZSK" {
+					zsk = row
+				}
+			}
+			switch {
+			case zsk == nil:
+				// The lifetime of keys that predate tracking counts from now.
+				row := DNSSECRollover{DomainID: int(domain.ID), Kind: "ZSK", Phase: rolloverIdle, RolledAt: now, UpdatedAt: now}
+				if err := r.db.WithContext(ctx).Create(&row).Error; err != nil {
+					return advanced, fmt.Errorf("failed to record DNSSEC rollover: %w", err)
+				}
+			case zsk.Phase == rolloverIdle && now.Sub(zsk.RolledAt) >= r.config.DNSSECZSKLifetime:
+				zsk.Phase = rolloverRequested
+			}
+		}
+
+		changed := false
+		for _, row := range rollovers {
+			if !row.due(now) {
+				continue
+			}
+			if err := r.advanceRollover(ctx, domain, row, now); err != nil {
+				r.logger.WithError(err).WithFields(logrus.Fields{
+					"domain": domain.Name,
+					"kind":   row.Kind,
+					"phase":  row.Phase,
+				}).Error("Failed to advance DNSSEC rollover")
+				continue
+			}
+			changed = true
+		}
+		if changed {
+			advanced = append(advanced, *domain)
+		}
+	}
+	return advanced, nil
+}
+
+// advanceRollover moves one rollover to its next phase. The wait before the
+// phase after is set from the TTLs of the zone file as served, plus
+// DNSSEC_ROLLOVER_MARGIN for secondaries to catch up.
+func (r *Reloader) advanceRollover(ctx context.Context, domain *Domain, ro *DNSSECRollover, now time.Time) error {
+	dnskeyTTL, maxTTL, err := zoneFileTTLs(r.zoneFilePath(domain.Name), domain.Name)
+	if err != nil {
+		return err
+	}
+	wait := func(ttl uint32) *time.Time {
+		next := now.Add(time.Duration(ttl)*time.Second + r.config.DNSSECRolloverMargin)
+		return &next
+	}
+	from := ro.Phase
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		switch ro.Phase {
+		case rolloverRequested:
+			old := activeKey(*domain, ro.Kind == "KSK")
+			if old == nil {
+				return fmt.Errorf("no active %s in cryptokeys", ro.Kind)
+			}
+			if ro.Kind == "KSK" {
+				// The new key neither signs nor is published until its
+				// DS is at the parent.
+				next, err := r.createKey(tx, domain, old.DNSKEY.Algorithm, dns.ZONE|dns.SEP, false, false)
+				if err != nil {
+					return err
+				}
+				ro.Phase, ro.NextAt = rolloverDSPending, nil
+				ro.OldKeyID, ro.NewKeyID = &old.ID, &next.ID
+				break
+			}
+			next, err := r.createKey(tx, domain, old.DNSKEY.Algorithm, dns.ZONE, false, true)
+			if err != nil {
+				return err
+			}
+			ro.Phase, ro.NextAt = rolloverZSKPublished, wait(dnskeyTTL)
+			ro.OldKeyID, ro.NewKeyID = &old.ID, &next.ID
+		case rolloverZSKPublished:
+			if err := setKeyState(tx, ro.NewKeyID, true, true); err != nil {
+				return err
+			}
+			if err := setKeyState(tx, ro.OldKeyID, false, true); err != nil {
+				return err
+			}
+			ro.Phase, ro.NextAt = rolloverZSKActive, wait(maxTTL)
+		case rolloverDSWait:
+			if err := setKeyState(tx, ro.NewKeyID, true, true); err != nil {
+				return err
+			}
+			if err := setKeyState(tx, ro.OldKeyID, false, false); err != nil {
+				return err
+			}
+			ro.Phase, ro.NextAt = rolloverKSKActive, wait(dnskeyTTL)
+		case rolloverZSKActive, rolloverKSKActive:
+			if ro.OldKeyID != nil {
+				if err := tx.Delete(&CryptoKey{}, *ro.OldKeyID).Error; err != nil {
+					return fmt.Errorf("failed to delete retired key: %w", err)
+				}
+			}
+			ro.Phase, ro.NextAt, ro.RolledAt = rolloverIdle, nil, now
+			ro.OldKeyID, ro.NewKeyID = nil, nil
+		default:
+			return fmt.Errorf("unknown rollover phase %q", ro.Phase)
+		}
+		ro.UpdatedAt = now
+		if err := tx.Save(ro).Error; err != nil {
+			return fmt.Errorf("failed to record DNSSEC rollover: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fields := logrus.Fields{"domain": domain.Name, "kind": ro.Kind, "from": from, "phase": ro.Phase}
+	if ro.NextAt != nil {
+		fields["next_at"] = ro.NextAt.UTC().Format(time.RFC3339)
+	}
+	r.logger.WithFields(fields).Info("Advanced DNSSEC rollover")
+	switch {
+	case ro.Phase == rolloverDSPending:
+		r.logger.WithField("domain", domain.Name).Warn("Add the new DS record to the parent zone, then confirm with dnssec rollover confirm")
+	case from == rolloverKSKActive:
+		r.logger.WithField("domain", domain.Name).Warn("KSK rollover finished; remove the old DS record from the parent zone")
+	}
+	return nil
+}
+
+// activeKey returns the first active key signing domain's zone or, with
+// ksk, its DNSKEY RRset; nil when there is none of that kind.
+func activeKey(domain Domain, ksk bool) *dnssecKey {
+	for i, key := range domain.Keys {
+		if key.Active && (key.DNSKEY.Flags&dns.SEP != 0) == ksk {
+			return &domain.Keys[i]
+		}
+	}
+	return nil
+}
+
+func setKeyState(tx *gorm.DB, id *int, active, published bool) error {
+	if id == nil {
+		return errors.New("rollover has no key")
+	}
+	err := tx.Model(&CryptoKey{}).Where("id = ?", *id).
+		Updates(map[string]interface{}{"active": active, "published": published}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update key %d: %w", *id, err)
+	}
+	return nil
+}
+
+// zoneFileTTLs returns the TTL of the DNSKEY RRset in a zone file and the
+// largest TTL of any record in it, which bounds how long its signatures
+// are cached.
+func zoneFileTTLs(path, zone string) (dnskey, max uint32, err error) {
+	rrs, err := parseZoneFile(path, zone)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, rr := range rrs {
+		ttl := rr.Header().Ttl
+		if rr.Header().Rrtype == dns.TypeDNSKEY && ttl > dnskey {
+			dnskey = ttl
+		}
+		if ttl > max {
+			max = ttl
+		}
+	}
+	return dnskey, max, nil
+}
+
+// refreshDS reloads domain's keys and exports its DS records.
+func (r *Reloader) refreshDS(ctx context.Context, domain Domain) error {
+	domains := []Domain{domain}
+	if err := r.loadDNSSECKeys(ctx, r.db, domains); err != nil {
+		return err
+	}
+	domains[0].Options = r.zoneOptions(domain.Name)
+	return r.exportDS(domains[0])
+}
+
+// startRollover asks the reloader to roll domain's ZSK or KSK; it starts
+// on the next rollover check.
+func (r *Reloader) startRollover(ctx context.Context, ref, kind string) (*Domain, *DNSSECRollover, error) {
+	kind = strings.ToUpper(kind)
+	if kind != "ZSK" && kind != "KSK" {
+		return nil, nil, errInvalid(fmt.Sprintf("unknown key kind %q, want ZSK or KSK", kind))
+	}
+	if !r.rollovers || !r.cryptokeys {
+		return nil, nil, errConflict("the database has no dnssec_rollovers table; run migrate")
+	}
+	if _, err := r.keySecret(); err != nil {
+		return nil, nil, errConflict(err.Error())
+	}
+	domain, err := r.findDomain(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	domains := []Domain{*domain}
+	if err := r.loadDNSSECKeys(ctx, r.db, domains); err != nil {
+		return nil, nil, err
+	}
+	if activeKey(domains[0], kind == "KSK") == nil {
+		return nil, nil, errConflict(fmt.Sprintf("%s has no active %s in cryptokeys", domain.Name, kind))
+	}
+
+	now := time.Now()
+	var ro DNSSECRollover
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("domain_id = ? AND kind = ?", domain.ID, kind).First(&ro).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			ro = DNSSECRollover{DomainID: int(domain.ID), Kind: kind, RolledAt: now}
+		case err != nil:
+			return fmt.Errorf("failed to read DNSSEC rollover: %w", err)
+		case ro.Phase != rolloverIdle:
+			return errConflict(fmt.Sprintf("%s %s rollover is already in phase %s", domain.Name, kind, ro.Phase))
+		}
+		ro.Phase, ro.NextAt, ro.UpdatedAt = rolloverRequested, &now, now
+		return tx.Save(&ro).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return domain, &ro, nil
+}
+
+// confirmDS records that the parent publishes the new KSK's DS. The keys
+// are swapped once the parent's DS TTL, DNSSEC_PARENT_DS_TTL, has passed.
+func (r *Reloader) confirmDS(ctx context.Context, ref string) (*Domain, *DNSSECRollover, error) {
+	if !r.rollovers {
+		return nil, nil, errConflict("the database has no dnssec_rollovers table; run migrate")
+	}
+	domain, err := r.findDomain(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	var ro DNSSECRollover
+	err = r.db.WithContext(ctx).Where("domain_id = ? AND kind = ?", domain.ID, "KSK").First(&ro).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, fmt.Errorf("failed to read DNSSEC rollover: %w", err)
+	}
+	if err != nil || ro.Phase != rolloverDSPending {
+		return nil, nil, errConflict(fmt.Sprintf("%s has no KSK rollover waiting for its DS", domain.Name))
+	}
+	now := time.Now()
+	next := now.Add(r.config.DNSSECParentDSTTL + r.config.DNSSECRolloverMargin)
+	ro.Phase, ro.NextAt, ro.UpdatedAt = rolloverDSWait, &next, now
+	if err := r.db.WithContext(ctx).Save(&ro).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to record DNSSEC rollover: %w", err)
+	}
+	return domain, &ro, nil
+}
+
+// listRollovers returns the rollover state of domain's keys.
+func (r *Reloader) listRollovers(ctx context.Context, ref string) (*Domain, []DNSSECRollover, error) {
+	domain, err := r.findDomain(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	rollovers := []DNSSECRollover{}
+	if !r.rollovers {
+		return domain, rollovers, nil
+	}
+	if err := r.db.WithContext(ctx).Where("domain_id = ?", domain.ID).Order("kind").Find(&rollovers).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to read DNSSEC rollovers: %w", err)
+	}
+	return domain, rollovers, nil
+}
+
+// dnssecRollover runs dnssec rollover: start a ZSK or KSK rollover,
+// confirm a KSK's DS is at the parent, or show the state.
+func (r *Reloader) dnssecRollover(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: reloader dnssec rollover <zsk|ksk|confirm|status> <zone>")
+	}
+	if err := r.connectDB(); err != nil {
+		return err
+	}
+
+	var err error
+	switch strings.ToLower(args[0]) {
+	case "zsk", "ksk":
+		_, _, err = r.startRollover(r.ctx, args[1], args[0])
+	case "confirm":
+		_, _, err = r.confirmDS(r.ctx, args[1])
+	case "status":
+	default:
+		return fmt.Errorf("unknown rollover action %q", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	domain, rollovers, err := r.listRollovers(r.ctx, args[1])
+	if err != nil {
+		return err
+	}
+	for _, ro := range rollovers {
+		fmt.Printf("%s %s: %s", domain.Name, ro.Kind, ro.Phase)
+		if ro.NextAt != nil {
+			fmt.Printf(", next phase after %s", ro.NextAt.UTC().Format(time.RFC3339))
+		}
+		fmt.Printf(", last rolled %s\n", ro.RolledAt.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+type rolloverRequest struct {
+	Kind string `json:"kind"`
+}
+
+// handleListRollovers serves GET /api/v1/domains/{id}/dnssec/rollovers.
+func (r *Reloader) handleListRollovers(w http.ResponseWriter, req *http.Request) {
+	domain, rollovers, err := r.listRollovers(req.Context(), req.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"domain":    domain.Name,
+		"rollovers": rollovers,
+	})
+}
+
+// handleStartRollover serves POST /api/v1/domains/{id}/dnssec/rollovers.
+func (r *Reloader) handleStartRollover(w http.ResponseWriter, req *http.Request) {
+	var body rolloverRequest
+	if err := readJSON(req, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	domain, rollover, err := r.startRollover(req.Context(), req.PathValue("id"), body.Kind)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"domain":   domain.Name,
+		"rollover": rollover,
+	})
+}
+
+// handleConfirmDS serves POST /api/v1/domains/{id}/dnssec/rollovers/ksk/confirm.
+func (r *Reloader) handleConfirmDS(w http.ResponseWriter, req *http.Request) {
+	domain, rollover, err := r.confirmDS(req.Context(), req.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"domain":   domain.Name,
+		"rollover": rollover,
+	})
+}
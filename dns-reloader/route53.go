@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+const (
+	route53Endpoint = "https://route53.amazonaws.com/2013-04-01"
+	route53XMLNS    = "https://route53.amazonaws.com/doc/2013-04-01/"
+	// route53BatchSize keeps each ChangeResourceRecordSets call well under
+	// the limit of 1000 records per request.
+	route53BatchSize = 100
+)
+
+// route53Types are the record types Route53 accepts; others stay local.
+var route53Types = map[string]bool{
+	"A": true, "AAAA": true, "CAA": true, "CNAME": true, "DS": true, "HTTPS": true, "MX": true,
+	"NAPTR": true, "NS": true, "PTR": true, "SPF": true, "SRV": true, "SSHFP": true, "SVCB": true,
+	"TLSA": true, "TXT": true,
+}
+
+// Route53Provider mirrors zones into Route53 public hosted zones of the same
+// name, calling the Route53 REST API directly, signed with the default AWS
+// credential chain. With ROUTE53_ZONES set only those zones are synced.
+// Alias and routing-policy record sets are managed in Route53 and left
+// alone.
+type Route53Provider struct {
+	aws    aws.Config
+	client *http.Client
+	zones  []string
+}
+
+func NewRoute53Provider(config *Config) (*Route53Provider, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), awsconfig.WithRegion("us-east-1"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return &Route53Provider{
+		aws:    awsCfg,
+		client: &http.Client{Timeout: 30 * time.Second},
+		zones:  config.Route53Zones,
+	}, nil
+}
+
+func (p *Route53Provider) Name() string {
+	return "route53"
+}
+
+type route53HostedZone struct {
+	ID     string `xml:"Id"`
+	Name   string `xml:"Name"`
+	Config struct {
+		PrivateZone bool `xml:"PrivateZone"`
+	} `xml:"Config"`
+}
+
+type route53Record struct {
+	Value string `xml:"Value"`
+}
+
+type route53RecordSet struct {
+	Name            string          `xml:"Name"`
+	Type            string          `xml:"Type"`
+	SetIdentifier   string          `xml:"SetIdentifier,omitempty"`
+	TTL             uint32          `xml:"TTL,omitempty"`
+	ResourceRecords []route53Record `xml:"ResourceRecords>ResourceRecord,omitempty"`
+	AliasTarget     *struct {
+		DNSName string `xml:"DNSName"`
+	} `xml:"AliasTarget,omitempty"`
+}
+
+type route53Change struct {
+	Action            string           `xml:"Action"`
+	ResourceRecordSet route53RecordSet `xml:"ResourceRecordSet"`
+}
+
+type route53ChangeRequest struct {
+	XMLName xml.Name        `xml:"ChangeResourceRecordSetsRequest"`
+	XMLNS   string          `xml:"xmlns,attr"`
+	Comment string          `xml:"ChangeBatch>Comment"`
+	Changes []route53Change `xml:"ChangeBatch>Changes>Change"`
+}
+
+func (p *Route53Provider) Sync(ctx context.Context, zone providerZone) (providerSyncResult, error) {
+	var result providerSyncResult
+	if len(p.zones) > 0 && !slices.Contains(p.zones, zone.Name) {
+		return result, errZoneNotHosted
+	}
+	id, err := p.hostedZone(ctx, zone.Name)
+	if err != nil {
+		return result, err
+	}
+	current, err := p.recordSets(ctx, id)
+	if err != nil {
+		return result, err
+	}
+
+	apex := zone.Name + "."
+	existing := make(map[string]route53RecordSet, len(current))
+	for _, set := range current {
+		if set.SetIdentifier != "" || set.AliasTarget != nil || set.Type == "SOA" || (set.Type == "NS" && set.Name == apex) {
+			continue
+		}
+		existing[set.Name+"/"+set.Type] = set
+	}
+
+	// Deletions go first so a name that changes type, say from A to CNAME,
+	// is cleared before the new set is written.
+	var deletes, upserts []route53Change
+	desired := make(map[string]bool, len(zone.RRsets))
+	for _, set := range zone.RRsets {
+		if !route53Types[set.Type] {
+			continue
+		}
+		desired[set.key()] = true
+		if old, ok := existing[set.key()]; ok && route53Set(old).equal(set) {
+			continue
+		}
+		record := route53RecordSet{Name: set.Name, Type: set.Type, TTL: set.TTL}
+		for _, value := range set.Values {
+			record.ResourceRecords = append(record.ResourceRecords, route53Record{Value: value})
+		}
+		upserts = append(upserts, route53Change{Action: "UPSERT", ResourceRecordSet: record})
+	}
+	for key, set := range existing {
+		if !desired[key] {
+			deletes = append(deletes, route53Change{Action: "DELETE", ResourceRecordSet: set})
+		}
+	}
+	slices.SortFunc(deletes, func(a, b route53Change) int {
+		return strings.Compare(a.ResourceRecordSet.Name+a.ResourceRecordSet.Type, b.ResourceRecordSet.Name+b.ResourceRecordSet.Type)
+	})
+
+	for batch := range slices.Chunk(append(deletes, upserts...), route53BatchSize) {
+		request := route53ChangeRequest{
+			XMLNS:   route53XMLNS,
+			Comment: "dns-reloader sync of " + zone.Name,
+			Changes: batch,
+		}
+		body, err := xml.Marshal(request)
+		if err != nil {
+			return result, err
+		}
+		if err := p.call(ctx, http.MethodPost, "/hostedzone/"+id+"/rrset/", body, nil); err != nil {
+			return result, err
+		}
+	}
+	result.Upserted = len(upserts)
+	result.Deleted = len(deletes)
+	return result, nil
+}
+
+// route53Set converts a record set read from Route53 to the form zones are
+// compared in. Route53 escapes some characters in names, * among them.
+func route53Set(set route53RecordSet) providerRRset {
+	converted := providerRRset{Name: strings.ReplaceAll(set.Name, `\052`, "*"), Type: set.Type, TTL: set.TTL}
+	for _, record := range set.ResourceRecords {
+		converted.Values = append(converted.Values, record.Value)
+	}
+	slices.Sort(converted.Values)
+	return converted
+}
+
+// hostedZone returns the ID of the public hosted zone named zone.
+func (p *Route53Provider) hostedZone(ctx context.Context, zone string) (string, error) {
+	var resp struct {
+		HostedZones []route53HostedZone `xml:"HostedZones>HostedZone"`
+	}
+	query := url.Values{"dnsname": {zone + "."}, "maxitems": {"10"}}
+	if err := p.call(ctx, http.MethodGet, "/hostedzonesbyname?"+query.Encode(), nil, &resp); err != nil {
+		return "", err
+	}
+	for _, hosted := range resp.HostedZones {
+		if strings.EqualFold(hosted.Name, zone+".") && !hosted.Config.PrivateZone {
+			return strings.TrimPrefix(hosted.ID, "/hostedzone/"), nil
+		}
+	}
+	return "", errZoneNotHosted
+}
+
+// recordSets lists every record set in the hosted zone, following the
+// pagination markers.
+func (p *Route53Provider) recordSets(ctx context.Context, id string) ([]route53RecordSet, error) {
+	var sets []route53RecordSet
+	query := url.Values{"maxitems": {strconv.Itoa(300)}}
+	for {
+		var resp struct {
+			ResourceRecordSets   []route53RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+			IsTruncated          bool               `xml:"IsTruncated"`
+			NextRecordName       string             `xml:"NextRecordName"`
+			NextRecordType       string             `xml:"NextRecordType"`
+			NextRecordIdentifier string             `xml:"NextRecordIdentifier"`
+		}
+		if err := p.call(ctx, http.MethodGet, "/hostedzone/"+id+"/rrset?"+query.Encode(), nil, &resp); err != nil {
+			return nil, err
+		}
+		for _, set := range resp.ResourceRecordSets {
+			set.Name = strings.ToLower(strings.ReplaceAll(set.Name, `\052`, "*"))
+			sets = append(sets, set)
+		}
+		if !resp.IsTruncated {
+			return sets, nil
+		}
+		query.Set("name", resp.NextRecordName)
+		query.Set("type", resp.NextRecordType)
+		query.Del("identifier")
+		if resp.NextRecordIdentifier != "" {
+			query.Set("identifier", resp.NextRecordIdentifier)
+		}
+	}
+}
+
+func (p *Route53Provider) call(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, route53Endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/xml")
+	}
+
+	credentials, err := p.aws.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	hash := sha256.Sum256(body)
+	if err := v4.NewSigner().SignHTTP(ctx, credentials, req, hex.EncodeToString(hash[:]), "route53", "us-east-1", time.Now()); err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("route53 request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("route53 %s %s returned %s: %s", method, strings.SplitN(path, "?", 2)[0], resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return xml.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// recordFromRR converts a wire-format resource record into the records table
+// layout: owner names without the trailing dot and MX priority split out.
+func recordFromRR(rr dns.RR, domainID int) Record {
+	header := rr.Header()
+	record := Record{
+		DomainID: domainID,
+		Name:     strings.TrimSuffix(strings.ToLower(header.Name), "."),
+		Type:     dns.TypeToString[header.Rrtype],
+		TTL:      int(header.Ttl),
+		Auth:     true,
+	}
+
+	switch v := rr.(type) {
+	case *dns.MX:
+		prio := int(v.Preference)
+		record.Prio = &prio
+		record.Content = v.Mx
+	default:
+		record.Content = rdataString(rr)
+	}
+
+	return record
+}
+
+// recordRR converts a stored record of zone into a resource record, the
+// way the zone file writer renders it: content relative to the zone, MX
+// priority from prio and TXT content quoted when it is not already.
+func recordRR(zone string, record Record) (dns.RR, error) {
+	content := record.Content
+	switch strings.ToUpper(record.Type) {
+	case "MX":
+		priority := 10
+		if record.Prio != nil {
+			priority = *record.Prio
+		}
+		content = fmt.Sprintf("%d %s", priority, content)
+	case "TXT":
+		if !strings.HasPrefix(content, "\"") {
+			content = fmt.Sprintf("\"%s\"", content)
+		}
+	}
+	line := fmt.Sprintf("%s. %d IN %s %s\n", strings.TrimSuffix(record.Name, "."), record.TTL, record.Type, content)
+	rrs, err := parseZone(strings.NewReader(line), zone, "record")
+	if err != nil {
+		return nil, err
+	}
+	if len(rrs) != 1 {
+		return nil, fmt.Errorf("record %s %s does not parse as one record", record.Name, record.Type)
+	}
+	return rrs[0], nil
+}
+
+// rdataString returns the presentation form of rr without its header.
+func rdataString(rr dns.RR) string {
+	return strings.TrimSpace(strings.TrimPrefix(rr.String(), rr.Header().String()))
+}
+
+// parseZoneFile reads a master file from disk into resource records.
+func parseZoneFile(path, origin string) ([]dns.RR, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zone file: %w", err)
+	}
+	defer f.Close()
+	return parseZone(f, origin, path)
+}
+
+// parseZone reads master-file content into resource records. file is only
+// used in error messages.
+func parseZone(r io.Reader, origin, file string) ([]dns.RR, error) {
+	parser := dns.NewZoneParser(r, dns.Fqdn(origin), file)
+	var rrs []dns.RR
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		rrs = append(rrs, rr)
+	}
+	if err := parser.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse zone file: %w", err)
+	}
+	return rrs, nil
+}
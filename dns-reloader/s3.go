@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// s3Client is the small part of the S3 API the zone backups need, called
+// with path-style URLs so MinIO, Ceph and other S3-compatible stores work
+// as well as AWS. Requests are signed with the default AWS credential
+// chain.
+type s3Client struct {
+	aws      aws.Config
+	client   *http.Client
+	endpoint string
+	region   string
+	bucket   string
+}
+
+type s3Object struct {
+	Key          string    `xml:"Key"`
+	LastModified time.Time `xml:"LastModified"`
+	Size         int64     `xml:"Size"`
+}
+
+func newS3Client(endpoint, region, bucket string) (*s3Client, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), awsconfig.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	return &s3Client{
+		aws:      awsCfg,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		region:   region,
+		bucket:   bucket,
+	}, nil
+}
+
+func (s *s3Client) put(ctx context.Context, key string, body []byte, contentType string) error {
+	header := http.Header{"Content-Type": {contentType}}
+	return s.call(ctx, http.MethodPut, s.objectPath(key), nil, body, header, nil)
+}
+
+func (s *s3Client) delete(ctx context.Context, key string) error {
+	return s.call(ctx, http.MethodDelete, s.objectPath(key), nil, nil, nil, nil)
+}
+
+// list returns every object whose key starts with prefix, following the
+// continuation tokens.
+func (s *s3Client) list(ctx context.Context, prefix string) ([]s3Object, error) {
+	var objects []s3Object
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		var resp struct {
+			Contents              []s3Object `xml:"Contents"`
+			IsTruncated           bool       `xml:"IsTruncated"`
+			NextContinuationToken string     `xml:"NextContinuationToken"`
+		}
+		if err := s.call(ctx, http.MethodGet, "/"+s.bucket, query, nil, nil, &resp); err != nil {
+			return nil, err
+		}
+		objects = append(objects, resp.Contents...)
+		if !resp.IsTruncated {
+			return objects, nil
+		}
+		query.Set("continuation-token", resp.NextContinuationToken)
+	}
+}
+
+func (s *s3Client) objectPath(key string) string {
+	return "/" + s.bucket + "/" + strings.TrimPrefix((&url.URL{Path: key}).EscapedPath(), "/")
+}
+
+func (s *s3Client) call(ctx context.Context, method, path string, query url.Values, body []byte, header http.Header, out interface{}) error {
+	target := s.endpoint + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	credentials, err := s.aws.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	hash := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(hash[:])
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if err := v4.NewSigner().SignHTTP(ctx, credentials, req, payloadHash, "s3", s.region, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("s3 request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("s3 %s %s returned %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return xml.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// published reports whether record is inside its not_before and not_after
+// window at now.
+func published(record Record, now time.Time) bool {
+	if record.NotBefore != nil && now.Before(*record.NotBefore) {
+		return false
+	}
+	return record.NotAfter == nil || now.Before(*record.NotAfter)
+}
+
+// withholdScheduled leaves out the records that are not yet or no longer
+// published at now.
+func withholdScheduled(records []Record, now time.Time) []Record {
+	for _, record := range records {
+		if !published(record, now) {
+			scheduled := make([]Record, 0, len(records))
+			for _, record := range records {
+				if published(record, now) {
+					scheduled = append(scheduled, record)
+				}
+			}
+			return scheduled
+		}
+	}
+	return records
+}
+
+// scheduleNextBoundary sets a timer for the next not_before or not_after
+// to pass, which regenerates the zones so the record is published or
+// retracted on time rather than with the next change.
+func (r *Reloader) scheduleNextBoundary(ctx context.Context, db *gorm.DB) error {
+	if !r.recordSchedules {
+		return nil
+	}
+	now := time.Now().UTC()
+	var next *time.Time
+	for _, column := range []string{"not_before", "not_after"} {
+		var boundary []time.Time
+		if err := db.WithContext(ctx).Model(&Record{}).Where(column+" > ?", now).Order(column).Limit(1).Pluck(column, &boundary).Error; err != nil {
+			return fmt.Errorf("failed to read record schedules: %w", err)
+		}
+		if len(boundary) > 0 && (next == nil || boundary[0].Before(*next)) {
+			next = &boundary[0]
+		}
+	}
+
+	r.scheduleMu.Lock()
+	defer r.scheduleMu.Unlock()
+	if r.scheduleTimer != nil {
+		r.scheduleTimer.Stop()
+		r.scheduleTimer = nil
+	}
+	if next == nil {
+		return nil
+	}
+	// The boundary is stored to the second or finer, so regenerating just
+	// after it is enough for the record to be inside its window.
+	boundary := *next
+	r.scheduleTimer = time.AfterFunc(time.Until(boundary)+time.Second, func() {
+		select {
+		case r.localChanges <- &DNSChangeNotification{Table: "records", Action: "SCHEDULE", Timestamp: time.Now(), internal: true}:
+		case <-r.ctx.Done():
+		}
+	})
+	return nil
+}
+
+// upcomingSchedules lists the records published or retracted after now
+// and no later than until, for the change calendar.
+func (r *Reloader) upcomingSchedules(ctx context.Context, now, until time.Time) ([]UpcomingChange, error) {
+	if !r.recordSchedules {
+		return nil, nil
+	}
+	var records []Record
+	if err := r.db.WithContext(ctx).Where("disabled = ?", false).
+		Where("(not_before > ? AND not_before <= ?) OR (not_after > ? AND not_after <= ?)", now, until, now, until).
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to read record schedules: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	ids := make([]int, len(records))
+	for i, record := range records {
+		ids[i] = record.DomainID
+	}
+	var domains []Domain
+	if err := r.db.WithContext(ctx).Select("id", "name").Where("id IN ?", ids).Find(&domains).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch domains: %w", err)
+	}
+	names := make(map[int]string, len(domains))
+	for _, domain := range domains {
+		names[int(domain.ID)] = domain.Name
+	}
+
+	var upcoming []UpcomingChange
+	for _, record := range records {
+		for _, boundary := range []struct {
+			at     *time.Time
+			action string
+			verb   string
+		}{{record.NotBefore, "PUBLISH", "published"}, {record.NotAfter, "RETRACT", "retracted"}} {
+			if boundary.at == nil || !boundary.at.After(now) || boundary.at.After(until) {
+				continue
+			}
+			upcoming = append(upcoming, UpcomingChange{
+				Action:      boundary.action,
+				Table:       "records",
+				DomainID:    record.DomainID,
+				Zone:        names[record.DomainID],
+				Name:        record.Name,
+				Type:        record.Type,
+				Description: fmt.Sprintf("%s %s %s is %s", record.Name, record.Type, record.Content, boundary.verb),
+				DueAt:       *boundary.at,
+			})
+		}
+	}
+	return upcoming, nil
+}
+
+// setRecordSchedule applies the not_before and not_after of body to
+// record, each RFC 3339 and "" to clear it. Like the view, they are
+// written on their own so schemas without the columns accept records.
+func (r *Reloader) setRecordSchedule(ctx context.Context, db *gorm.DB, record *Record, body recordRequest) error {
+	if body.NotBefore == nil && body.NotAfter == nil {
+		return nil
+	}
+	if !r.recordSchedules {
+		return errValidation([]string{"the records table has no not_before and not_after columns; run the migrations"})
+	}
+	notBefore, notAfter := record.NotBefore, record.NotAfter
+	var problems []string
+	for _, field := range []struct {
+		name  string
+		value *string
+		dst   **time.Time
+	}{{"not_before", body.NotBefore, &notBefore}, {"not_after", body.NotAfter, &notAfter}} {
+		if field.value == nil {
+			continue
+		}
+		if *field.value == "" {
+			*field.dst = nil
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, *field.value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s %q is not an RFC 3339 time", field.name, *field.value))
+			continue
+		}
+		t = t.UTC()
+		*field.dst = &t
+	}
+	if len(problems) == 0 && notBefore != nil && notAfter != nil && !notAfter.After(*notBefore) {
+		problems = append(problems, "not_after must be after not_before")
+	}
+	if len(problems) > 0 {
+		return errValidation(problems)
+	}
+	if err := db.WithContext(ctx).Table(record.TableName()).Where("id = ?", record.ID).
+		UpdateColumns(map[string]interface{}{"not_before": notBefore, "not_after": notAfter}).Error; err != nil {
+		return fmt.Errorf("failed to set record schedule: %w", err)
+	}
+	record.NotBefore, record.NotAfter = notBefore, notAfter
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleBoundaryBypassesOutbox(t *testing.T) {
+	r := newTestReloader(t, map[string]string{"CHANGE_OUTBOX": "true"})
+	ctx := t.Context()
+	createTestZone(t, r, "example.com")
+	notBefore := time.Now().Add(time.Second).Format(time.RFC3339)
+	notAfter := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	for _, body := range []recordRequest{
+		{Name: "launch", Type: "A", Content: "192.0.2.1", NotBefore: &notBefore},
+		{Name: "retired", Type: "A", Content: "192.0.2.2", NotAfter: &notAfter},
+	} {
+		if _, err := r.createRecord(ctx, "example.com", body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	applyLocalChanges(r)
+	if _, err := r.regenerateAllZones(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if zone := readZoneFile(t, r, "example.com"); zoneHasRecord(zone, "A", "192.0.2.1") || zoneHasRecord(zone, "A", "192.0.2.2") {
+		t.Fatalf("records outside their window published:\n%s", zone)
+	}
+
+	select {
+	case change := <-r.localChanges:
+		r.handleLocalChange(change)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no regeneration at not_before")
+	}
+	if zone := readZoneFile(t, r, "example.com"); !zoneHasRecord(zone, "A", "192.0.2.1") {
+		t.Fatalf("record not published at not_before with the outbox on:\n%s", zone)
+	}
+}
+
+func TestUpcomingSchedules(t *testing.T) {
+	r := newTestReloader(t, nil)
+	ctx := t.Context()
+	createTestZone(t, r, "example.com")
+	now := time.Now().UTC()
+	at := func(d time.Duration) *string {
+		s := now.Add(d).Format(time.RFC3339)
+		return &s
+	}
+	for _, body := range []recordRequest{
+		{Name: "launch", Type: "A", Content: "192.0.2.1", NotBefore: at(time.Hour)},
+		{Name: "retire", Type: "A", Content: "192.0.2.2", NotAfter: at(2 * time.Hour)},
+		{Name: "later", Type: "A", Content: "192.0.2.3", NotBefore: at(60 * 24 * time.Hour)},
+		{Name: "live", Type: "A", Content: "192.0.2.4", NotBefore: at(-time.Hour)},
+	} {
+		if _, err := r.createRecord(ctx, "example.com", body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	upcoming, err := r.upcomingSchedules(ctx, now, now.AddDate(0, 0, 30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := make(map[string]string)
+	for _, change := range upcoming {
+		if change.Zone != "example.com" {
+			t.Errorf("%s is listed in zone %q", change.Name, change.Zone)
+		}
+		actions[change.Name] = change.Action
+	}
+	tests := []struct {
+		name   string
+		action string
+	}{
+		{"launch.example.com", "PUBLISH"},
+		{"retire.example.com", "RETRACT"},
+		{"later.example.com", ""},
+		{"live.example.com", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := actions[tt.name]; got != tt.action {
+				t.Fatalf("action = %q, want %q", got, tt.action)
+			}
+		})
+	}
+}
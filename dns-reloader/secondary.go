@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+)
+
+// secondaryRetry is how soon a secondary zone that has never been
+// transferred is tried again when none of its masters answer.
+const secondaryRetry = time.Minute
+
+// secondaryZone is what the reloader tracks of a zone it is secondary for.
+// next is when its masters are asked for the SOA again: after the refresh
+// interval of the last SOA, or the retry interval when they could not be
+// reached. expires is when the last transferred copy stops being current.
+type secondaryZone struct {
+	domain  Domain
+	masters []string
+	key     string
+	next    time.Time
+	retry   time.Duration
+	expires time.Time
+	expired bool
+	running bool
+}
+
+// isSecondary reports whether the reloader transfers domain from its
+// masters: with SECONDARY_MODE set, SLAVE domains that have one.
+func (r *Reloader) isSecondary(domain Domain) bool {
+	return r.config.SecondaryMode && domainType(domain) == "SLAVE" && len(masterList(domain.Master)) > 0
+}
+
+// masterList reads the masters column, a comma separated list of
+// addresses with optional ports as PowerDNS stores it.
+func masterList(master *string) []string {
+	if master == nil {
+		return nil
+	}
+	return parseList(*master)
+}
+
+// checkSecondaries runs on every listener or polling tick with
+// SECONDARY_MODE set. Each SLAVE zone whose refresh or retry timer is due
+// is checked in the background: when the SOA serial of its first answering
+// master is newer than the stored one, the zone is transferred with AXFR
+// and its records replaced, which regenerates its file. The records are
+// owned by the masters; the API refuses to change them. A zone that could
+// not be refreshed within its SOA expire time is reported once and keeps
+// being served from the last copy.
+func (r *Reloader) checkSecondaries() {
+	if !r.config.SecondaryMode {
+		return
+	}
+	var domains []Domain
+	if err := r.db.WithContext(r.ctx).Where("UPPER(type) = ?", "SLAVE").Find(&domains).Error; err != nil {
+		r.logger.WithError(err).Warn("Failed to list secondary zones")
+		return
+	}
+	if err := r.loadZoneOptions(r.ctx, r.db, domains); err != nil {
+		r.logger.WithError(err).Warn("Failed to read secondary zone metadata")
+		return
+	}
+
+	now := time.Now()
+	r.secondaryMu.Lock()
+	defer r.secondaryMu.Unlock()
+	zones := make(map[string]*secondaryZone, len(domains))
+	for _, domain := range domains {
+		if !r.isSecondary(domain) {
+			continue
+		}
+		zone := r.secondaries[domain.Name]
+		if zone == nil {
+			zone = &secondaryZone{}
+		}
+		zone.domain = domain
+		zone.masters = masterList(domain.Master)
+		zone.key = domain.Options.MasterTSIG
+		zones[domain.Name] = zone
+
+		if !zone.expired && !zone.expires.IsZero() && now.After(zone.expires) {
+			zone.expired = true
+			r.logger.WithFields(logrus.Fields{
+				"domain":  domain.Name,
+				"masters": zone.masters,
+			}).Error("Secondary zone expired without a refresh; serving the last transferred copy")
+			r.alertFailure("secondary", domain.Name, errors.New("zone expired without a refresh from its masters"))
+		}
+		if !zone.running && !now.Before(zone.next) {
+			r.startRefresh(zone)
+		}
+	}
+	r.secondaries = zones
+}
+
+// startRefresh checks zone against its masters in the background. The
+// caller holds secondaryMu.
+func (r *Reloader) startRefresh(zone *secondaryZone) {
+	zone.running = true
+	domain, masters, keyName := zone.domain, zone.masters, zone.key
+	go func() {
+		soa, err := r.refreshSecondary(domain, masters, keyName)
+
+		r.secondaryMu.Lock()
+		defer r.secondaryMu.Unlock()
+		zone.running = false
+		now := time.Now()
+		if err != nil {
+			retry := zone.retry
+			if retry == 0 {
+				retry = secondaryRetry
+			}
+			zone.next = now.Add(retry)
+			secondaryChecks.WithLabelValues("failed").Inc()
+			r.logger.WithError(err).WithFields(logrus.Fields{
+				"domain":  domain.Name,
+				"masters": masters,
+				"retry":   retry,
+			}).Warn("Failed to refresh secondary zone")
+			return
+		}
+		zone.next = now.Add(time.Duration(soa.Refresh) * time.Second)
+		zone.retry = time.Duration(soa.Retry) * time.Second
+		zone.expires = now.Add(time.Duration(soa.Expire) * time.Second)
+		if zone.expired {
+			zone.expired = false
+			r.alertRecovered("secondary", domain.Name)
+		}
+	}()
+}
+
+// refreshSecondary brings a secondary zone up to date from the first of
+// its masters that answers, and returns that master's SOA.
+func (r *Reloader) refreshSecondary(domain Domain, masters []string, keyName string) (*dns.SOA, error) {
+	var key *tsigKey
+	if keyName != "" {
+		if key = r.cachedTSIGKey(keyName); key == nil {
+			return nil, fmt.Errorf("AXFR-MASTER-TSIG key %s not found", keyName)
+		}
+	}
+	current, stored, err := r.storedSerial(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, master := range masters {
+		soa, err := querySOA(r.ctx, domain.Name, master, key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", master, err))
+			continue
+		}
+		if stored && !serialNewer(soa.Serial, current) {
+			secondaryChecks.WithLabelValues("current").Inc()
+			return soa, nil
+		}
+
+		rrs, err := transferZone(domain.Name, master, key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", master, err))
+			continue
+		}
+		records := make([]Record, 0, len(rrs))
+		for _, rr := range rrs {
+			record := recordFromRR(rr, 0)
+			record.CreatedBy = axfrOwner
+			records = append(records, record)
+		}
+		if _, _, err := r.loadTransferredZone(r.ctx, domain.Name, domainType(domain), true, records); err != nil {
+			return nil, fmt.Errorf("failed to store transferred zone: %w", err)
+		}
+		if transferred := zoneSOA(rrs); transferred != nil {
+			soa = transferred
+		}
+		r.domainChanged(&domain, "AXFR")
+		secondaryChecks.WithLabelValues("transferred").Inc()
+		r.logger.WithFields(logrus.Fields{
+			"domain":  domain.Name,
+			"master":  master,
+			"serial":  soa.Serial,
+			"records": len(records),
+		}).Info("Transferred secondary zone")
+		return soa, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// storedSerial returns the SOA serial of domain's records, and whether it
+// has one.
+func (r *Reloader) storedSerial(domain Domain) (uint32, bool, error) {
+	var contents []string
+	if err := r.db.WithContext(r.ctx).Model(&Record{}).
+		Where("domain_id = ? AND type = ?", domain.ID, "SOA").Limit(1).Pluck("content", &contents).Error; err != nil {
+		return 0, false, fmt.Errorf("failed to read stored SOA: %w", err)
+	}
+	if len(contents) == 0 {
+		return 0, false, nil
+	}
+	fields := strings.Fields(contents[0])
+	if len(fields) < 3 {
+		return 0, false, nil
+	}
+	serial, err := strconv.ParseUint(fields[2], 10, 32)
+	if err != nil {
+		return 0, false, nil
+	}
+	return uint32(serial), true, nil
+}
+
+// serialNewer compares SOA serials with RFC 1982 arithmetic, so a serial
+// that wrapped around still counts as newer.
+func serialNewer(serial, than uint32) bool {
+	return serial != than && int32(serial-than) > 0
+}
+
+// querySOA asks master, which may omit the port, for zone's SOA, signed
+// with key when it is not nil.
+func querySOA(ctx context.Context, zone, master string, key *tsigKey) (*dns.SOA, error) {
+	if _, _, err := net.SplitHostPort(master); err != nil {
+		master = net.JoinHostPort(master, "53")
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(zone), dns.TypeSOA)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	client := &dns.Client{}
+	if key != nil {
+		msg.SetTsig(key.Name, key.Algorithm, 300, time.Now().Unix())
+		client.TsigSecret = map[string]string{key.Name: key.Secret}
+	}
+	resp, _, err := client.ExchangeContext(ctx, msg, master)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("SOA query answered %s", dns.RcodeToString[resp.Rcode])
+	}
+	if soa := zoneSOA(resp.Answer); soa != nil {
+		return soa, nil
+	}
+	return nil, errors.New("SOA query returned no SOA")
+}
+
+// receiveNotify handles a NOTIFY for a secondary zone, which has the zone
+// checked now instead of when its refresh timer runs out. It must come
+// from one of the zone's masters or be signed with its AXFR-MASTER-TSIG
+// key. It returns the rcode to answer with.
+func (r *Reloader) receiveNotify(question dns.Question, peer netip.Addr, tsig *dns.TSIG) int {
+	name := strings.ToLower(strings.TrimSuffix(question.Name, "."))
+	logger := r.logger.WithFields(logrus.Fields{"domain": name, "peer": peer.String()})
+
+	r.secondaryMu.Lock()
+	defer r.secondaryMu.Unlock()
+	zone := r.secondaries[name]
+	if zone == nil || question.Qclass != dns.ClassINET {
+		return dns.RcodeNotAuth
+	}
+	if !zone.fromMaster(peer, tsig) {
+		logger.Warn("Refused NOTIFY not from a master of the zone")
+		return dns.RcodeRefused
+	}
+	logger.Debug("Received NOTIFY")
+	zone.next = time.Time{}
+	if !zone.running {
+		r.startRefresh(zone)
+	}
+	return dns.RcodeSuccess
+}
+
+// fromMaster reports whether a NOTIFY came from one of the zone's masters
+// or was signed with its AXFR-MASTER-TSIG key.
+func (z *secondaryZone) fromMaster(peer netip.Addr, tsig *dns.TSIG) bool {
+	if tsig != nil && z.key != "" && strings.ToLower(tsig.Hdr.Name) == z.key {
+		return true
+	}
+	for _, master := range z.masters {
+		host := master
+		if h, _, err := net.SplitHostPort(master); err == nil {
+			host = h
+		}
+		if addr, err := netip.ParseAddr(host); err == nil && addr.Unmap() == peer {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWritable refuses changes to the records of a secondary zone, which
+// its masters own: the next transfer would overwrite them.
+func (r *Reloader) checkWritable(domain *Domain) error {
+	if !r.isSecondary(*domain) {
+		return nil
+	}
+	return errConflict(fmt.Sprintf("%s is a secondary zone transferred from %s; change it on its master", domain.Name, *domain.Master))
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+var (
+	// awsSecretARN matches an AWS Secrets Manager ARN, optionally followed
+	// the way ECS task definitions reference secrets by :json-key,
+	// :version-stage and :version-id.
+	awsSecretARN = regexp.MustCompile(`^arn:aws[a-z-]*:secretsmanager:([a-z0-9-]+):\d+:secret:[^:]+(:[^:]*){0,3}$`)
+	// gcpSecretName matches a GCP Secret Manager secret or version,
+	// optionally followed by #json-key.
+	gcpSecretName = regexp.MustCompile(`^projects/[^/#]+/secrets/[^/#]+(/versions/[^/#]+)?(#.+)?$`)
+)
+
+// secretManagerClient is the HTTP client for both secret managers.
+var secretManagerClient = &http.Client{Timeout: 30 * time.Second}
+
+// gcpSecretManagerURL is the Secret Manager API the versions are read from.
+var gcpSecretManagerURL = "https://secretmanager.googleapis.com/v1/"
+
+func isAWSSecretARN(value string) bool {
+	return awsSecretARN.MatchString(value)
+}
+
+func isGCPSecretName(value string) bool {
+	return gcpSecretName.MatchString(value)
+}
+
+// awsConfig is loaded once from the default credential chain.
+var awsConfig = sync.OnceValues(func() (aws.Config, error) {
+	return awsconfig.LoadDefaultConfig(context.Background())
+})
+
+// readAWSSecret reads the SecretString of an AWS Secrets Manager ARN with
+// GetSecretValue, signed with the default AWS credential chain in the
+// ARN's region. AWS_ENDPOINT_URL_SECRETS_MANAGER replaces the endpoint.
+func readAWSSecret(ctx context.Context, reference string) (string, error) {
+	parts := strings.Split(reference, ":")
+	region := parts[3]
+	request := map[string]string{"SecretId": strings.Join(parts[:7], ":")}
+	var field string
+	if len(parts) > 7 {
+		field = parts[7]
+	}
+	if len(parts) > 8 && parts[8] != "" {
+		request["VersionStage"] = parts[8]
+	}
+	if len(parts) > 9 && parts[9] != "" {
+		request["VersionId"] = parts[9]
+	}
+
+	cfg, err := awsConfig()
+	if err != nil {
+		return "", fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	credentials, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	endpoint := getEnv("AWS_ENDPOINT_URL_SECRETS_MANAGER", fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", region))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	hash := sha256.Sum256(body)
+	if err := v4.NewSigner().SignHTTP(ctx, credentials, req, hex.EncodeToString(hash[:]), "secretsmanager", region, time.Now()); err != nil {
+		return "", fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	resp, err := secretManagerClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("secrets manager request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("secrets manager returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var secret struct {
+		SecretString *string `json:"SecretString"`
+		SecretBinary []byte  `json:"SecretBinary"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", fmt.Errorf("failed to decode secrets manager response: %w", err)
+	}
+	value := string(secret.SecretBinary)
+	if secret.SecretString != nil {
+		value = *secret.SecretString
+	}
+	return secretField(value, field)
+}
+
+// gcpToken is the cached access token for Secret Manager.
+var gcpToken struct {
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// gcpAccessToken returns GOOGLE_OAUTH_ACCESS_TOKEN, or a token of the
+// service account of the GCE instance or GKE workload from the metadata
+// server at GCE_METADATA_HOST.
+func gcpAccessToken(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+	gcpToken.mu.Lock()
+	defer gcpToken.mu.Unlock()
+	if gcpToken.token != "" && time.Until(gcpToken.expires) > time.Minute {
+		return gcpToken.token, nil
+	}
+
+	host := getEnv("GCE_METADATA_HOST", "metadata.google.internal")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := secretManagerClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("metadata server request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("metadata server returned %s", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode metadata server token: %w", err)
+	}
+	gcpToken.token = token.AccessToken
+	gcpToken.expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return gcpToken.token, nil
+}
+
+// readGCPSecret reads a version of a GCP Secret Manager secret, the latest
+// unless the name has /versions/.
+func readGCPSecret(ctx context.Context, reference string) (string, error) {
+	name, field, _ := strings.Cut(reference, "#")
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	token, err := gcpAccessToken(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get GCP access token: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpSecretManagerURL+name+":access", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := secretManagerClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("secret manager request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("secret manager returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var version struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		return "", fmt.Errorf("failed to decode secret manager response: %w", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(version.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode secret payload: %w", err)
+	}
+	return secretField(string(data), field)
+}
+
+// secretField returns the string field of a JSON secret, or the whole
+// secret when field is "".
+func secretField(value, field string) (string, error) {
+	if field == "" {
+		return value, nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", errors.New("secret is not a JSON object")
+	}
+	switch v := fields[field].(type) {
+	case string:
+		return v, nil
+	case float64, bool:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("secret has no field %q", field)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// secretErrors collects the secrets that could not be read while the
+// configuration was loaded. NewReloader refuses to start with any, rather
+// than run with an empty credential.
+var secretErrors []error
+
+// secretReference is a secret variable whose value is read from a secret
+// store, kept so refreshSecrets can read it again.
+type secretReference struct {
+	key       string
+	reference string
+	value     string
+}
+
+// secretReferences are the variables that held references, in the order
+// they were read.
+var secretReferences []*secretReference
+
+// getSecret reads a credential from the environment variable key, or from
+// the file named by key_FILE, the way Docker and Kubernetes secrets are
+// mounted, so it never has to be in the environment. A value of the form
+// vault:<path>#<field>, an AWS Secrets Manager ARN or a GCP Secret Manager
+// name is read from that store.
+func getSecret(key, defaultValue string) string {
+	value, err := readSecretEnv(key)
+	if err != nil {
+		secretErrors = append(secretErrors, err)
+		return defaultValue
+	}
+	if value == "" {
+		return defaultValue
+	}
+	if !isSecretReference(value) {
+		return value
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	resolved, err := resolveSecret(ctx, value)
+	if err != nil {
+		secretErrors = append(secretErrors, fmt.Errorf("failed to read %s: %w", key, err))
+		return defaultValue
+	}
+	secretReferences = append(secretReferences, &secretReference{key: key, reference: value, value: resolved})
+	return resolved
+}
+
+// readSecretEnv reads key, or the file named by key_FILE with a trailing
+// newline dropped. Setting both is an error.
+func readSecretEnv(key string) (string, error) {
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return os.Getenv(key), nil
+	}
+	if os.Getenv(key) != "" {
+		return "", fmt.Errorf("both %s and %s_FILE are set", key, key)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s_FILE: %w", key, err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+func isSecretReference(value string) bool {
+	return strings.HasPrefix(value, vaultPrefix) || isAWSSecretARN(value) || isGCPSecretName(value)
+}
+
+// resolveSecret reads the secret a reference names.
+func resolveSecret(ctx context.Context, reference string) (string, error) {
+	switch {
+	case strings.HasPrefix(reference, vaultPrefix):
+		return readVaultReference(ctx, reference)
+	case isAWSSecretARN(reference):
+		return readAWSSecret(ctx, reference)
+	default:
+		return readGCPSecret(ctx, reference)
+	}
+}
+
+// passwordSecretKey is the variable holding the database password of the
+// driver.
+func passwordSecretKey(driver string) string {
+	switch driver {
+	case "postgres", "postgresql":
+		return "POSTGRES_PASSWORD"
+	case "mysql", "mariadb":
+		return "MYSQL_PASSWORD"
+	}
+	return ""
+}
+
+// setupPasswordSecret hands a database password read from a secret
+// reference to the driver as credentials, so refreshSecrets can change it
+// for new connections.
+func (r *Reloader) setupPasswordSecret() {
+	storage, ok := r.storage.(credentialed)
+	if !ok || r.config.DatabaseURL != "" {
+		return
+	}
+	key := passwordSecretKey(r.config.DBDriver)
+	for _, ref := range secretReferences {
+		if ref.key != key {
+			continue
+		}
+		username := r.config.PostgresUser
+		if key == "MYSQL_PASSWORD" {
+			username = r.config.MySQLUser
+		}
+		r.dbCredentials = &dbCredentials{username: username, password: ref.value}
+		storage.useCredentials(r.dbCredentials)
+		return
+	}
+}
+
+// refreshSecrets reads the secret references again every
+// SECRET_REFRESH_INTERVAL. A new database password is used for new
+// connections. Every other secret was handed to a client at startup, so
+// when one of them changes the reloader shuts down to be restarted with
+// it.
+func (r *Reloader) refreshSecrets() {
+	if len(secretReferences) == 0 || r.config.SecretRefreshInterval <= 0 {
+		return
+	}
+	logger := r.logger.WithField("component", "secrets")
+	ticker := time.NewTicker(r.config.SecretRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var changed []string
+		for _, ref := range secretReferences {
+			ctx, cancel := context.WithTimeout(r.ctx, 30*time.Second)
+			value, err := resolveSecret(ctx, ref.reference)
+			cancel()
+			if err != nil {
+				secretRefreshes.WithLabelValues("failed").Inc()
+				logger.WithError(err).WithField("key", ref.key).Warn("Failed to refresh secret")
+				continue
+			}
+			if value == ref.value {
+				secretRefreshes.WithLabelValues("unchanged").Inc()
+				continue
+			}
+			secretRefreshes.WithLabelValues("changed").Inc()
+			ref.value = value
+			if r.dbCredentials != nil && r.config.VaultDBCredsPath == "" && ref.key == passwordSecretKey(r.config.DBDriver) {
+				username, _ := r.dbCredentials.current()
+				r.rotateDBCredentials(username, value)
+				logger.WithField("key", ref.key).Info("Database password changed, using it for new connections")
+				continue
+			}
+			changed = append(changed, ref.key)
+		}
+		if len(changed) > 0 {
+			logger.WithField("keys", changed).Warn("Secrets changed, shutting down to restart with them")
+			r.cancel()
+			return
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ordinalSuffix is the ordinal a StatefulSet appends to its pod names.
+var ordinalSuffix = regexp.MustCompile(`-(\d+)$`)
+
+// shardIndex is SHARD_INDEX, or when it is unset the ordinal at the end of
+// the leader identity, so the pods of a StatefulSet take a shard each
+// without being configured one by one. It is -1 when there is neither.
+func shardIndex(identity string) int {
+	if value := getEnv("SHARD_INDEX", ""); value != "" {
+		index, err := strconv.Atoi(value)
+		if err != nil {
+			return -1
+		}
+		return index
+	}
+	if match := ordinalSuffix.FindStringSubmatch(identity); match != nil {
+		index, _ := strconv.Atoi(match[1])
+		return index
+	}
+	return -1
+}
+
+// validateSharding checks that SHARD_COUNT and the shard index agree.
+func validateSharding(config *Config) error {
+	if config.ShardCount <= 1 {
+		return nil
+	}
+	if config.ShardIndex < 0 || config.ShardIndex >= config.ShardCount {
+		return fmt.Errorf("SHARD_COUNT is %d, so SHARD_INDEX must be 0 to %d, or the pod name or hostname must end in it", config.ShardCount, config.ShardCount-1)
+	}
+	return nil
+}
+
+// domainShard is the shard that owns domain: the one X-SHARD names, or the
+// FNV-1a hash of its name modulo SHARD_COUNT, which every reloader
+// computes alike.
+func domainShard(domain Domain, count int) int {
+	if domain.Options.Shard != nil {
+		return *domain.Options.Shard
+	}
+	h := fnv.New32a()
+	h.Write([]byte(strings.TrimSuffix(strings.ToLower(domain.Name), ".")))
+	return int(h.Sum32() % uint32(count))
+}
+
+// ownsDomain reports whether this reloader's shard owns domain. Without
+// SHARD_COUNT it owns every domain.
+func (r *Reloader) ownsDomain(domain Domain) bool {
+	if r.config.ShardCount <= 1 {
+		return true
+	}
+	return domainShard(domain, r.config.ShardCount) == r.config.ShardIndex
+}
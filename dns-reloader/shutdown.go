@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runStats counts what this process has done since it started.
+type runStats struct {
+	startedAt          time.Time
+	notifications      atomic.Int64
+	zonesPublished     atomic.Int64
+	generationFailures atomic.Int64
+	reloads            atomic.Int64
+	reloadFailures     atomic.Int64
+}
+
+type ShutdownReport struct {
+	StartedAt            time.Time      `json:"started_at"`
+	StoppedAt            time.Time      `json:"stopped_at"`
+	Uptime               string         `json:"uptime"`
+	UptimeSeconds        float64        `json:"uptime_seconds"`
+	Mode                 string         `json:"mode"`
+	Notifications        int64          `json:"notifications_received"`
+	ZonesPublished       int64          `json:"zones_published"`
+	GenerationFailures   int64          `json:"generation_failures"`
+	Reloads              int64          `json:"reloads"`
+	ReloadFailures       int64          `json:"reload_failures"`
+	PendingReloadZones   []string       `json:"pending_reload_zones"`
+	NotificationsDropped int            `json:"notifications_dropped"`
+	ReloadTargets        []TargetResult `json:"reload_targets,omitempty"`
+	ExitError            string         `json:"exit_error,omitempty"`
+}
+
+func (r *Reloader) buildShutdownReport(runErr error) ShutdownReport {
+	now := time.Now()
+	report := ShutdownReport{
+		StartedAt:          r.stats.startedAt,
+		StoppedAt:          now,
+		Uptime:             now.Sub(r.stats.startedAt).Round(time.Second).String(),
+		UptimeSeconds:      now.Sub(r.stats.startedAt).Seconds(),
+		Mode:               "polling",
+		Notifications:      r.stats.notifications.Load(),
+		ZonesPublished:     r.stats.zonesPublished.Load(),
+		GenerationFailures: r.stats.generationFailures.Load(),
+		Reloads:            r.stats.reloads.Load(),
+		ReloadFailures:     r.stats.reloadFailures.Load(),
+		PendingReloadZones: []string{},
+	}
+
+	if r.listener != nil {
+		report.Mode = "listener"
+		report.NotificationsDropped = len(r.listener.Notify)
+	}
+	if r.pending != nil {
+		report.PendingReloadZones = r.pending.zones
+	}
+	if reporter, ok := r.backend.(TargetReporter); ok {
+		report.ReloadTargets = reporter.Targets()
+	}
+	if runErr != nil {
+		report.ExitError = runErr.Error()
+	}
+	return report
+}
+
+// emitShutdownReport logs a final summary of the run and, when configured,
+// posts it to SHUTDOWN_WEBHOOK_URL.
+func (r *Reloader) emitShutdownReport(runErr error) {
+	report := r.buildShutdownReport(runErr)
+
+	r.logger.WithFields(logrus.Fields{
+		"started_at":             report.StartedAt,
+		"uptime":                 report.Uptime,
+		"mode":                   report.Mode,
+		"notifications_received": report.Notifications,
+		"zones_published":        report.ZonesPublished,
+		"generation_failures":    report.GenerationFailures,
+		"reloads":                report.Reloads,
+		"reload_failures":        report.ReloadFailures,
+		"pending_reload_zones":   report.PendingReloadZones,
+		"notifications_dropped":  report.NotificationsDropped,
+		"reload_targets":         len(report.ReloadTargets),
+		"exit_error":             report.ExitError,
+	}).Info("Shutdown report")
+
+	if r.config.ShutdownWebhookURL == "" {
+		return
+	}
+	if err := postShutdownReport(r.config.ShutdownWebhookURL, report); err != nil {
+		r.logger.WithError(err).Warn("Failed to deliver shutdown report")
+	}
+}
+
+func postShutdownReport(url string, report ShutdownReport) error {
+	// The run context is already cancelled at this point.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return postJSON(ctx, url, report)
+}
+
+// postJSON posts v as JSON to url, treating any non-2xx response as an error.
+func postJSON(ctx context.Context, url string, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// signerExternal is the X-DNSSEC-SIGNER value that has a zone signed by
+// DNSSEC_SIGNER_COMMAND instead of with the reloader's own keys.
+const signerExternal = "external"
+
+// signerAdded are the types an external signer may add to a zone. Anything
+// else it adds or changes fails the zone.
+var signerAdded = map[uint16]bool{
+	dns.TypeRRSIG: true, dns.TypeNSEC: true, dns.TypeNSEC3: true, dns.TypeNSEC3PARAM: true,
+	dns.TypeDNSKEY: true, dns.TypeCDS: true, dns.TypeCDNSKEY: true, dns.TypeZONEMD: true,
+}
+
+// SignerData is the data passed to the DNSSEC_SIGNER_COMMAND template.
+// ZoneFile is a temporary copy of the unsigned zone, also given on stdin.
+type SignerData struct {
+	Zone     string
+	ZoneFile string
+}
+
+// externalSign pipes the unsigned zone through DNSSEC_SIGNER_COMMAND, for
+// keys held where the reloader cannot sign with them, such as an HSM. The
+// command writes the signed zone to stdout, for dnssec-signzone with
+// "-f -". The output must hold every record given to it unchanged and its
+// signatures must verify; what it adds becomes the signature section, so
+// the file keeps the layout of a zone signed in-process.
+func (r *Reloader) externalSign(ctx context.Context, domain Domain, content string, now time.Time) (string, error) {
+	if r.config.DNSSECSignerCommand == "" {
+		return "", fmt.Errorf("%s is %s but DNSSEC_SIGNER_COMMAND is not set", metaDNSSECSigner, signerExternal)
+	}
+	tmpl, err := template.New("signer").Option("missingkey=error").Parse(r.config.DNSSECSignerCommand)
+	if err != nil {
+		return "", fmt.Errorf("invalid DNSSEC_SIGNER_COMMAND template: %w", err)
+	}
+
+	unsigned, err := os.CreateTemp("", "db."+domain.Name+".*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(unsigned.Name())
+	if _, err := unsigned.WriteString(content); err != nil {
+		unsigned.Close()
+		return "", err
+	}
+	if err := unsigned.Close(); err != nil {
+		return "", err
+	}
+
+	var command strings.Builder
+	if err := tmpl.Execute(&command, SignerData{Zone: domain.Name, ZoneFile: unsigned.Name()}); err != nil {
+		return "", fmt.Errorf("failed to render DNSSEC_SIGNER_COMMAND: %w", err)
+	}
+	if r.config.DNSSECSignerTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.config.DNSSECSignerTimeout)
+		defer cancel()
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command.String())
+	cmd.Stdin = strings.NewReader(content)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("signer command failed: %w (output: %s)", err, strings.TrimSpace(stderr.String()))
+	}
+
+	added, expires, err := checkSignedZone(domain.Name, content, stdout.String(), now)
+	if err != nil {
+		return "", fmt.Errorf("signer output rejected: %w", err)
+	}
+	var section strings.Builder
+	section.WriteString("\n" + signaturesMarker + expires.UTC().Format(time.RFC3339) + ", " + signingState(domain) + "\n")
+	for _, rr := range added {
+		section.WriteString(rr.String() + "\n")
+	}
+	return section.String(), nil
+}
+
+// checkSignedZone compares a signer's output with the zone it was given and
+// returns the records it added and when the first of its signatures
+// expires. The SOA must be signed and every signature must verify against
+// a DNSKEY in the output and be valid at now.
+func checkSignedZone(zone, unsigned, signed string, now time.Time) ([]dns.RR, time.Time, error) {
+	in, err := parseZone(strings.NewReader(unsigned), zone, "unsigned")
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	out, err := parseZone(strings.NewReader(signed), zone, "signed")
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	// Records are compared without their TTL, which RRSIGs carry anyway.
+	key := func(rr dns.RR) string {
+		header := *rr.Header()
+		header.Name = strings.ToLower(header.Name)
+		header.Ttl = 0
+		return header.String() + rdataString(rr)
+	}
+	given := make(map[string]bool, len(in))
+	for _, rr := range in {
+		given[key(rr)] = true
+	}
+
+	var added []dns.RR
+	sets := make(map[string][]dns.RR)
+	keys := make(map[uint16][]*dns.DNSKEY)
+	var sigs []*dns.RRSIG
+	for _, rr := range out {
+		header := rr.Header()
+		if given[key(rr)] {
+			delete(given, key(rr))
+		} else if !signerAdded[header.Rrtype] {
+			return nil, time.Time{}, fmt.Errorf("signer added or changed %s %s", header.Name, dns.TypeToString[header.Rrtype])
+		} else {
+			added = append(added, rr)
+		}
+		switch rr := rr.(type) {
+		case *dns.RRSIG:
+			sigs = append(sigs, rr)
+			continue
+		case *dns.DNSKEY:
+			keys[rr.KeyTag()] = append(keys[rr.KeyTag()], rr)
+		}
+		set := strings.ToLower(header.Name) + " " + dns.TypeToString[header.Rrtype]
+		sets[set] = append(sets[set], rr)
+	}
+	for missing := range given {
+		return nil, time.Time{}, fmt.Errorf("signer dropped %s", strings.TrimSpace(missing))
+	}
+
+	apex := dns.Fqdn(strings.ToLower(zone))
+	var expires time.Time
+	soaSigned := false
+	for _, sig := range sigs {
+		rrset := sets[strings.ToLower(sig.Hdr.Name)+" "+dns.TypeToString[sig.TypeCovered]]
+		verified := false
+		for _, dnskey := range keys[sig.KeyTag] {
+			if sig.Verify(dnskey, rrset) == nil {
+				verified = true
+				break
+			}
+		}
+		if !verified {
+			return nil, time.Time{}, fmt.Errorf("signature over %s %s by key %d does not verify", sig.Hdr.Name, dns.TypeToString[sig.TypeCovered], sig.KeyTag)
+		}
+		if !sig.ValidityPeriod(now) {
+			return nil, time.Time{}, fmt.Errorf("signature over %s %s is not valid now", sig.Hdr.Name, dns.TypeToString[sig.TypeCovered])
+		}
+		expiration := time.Unix(int64(sig.Expiration), 0)
+		if expires.IsZero() || expiration.Before(expires) {
+			expires = expiration
+		}
+		soaSigned = soaSigned || (sig.TypeCovered == dns.TypeSOA && strings.EqualFold(sig.Hdr.Name, apex))
+	}
+	if !soaSigned {
+		return nil, time.Time{}, fmt.Errorf("SOA is not signed")
+	}
+	return added, expires, nil
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// signaturesMarker starts the section a signed zone file ends with. The
+// rest of the line is when the signatures expire and, after a comma, what
+// they were made with, from signingState.
+const signaturesMarker = "; DNSSEC signatures, expiring "
+
+// signingKey is a DNSKEY with the private key that signs for it.
+type signingKey struct {
+	DNSKEY *dns.DNSKEY
+	Signer crypto.Signer
+}
+
+// splitSignatures removes the signature section from zone file content and
+// returns the unsigned content, when the signatures expire, zero for an
+// unsigned file, and the signingState they were made in.
+func splitSignatures(content string) (string, time.Time, string) {
+	i := strings.Index(content, "\n"+signaturesMarker)
+	if i < 0 {
+		return content, time.Time{}, ""
+	}
+	line, _, _ := strings.Cut(content[i+1+len(signaturesMarker):], "\n")
+	expiry, state, _ := strings.Cut(line, ",")
+	expires, err := time.Parse(time.RFC3339, strings.TrimSpace(expiry))
+	if err != nil {
+		// Anything unreadable counts as expired, so the zone is re-signed.
+		expires = time.Unix(1, 0)
+	}
+	return content[:i], expires, strings.TrimSpace(state)
+}
+
+// signingKeys returns the keys that sign domain: its active cryptokeys or,
+// when it has none, the K<zone>.+<alg>+<tag> key files in
+// DNSSEC_KEY_DIRECTORY.
+func (r *Reloader) signingKeys(domain Domain) ([]signingKey, error) {
+	var keys []signingKey
+	for _, key := range domain.Keys {
+		if !key.Active {
+			continue
+		}
+		private, err := key.DNSKEY.ReadPrivateKey(strings.NewReader(key.Private), fmt.Sprintf("cryptokey %d", key.ID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cryptokey %d: %w", key.ID, err)
+		}
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("cryptokey %d cannot sign", key.ID)
+		}
+		keys = append(keys, signingKey{DNSKEY: key.DNSKEY, Signer: signer})
+	}
+	if len(keys) > 0 || r.config.DNSSECKeyDirectory == "" {
+		return keys, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(r.config.DNSSECKeyDirectory, "K"+domain.Name+".+*.key"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		key, err := readKeyFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// splitKeys divides keys into key-signing and zone-signing keys. When only
+// one kind is there it serves as both.
+func splitKeys(keys []signingKey) (ksks, zsks []signingKey) {
+	for _, key := range keys {
+		if key.DNSKEY.Flags&dns.SEP != 0 {
+			ksks = append(ksks, key)
+		} else {
+			zsks = append(zsks, key)
+		}
+	}
+	if len(zsks) == 0 {
+		zsks = ksks
+	}
+	if len(ksks) == 0 {
+		ksks = zsks
+	}
+	return ksks, zsks
+}
+
+// readKeyFiles reads a dnssec-keygen key pair: the DNSKEY from path and the
+// private key from the .private file next to it.
+func readKeyFiles(path string) (signingKey, error) {
+	public, err := os.Open(path)
+	if err != nil {
+		return signingKey{}, err
+	}
+	defer public.Close()
+	rr, err := dns.ReadRR(public, path)
+	if err != nil {
+		return signingKey{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	dnskey, ok := rr.(*dns.DNSKEY)
+	if !ok {
+		return signingKey{}, fmt.Errorf("%s does not hold a DNSKEY", path)
+	}
+
+	privatePath := strings.TrimSuffix(path, ".key") + ".private"
+	f, err := os.Open(privatePath)
+	if err != nil {
+		return signingKey{}, err
+	}
+	defer f.Close()
+	private, err := dnskey.ReadPrivateKey(f, privatePath)
+	if err != nil {
+		return signingKey{}, fmt.Errorf("failed to read %s: %w", privatePath, err)
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return signingKey{}, fmt.Errorf("%s cannot sign", privatePath)
+	}
+	return signingKey{DNSKEY: dnskey, Signer: signer}, nil
+}
+
+// signZone signs rendered zone content and returns the signature section to
+// append to it: the DNSKEYs of signing keys the zone does not publish yet,
+// CDS and CDNSKEY records for the key-signing keys, an NSEC chain, or an NSEC3 one with NSEC3PARAM metadata, and the RRSIGs,
+// valid for DNSSEC_SIGNATURE_VALIDITY.
+// Key-signing keys sign the DNSKEY RRset and zone-signing keys the rest; a
+// zone with only one kind uses it for both. Delegations get their DS and
+// NSEC records signed and glue below them is left alone, as RFC 4035 has
+// it.
+func (r *Reloader) signZone(domain Domain, content string, now time.Time) (string, error) {
+	keys, err := r.signingKeys(domain)
+	if err != nil {
+		return "", err
+	}
+	if len(keys) == 0 {
+		return "", errors.New("no active DNSSEC keys")
+	}
+	ksks, zsks := splitKeys(keys)
+
+	rrs, err := parseZone(strings.NewReader(content), domain.Name, "db."+domain.Name)
+	if err != nil {
+		return "", err
+	}
+	apex := dns.Fqdn(strings.ToLower(domain.Name))
+	sets := make(map[string]map[uint16][]dns.RR)
+	var soa *dns.SOA
+	for _, rr := range rrs {
+		header := rr.Header()
+		switch header.Rrtype {
+		case dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3, dns.TypeNSEC3PARAM:
+			continue
+		case dns.TypeSOA:
+			soa = rr.(*dns.SOA)
+		}
+		header.Name = strings.ToLower(header.Name)
+		if sets[header.Name] == nil {
+			sets[header.Name] = make(map[uint16][]dns.RR)
+		}
+		sets[header.Name][header.Rrtype] = append(sets[header.Name][header.Rrtype], rr)
+	}
+	if soa == nil {
+		return "", errors.New("zone has no SOA record")
+	}
+
+	var section strings.Builder
+	for _, key := range keys {
+		published := false
+		for _, rr := range sets[apex][dns.TypeDNSKEY] {
+			if rr.(*dns.DNSKEY).PublicKey == key.DNSKEY.PublicKey {
+				published = true
+				break
+			}
+		}
+		if !published {
+			dnskey := *key.DNSKEY
+			dnskey.Hdr = dns.RR_Header{Name: apex, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: soa.Hdr.Ttl}
+			sets[apex][dns.TypeDNSKEY] = append(sets[apex][dns.TypeDNSKEY], &dnskey)
+			section.WriteString(dnskey.String() + "\n")
+		}
+	}
+
+	// CDS and CDNSKEY (RFC 7344) let the parent pick key changes up by
+	// itself, unless the zone already publishes its own.
+	if sets[apex][dns.TypeCDS] == nil && sets[apex][dns.TypeCDNSKEY] == nil {
+		for _, key := range parentKeys(domain, ksks) {
+			cdnskey := key.ToCDNSKEY()
+			cdnskey.Hdr = dns.RR_Header{Name: apex, Rrtype: dns.TypeCDNSKEY, Class: dns.ClassINET, Ttl: soa.Hdr.Ttl}
+			sets[apex][dns.TypeCDNSKEY] = append(sets[apex][dns.TypeCDNSKEY], cdnskey)
+			section.WriteString(cdnskey.String() + "\n")
+			if ds := key.ToDS(dns.SHA256); ds != nil {
+				cds := ds.ToCDS()
+				cds.Hdr = dns.RR_Header{Name: apex, Rrtype: dns.TypeCDS, Class: dns.ClassINET, Ttl: soa.Hdr.Ttl}
+				sets[apex][dns.TypeCDS] = append(sets[apex][dns.TypeCDS], cds)
+				section.WriteString(cds.String() + "\n")
+			}
+		}
+	}
+
+	var cuts []string
+	for name, types := range sets {
+		if _, ok := types[dns.TypeNS]; ok && name != apex {
+			cuts = append(cuts, name)
+		}
+	}
+	var names []string
+	for name := range sets {
+		glue := false
+		for _, cut := range cuts {
+			if name != cut && dns.IsSubDomain(cut, name) {
+				glue = true
+				break
+			}
+		}
+		if !glue {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return canonicalLess(names[i], names[j]) })
+
+	nsecTTL := min(soa.Hdr.Ttl, soa.Minttl)
+	inception := now.Add(-time.Hour)
+	expiration := now.Add(r.config.DNSSECSignatureValidity)
+	sign := func(rrset []dns.RR, signers []signingKey) error {
+		for _, key := range signers {
+			sig := &dns.RRSIG{
+				Hdr:        dns.RR_Header{Name: rrset[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: rrset[0].Header().Ttl},
+				Algorithm:  key.DNSKEY.Algorithm,
+				SignerName: apex,
+				KeyTag:     key.DNSKEY.KeyTag(),
+				Inception:  uint32(inception.Unix()),
+				Expiration: uint32(expiration.Unix()),
+			}
+			if err := sig.Sign(key.Signer, rrset); err != nil {
+				return fmt.Errorf("failed to sign %s %s: %w", rrset[0].Header().Name, dns.TypeToString[rrset[0].Header().Rrtype], err)
+			}
+			section.WriteString(sig.String() + "\n")
+		}
+		return nil
+	}
+
+	var denial []dns.RR
+	if param := domain.Options.NSEC3; param != nil {
+		nsec3param := *param
+		nsec3param.Hdr = dns.RR_Header{Name: apex, Rrtype: dns.TypeNSEC3PARAM, Class: dns.ClassINET}
+		// The opt-out flag belongs in the NSEC3 records only (RFC 5155).
+		nsec3param.Flags = 0
+		sets[apex][dns.TypeNSEC3PARAM] = []dns.RR{&nsec3param}
+		section.WriteString(nsec3param.String() + "\n")
+		denial = nsec3Chain(apex, names, sets, param, nsecTTL)
+	} else {
+		denial = nsecChain(apex, names, sets, nsecTTL)
+	}
+	for _, rr := range denial {
+		section.WriteString(rr.String() + "\n")
+		if err := sign([]dns.RR{rr}, zsks); err != nil {
+			return "", err
+		}
+	}
+
+	for _, name := range names {
+		types := sets[name]
+		delegation := isDelegation(apex, name, types)
+		rrtypes := make([]uint16, 0, len(types))
+		for rrtype := range types {
+			if !delegation || rrtype == dns.TypeDS {
+				rrtypes = append(rrtypes, rrtype)
+			}
+		}
+		sort.Slice(rrtypes, func(a, b int) bool { return rrtypes[a] < rrtypes[b] })
+		for _, rrtype := range rrtypes {
+			signers := zsks
+			if rrtype == dns.TypeDNSKEY {
+				signers = ksks
+			}
+			if err := sign(types[rrtype], signers); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return "\n" + signaturesMarker + expiration.UTC().Format(time.RFC3339) + ", " + signingState(domain) + "\n" + section.String(), nil
+}
+
+// isDelegation reports whether name is a zone cut below apex: it has NS
+// records, which are then the child's and stay unsigned.
+func isDelegation(apex, name string, types map[uint16][]dns.RR) bool {
+	_, ok := types[dns.TypeNS]
+	return ok && name != apex
+}
+
+// bitmap lists the types that exist at name, as NSEC and NSEC3 records
+// give them: only NS and DS at a delegation.
+func bitmap(apex, name string, types map[uint16][]dns.RR) []uint16 {
+	delegation := isDelegation(apex, name, types)
+	var rrtypes []uint16
+	for rrtype := range types {
+		if delegation && rrtype != dns.TypeNS && rrtype != dns.TypeDS {
+			continue
+		}
+		rrtypes = append(rrtypes, rrtype)
+	}
+	return rrtypes
+}
+
+// nsecChain links names, in canonical order, with NSEC records.
+func nsecChain(apex string, names []string, sets map[string]map[uint16][]dns.RR, ttl uint32) []dns.RR {
+	chain := make([]dns.RR, 0, len(names))
+	for i, name := range names {
+		nsec := &dns.NSEC{
+			Hdr:        dns.RR_Header{Name: name, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: ttl},
+			NextDomain: names[(i+1)%len(names)],
+			TypeBitMap: append(bitmap(apex, name, sets[name]), dns.TypeRRSIG, dns.TypeNSEC),
+		}
+		slices.Sort(nsec.TypeBitMap)
+		chain = append(chain, nsec)
+	}
+	return chain
+}
+
+// nsec3Chain links the hashes of names with NSEC3 records, as RFC 5155
+// section 7.1 has it. Empty non-terminals get a record of their own; with
+// opt-out, delegations without DS are left out, along with the empty
+// non-terminals only they needed.
+func nsec3Chain(apex string, names []string, sets map[string]map[uint16][]dns.RR, param *dns.NSEC3PARAM, ttl uint32) []dns.RR {
+	optOut := param.Flags&1 != 0
+	owners := make(map[string][]uint16)
+	for _, name := range names {
+		types := sets[name]
+		if optOut && isDelegation(apex, name, types) && types[dns.TypeDS] == nil {
+			continue
+		}
+		rrtypes := bitmap(apex, name, types)
+		if !isDelegation(apex, name, types) || types[dns.TypeDS] != nil {
+			rrtypes = append(rrtypes, dns.TypeRRSIG)
+		}
+		owners[name] = rrtypes
+		for parent := name; parent != apex; {
+			i, _ := dns.NextLabel(parent, 0)
+			parent = parent[i:]
+			if _, ok := owners[parent]; ok || parent == apex {
+				break
+			}
+			if _, ok := sets[parent]; !ok {
+				owners[parent] = nil
+			}
+		}
+	}
+
+	type hashed struct {
+		hash  string
+		types []uint16
+	}
+	hashes := make([]hashed, 0, len(owners))
+	for name, types := range owners {
+		hashes = append(hashes, hashed{dns.HashName(name, param.Hash, param.Iterations, param.Salt), types})
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i].hash < hashes[j].hash })
+
+	chain := make([]dns.RR, 0, len(hashes))
+	for i, h := range hashes {
+		slices.Sort(h.types)
+		next := hashes[(i+1)%len(hashes)].hash
+		chain = append(chain, &dns.NSEC3{
+			Hdr:        dns.RR_Header{Name: strings.ToLower(h.hash) + "." + apex, Rrtype: dns.TypeNSEC3, Class: dns.ClassINET, Ttl: ttl},
+			Hash:       param.Hash,
+			Flags:      param.Flags,
+			Iterations: param.Iterations,
+			SaltLength: param.SaltLength,
+			Salt:       param.Salt,
+			HashLength: uint8(len(next) * 5 / 8),
+			NextDomain: next,
+			TypeBitMap: h.types,
+		})
+	}
+	return chain
+}
+
+// signingState sums up what domain's signatures depend on besides its
+// records: the denial method and the tags of the active cryptokeys and of
+// those with a DS at the parent, or only that the signer is external. It is recorded after the expiry in the
+// signatures marker, so a zone is re-signed when any of it changes.
+func signingState(domain Domain) string {
+	if domain.Options.Signer == signerExternal {
+		return signerExternal
+	}
+	state := "NSEC"
+	if param := domain.Options.NSEC3; param != nil {
+		salt := param.Salt
+		if salt == "" {
+			salt = "-"
+		}
+		state = fmt.Sprintf("NSEC3 %d %d %d %s", param.Hash, param.Flags, param.Iterations, salt)
+	}
+	var active, parentDS []string
+	for _, key := range domain.Keys {
+		if key.Active {
+			active = append(active, fmt.Sprint(key.DNSKEY.KeyTag()))
+		}
+		if key.ParentDS {
+			parentDS = append(parentDS, fmt.Sprint(key.DNSKEY.KeyTag()))
+		}
+	}
+	if len(active) > 0 {
+		state += ", keys " + strings.Join(active, " ")
+	}
+	if len(parentDS) > 0 {
+		state += ", ds " + strings.Join(parentDS, " ")
+	}
+	return state
+}
+
+// canonicalLess orders names as RFC 4034 section 6.1 does: label by label
+// from the right, case-insensitively.
+func canonicalLess(a, b string) bool {
+	la, lb := dns.SplitDomainName(a), dns.SplitDomainName(b)
+	for i, j := len(la)-1, len(lb)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if c := strings.Compare(strings.ToLower(la[i]), strings.ToLower(lb[j])); c != 0 {
+			return c < 0
+		}
+	}
+	return len(la) < len(lb)
+}
+
+// refreshSignatures regenerates zones once an hour, which re-signs those
+// whose signatures expire within DNSSEC_REFRESH. Called on every listener
+// or polling tick.
+func (r *Reloader) refreshSignatures() {
+	if time.Since(r.lastResign) < time.Hour {
+		return
+	}
+	r.lastResign = time.Now()
+	signing := false
+	r.zoneOptsMu.RLock()
+	for _, opts := range r.zoneOpts {
+		signing = signing || opts.Sign
+	}
+	r.zoneOptsMu.RUnlock()
+	if !signing {
+		return
+	}
+
+	change := &DNSChangeNotification{
+		Table:     "dnssec",
+		Action:    "RESIGN",
+		Timestamp: time.Now(),
+	}
+	if err := r.triggerCoreReload(r.ctx, change); err != nil {
+		r.logger.WithError(err).Error("Failed to refresh DNSSEC signatures")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPNotifier emails alerts whose kind is in ALERT_EMAIL_KINDS. Security is
+// "starttls" (upgrade when offered), "tls" (implicit TLS, usually port 465)
+// or "none".
+type SMTPNotifier struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+	to       []string
+	security string
+	kinds    map[string]bool
+}
+
+func NewSMTPNotifier(config *Config) (*SMTPNotifier, error) {
+	if config.SMTPFrom == "" {
+		return nil, fmt.Errorf("SMTP_FROM is required for email alerts")
+	}
+	switch config.SMTPSecurity {
+	case "starttls", "tls", "none":
+	default:
+		return nil, fmt.Errorf("unknown SMTP_SECURITY %q", config.SMTPSecurity)
+	}
+
+	kinds := make(map[string]bool, len(config.AlertEmailKinds))
+	for _, kind := range config.AlertEmailKinds {
+		kinds[kind] = true
+	}
+	return &SMTPNotifier{
+		host:     config.SMTPHost,
+		port:     config.SMTPPort,
+		username: config.SMTPUsername,
+		password: config.SMTPPassword,
+		from:     config.SMTPFrom,
+		to:       config.AlertEmailTo,
+		security: config.SMTPSecurity,
+		kinds:    kinds,
+	}, nil
+}
+
+func (s *SMTPNotifier) Name() string {
+	return "smtp"
+}
+
+func (s *SMTPNotifier) Notify(ctx context.Context, alert Alert) error {
+	if len(s.kinds) > 0 && !s.kinds[alert.Kind] {
+		return nil
+	}
+
+	addr := net.JoinHostPort(s.host, s.port)
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	tlsConfig := &tls.Config{ServerName: s.host}
+	if s.security == "tls" {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp handshake failed: %w", err)
+	}
+	defer client.Close()
+
+	if s.security == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("starttls failed: %w", err)
+			}
+		}
+	}
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("smtp auth failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(s.from); err != nil {
+		return fmt.Errorf("smtp MAIL FROM failed: %w", err)
+	}
+	for _, rcpt := range s.to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp RCPT TO %s failed: %w", rcpt, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA failed: %w", err)
+	}
+	if _, err := w.Write(s.message(alert)); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return client.Quit()
+}
+
+func (s *SMTPNotifier) message(alert Alert) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", strings.NewReplacer("\r", " ", "\n", " ").Replace(alert.Summary()))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(alertText(alert), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// softDeletes is set from SOFT_DELETE. It is global rather than per
+// connection because GORM caches each model's clauses for the life of the
+// process.
+var softDeletes atomic.Bool
+
+// SoftDeletedAt is the deleted_at column of tools that soft delete records
+// and domains. While SOFT_DELETE is off it is never written and adds no
+// conditions, so schemas without the column keep working. While it is on,
+// queries skip deleted rows and deletes set the column instead of removing
+// the row, just like gorm.DeletedAt.
+type SoftDeletedAt sql.NullTime
+
+func (n *SoftDeletedAt) Scan(value interface{}) error {
+	return (*sql.NullTime)(n).Scan(value)
+}
+
+func (n SoftDeletedAt) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Time, nil
+}
+
+func (SoftDeletedAt) QueryClauses(f *schema.Field) []clause.Interface {
+	return []clause.Interface{softDeleteClause[gorm.SoftDeleteQueryClause]{gorm.SoftDeleteQueryClause{Field: f}}}
+}
+
+func (SoftDeletedAt) UpdateClauses(f *schema.Field) []clause.Interface {
+	return []clause.Interface{softDeleteClause[gorm.SoftDeleteUpdateClause]{gorm.SoftDeleteUpdateClause{Field: f}}}
+}
+
+func (SoftDeletedAt) DeleteClauses(f *schema.Field) []clause.Interface {
+	return []clause.Interface{softDeleteClause[gorm.SoftDeleteDeleteClause]{gorm.SoftDeleteDeleteClause{Field: f}}}
+}
+
+// softDeleteClause applies one of GORM's soft delete clauses only while
+// soft deletes are enabled.
+type softDeleteClause[C interface {
+	clause.Interface
+	ModifyStatement(*gorm.Statement)
+}] struct {
+	clause C
+}
+
+func (c softDeleteClause[C]) Name() string                  { return c.clause.Name() }
+func (c softDeleteClause[C]) Build(b clause.Builder)        { c.clause.Build(b) }
+func (c softDeleteClause[C]) MergeClause(cl *clause.Clause) { c.clause.MergeClause(cl) }
+
+func (c softDeleteClause[C]) ModifyStatement(stmt *gorm.Statement) {
+	if softDeletes.Load() {
+		c.clause.ModifyStatement(stmt)
+	}
+}
+
+// enableSoftDeletes turns on SOFT_DELETE after checking that both tables
+// have the deleted_at column.
+func enableSoftDeletes(db *gorm.DB) error {
+	for _, model := range []schema.Tabler{&Record{}, &Domain{}} {
+		if !db.Migrator().HasColumn(model, "deleted_at") {
+			return fmt.Errorf("SOFT_DELETE is enabled but the %s table has no deleted_at column", model.TableName())
+		}
+	}
+	softDeletes.Store(true)
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHBackend runs the reload command on remote nameservers, optionally
+// rsyncing the zones directory to each host first.
+type SSHBackend struct {
+	hosts          []string
+	user           string
+	command        string
+	keyFile        string
+	knownHosts     string
+	syncDir        string
+	zonesDirectory string
+	clientConfig   *ssh.ClientConfig
+	tracker        *targetTracker
+}
+
+func NewSSHBackend(config *Config) (*SSHBackend, error) {
+	if len(config.SSHHosts) == 0 {
+		return nil, fmt.Errorf("SSH_HOSTS is required for the ssh backend")
+	}
+
+	key, err := os.ReadFile(config.SSHKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ssh key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ssh key: %w", err)
+	}
+
+	hostKeyCallback, err := knownhosts.New(config.SSHKnownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts: %w", err)
+	}
+
+	return &SSHBackend{
+		hosts:          config.SSHHosts,
+		user:           config.SSHUser,
+		command:        config.SSHReloadCommand,
+		keyFile:        config.SSHKeyFile,
+		knownHosts:     config.SSHKnownHosts,
+		syncDir:        config.SSHSyncDir,
+		zonesDirectory: config.ZonesDirectory,
+		clientConfig: &ssh.ClientConfig{
+			User:            config.SSHUser,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         10 * time.Second,
+		},
+		tracker: newTargetTracker(),
+	}, nil
+}
+
+func (s *SSHBackend) Name() string {
+	return "ssh"
+}
+
+func (s *SSHBackend) Reload(ctx context.Context, zones []string) error {
+	return s.tracker.reloadAll(ctx, s.hosts, func(ctx context.Context, host string) error {
+		if s.syncDir != "" {
+			if err := s.rsync(ctx, host); err != nil {
+				return err
+			}
+		}
+		return s.run(ctx, host)
+	})
+}
+
+func (s *SSHBackend) Targets() []TargetResult {
+	return s.tracker.Targets()
+}
+
+func (s *SSHBackend) run(ctx context.Context, host string) error {
+	addr := host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, s.clientConfig)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("ssh handshake failed: %w", err)
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open ssh session: %w", err)
+	}
+	defer session.Close()
+
+	var output bytes.Buffer
+	session.Stdout = &output
+	session.Stderr = &output
+
+	done := make(chan error, 1)
+	go func() { done <- session.Run(s.command) }()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("remote reload command failed: %w (output: %s)", err, strings.TrimSpace(output.String()))
+		}
+		return nil
+	}
+}
+
+func (s *SSHBackend) rsync(ctx context.Context, host string) error {
+	hostname, port := host, "22"
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		hostname, port = h, p
+	}
+
+	sshCommand := fmt.Sprintf("ssh -p %s -i %s -o UserKnownHostsFile=%s -o StrictHostKeyChecking=yes -o BatchMode=yes",
+		port, s.keyFile, s.knownHosts)
+	cmd := exec.CommandContext(ctx, "rsync", "-a", "--delete", "--exclude", "*.tmp",
+		"-e", sshCommand,
+		strings.TrimSuffix(s.zonesDirectory, "/")+"/",
+		fmt.Sprintf("%s@%s:%s/", s.user, hostname, strings.TrimSuffix(s.syncDir, "/")))
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("rsync failed: %w (output: %s)", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ZoneStatus is the last known state of one zone as served by /status.
+type ZoneStatus struct {
+	Zone              string     `json:"zone"`
+	LastGenerated     time.Time  `json:"last_generated"`
+	LastChanged       *time.Time `json:"last_changed,omitempty"`
+	Serial            string     `json:"serial,omitempty"`
+	Records           int        `json:"records"`
+	Hash              string     `json:"hash,omitempty"`
+	GenerationError   string     `json:"generation_error,omitempty"`
+	LastReload        *time.Time `json:"last_reload,omitempty"`
+	LastReloadResult  string     `json:"last_reload_result,omitempty"`
+	LastReloadError   string     `json:"last_reload_error,omitempty"`
+	PendingReloadFrom *time.Time `json:"pending_reload_since,omitempty"`
+	Drift             bool       `json:"drift"`
+}
+
+// statusTracker is shared between the change loop and the HTTP server.
+type statusTracker struct {
+	mu    sync.Mutex
+	mode  string
+	zones map[string]*ZoneStatus
+
+	lastReload       time.Time
+	lastReloadResult string
+	lastReloadError  string
+}
+
+func newStatusTracker() *statusTracker {
+	return &statusTracker{mode: "starting", zones: make(map[string]*ZoneStatus)}
+}
+
+func (s *statusTracker) setMode(mode string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mode = mode
+}
+
+func (s *statusTracker) currentMode() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mode
+}
+
+func (s *statusTracker) zone(name string) *ZoneStatus {
+	zs, ok := s.zones[name]
+	if !ok {
+		zs = &ZoneStatus{Zone: name}
+		s.zones[name] = zs
+	}
+	return zs
+}
+
+func (s *statusTracker) recordGeneration(name string, zone generatedZone, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	zs := s.zone(name)
+	zs.LastGenerated = now
+	if err != nil {
+		zs.GenerationError = err.Error()
+		return
+	}
+	zs.GenerationError = ""
+	zs.Serial = zone.Serial
+	zs.Records = zone.Records
+	zs.Hash = zone.Hash
+	if zone.Changed {
+		zs.LastChanged = &now
+	}
+}
+
+// serial returns the serial zone was last generated with.
+func (s *statusTracker) serial(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if zs, ok := s.zones[name]; ok {
+		return zs.Serial
+	}
+	return ""
+}
+
+func (s *statusTracker) recordDrift(name string, drift bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.zone(name).Drift = drift
+}
+
+// retainZones drops zones whose domain no longer exists.
+func (s *statusTracker) retainZones(names []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keep := make(map[string]bool, len(names))
+	for _, name := range names {
+		keep[name] = true
+	}
+	for name := range s.zones {
+		if !keep[name] {
+			delete(s.zones, name)
+		}
+	}
+}
+
+func (s *statusTracker) recordReload(zones []string, pending *pendingReload, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	result, message := "success", ""
+	if err != nil {
+		result, message = "failed", err.Error()
+	}
+	s.lastReload, s.lastReloadResult, s.lastReloadError = now, result, message
+
+	for _, name := range zones {
+		zs := s.zone(name)
+		zs.LastReload = &now
+		zs.LastReloadResult = result
+		zs.LastReloadError = message
+		zs.PendingReloadFrom = nil
+		if pending != nil {
+			since := pending.since
+			zs.PendingReloadFrom = &since
+		}
+	}
+}
+
+func (r *Reloader) handleStatus(w http.ResponseWriter, req *http.Request) {
+	database := map[string]interface{}{"connected": false}
+	if r.storage != nil {
+		database["driver"] = r.storage.Name()
+	}
+	if r.rawDB != nil {
+		ctx, cancel := context.WithTimeout(req.Context(), 2*time.Second)
+		defer cancel()
+		if err := r.rawDB.PingContext(ctx); err != nil {
+			database["error"] = err.Error()
+		} else {
+			database["connected"] = true
+		}
+	}
+
+	r.status.mu.Lock()
+	zones := make([]ZoneStatus, 0, len(r.status.zones))
+	for _, zs := range r.status.zones {
+		zones = append(zones, *zs)
+	}
+	reload := map[string]interface{}{"backend": r.backendName()}
+	if !r.status.lastReload.IsZero() {
+		reload["last_reload"] = r.status.lastReload
+		reload["result"] = r.status.lastReloadResult
+		if r.status.lastReloadError != "" {
+			reload["error"] = r.status.lastReloadError
+		}
+	}
+	mode := r.status.mode
+	r.status.mu.Unlock()
+
+	sort.Slice(zones, func(i, j int) bool { return zones[i].Zone < zones[j].Zone })
+
+	status := http.StatusOK
+	if database["connected"] != true {
+		status = http.StatusServiceUnavailable
+	}
+	body := map[string]interface{}{
+		"mode":       mode,
+		"leader":     r.isLeader(),
+		"started_at": r.stats.startedAt,
+		"database":   database,
+		"reload":     reload,
+		"zones":      zones,
+	}
+	if r.config.ShardCount > 1 {
+		body["shard"] = map[string]int{"index": r.config.ShardIndex, "count": r.config.ShardCount}
+	}
+	writeJSON(w, status, body)
+}
+
+func (r *Reloader) backendName() string {
+	if r.backend == nil {
+		return ""
+	}
+	return r.backend.Name()
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// errNotifyUnsupported is returned by setupListener when the storage driver
+// cannot push changes, so the reloader polls instead.
+var errNotifyUnsupported = errors.New("storage driver does not support change notifications")
+
+// Storage is the database holding the domains and records tables. Each
+// driver supplies its GORM dialector and a way to detect changes by polling.
+type Storage interface {
+	Name() string
+	// Host identifies the database in logs and alerts.
+	Host() string
+	Dialector() gorm.Dialector
+	// DetectChanges reports how many records and domains changed since the
+	// previous poll at since.
+	DetectChanges(ctx context.Context, db *gorm.DB, since time.Time) (records, domains int64, err error)
+}
+
+// connLimiter is implemented by drivers that need a smaller connection
+// pool than the default.
+type connLimiter interface {
+	maxOpenConns() int
+}
+
+// configurePool applies the DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS and
+// DB_CONN_MAX_LIFETIME settings, capped by the driver's own limit.
+func configurePool(sqlDB *sql.DB, config *Config, storage Storage) {
+	maxOpen, maxIdle := config.DBMaxOpenConns, config.DBMaxIdleConns
+	if limiter, ok := storage.(connLimiter); ok {
+		maxOpen = limiter.maxOpenConns()
+		maxIdle = maxOpen
+	}
+	sqlDB.SetMaxOpenConns(maxOpen)
+	sqlDB.SetMaxIdleConns(maxIdle)
+	sqlDB.SetConnMaxLifetime(config.DBConnMaxLifetime)
+}
+
+func NewStorage(config *Config) (Storage, error) {
+	switch config.DBDriver {
+	case "postgres", "postgresql":
+		return newPostgresStorage(config)
+	case "mysql", "mariadb":
+		return &MySQLStorage{config: config}, nil
+	case "sqlite", "sqlite3":
+		return &SQLiteStorage{config: config}, nil
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", config.DBDriver)
+	}
+}
+
+// PostgresStorage is the default driver. Changes are pushed with
+// LISTEN/NOTIFY; polling compares created_at and updated_at.
+type PostgresStorage struct {
+	config *Config
+	// url is DATABASE_URL when set, replacing the POSTGRES_* variables.
+	url *url.URL
+	// credentials replace the user and password with ones leased from
+	// Vault.
+	credentials *dbCredentials
+}
+
+func newPostgresStorage(config *Config) (*PostgresStorage, error) {
+	p := &PostgresStorage{config: config}
+	if config.DatabaseURL == "" {
+		return p, nil
+	}
+	u, err := parsePostgresURL("DATABASE_URL", config.DatabaseURL)
+	if err != nil {
+		return nil, err
+	}
+	p.url = u
+	return p, nil
+}
+
+// parsePostgresURL parses the postgres:// URL held in the named variable.
+func parsePostgresURL(name, raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, redactURLError(err))
+	}
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return nil, fmt.Errorf("%s must be a postgres:// URL, got scheme %q", name, u.Scheme)
+	}
+	return u, nil
+}
+
+// redactURLError drops the URL, which may hold a password, from a parse
+// error.
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+func (p *PostgresStorage) Name() string {
+	return "postgres"
+}
+
+func (p *PostgresStorage) Host() string {
+	if p.url != nil {
+		return p.url.Hostname()
+	}
+	return p.config.PostgresHost
+}
+
+func (p *PostgresStorage) Dialector() gorm.Dialector {
+	if p.url != nil {
+		return p.urlDialector(p.url)
+	}
+	dsn := p.conninfo()
+	for _, param := range p.sessionParams() {
+		dsn += " " + param.key + "=" + param.value
+	}
+	return p.open(dsn)
+}
+
+func (p *PostgresStorage) useCredentials(credentials *dbCredentials) {
+	p.credentials = credentials
+}
+
+// open opens dsn, asking for the current Vault credentials on every new
+// connection when there are any. A dsn pgx cannot parse is left to
+// postgres.Open to report.
+func (p *PostgresStorage) open(dsn string) gorm.Dialector {
+	if p.credentials == nil {
+		return postgres.Open(dsn)
+	}
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return postgres.Open(dsn)
+	}
+	db := stdlib.OpenDB(*config, stdlib.OptionBeforeConnect(func(_ context.Context, cc *pgx.ConnConfig) error {
+		cc.User, cc.Password = p.credentials.current()
+		return nil
+	}))
+	return postgres.New(postgres.Config{Conn: db})
+}
+
+// urlDialector opens a postgres:// URL with the same session parameters as
+// the keyword/value connection, unless the URL already sets them.
+func (p *PostgresStorage) urlDialector(u *url.URL) gorm.Dialector {
+	dsn := *u
+	q := dsn.Query()
+	for _, param := range p.sessionParams() {
+		if q.Get(param.key) == "" && q.Get(strings.ToLower(param.key)) == "" {
+			q.Set(param.key, param.value)
+		}
+	}
+	dsn.RawQuery = q.Encode()
+	return p.open(dsn.String())
+}
+
+// sessionParams are the run-time parameters of every GORM connection:
+// UTC timestamps, and DB_STATEMENT_TIMEOUT and DB_LOCK_TIMEOUT in
+// milliseconds when set.
+func (p *PostgresStorage) sessionParams() []struct{ key, value string } {
+	params := []struct{ key, value string }{{"TimeZone", "UTC"}}
+	if p.config.DBStatementTimeout > 0 {
+		params = append(params, struct{ key, value string }{"statement_timeout", strconv.FormatInt(p.config.DBStatementTimeout.Milliseconds(), 10)})
+	}
+	if p.config.DBLockTimeout > 0 {
+		params = append(params, struct{ key, value string }{"lock_timeout", strconv.FormatInt(p.config.DBLockTimeout.Milliseconds(), 10)})
+	}
+	return params
+}
+
+// listenerDSN is the connection string for the LISTEN connection.
+func (p *PostgresStorage) listenerDSN() string {
+	var username, password string
+	if p.credentials != nil {
+		username, password = p.credentials.current()
+	}
+	if p.url != nil {
+		u := *p.url
+		if p.credentials != nil {
+			u.User = url.UserPassword(username, password)
+		}
+		return u.String()
+	}
+	if p.credentials != nil {
+		// Later keywords win in libpq.
+		return p.conninfo() + " user=" + quoteConninfo(username) + " password=" + quoteConninfo(password)
+	}
+	return p.conninfo()
+}
+
+// conninfo builds the libpq keyword/value string shared by the GORM
+// connection and the listener, so both use the same port and TLS settings.
+func (p *PostgresStorage) conninfo() string {
+	params := []struct{ key, value string }{
+		{"host", p.config.PostgresHost},
+		{"port", strconv.Itoa(p.config.PostgresPort)},
+		{"dbname", p.config.PostgresDB},
+		{"user", p.config.PostgresUser},
+		{"password", p.config.PostgresPassword},
+		{"sslmode", p.config.PostgresSSLMode},
+		{"sslcert", p.config.PostgresSSLCert},
+		{"sslkey", p.config.PostgresSSLKey},
+		{"sslrootcert", p.config.PostgresSSLCA},
+	}
+	parts := make([]string, 0, len(params))
+	for _, param := range params {
+		if param.value == "" {
+			continue
+		}
+		parts = append(parts, param.key+"="+quoteConninfo(param.value))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteConninfo quotes a conninfo value containing spaces, quotes or
+// backslashes, as libpq requires.
+func quoteConninfo(v string) string {
+	if !strings.ContainsAny(v, " '\\") {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// DetectChanges counts soft-deleted rows too, and with SOFT_DELETE also
+// looks at deleted_at, so deletes are picked up even if the deleting tool
+// leaves updated_at alone.
+func (p *PostgresStorage) DetectChanges(ctx context.Context, db *gorm.DB, since time.Time) (records, domains int64, err error) {
+	changed := db.Where("updated_at > ? OR created_at > ?", since, since)
+	if softDeletes.Load() {
+		changed = changed.Or("deleted_at > ?", since)
+	}
+	if err := db.WithContext(ctx).Unscoped().Model(&Record{}).Where(changed).Count(&records).Error; err != nil {
+		return 0, 0, fmt.Errorf("failed to check for record changes: %w", err)
+	}
+	if err := db.WithContext(ctx).Unscoped().Model(&Domain{}).Where(changed).Count(&domains).Error; err != nil {
+		return records, 0, fmt.Errorf("failed to check for domain changes: %w", err)
+	}
+	return records, domains, nil
+}
+
+// MySQLStorage serves PowerDNS deployments that keep their records in MySQL
+// or MariaDB. There is no push mechanism, so changes are found by comparing
+// table checksums between polls, which also catches deletes and rows
+// written without timestamps.
+type MySQLStorage struct {
+	config      *Config
+	checksums   map[string]int64
+	credentials *dbCredentials
+}
+
+func (m *MySQLStorage) Name() string {
+	return "mysql"
+}
+
+func (m *MySQLStorage) Host() string {
+	return m.config.MySQLHost
+}
+
+// Dialector allows multiple statements per query so schema migrations,
+// which contain trigger bodies, can run as one file.
+func (m *MySQLStorage) Dialector() gorm.Dialector {
+	dsn := fmt.Sprintf(
+		"%s:%s@tcp(%s:%d)/%s?parseTime=true&loc=UTC&charset=utf8mb4&multiStatements=true",
+		m.config.MySQLUser,
+		m.config.MySQLPassword,
+		m.config.MySQLHost,
+		m.config.MySQLPort,
+		m.config.MySQLDB,
+	)
+	// max_execution_time only limits SELECTs; InnoDB lock waits are whole
+	// seconds.
+	if m.config.DBStatementTimeout > 0 {
+		dsn += fmt.Sprintf("&max_execution_time=%d", m.config.DBStatementTimeout.Milliseconds())
+	}
+	if m.config.DBLockTimeout > 0 {
+		dsn += fmt.Sprintf("&innodb_lock_wait_timeout=%d", max(1, int(m.config.DBLockTimeout.Round(time.Second).Seconds())))
+	}
+	if m.credentials == nil {
+		return mysql.Open(dsn)
+	}
+	config, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		return mysql.Open(dsn)
+	}
+	config.Apply(mysqldriver.BeforeConnect(func(_ context.Context, mc *mysqldriver.Config) error {
+		mc.User, mc.Passwd = m.credentials.current()
+		return nil
+	}))
+	connector, err := mysqldriver.NewConnector(config)
+	if err != nil {
+		return mysql.Open(dsn)
+	}
+	return mysql.New(mysql.Config{DSNConfig: config, Conn: sql.OpenDB(connector)})
+}
+
+func (m *MySQLStorage) useCredentials(credentials *dbCredentials) {
+	m.credentials = credentials
+}
+
+// DetectChanges reports 1 for each table whose checksum moved. The first
+// call only records the baseline.
+func (m *MySQLStorage) DetectChanges(ctx context.Context, db *gorm.DB, since time.Time) (records, domains int64, err error) {
+	rows, err := db.WithContext(ctx).Raw("CHECKSUM TABLE records, domains").Rows()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to checksum tables: %w", err)
+	}
+	defer rows.Close()
+
+	current := make(map[string]int64, 2)
+	for rows.Next() {
+		var table string
+		var checksum *int64
+		if err := rows.Scan(&table, &checksum); err != nil {
+			return 0, 0, fmt.Errorf("failed to read table checksum: %w", err)
+		}
+		if checksum == nil {
+			return 0, 0, fmt.Errorf("table %s has no checksum", table)
+		}
+		current[table] = *checksum
+	}
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("failed to read table checksums: %w", err)
+	}
+
+	previous := m.checksums
+	m.checksums = current
+	if previous == nil {
+		return 0, 0, nil
+	}
+	for table, checksum := range current {
+		if previous[table] == checksum {
+			continue
+		}
+		switch table {
+		case m.config.MySQLDB + ".records":
+			records = 1
+		case m.config.MySQLDB + ".domains":
+			domains = 1
+		}
+	}
+	return records, domains, nil
+}
+
+// SQLiteStorage runs everything from a single database file for edge and
+// lab boxes. Writes made through the API are queued internally; writes by
+// other processes (the sqlite3 shell, a sync job) are found by polling
+// PRAGMA data_version, which moves whenever another connection commits.
+type SQLiteStorage struct {
+	config      *Config
+	dataVersion *int64
+}
+
+func (s *SQLiteStorage) Name() string {
+	return "sqlite"
+}
+
+func (s *SQLiteStorage) Host() string {
+	return s.config.SQLitePath
+}
+
+func (s *SQLiteStorage) Dialector() gorm.Dialector {
+	busyTimeout := 5 * time.Second
+	if s.config.DBLockTimeout > 0 {
+		busyTimeout = s.config.DBLockTimeout
+	}
+	dsn := fmt.Sprintf(
+		"file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)",
+		s.config.SQLitePath,
+		busyTimeout.Milliseconds(),
+	)
+	return sqlite.Open(dsn)
+}
+
+// maxOpenConns is 1: SQLite has a single writer, and data_version is only
+// meaningful when polled on the same connection each time.
+func (s *SQLiteStorage) maxOpenConns() int {
+	return 1
+}
+
+// DetectChanges cannot tell records from domains, so any commit by another
+// process is reported as a record change. The first call only records the
+// baseline.
+func (s *SQLiteStorage) DetectChanges(ctx context.Context, db *gorm.DB, since time.Time) (records, domains int64, err error) {
+	var version int64
+	if err := db.WithContext(ctx).Raw("PRAGMA data_version").Scan(&version).Error; err != nil {
+		return 0, 0, fmt.Errorf("failed to read data version: %w", err)
+	}
+
+	previous := s.dataVersion
+	s.dataVersion = &version
+	if previous == nil || *previous == version {
+		return 0, 0, nil
+	}
+	return 1, 0, nil
+}
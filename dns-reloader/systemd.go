@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// SystemdBackend reloads a CoreDNS unit managed by systemd on the same host,
+// either with `systemctl reload` (which runs the unit's ExecReload) or by
+// sending SIGUSR1 to the unit's main process.
+type SystemdBackend struct {
+	unit string
+	mode string
+}
+
+func NewSystemdBackend(unit, mode string) (*SystemdBackend, error) {
+	if mode != "kill" && mode != "reload" {
+		return nil, fmt.Errorf("unknown systemd reload mode %q", mode)
+	}
+	return &SystemdBackend{unit: unit, mode: mode}, nil
+}
+
+func (s *SystemdBackend) Name() string {
+	return "systemd"
+}
+
+func (s *SystemdBackend) Reload(ctx context.Context, zones []string) error {
+	args := []string{"reload", s.unit}
+	if s.mode == "kill" {
+		args = []string{"kill", "--signal=USR1", "--kill-whom=main", s.unit}
+	}
+
+	cmd := exec.CommandContext(ctx, "systemctl", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s failed: %w (output: %s)", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TargetResult is the outcome of the most recent reload of one target.
+type TargetResult struct {
+	Target      string    `json:"target"`
+	OK          bool      `json:"ok"`
+	Error       string    `json:"error,omitempty"`
+	LastAttempt time.Time `json:"last_attempt"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+}
+
+// TargetReporter is implemented by backends that signal several servers.
+type TargetReporter interface {
+	Targets() []TargetResult
+}
+
+// ReloadTargetsError reports a reload where some targets failed.
+type ReloadTargetsError struct {
+	Total  int
+	Failed []TargetResult
+}
+
+func (e *ReloadTargetsError) Error() string {
+	var failures []string
+	for _, result := range e.Failed {
+		failures = append(failures, fmt.Sprintf("%s: %s", result.Target, result.Error))
+	}
+	return fmt.Sprintf("reloaded %d of %d targets; failed %s",
+		e.Total-len(e.Failed), e.Total, strings.Join(failures, "; "))
+}
+
+// targetTracker signals a set of targets and remembers each one's last result.
+type targetTracker struct {
+	mu      sync.Mutex
+	results map[string]TargetResult
+}
+
+func newTargetTracker() *targetTracker {
+	return &targetTracker{results: make(map[string]TargetResult)}
+}
+
+func (t *targetTracker) reloadAll(ctx context.Context, targets []string, reload func(context.Context, string) error) error {
+	if len(targets) == 0 {
+		return fmt.Errorf("no reload targets found")
+	}
+
+	var failed []TargetResult
+	for _, target := range targets {
+		result := t.record(target, reload(ctx, target))
+		if !result.OK {
+			failed = append(failed, result)
+		}
+	}
+
+	if len(failed) > 0 {
+		return &ReloadTargetsError{Total: len(targets), Failed: failed}
+	}
+	return nil
+}
+
+func (t *targetTracker) record(target string, err error) TargetResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := t.results[target]
+	result.Target = target
+	result.LastAttempt = time.Now()
+	result.OK = err == nil
+	result.Error = ""
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.LastSuccess = result.LastAttempt
+	}
+	t.results[target] = result
+	return result
+}
+
+func (t *targetTracker) Targets() []TargetResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	results := make([]TargetResult, 0, len(t.results))
+	for _, result := range t.results {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Target < results[j].Target })
+	return results
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// systemRoutes act on the whole server rather than on domains, so tokens
+// limited to an account may not use them.
+var systemRoutes = map[string]bool{
+	"GET /api/v1/events":              true,
+	"POST /api/v1/reload":             true,
+	"POST /api/v1/onboarding":         true,
+	"GET /api/v1/tsig-keys":           true,
+	"POST /api/v1/tsig-keys":          true,
+	"DELETE /api/v1/tsig-keys/{name}": true,
+}
+
+// requestAccount is the account the request's token is limited to, or ""
+// when it may see every domain.
+func requestAccount(ctx context.Context) string {
+	token := requestToken(ctx)
+	if token == nil || token.Account == nil {
+		return ""
+	}
+	return *token.Account
+}
+
+// checkAccount hides a domain of another account from an account's token,
+// as if it did not exist.
+func checkAccount(ctx context.Context, domain *Domain) error {
+	account := requestAccount(ctx)
+	if account == "" || (domain.Account != nil && *domain.Account == account) {
+		return nil
+	}
+	return errNotFound("domain not found")
+}
+
+// scopeAccount limits a query on domains to the request's account.
+func scopeAccount(ctx context.Context, query *gorm.DB) *gorm.DB {
+	if account := requestAccount(ctx); account != "" {
+		return query.Where("account = ?", account)
+	}
+	return query
+}
+
+// scopeAccountRecords limits a query on records, or any table with a
+// domain_id, to the domains of the request's account.
+func (r *Reloader) scopeAccountRecords(ctx context.Context, query *gorm.DB) *gorm.DB {
+	if account := requestAccount(ctx); account != "" {
+		return query.Where("domain_id IN (?)", r.db.WithContext(ctx).Model(&Domain{}).Select("id").Where("account = ?", account))
+	}
+	return query
+}
+
+// accountFor is the account to store on a domain created or updated with
+// account: the request's own for an account's token, which may not hand
+// domains to another account.
+func accountFor(ctx context.Context, account *string) (*string, error) {
+	own := requestAccount(ctx)
+	if own == "" {
+		return account, nil
+	}
+	if account != nil && *account != own {
+		return nil, errForbidden(fmt.Sprintf("domains of account %s cannot be given to account %s", own, *account))
+	}
+	return &own, nil
+}
+
+// scopeChanges keeps the change sets that only touch domains of the
+// request's account, since a change set names every zone it regenerated.
+func (r *Reloader) scopeChanges(ctx context.Context, changes []ChangeSet) ([]ChangeSet, error) {
+	account := requestAccount(ctx)
+	if account == "" {
+		return changes, nil
+	}
+	var domains []Domain
+	if err := r.db.WithContext(ctx).Select("id", "name").Where("account = ?", account).Find(&domains).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch domains: %w", err)
+	}
+	ids := make(map[int]bool, len(domains))
+	names := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		ids[int(domain.ID)] = true
+		names[domain.Name] = true
+	}
+	return slices.DeleteFunc(changes, func(cs ChangeSet) bool {
+		if cs.DomainID != 0 {
+			return !ids[cs.DomainID]
+		}
+		return len(cs.Zones) == 0 || slices.ContainsFunc(cs.Zones, func(zone string) bool { return !names[zone] })
+	}), nil
+}
+
+// tenantDirectory is the subdirectory of ZONES_DIRECTORY that
+// TENANT_DIRECTORIES, a list of account=directory pairs, gives the zones of
+// account, or "" to leave them in ZONES_DIRECTORY itself. A pair for * is
+// used for every account without one of its own.
+func (r *Reloader) tenantDirectory(account string) string {
+	var fallback string
+	for _, pair := range r.config.TenantDirectories {
+		name, directory, _ := strings.Cut(pair, "=")
+		switch strings.TrimSpace(name) {
+		case account:
+			return strings.TrimSpace(directory)
+		case "*":
+			fallback = strings.TrimSpace(directory)
+		}
+	}
+	return fallback
+}
+
+// validateTenantDirectories checks that each TENANT_DIRECTORIES pair names
+// a single directory, so no tenant can write outside ZONES_DIRECTORY.
+func validateTenantDirectories(pairs []string) error {
+	for _, pair := range pairs {
+		name, directory, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%q is not account=directory", pair)
+		}
+		if !viewPattern.MatchString(strings.TrimSpace(directory)) {
+			return fmt.Errorf("directory %q of %s must be letters, digits, - or _", directory, name)
+		}
+	}
+	return nil
+}
+
+// AccountStats summarizes the domains of an account, or of every account.
+type AccountStats struct {
+	Account     string           `json:"account,omitempty"`
+	Domains     int64            `json:"domains"`
+	Records     int64            `json:"records"`
+	RecordTypes map[string]int64 `json:"record_types"`
+	Generations map[string]int64 `json:"generations,omitempty"`
+}
+
+// accountStats counts the domains and records of account, all of them when
+// it is "", and with AUDIT_GENERATIONS the zone generations of the last
+// day by result.
+func (r *Reloader) accountStats(ctx context.Context, account string) (*AccountStats, error) {
+	stats := &AccountStats{Account: account, RecordTypes: map[string]int64{}}
+	domains := r.db.WithContext(ctx).Model(&Domain{})
+	records := r.db.WithContext(ctx).Model(&Record{})
+	if account != "" {
+		domains = domains.Where("account = ?", account)
+		records = records.Where("domain_id IN (?)", r.db.WithContext(ctx).Model(&Domain{}).Select("id").Where("account = ?", account))
+	}
+	if err := domains.Count(&stats.Domains).Error; err != nil {
+		return nil, fmt.Errorf("failed to count domains: %w", err)
+	}
+
+	var types []struct {
+		Type  string
+		Count int64
+	}
+	if err := records.Select("UPPER(type) AS type, COUNT(*) AS count").Group("UPPER(type)").Scan(&types).Error; err != nil {
+		return nil, fmt.Errorf("failed to count records: %w", err)
+	}
+	for _, row := range types {
+		stats.RecordTypes[row.Type] = row.Count
+		stats.Records += row.Count
+	}
+
+	if r.config.AuditGenerations && (account == "" || r.accounts) {
+		var results []struct {
+			Result string
+			Count  int64
+		}
+		query := r.db.WithContext(ctx).Model(&ZoneGeneration{}).Where("created_at > ?", time.Now().Add(-24*time.Hour))
+		if account != "" {
+			query = query.Where("account = ?", account)
+		}
+		if err := query.Select("result, COUNT(*) AS count").Group("result").Scan(&results).Error; err != nil {
+			return nil, fmt.Errorf("failed to count zone generations: %w", err)
+		}
+		stats.Generations = make(map[string]int64, len(results))
+		for _, row := range results {
+			stats.Generations[row.Result] = row.Count
+		}
+	}
+	return stats, nil
+}
+
+// handleStats serves GET /api/v1/stats, for ?account= or every account.
+// A token limited to an account only gets the statistics of its own.
+func (r *Reloader) handleStats(w http.ResponseWriter, req *http.Request) {
+	account := req.URL.Query().Get("account")
+	if own := requestAccount(req.Context()); own != "" {
+		if account != "" && account != own {
+			writeError(w, http.StatusForbidden, fmt.Sprintf("token may only read the statistics of account %s", own))
+			return
+		}
+		account = own
+	}
+	stats, err := r.accountStats(req.Context(), account)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccountScope(t *testing.T) {
+	r := newTestReloader(t, map[string]string{"API_ADMIN_TOKEN": "admin-secret"})
+	ctx := t.Context()
+	for name, account := range map[string]string{"alpha.example": "alpha", "beta.example": "beta"} {
+		if _, _, err := r.createDomain(ctx, domainCreateRequest{Name: name, Type: "NATIVE", Account: &account}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alpha, err := r.createAPIToken(ctx, apiTokenRequest{Name: "alpha", Account: "alpha", Role: "zone-admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/domains", r.handleListDomains)
+	mux.HandleFunc("GET /api/v1/domains/{id}", r.handleGetDomain)
+	mux.HandleFunc("POST /api/v1/domains", r.handleCreateDomain)
+	mux.HandleFunc("POST /api/v1/reload", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := r.authenticate(mux)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		secret string
+		want   int
+		shows  string
+		hides  string
+	}{
+		{name: "admin lists every account", method: "GET", path: "/api/v1/domains", secret: "admin-secret", want: http.StatusOK, shows: "beta.example"},
+		{name: "account lists its own", method: "GET", path: "/api/v1/domains", secret: alpha.Secret, want: http.StatusOK, shows: "alpha.example", hides: "beta.example"},
+		{name: "own domain", method: "GET", path: "/api/v1/domains/alpha.example", secret: alpha.Secret, want: http.StatusOK},
+		{name: "another account's domain", method: "GET", path: "/api/v1/domains/beta.example", secret: alpha.Secret, want: http.StatusNotFound},
+		{name: "create in its account", method: "POST", path: "/api/v1/domains", body: `{"name":"new.example","type":"NATIVE"}`, secret: alpha.Secret, want: http.StatusCreated, shows: `"account":"alpha"`},
+		{name: "create for another account", method: "POST", path: "/api/v1/domains", body: `{"name":"other.example","type":"NATIVE","account":"beta"}`, secret: alpha.Secret, want: http.StatusForbidden},
+		{name: "admin reloads", method: "POST", path: "/api/v1/reload", secret: "admin-secret", want: http.StatusNoContent},
+		{name: "account may not reload", method: "POST", path: "/api/v1/reload", secret: alpha.Secret, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+tt.secret)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("answered %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.shows != "" && !strings.Contains(w.Body.String(), tt.shows) {
+				t.Errorf("answer lacks %s: %s", tt.shows, w.Body)
+			}
+			if tt.hides != "" && strings.Contains(w.Body.String(), tt.hides) {
+				t.Errorf("answer shows %s: %s", tt.hides, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// renderTinydns renders zones as a tinydns data file, to be compiled with
+// tinydns-data. Types without a tinydns line of their own are written as
+// generic records carrying their wire-format data.
+func renderTinydns(zones []outputZone) (string, error) {
+	var data strings.Builder
+	data.WriteString("# Generated by dns-reloader, do not edit\n")
+	for _, zone := range zones {
+		data.WriteString("\n# " + zone.Name + "\n")
+		for _, rr := range zone.RRs {
+			data.WriteString(tinydnsLine(rr) + "\n")
+		}
+	}
+	return data.String(), nil
+}
+
+// tinydnsLine converts one record to its tinydns data line.
+func tinydnsLine(rr dns.RR) string {
+	header := rr.Header()
+	name := tinydnsName(header.Name)
+	switch v := rr.(type) {
+	case *dns.SOA:
+		return fmt.Sprintf("Z%s:%s:%s:%d:%d:%d:%d:%d:%d", name, tinydnsName(v.Ns), tinydnsName(v.Mbox),
+			v.Serial, v.Refresh, v.Retry, v.Expire, v.Minttl, header.Ttl)
+	case *dns.NS:
+		return fmt.Sprintf("&%s::%s:%d", name, tinydnsName(v.Ns), header.Ttl)
+	case *dns.A:
+		return fmt.Sprintf("+%s:%s:%d", name, v.A, header.Ttl)
+	case *dns.MX:
+		return fmt.Sprintf("@%s::%s:%d:%d", name, tinydnsName(v.Mx), v.Preference, header.Ttl)
+	case *dns.CNAME:
+		return fmt.Sprintf("C%s:%s:%d", name, tinydnsName(v.Target), header.Ttl)
+	case *dns.PTR:
+		return fmt.Sprintf("^%s:%s:%d", name, tinydnsName(v.Ptr), header.Ttl)
+	case *dns.TXT:
+		if len(v.Txt) == 1 {
+			return fmt.Sprintf("'%s:%s:%d", name, tinydnsEscape([]byte(txtUnescape(v.Txt[0]))), header.Ttl)
+		}
+	}
+
+	// The generic form keeps anything else, and TXT records split into
+	// several strings, exactly.
+	rdata, err := wireRdata(rr)
+	if err != nil {
+		return fmt.Sprintf("# %s: %v", rr.String(), err)
+	}
+	return fmt.Sprintf(":%s:%d:%s:%d", name, header.Rrtype, tinydnsEscape(rdata), header.Ttl)
+}
+
+// wireRdata returns the uncompressed wire format of rr's data.
+func wireRdata(rr dns.RR) ([]byte, error) {
+	wire := make([]byte, 2*dns.Len(rr)+512)
+	end, err := dns.PackRR(rr, wire, 0, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	// The owner name is followed by type, class, TTL and rdlength.
+	start, err := dns.PackDomainName(rr.Header().Name, make([]byte, 256), 0, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	return wire[start+10 : end], nil
+}
+
+// tinydnsName strips the trailing dot tinydns names do not carry and
+// escapes the result.
+func tinydnsName(name string) string {
+	return tinydnsEscape([]byte(strings.TrimSuffix(name, ".")))
+}
+
+// tinydnsEscape writes b with colons, backslashes and unprintable bytes as
+// three-digit octal escapes, as tinydns-data reads them.
+func tinydnsEscape(b []byte) string {
+	var out strings.Builder
+	for _, c := range b {
+		if c < 0x20 || c > 0x7e || c == ':' || c == '\\' {
+			fmt.Fprintf(&out, "\\%03o", c)
+			continue
+		}
+		out.WriteByte(c)
+	}
+	return out.String()
+}
+
+// txtUnescape turns the presentation escapes miekg/dns keeps in TXT strings
+// back into the bytes they stand for.
+func txtUnescape(s string) string {
+	rdata, err := wireRdata(&dns.TXT{Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeTXT, Class: dns.ClassINET}, Txt: []string{s}})
+	if err != nil || len(rdata) == 0 {
+		return s
+	}
+	// The rdata is a single length-prefixed string.
+	return string(rdata[1:])
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// serve runs server over TLS when TLS_CERT_FILE is set.
+func (r *Reloader) serve(server *http.Server) error {
+	if r.tlsConfig == nil {
+		return server.ListenAndServe()
+	}
+	server.TLSConfig = r.tlsConfig
+	return server.ListenAndServeTLS("", "")
+}
+
+// tlsClientAuth maps TLS_CLIENT_AUTH to how client certificates are
+// checked.
+var tlsClientAuth = map[string]tls.ClientAuthType{
+	"none":            tls.NoClientCert,
+	"request":         tls.RequestClientCert,
+	"verify-if-given": tls.VerifyClientCertIfGiven,
+	"require":         tls.RequireAndVerifyClientCert,
+}
+
+// serverTLSConfig is the TLS configuration of the HTTP, gRPC and pprof
+// listeners, or nil when TLS_CERT_FILE is not set and they serve plain
+// text. With TLS_CLIENT_CA_FILE, clients must present a certificate signed
+// by one of its CAs; TLS_CLIENT_AUTH=verify-if-given still lets clients
+// without one in, such as health checks, which API tokens then cover.
+func serverTLSConfig(config *Config) (*tls.Config, error) {
+	if config.TLSCertFile == "" && config.TLSKeyFile == "" {
+		if config.TLSClientCAFile != "" {
+			return nil, errors.New("TLS_CLIENT_CA_FILE needs TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		return nil, nil
+	}
+	if config.TLSCertFile == "" || config.TLSKeyFile == "" {
+		return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	keyPair := &certificateFile{certFile: config.TLSCertFile, keyFile: config.TLSKeyFile}
+	if _, err := keyPair.load(); err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: keyPair.getCertificate,
+	}
+
+	clientAuth := config.TLSClientAuth
+	if clientAuth == "" {
+		clientAuth = "none"
+		if config.TLSClientCAFile != "" {
+			clientAuth = "require"
+		}
+	}
+	authType, ok := tlsClientAuth[clientAuth]
+	if !ok {
+		return nil, fmt.Errorf("unknown TLS_CLIENT_AUTH %q, expected none, request, verify-if-given or require", clientAuth)
+	}
+	tlsConfig.ClientAuth = authType
+	if config.TLSClientCAFile != "" {
+		pem, err := os.ReadFile(config.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", config.TLSClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+	} else if authType == tls.VerifyClientCertIfGiven || authType == tls.RequireAndVerifyClientCert {
+		return nil, fmt.Errorf("TLS_CLIENT_AUTH=%s needs TLS_CLIENT_CA_FILE", clientAuth)
+	}
+	return tlsConfig, nil
+}
+
+// certificateFile is a certificate and key read from disk, read again
+// when the certificate file changes, so one renewed by cert-manager or
+// certbot is picked up without a restart.
+type certificateFile struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func (c *certificateFile) load() (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	c.cert = &cert
+	if info, err := os.Stat(c.certFile); err == nil {
+		c.modTime = info.ModTime()
+	}
+	return c.cert, nil
+}
+
+// getCertificate looks at the certificate file at most every ten seconds.
+// A renewed pair that cannot be loaded, such as a certificate written
+// before its key, leaves the previous one in use.
+func (c *certificateFile) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.checked) < 10*time.Second {
+		return c.cert, nil
+	}
+	c.checked = time.Now()
+	info, err := os.Stat(c.certFile)
+	if err != nil || info.ModTime().Equal(c.modTime) {
+		return c.cert, nil
+	}
+	c.load()
+	return c.cert, nil
+}
@@ -0,0 +1,661 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// APIToken is a row of the api_tokens table. A token with Names is scoped
+// to the records whose names match one of its patterns, and to Types and
+// DomainID when they are set, so automation such as an ACME client can
+// change exactly the records it needs. A token without Names may use the
+// whole API. A token with an Account only sees the domains of that
+// account. Role limits what it may do, and grants raise it on single
+// domains.
+type APIToken struct {
+	ID         int        `gorm:"primaryKey;column:id"`
+	Name       string     `gorm:"column:name"`
+	SecretHash string     `gorm:"column:secret_hash"`
+	DomainID   *int       `gorm:"column:domain_id"`
+	Names      *string    `gorm:"column:names"`
+	Types      *string    `gorm:"column:types"`
+	RateLimit  int        `gorm:"column:rate_limit"`
+	ExpiresAt  *time.Time `gorm:"column:expires_at"`
+	CreatedAt  time.Time  `gorm:"column:created_at"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at"`
+	Account    *string    `gorm:"column:account"`
+	Role       string     `gorm:"column:role"`
+
+	grants map[int]role `gorm:"-"`
+}
+
+func (APIToken) TableName() string {
+	return "api_tokens"
+}
+
+// scoped reports whether the token is restricted to some records.
+func (t *APIToken) scoped() bool {
+	return t.Names != nil && *t.Names != ""
+}
+
+// allows reports whether the token may read or change a record named name
+// of type rrtype in domain. Patterns are matched with path.Match against
+// the lower-case name without its trailing dot, so *.dyn.example.com
+// matches any name under dyn.example.com.
+func (t *APIToken) allows(domain *Domain, name, rrtype string) bool {
+	if !t.scoped() {
+		return true
+	}
+	if t.DomainID != nil && *t.DomainID != int(domain.ID) {
+		return false
+	}
+	if t.Types != nil && *t.Types != "" && !slices.ContainsFunc(parseList(*t.Types), func(allowed string) bool {
+		return strings.EqualFold(allowed, rrtype)
+	}) {
+		return false
+	}
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	for _, pattern := range parseList(*t.Names) {
+		pattern = strings.TrimSuffix(strings.ToLower(pattern), ".")
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type tokenContextKey struct{}
+
+// requestToken is the token a request was made with, or nil.
+func requestToken(ctx context.Context) *APIToken {
+	token, _ := ctx.Value(tokenContextKey{}).(*APIToken)
+	return token
+}
+
+// checkScope refuses a record outside the scope of the request's token.
+func checkScope(ctx context.Context, domain *Domain, record *Record) error {
+	token := requestToken(ctx)
+	if token == nil || token.allows(domain, record.Name, record.Type) {
+		return nil
+	}
+	return errForbidden(fmt.Sprintf("token %s may not change %s %s", token.Name, record.Name, record.Type))
+}
+
+// scopeRecords drops the records outside the scope of the request's token.
+func scopeRecords(ctx context.Context, domain *Domain, records []Record) []Record {
+	token := requestToken(ctx)
+	if token == nil || !token.scoped() {
+		return records
+	}
+	return slices.DeleteFunc(records, func(record Record) bool {
+		return !token.allows(domain, record.Name, record.Type)
+	})
+}
+
+// scopedRoutes are the routes a scoped token may use; the handlers behind
+// them check each record against its patterns.
+var scopedRoutes = map[string]bool{
+	"GET /api/v1/domains/{id}/records":               true,
+	"POST /api/v1/domains/{id}/records":              true,
+	"POST /api/v1/domains/{id}/records:batch":        true,
+	"PUT /api/v1/domains/{id}/records/{recordID}":    true,
+	"DELETE /api/v1/domains/{id}/records/{recordID}": true,
+}
+
+// tokenLimiter counts the requests of each token per minute for their
+// rate_limit. The counts are kept per process.
+type tokenLimiter struct {
+	mu      sync.Mutex
+	windows map[int]*tokenWindow
+}
+
+type tokenWindow struct {
+	start time.Time
+	count int
+}
+
+// allow counts a request of token, and when it is over its limit returns
+// false and how long until the next window.
+func (l *tokenLimiter) allow(token *APIToken, now time.Time) (bool, time.Duration) {
+	if token.RateLimit <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.windows == nil {
+		l.windows = make(map[int]*tokenWindow)
+	}
+	window := l.windows[token.ID]
+	if window == nil || now.Sub(window.start) >= time.Minute {
+		window = &tokenWindow{start: now}
+		l.windows[token.ID] = window
+	}
+	if window.count >= token.RateLimit {
+		return false, window.start.Add(time.Minute).Sub(now)
+	}
+	window.count++
+	return true, 0
+}
+
+// authenticate checks the bearer token of API requests, which every route
+// but the metrics, status, OpenAPI and /nic/update ones needs.
+func (r *Reloader) authenticate(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, pattern := mux.Handler(req)
+		if !strings.HasPrefix(req.URL.Path, "/api/") || strings.HasPrefix(pattern, "GET /api/v1/openapi.") {
+			mux.ServeHTTP(w, req)
+			return
+		}
+		secret, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "an API token is required")
+			return
+		}
+
+		token, err := r.findAPIToken(req.Context(), strings.TrimSpace(secret))
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeAPIError(w, err)
+			return
+		}
+		ctx, err := r.admit(req.Context(), token, pattern, pathValue(pattern, req.URL.Path, routeRoles[pattern].domain))
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		if ok, wait := r.tokenLimits.allow(token, time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			writeError(w, http.StatusTooManyRequests, fmt.Sprintf("token %s is limited to %d requests a minute", token.Name, token.RateLimit))
+			return
+		}
+		mux.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// admit checks that token may use the route pattern, on the domain named
+// domain when the route acts on one, and returns ctx carrying the token so
+// the operations behind the route keep to its account and records. The
+// HTTP and gRPC APIs both admit requests through it.
+func (r *Reloader) admit(ctx context.Context, token *APIToken, pattern, domain string) (context.Context, error) {
+	logger := r.logger.WithFields(logrus.Fields{"token": token.Name, "route": pattern})
+	if token.scoped() && !scopedRoutes[pattern] {
+		logger.Warn("Refused API request outside the token's scope")
+		return nil, errForbidden(fmt.Sprintf("token %s may only manage its records", token.Name))
+	}
+	if token.Account != nil && systemRoutes[pattern] {
+		logger.WithField("account", *token.Account).Warn("Refused server-wide API request for an account's token")
+		return nil, errForbidden(fmt.Sprintf("token %s may only manage the domains of account %s", token.Name, *token.Account))
+	}
+	ctx = context.WithValue(ctx, tokenContextKey{}, token)
+	if err := r.authorize(ctx, token, pattern, domain); err != nil {
+		logger.WithField("role", token.Role).Warn("Refused API request above the token's role")
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// adminToken is the token of API_ADMIN_TOKEN, which may use the whole API.
+var adminToken = &APIToken{Name: "admin", Role: roleSystemAdmin.String()}
+
+// isAdminToken reports whether secret is API_ADMIN_TOKEN, which matches
+// nothing while it is unset.
+func (r *Reloader) isAdminToken(secret string) bool {
+	admin := r.config.APIAdminToken
+	return admin != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(admin)) == 1
+}
+
+// findAPIToken returns adminToken for API_ADMIN_TOKEN, or else the
+// unexpired token with secret. Its last use is recorded at most once a
+// minute.
+func (r *Reloader) findAPIToken(ctx context.Context, secret string) (*APIToken, error) {
+	if r.isAdminToken(secret) {
+		return adminToken, nil
+	}
+	unauthorized := &apiError{status: http.StatusUnauthorized, message: "invalid API token"}
+	if !r.apitokens || secret == "" {
+		return nil, unauthorized
+	}
+	var token APIToken
+	err := r.db.WithContext(ctx).Where("secret_hash = ?", hashSecret(secret)).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, unauthorized
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up API token: %w", err)
+	}
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return nil, &apiError{status: http.StatusUnauthorized, message: fmt.Sprintf("token %s has expired", token.Name)}
+	}
+	if !r.roles {
+		// Tokens created before roles could use everything.
+		token.Role = roleSystemAdmin.String()
+	}
+	if err := r.loadGrants(ctx, &token); err != nil {
+		return nil, err
+	}
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= time.Minute {
+		if err := r.db.WithContext(ctx).Model(&token).Update("last_used_at", now).Error; err != nil {
+			r.logger.WithError(err).WithField("token", token.Name).Warn("Failed to record API token use")
+		}
+	}
+	return &token, nil
+}
+
+// apiTokenRequest creates a token. Account, Domain and Names scope it; TTL
+// is a duration such as 720h after which it expires. Role defaults to
+// viewer, and Grants maps domains to the roles the token has on them.
+type apiTokenRequest struct {
+	Name      string            `json:"name"`
+	Account   string            `json:"account,omitempty"`
+	Role      string            `json:"role,omitempty"`
+	Grants    map[string]string `json:"grants,omitempty"`
+	Domain    string            `json:"domain,omitempty"`
+	Names     []string          `json:"names,omitempty"`
+	Types     []string          `json:"types,omitempty"`
+	TTL       string            `json:"ttl,omitempty"`
+	RateLimit int               `json:"rate_limit,omitempty"`
+}
+
+// APITokenResponse describes a token. Secret is only set when it was just
+// created.
+type APITokenResponse struct {
+	Name       string            `json:"name"`
+	Account    string            `json:"account,omitempty"`
+	Role       string            `json:"role"`
+	Grants     map[string]string `json:"grants,omitempty"`
+	Domain     string            `json:"domain,omitempty"`
+	Names      []string          `json:"names,omitempty"`
+	Types      []string          `json:"types,omitempty"`
+	RateLimit  int               `json:"rate_limit"`
+	ExpiresAt  *time.Time        `json:"expires_at,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	LastUsedAt *time.Time        `json:"last_used_at,omitempty"`
+	Secret     string            `json:"secret,omitempty"`
+}
+
+// createAPIToken stores a token and returns it with its generated secret.
+// A token limited to an account can only create tokens for that account.
+func (r *Reloader) createAPIToken(ctx context.Context, body apiTokenRequest) (*APITokenResponse, error) {
+	if !r.apitokens {
+		return nil, errConflict("the database has no api_tokens table")
+	}
+	var requested *string
+	if body.Account = strings.TrimSpace(body.Account); body.Account != "" {
+		requested = &body.Account
+	}
+	account, err := accountFor(ctx, requested)
+	if err != nil {
+		return nil, err
+	}
+	if account != nil && !r.accounts {
+		return nil, errConflict("the api_tokens table has no account column")
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		return nil, errInvalid("name is required")
+	}
+	if body.RateLimit < 0 {
+		return nil, errInvalid("rate_limit cannot be negative")
+	}
+	if (body.Domain != "" || len(body.Types) > 0) && len(body.Names) == 0 {
+		return nil, errInvalid("a token scoped to a domain or types also needs names")
+	}
+	for _, pattern := range body.Names {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errInvalid(fmt.Sprintf("invalid name pattern %q", pattern))
+		}
+	}
+	if body.Role == "" {
+		body.Role = roleViewer.String()
+	}
+	tokenRole, ok := parseRole(body.Role)
+	if !ok {
+		return nil, errInvalid(fmt.Sprintf("unknown role %q", body.Role))
+	}
+	if !r.roles && (tokenRole != roleSystemAdmin || len(body.Grants) > 0) {
+		return nil, errConflict("the api_tokens table has no role column")
+	}
+	token := APIToken{Name: body.Name, RateLimit: body.RateLimit, Account: account, Role: tokenRole.String(), CreatedAt: time.Now()}
+	if len(body.Names) > 0 {
+		names := strings.Join(body.Names, ",")
+		token.Names = &names
+	}
+	if len(body.Types) > 0 {
+		types := strings.ToUpper(strings.Join(body.Types, ","))
+		token.Types = &types
+	}
+	if body.TTL != "" {
+		ttl, err := time.ParseDuration(body.TTL)
+		if err != nil || ttl <= 0 {
+			return nil, errInvalid(fmt.Sprintf("invalid ttl %q", body.TTL))
+		}
+		expires := token.CreatedAt.Add(ttl)
+		token.ExpiresAt = &expires
+	}
+	var domain *Domain
+	if body.Domain != "" {
+		if domain, err = r.findDomain(ctx, body.Domain); err != nil {
+			return nil, err
+		}
+		if account != nil && (domain.Account == nil || *domain.Account != *account) {
+			return nil, errInvalid(fmt.Sprintf("%s is not a domain of account %s", domain.Name, *account))
+		}
+		id := int(domain.ID)
+		token.DomainID = &id
+	}
+	grants := make([]TokenGrant, 0, len(body.Grants))
+	grantNames := make(map[string]string, len(body.Grants))
+	for ref, name := range body.Grants {
+		granted, ok := parseRole(name)
+		if !ok {
+			return nil, errInvalid(fmt.Sprintf("unknown role %q for %s", name, ref))
+		}
+		grantDomain, err := r.findDomain(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, TokenGrant{DomainID: int(grantDomain.ID), Role: granted.String()})
+		grantNames[grantDomain.Name] = granted.String()
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+	secret := base64.RawURLEncoding.EncodeToString(buf)
+	token.SecretHash = hashSecret(secret)
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&APIToken{}).Where("name = ?", token.Name).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return errConflict(fmt.Sprintf("token %s already exists", token.Name))
+		}
+		create := tx
+		if !r.accounts {
+			create = create.Omit("account")
+		}
+		if !r.roles {
+			create = create.Omit("role")
+		}
+		if err := create.Create(&token).Error; err != nil {
+			return err
+		}
+		for i := range grants {
+			grants[i].TokenID = token.ID
+		}
+		if len(grants) == 0 {
+			return nil
+		}
+		return tx.Create(&grants).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := apiTokenResponse(token, domain)
+	if len(grantNames) > 0 {
+		resp.Grants = grantNames
+	}
+	resp.Secret = secret
+	return &resp, nil
+}
+
+// listAPITokens returns the stored tokens, without their secrets.
+func (r *Reloader) listAPITokens(ctx context.Context) ([]APITokenResponse, error) {
+	if !r.apitokens {
+		return nil, errConflict("the database has no api_tokens table")
+	}
+	var tokens []APIToken
+	if err := scopeAccount(ctx, r.db.WithContext(ctx)).Order("name").Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("failed to read api_tokens: %w", err)
+	}
+	var domains []Domain
+	if err := r.db.WithContext(ctx).Find(&domains).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch domains: %w", err)
+	}
+	byID := make(map[int]*Domain, len(domains))
+	for i := range domains {
+		byID[int(domains[i].ID)] = &domains[i]
+	}
+	var grants []TokenGrant
+	if r.roles {
+		if err := r.db.WithContext(ctx).Find(&grants).Error; err != nil {
+			return nil, fmt.Errorf("failed to read api_token_grants: %w", err)
+		}
+	}
+	resp := make([]APITokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		var domain *Domain
+		if token.DomainID != nil {
+			domain = byID[*token.DomainID]
+		}
+		if !r.roles {
+			token.Role = roleSystemAdmin.String()
+		}
+		item := apiTokenResponse(token, domain)
+		for _, grant := range grants {
+			if grant.TokenID != token.ID || byID[grant.DomainID] == nil {
+				continue
+			}
+			if item.Grants == nil {
+				item.Grants = make(map[string]string)
+			}
+			item.Grants[byID[grant.DomainID].Name] = grant.Role
+		}
+		resp = append(resp, item)
+	}
+	return resp, nil
+}
+
+func apiTokenResponse(token APIToken, domain *Domain) APITokenResponse {
+	resp := APITokenResponse{
+		Name:       token.Name,
+		Role:       token.Role,
+		RateLimit:  token.RateLimit,
+		ExpiresAt:  token.ExpiresAt,
+		CreatedAt:  token.CreatedAt,
+		LastUsedAt: token.LastUsedAt,
+	}
+	if token.Account != nil {
+		resp.Account = *token.Account
+	}
+	if domain != nil {
+		resp.Domain = domain.Name
+	}
+	if token.Names != nil {
+		resp.Names = parseList(*token.Names)
+	}
+	if token.Types != nil {
+		resp.Types = parseList(*token.Types)
+	}
+	return resp
+}
+
+// deleteAPIToken revokes a token.
+func (r *Reloader) deleteAPIToken(ctx context.Context, name string) error {
+	if !r.apitokens {
+		return errConflict("the database has no api_tokens table")
+	}
+	result := scopeAccount(ctx, r.db.WithContext(ctx)).Where("name = ?", name).Delete(&APIToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errNotFound(fmt.Sprintf("token %s not found", name))
+	}
+	return nil
+}
+
+// handleListTokens serves GET /api/v1/tokens.
+func (r *Reloader) handleListTokens(w http.ResponseWriter, req *http.Request) {
+	tokens, err := r.listAPITokens(req.Context())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tokens": tokens})
+}
+
+// handleCreateToken serves POST /api/v1/tokens.
+func (r *Reloader) handleCreateToken(w http.ResponseWriter, req *http.Request) {
+	var body apiTokenRequest
+	if err := readJSON(req, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	token, err := r.createAPIToken(req.Context(), body)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, token)
+}
+
+// handleDeleteToken serves DELETE /api/v1/tokens/{name}.
+func (r *Reloader) handleDeleteToken(w http.ResponseWriter, req *http.Request) {
+	if err := r.deleteAPIToken(req.Context(), req.PathValue("name")); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tokenCommand runs the token subcommands, add, list and delete.
+func (r *Reloader) tokenCommand(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "add":
+			return r.tokenAdd(args[1:])
+		case "list":
+			return r.tokenList()
+		case "delete":
+			if len(args) != 2 {
+				return errors.New("usage: reloader token delete <name>")
+			}
+			if err := r.connectDB(); err != nil {
+				return err
+			}
+			if err := r.deleteAPIToken(r.ctx, args[1]); err != nil {
+				return err
+			}
+			r.logger.WithField("token", args[1]).Info("Deleted API token")
+			return nil
+		}
+	}
+	return errors.New("usage: reloader token add [flags] <name>\n       reloader token list\n       reloader token delete <name>")
+}
+
+// tokenAdd runs token add and prints the secret once.
+func (r *Reloader) tokenAdd(args []string) error {
+	fs := flag.NewFlagSet("token add", flag.ContinueOnError)
+	account := fs.String("account", "", "account whose domains the token is limited to")
+	tokenRole := fs.String("role", "viewer", "role of the token: viewer, editor, zone-admin or system-admin")
+	grant := fs.String("grant", "", "comma separated domain=role pairs raising the role on those domains")
+	domain := fs.String("domain", "", "domain the token is limited to")
+	names := fs.String("names", "", "comma separated name patterns the token may change, such as _acme-challenge.www.example.com")
+	types := fs.String("types", "", "comma separated record types the token may change")
+	ttl := fs.String("ttl", "", "how long until the token expires, such as 720h")
+	rate := fs.Int("rate", 0, "requests a minute the token may make, 0 for no limit")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: reloader token add [flags] <name>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("token add takes one token name")
+	}
+	grants := make(map[string]string)
+	for _, pair := range parseList(*grant) {
+		domain, granted, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("grant %q is not domain=role", pair)
+		}
+		grants[domain] = granted
+	}
+	if err := r.connectDB(); err != nil {
+		return err
+	}
+
+	token, err := r.createAPIToken(r.ctx, apiTokenRequest{
+		Name:      fs.Arg(0),
+		Account:   *account,
+		Role:      *tokenRole,
+		Grants:    grants,
+		Domain:    *domain,
+		Names:     parseList(*names),
+		Types:     parseList(*types),
+		TTL:       *ttl,
+		RateLimit: *rate,
+	})
+	if err != nil {
+		return err
+	}
+	r.logger.WithFields(logrus.Fields{
+		"token": token.Name,
+		"role":  token.Role,
+		"names": token.Names,
+	}).Info("Created API token")
+	fmt.Println(token.Secret)
+	return nil
+}
+
+// tokenList runs token list. Secrets cannot be shown; only their hashes are
+// stored.
+func (r *Reloader) tokenList() error {
+	if err := r.connectDB(); err != nil {
+		return err
+	}
+	tokens, err := r.listAPITokens(r.ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tROLE\tACCOUNT\tDOMAIN\tNAMES\tTYPES\tRATE\tEXPIRES")
+	for _, token := range tokens {
+		account, scope, domain, types, expires := "*", "*", "-", "-", "never"
+		if token.Account != "" {
+			account = token.Account
+		}
+		if len(token.Names) > 0 {
+			scope = strings.Join(token.Names, ",")
+		}
+		if token.Domain != "" {
+			domain = token.Domain
+		}
+		if len(token.Types) > 0 {
+			types = strings.Join(token.Types, ",")
+		}
+		if token.ExpiresAt != nil {
+			expires = token.ExpiresAt.Format(time.RFC3339)
+		}
+		role := token.Role
+		for grantDomain, granted := range token.Grants {
+			role += fmt.Sprintf(",%s=%s", grantDomain, granted)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", token.Name, role, account, domain, scope, types, token.RateLimit, expires)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	r := newTestReloader(t, map[string]string{"API_ADMIN_TOKEN": "admin-secret"})
+	ctx := t.Context()
+	createTestZone(t, r, "example.com")
+	full, err := r.createAPIToken(ctx, apiTokenRequest{Name: "full", Role: "zone-admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	acme, err := r.createAPIToken(ctx, apiTokenRequest{Name: "acme", Role: "editor", Names: []string{"_acme-challenge.*"}, Types: []string{"TXT"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limited, err := r.createAPIToken(ctx, apiTokenRequest{Name: "limited", RateLimit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	for _, pattern := range []string{
+		"GET /status",
+		"GET /api/v1/openapi.json",
+		"GET /api/v1/domains",
+		"POST /api/v1/domains",
+		"GET /api/v1/domains/{id}/records",
+		"POST /api/v1/domains/{id}/records",
+	} {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+	handler := r.authenticate(mux)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		secret string
+		want   int
+	}{
+		{"status needs no token", "GET", "/status", "", http.StatusNoContent},
+		{"OpenAPI needs no token", "GET", "/api/v1/openapi.json", "", http.StatusNoContent},
+		{"read without a token", "GET", "/api/v1/domains", "", http.StatusUnauthorized},
+		{"write without a token", "POST", "/api/v1/domains", "", http.StatusUnauthorized},
+		{"unknown token", "POST", "/api/v1/domains", "wrong", http.StatusUnauthorized},
+		{"admin token", "POST", "/api/v1/domains", "admin-secret", http.StatusNoContent},
+		{"stored token", "POST", "/api/v1/domains", full.Secret, http.StatusNoContent},
+		{"scoped token on its records", "POST", "/api/v1/domains/example.com/records", acme.Secret, http.StatusNoContent},
+		{"scoped token elsewhere", "POST", "/api/v1/domains", acme.Secret, http.StatusForbidden},
+		{"within the rate limit", "GET", "/api/v1/domains", limited.Secret, http.StatusNoContent},
+		{"over the rate limit", "GET", "/api/v1/domains", limited.Secret, http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.secret != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.secret)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("answered %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
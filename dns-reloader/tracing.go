@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer resolves against the global provider, so spans are no-ops until
+// setupTracing installs an exporter.
+var tracer = otel.Tracer("dns-reloader")
+
+// setupTracing exports spans over OTLP/HTTP when an endpoint is configured.
+// The exporter and resource also honour the standard OTEL_* variables
+// (headers, insecure, service name, resource attributes).
+func (r *Reloader) setupTracing() (func(), error) {
+	if r.config.OTLPEndpoint == "" {
+		return func() {}, nil
+	}
+
+	exporter, err := otlptracehttp.New(r.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(
+		resource.NewSchemaless(semconv.ServiceName("dns-reloader")),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	r.logger.WithField("endpoint", r.config.OTLPEndpoint).Info("OpenTelemetry tracing enabled")
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			r.logger.WithError(err).Warn("Failed to flush traces")
+		}
+	}, nil
+}
+
+// endSpan records err on span before ending it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func changeAttributes(change *DNSChangeNotification) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("dns.change.table", change.Table),
+		attribute.String("dns.change.action", change.Action),
+		attribute.Int("dns.change.domain_id", change.DomainID),
+		attribute.String("dns.change.name", change.Name),
+		attribute.String("dns.change.type", change.Type),
+	}
+}
+
+// soaSerial extracts the serial from SOA rdata ("mname rname serial ...").
+func soaSerial(content string) string {
+	fields := strings.Fields(content)
+	if len(fields) < 3 {
+		return ""
+	}
+	return fields[2]
+}
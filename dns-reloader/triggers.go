@@ -0,0 +1,88 @@
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+//go:embed triggers.sql
+var triggerFunctionsSQL string
+
+// triggerChannels picks the NOTIFY channel for each table: the first
+// configured channel, or dns_domains_changed for domains when the reloader
+// listens on it.
+func (r *Reloader) triggerChannels() (records, domains string) {
+	records = "dns_records_changed"
+	if len(r.config.NotifyChannels) > 0 {
+		records = r.config.NotifyChannels[0]
+	}
+	domains = records
+	if slices.Contains(r.config.NotifyChannels, "dns_domains_changed") {
+		domains = "dns_domains_changed"
+	}
+	return records, domains
+}
+
+// installTriggers creates or replaces the notify functions and the triggers
+// on records and domains in one transaction, so it is safe to run on every
+// start.
+func (r *Reloader) installTriggers() error {
+	if _, ok := r.storage.(*PostgresStorage); !ok {
+		return fmt.Errorf("change triggers are only used with the postgres driver, not %s", r.storage.Name())
+	}
+	recordsChannel, domainsChannel := r.triggerChannels()
+
+	err := r.db.WithContext(r.ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(triggerFunctionsSQL).Error; err != nil {
+			return fmt.Errorf("failed to create notify functions: %w", err)
+		}
+		triggers := []struct{ name, table, function, channel string }{
+			{"records_change_trigger", "records", "notify_records_change", recordsChannel},
+			{"domains_change_trigger", "domains", "notify_domains_change", domainsChannel},
+		}
+		for _, t := range triggers {
+			stmt := fmt.Sprintf(
+				"DROP TRIGGER IF EXISTS %[1]s ON %[2]s; "+
+					"CREATE TRIGGER %[1]s AFTER INSERT OR UPDATE OR DELETE ON %[2]s "+
+					"FOR EACH ROW EXECUTE FUNCTION %[3]s(%[4]s)",
+				t.name, t.table, t.function, quoteSQLString(t.channel),
+			)
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("failed to create %s: %w", t.name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.logger.WithFields(logrus.Fields{
+		"records_channel": recordsChannel,
+		"domains_channel": domainsChannel,
+	}).Info("Change notification triggers installed")
+	return nil
+}
+
+// quoteSQLString quotes s as a SQL string literal.
+func quoteSQLString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// migrateTriggers installs or updates the change notification triggers.
+func (r *Reloader) migrateTriggers(args []string) error {
+	fs := flag.NewFlagSet("migrate-triggers", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := r.connectDB(); err != nil {
+		return err
+	}
+	return r.installTriggers()
+}
@@ -0,0 +1,62 @@
+-- Change notification functions installed by `reloader migrate-triggers`
+-- and AUTO_INSTALL_TRIGGERS. Keep in sync with database/init.sql.
+-- The channel is the trigger's first argument, defaulting to
+-- dns_records_changed.
+
+CREATE OR REPLACE FUNCTION notify_records_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    notification_data JSON;
+BEGIN
+    -- Batch imports notify once themselves after committing
+    IF current_setting('dns_reloader.suppress_notify', true) = 'on' THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    notification_data = json_build_object(
+        'table', TG_TABLE_NAME,
+        'action', TG_OP,
+        'id', COALESCE(NEW.id, OLD.id),
+        'domain_id', COALESCE(NEW.domain_id, OLD.domain_id),
+        'name', COALESCE(NEW.name, OLD.name),
+        'type', COALESCE(NEW.type, OLD.type),
+        'timestamp', CURRENT_TIMESTAMP
+    );
+
+    PERFORM pg_notify(COALESCE(TG_ARGV[0], 'dns_records_changed'), notification_data::text);
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    ELSE
+        RETURN NEW;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_domains_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    notification_data JSON;
+BEGIN
+    notification_data = json_build_object(
+        'table', TG_TABLE_NAME,
+        'action', TG_OP,
+        'id', COALESCE(NEW.id, OLD.id),
+        'domain_id', COALESCE(NEW.id, OLD.id), -- Use id as domain_id for domains table
+        'name', COALESCE(NEW.name, OLD.name),
+        'type', COALESCE(NEW.type, OLD.type),
+        'timestamp', CURRENT_TIMESTAMP
+    );
+
+    PERFORM pg_notify(COALESCE(TG_ARGV[0], 'dns_records_changed'), notification_data::text);
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    ELSE
+        RETURN NEW;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
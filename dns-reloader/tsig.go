@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// TSIGKey is a row of the PowerDNS tsigkeys table. Name and Algorithm are
+// stored without the trailing dot, as pdnsutil writes them; Secret is the
+// base64 key, or that encrypted with DNSSEC_KEY_SECRET for keys made here.
+type TSIGKey struct {
+	ID        int    `gorm:"primaryKey;column:id"`
+	Name      string `gorm:"column:name"`
+	Algorithm string `gorm:"column:algorithm"`
+	Secret    string `gorm:"column:secret"`
+}
+
+func (TSIGKey) TableName() string {
+	return "tsigkeys"
+}
+
+// tsigHashes are the algorithms keys can be created with and the DNS
+// server verifies. Generated secrets are as long as the hash output.
+var tsigHashes = map[string]func() hash.Hash{
+	dns.HmacSHA1:   sha1.New,
+	dns.HmacSHA224: sha256.New224,
+	dns.HmacSHA256: sha256.New,
+	dns.HmacSHA384: sha512.New384,
+	dns.HmacSHA512: sha512.New,
+}
+
+// tsigCacheTTL is how long the DNS server uses the keys it read before
+// reading them again, so new and deleted keys take effect without a
+// restart.
+const tsigCacheTTL = 30 * time.Second
+
+// tsigKeyFromRow returns the key of a tsigkeys row with its secret
+// decrypted.
+func (r *Reloader) tsigKeyFromRow(row TSIGKey) (*tsigKey, error) {
+	secret, err := r.decryptKeyContent(row.Secret)
+	if err != nil {
+		return nil, err
+	}
+	return &tsigKey{
+		Name:      dns.Fqdn(strings.ToLower(row.Name)),
+		Algorithm: dns.Fqdn(strings.ToLower(row.Algorithm)),
+		Secret:    secret,
+	}, nil
+}
+
+// loadTSIGKeys reads every stored key by its fully qualified name. Keys
+// that cannot be decrypted are logged and left out.
+func (r *Reloader) loadTSIGKeys(ctx context.Context) (map[string]*tsigKey, error) {
+	keys := make(map[string]*tsigKey)
+	if !r.tsigkeys {
+		return keys, nil
+	}
+	var rows []TSIGKey
+	if err := r.db.WithContext(ctx).Order("id").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read tsigkeys: %w", err)
+	}
+	for _, row := range rows {
+		key, err := r.tsigKeyFromRow(row)
+		if err != nil {
+			r.logger.WithError(err).WithField("key", row.Name).Warn("Ignoring unreadable TSIG key")
+			continue
+		}
+		keys[key.Name] = key
+	}
+	return keys, nil
+}
+
+// findTSIGKey reads one stored key by name.
+func (r *Reloader) findTSIGKey(ctx context.Context, name string) (*tsigKey, error) {
+	if !r.tsigkeys {
+		return nil, errConflict("the database has no tsigkeys table")
+	}
+	var row TSIGKey
+	err := r.db.WithContext(ctx).Where("name = ?", tsigKeyName(name)).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errNotFound(fmt.Sprintf("TSIG key %s not found", tsigKeyName(name)))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tsigkeys: %w", err)
+	}
+	return r.tsigKeyFromRow(row)
+}
+
+// cachedTSIGKey returns a stored key by name for the DNS server and NOTIFY
+// sender, reading the table at most once per tsigCacheTTL.
+func (r *Reloader) cachedTSIGKey(name string) *tsigKey {
+	r.tsigMu.Lock()
+	defer r.tsigMu.Unlock()
+	if time.Since(r.tsigLoaded) > tsigCacheTTL {
+		keys, err := r.loadTSIGKeys(r.ctx)
+		if err != nil {
+			r.logger.WithError(err).Warn("Failed to read TSIG keys, using the ones read before")
+		} else {
+			r.tsigCache = keys
+		}
+		r.tsigLoaded = time.Now()
+	}
+	return r.tsigCache[dns.Fqdn(strings.ToLower(name))]
+}
+
+// forgetTSIGKeys has the next cachedTSIGKey read the table again.
+func (r *Reloader) forgetTSIGKeys() {
+	r.tsigMu.Lock()
+	r.tsigLoaded = time.Time{}
+	r.tsigMu.Unlock()
+}
+
+// tsigKeyName is a key name as stored: lower case, without the trailing
+// dot.
+func tsigKeyName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+// createTSIGKey stores a key, generating its secret when none is given.
+// The secret is encrypted with DNSSEC_KEY_SECRET.
+func (r *Reloader) createTSIGKey(ctx context.Context, name, algorithm, secret string) (*tsigKey, error) {
+	if !r.tsigkeys {
+		return nil, errConflict("the database has no tsigkeys table")
+	}
+	name = tsigKeyName(name)
+	if _, ok := dns.IsDomainName(name); !ok || name == "" {
+		return nil, errInvalid(fmt.Sprintf("invalid TSIG key name %q", name))
+	}
+	if algorithm == "" {
+		algorithm = dns.HmacSHA256
+	}
+	algorithm = dns.Fqdn(strings.ToLower(algorithm))
+	newHash, ok := tsigHashes[algorithm]
+	if !ok {
+		return nil, errInvalid(fmt.Sprintf("unsupported TSIG algorithm %q", strings.TrimSuffix(algorithm, ".")))
+	}
+	if secret == "" {
+		raw := make([]byte, newHash().Size())
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		secret = base64.StdEncoding.EncodeToString(raw)
+	} else if raw, err := base64.StdEncoding.DecodeString(secret); err != nil || len(raw) == 0 {
+		return nil, errInvalid("TSIG secret must be base64")
+	}
+	content, err := r.encryptKeyContent(secret)
+	if err != nil {
+		return nil, errConflict(err.Error())
+	}
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&TSIGKey{}).Where("name = ?", name).Count(&existing).Error; err != nil {
+			return fmt.Errorf("failed to read tsigkeys: %w", err)
+		}
+		if existing > 0 {
+			return errConflict(fmt.Sprintf("TSIG key %s already exists", name))
+		}
+		row := TSIGKey{Name: name, Algorithm: strings.TrimSuffix(algorithm, "."), Secret: content}
+		if err := tx.Create(&row).Error; err != nil {
+			return fmt.Errorf("failed to store TSIG key: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.forgetTSIGKeys()
+	return &tsigKey{Name: dns.Fqdn(name), Algorithm: algorithm, Secret: secret}, nil
+}
+
+// deleteTSIGKey removes a stored key.
+func (r *Reloader) deleteTSIGKey(ctx context.Context, name string) error {
+	if !r.tsigkeys {
+		return errConflict("the database has no tsigkeys table")
+	}
+	result := r.db.WithContext(ctx).Where("name = ?", tsigKeyName(name)).Delete(&TSIGKey{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete TSIG key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errNotFound(fmt.Sprintf("TSIG key %s not found", tsigKeyName(name)))
+	}
+	r.forgetTSIGKeys()
+	return nil
+}
+
+// tsigProvider signs and verifies the DNS server's messages with the
+// stored keys.
+type tsigProvider struct {
+	r *Reloader
+}
+
+func (p tsigProvider) Generate(msg []byte, t *dns.TSIG) ([]byte, error) {
+	key := p.r.cachedTSIGKey(t.Hdr.Name)
+	if key == nil {
+		return nil, dns.ErrSecret
+	}
+	newHash, ok := tsigHashes[key.Algorithm]
+	if !ok || dns.CanonicalName(t.Algorithm) != key.Algorithm {
+		return nil, dns.ErrKeyAlg
+	}
+	secret, err := base64.StdEncoding.DecodeString(key.Secret)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(newHash, secret)
+	mac.Write(msg)
+	return mac.Sum(nil), nil
+}
+
+func (p tsigProvider) Verify(msg []byte, t *dns.TSIG) error {
+	expected, err := p.Generate(msg, t)
+	if err != nil {
+		return err
+	}
+	mac, err := hex.DecodeString(t.MAC)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(mac, expected) {
+		return dns.ErrSig
+	}
+	return nil
+}
+
+// tsigCommand runs the tsig subcommands, add, list and delete.
+func (r *Reloader) tsigCommand(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "add":
+			return r.tsigAdd(args[1:])
+		case "list":
+			return r.tsigList()
+		case "delete":
+			if len(args) != 2 {
+				return errors.New("usage: reloader tsig delete <name>")
+			}
+			if err := r.connectDB(); err != nil {
+				return err
+			}
+			if err := r.deleteTSIGKey(r.ctx, args[1]); err != nil {
+				return err
+			}
+			r.logger.WithField("key", tsigKeyName(args[1])).Info("Deleted TSIG key")
+			return nil
+		}
+	}
+	return errors.New("usage: reloader tsig add [-algorithm name] [-secret base64] <name>\n       reloader tsig list\n       reloader tsig delete <name>")
+}
+
+// tsigAdd runs tsig add and prints the key in dig's -y form.
+func (r *Reloader) tsigAdd(args []string) error {
+	fs := flag.NewFlagSet("tsig add", flag.ContinueOnError)
+	algorithm := fs.String("algorithm", "hmac-sha256", "algorithm of the key")
+	secret := fs.String("secret", "", "base64 secret to store instead of generating one")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: reloader tsig add [flags] <name>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("tsig add takes one key name")
+	}
+	if err := r.connectDB(); err != nil {
+		return err
+	}
+
+	key, err := r.createTSIGKey(r.ctx, fs.Arg(0), *algorithm, *secret)
+	if err != nil {
+		return err
+	}
+	r.logger.WithFields(logrus.Fields{
+		"key":       key.Name,
+		"algorithm": key.Algorithm,
+	}).Info("Created TSIG key")
+	fmt.Printf("%s:%s:%s\n", strings.TrimSuffix(key.Algorithm, "."), strings.TrimSuffix(key.Name, "."), key.Secret)
+	return nil
+}
+
+// tsigList runs tsig list. Secrets are not printed.
+func (r *Reloader) tsigList() error {
+	if err := r.connectDB(); err != nil {
+		return err
+	}
+	if !r.tsigkeys {
+		return errors.New("the database has no tsigkeys table")
+	}
+	var rows []TSIGKey
+	if err := r.db.WithContext(r.ctx).Order("name").Find(&rows).Error; err != nil {
+		return fmt.Errorf("failed to read tsigkeys: %w", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tALGORITHM\tENCRYPTED")
+	for _, row := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%t\n", row.Name, row.Algorithm, strings.HasPrefix(row.Secret, encryptedKeyPrefix))
+	}
+	return w.Flush()
+}
+
+// TSIGKeyResponse is a stored key as the API returns it. Secret is only
+// set in the response to creating the key.
+type TSIGKeyResponse struct {
+	Name      string `json:"name"`
+	Algorithm string `json:"algorithm"`
+	Secret    string `json:"secret,omitempty"`
+}
+
+type tsigKeyRequest struct {
+	Name      string `json:"name"`
+	Algorithm string `json:"algorithm"`
+	Secret    string `json:"secret"`
+}
+
+// handleListTSIGKeys serves GET /api/v1/tsig-keys.
+func (r *Reloader) handleListTSIGKeys(w http.ResponseWriter, req *http.Request) {
+	if !r.tsigkeys {
+		writeAPIError(w, errConflict("the database has no tsigkeys table"))
+		return
+	}
+	var rows []TSIGKey
+	if err := r.db.WithContext(req.Context()).Order("name").Find(&rows).Error; err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	keys := make([]TSIGKeyResponse, len(rows))
+	for i, row := range rows {
+		keys[i] = TSIGKeyResponse{Name: row.Name, Algorithm: row.Algorithm}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": keys})
+}
+
+// handleCreateTSIGKey serves POST /api/v1/tsig-keys. The secret is
+// returned once, in the response.
+func (r *Reloader) handleCreateTSIGKey(w http.ResponseWriter, req *http.Request) {
+	var body tsigKeyRequest
+	if err := readJSON(req, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	key, err := r.createTSIGKey(req.Context(), body.Name, body.Algorithm, body.Secret)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, TSIGKeyResponse{
+		Name:      strings.TrimSuffix(key.Name, "."),
+		Algorithm: strings.TrimSuffix(key.Algorithm, "."),
+		Secret:    key.Secret,
+	})
+}
+
+// handleDeleteTSIGKey serves DELETE /api/v1/tsig-keys/{name}.
+func (r *Reloader) handleDeleteTSIGKey(w http.ResponseWriter, req *http.Request) {
+	if err := r.deleteTSIGKey(req.Context(), req.PathValue("name")); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// renderUnbound renders zones as an Unbound include file of local-zone and
+// local-data directives, for resolvers that cannot transfer zones. Each
+// zone is static, so names missing from it get NXDOMAIN from Unbound
+// rather than being resolved upstream.
+func renderUnbound(zones []outputZone) (string, error) {
+	var conf strings.Builder
+	conf.WriteString("# Generated by dns-reloader, do not edit\n")
+	conf.WriteString("server:\n")
+	for _, zone := range zones {
+		conf.WriteString("\n  local-zone: \"" + dns.Fqdn(zone.Name) + "\" static\n")
+		for _, rr := range zone.RRs {
+			conf.WriteString("  " + unboundLocalData(rr) + "\n")
+		}
+	}
+	return conf.String(), nil
+}
+
+// unboundLocalData returns the local-data directive for rr. Records with
+// double quotes in their data, TXT mostly, are wrapped in single quotes;
+// a record that has both kinds cannot be written and becomes a comment.
+func unboundLocalData(rr dns.RR) string {
+	data := strings.ReplaceAll(rr.String(), "\t", " ")
+	switch {
+	case !strings.Contains(data, `"`):
+		return `local-data: "` + data + `"`
+	case !strings.Contains(data, "'"):
+		return "local-data: '" + data + "'"
+	}
+	return "# cannot quote for unbound: " + data
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// validateZoneRecords checks a zone's records for problems that would make
+// CoreDNS reject or misserve the rendered file. It returns one message per
+// problem found.
+func validateZoneRecords(domainName string, records []Record) []string {
+	var problems []string
+	var soaCount, apexNS int
+	typesByName := make(map[string]map[string]bool)
+
+	for _, record := range records {
+		if record.Disabled {
+			continue
+		}
+
+		problems = append(problems, validateRecord(domainName, record)...)
+
+		name := strings.TrimSuffix(strings.ToLower(record.Name), ".")
+		recordType := strings.ToUpper(record.Type)
+		if typesByName[name] == nil {
+			typesByName[name] = make(map[string]bool)
+		}
+		typesByName[name][recordType] = true
+
+		switch recordType {
+		case "SOA":
+			soaCount++
+		case "NS":
+			if name == domainName {
+				apexNS++
+			}
+		}
+	}
+
+	if soaCount == 0 {
+		problems = append(problems, "zone has no SOA record (a default will be generated)")
+	} else if soaCount > 1 {
+		problems = append(problems, fmt.Sprintf("zone has %d SOA records", soaCount))
+	}
+	if apexNS == 0 {
+		problems = append(problems, "zone has no NS records at the apex")
+	}
+
+	for name, types := range typesByName {
+		if types["CNAME"] && len(types) > 1 {
+			problems = append(problems, fmt.Sprintf("%s has a CNAME alongside other record types", name))
+		}
+	}
+
+	return problems
+}
+
+// validateRecord checks a single record in isolation.
+func validateRecord(domainName string, record Record) []string {
+	var problems []string
+	name := strings.TrimSuffix(strings.ToLower(record.Name), ".")
+	recordType := strings.ToUpper(record.Type)
+	if name != domainName && !strings.HasSuffix(name, "."+domainName) {
+		problems = append(problems, fmt.Sprintf("%s %s is outside zone %s", record.Name, recordType, domainName))
+	}
+
+	switch recordType {
+	case "SOA":
+		if len(strings.Fields(record.Content)) != 7 {
+			problems = append(problems, fmt.Sprintf("%s SOA must have 7 fields", record.Name))
+		}
+	case "A":
+		if ip := net.ParseIP(record.Content); ip == nil || ip.To4() == nil {
+			problems = append(problems, fmt.Sprintf("%s A has invalid IPv4 address %q", record.Name, record.Content))
+		}
+	case "AAAA":
+		if ip := net.ParseIP(record.Content); ip == nil || ip.To4() != nil {
+			problems = append(problems, fmt.Sprintf("%s AAAA has invalid IPv6 address %q", record.Name, record.Content))
+		}
+	case "MX", "CNAME":
+		if record.Content == "" {
+			problems = append(problems, fmt.Sprintf("%s %s has empty target", record.Name, recordType))
+		}
+	}
+
+	if record.Weight != nil && (*record.Weight < 0 || *record.Weight > maxRecordWeight) {
+		problems = append(problems, fmt.Sprintf("%s %s weight must be 0 to %d", record.Name, recordType, maxRecordWeight))
+	}
+	if record.TTL < 0 {
+		problems = append(problems, fmt.Sprintf("%s %s has negative TTL", record.Name, recordType))
+	}
+	return problems
+}
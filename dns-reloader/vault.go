@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// vaultPrefix marks a secret variable whose value is a reference into
+// Vault, vault:<path>#<field>, such as vault:secret/data/dns#password.
+const vaultPrefix = "vault:"
+
+// vaultRevokeDelay is how long the lease of rotated database credentials
+// is kept, so queries still running on its connections can finish.
+const vaultRevokeDelay = time.Minute
+
+// errVaultForbidden is a 403 from Vault, which for a token from a login
+// usually means it expired.
+var errVaultForbidden = errors.New("vault denied the request")
+
+// vaultClient reads secrets from the Vault at VAULT_ADDR. It authenticates
+// with VAULT_TOKEN, or logs in with AppRole (VAULT_ROLE_ID and
+// VAULT_SECRET_ID) or as a Kubernetes service account
+// (VAULT_KUBERNETES_ROLE), logging in again when the token expires.
+type vaultClient struct {
+	address   string
+	namespace string
+	client    *http.Client
+	login     func(ctx context.Context) (string, error)
+
+	mu    sync.Mutex
+	token string
+}
+
+// vaultFromEnv is the client shared by every vault: reference and the
+// database credentials. It reads the VAULT_* variables itself, since the
+// secrets of the Config are resolved while it is being built.
+var vaultFromEnv = sync.OnceValues(newVaultClient)
+
+func newVaultClient() (*vaultClient, error) {
+	address := strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/")
+	if address == "" {
+		return nil, errors.New("VAULT_ADDR is not set")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caCert := os.Getenv("VAULT_CACERT"); caCert != "" {
+		pem, err := os.ReadFile(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Vault CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caCert)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	v := &vaultClient{
+		address:   address,
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+		client:    &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}
+
+	token, err := readSecretEnv("VAULT_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	secretID, err := readSecretEnv("VAULT_SECRET_ID")
+	if err != nil {
+		return nil, err
+	}
+	roleID, kubernetesRole := os.Getenv("VAULT_ROLE_ID"), os.Getenv("VAULT_KUBERNETES_ROLE")
+	switch {
+	case token != "":
+		v.token = token
+	case roleID != "":
+		v.login = func(ctx context.Context) (string, error) {
+			return v.authenticate(ctx, "auth/"+getEnv("VAULT_APPROLE_MOUNT", "approle")+"/login",
+				map[string]string{"role_id": roleID, "secret_id": secretID})
+		}
+	case kubernetesRole != "":
+		v.login = func(ctx context.Context) (string, error) {
+			jwt, err := os.ReadFile(getEnv("VAULT_KUBERNETES_TOKEN_PATH", "/var/run/secrets/kubernetes.io/serviceaccount/token"))
+			if err != nil {
+				return "", fmt.Errorf("failed to read service account token: %w", err)
+			}
+			return v.authenticate(ctx, "auth/"+getEnv("VAULT_KUBERNETES_MOUNT", "kubernetes")+"/login",
+				map[string]string{"role": kubernetesRole, "jwt": strings.TrimSpace(string(jwt))})
+		}
+	default:
+		return nil, errors.New("VAULT_TOKEN, VAULT_ROLE_ID or VAULT_KUBERNETES_ROLE must be set")
+	}
+	return v, nil
+}
+
+// vaultResponse is the envelope of Vault's secret and auth responses.
+type vaultResponse struct {
+	LeaseID       string         `json:"lease_id"`
+	LeaseDuration int            `json:"lease_duration"`
+	Renewable     bool           `json:"renewable"`
+	Data          map[string]any `json:"data"`
+	Auth          *struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+// do sends a request to the Vault API, body encoded as JSON when not nil.
+func (v *vaultClient) do(ctx context.Context, method, path, token string, body any) (*vaultResponse, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, v.address+"/v1/"+strings.TrimPrefix(path, "/"), reader)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result vaultResponse
+	if resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil && resp.StatusCode < 300 {
+			return nil, fmt.Errorf("failed to decode vault response: %w", err)
+		}
+	}
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("%w: %s", errVaultForbidden, strings.Join(result.Errors, "; "))
+	case resp.StatusCode >= 300:
+		return nil, fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(result.Errors, "; "))
+	}
+	return &result, nil
+}
+
+// authenticate logs in at path and returns the client token.
+func (v *vaultClient) authenticate(ctx context.Context, path string, body any) (string, error) {
+	resp, err := v.do(ctx, http.MethodPost, path, "", body)
+	if err != nil {
+		return "", fmt.Errorf("failed to log in to vault: %w", err)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return "", errors.New("vault login returned no token")
+	}
+	return resp.Auth.ClientToken, nil
+}
+
+// request sends an authenticated request, logging in first if there is no
+// token yet, and once more if Vault rejects the one there is.
+func (v *vaultClient) request(ctx context.Context, method, path string, body any) (*vaultResponse, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		if v.token == "" {
+			token, err := v.login(ctx)
+			if err != nil {
+				return nil, err
+			}
+			v.token = token
+		}
+		resp, err := v.do(ctx, method, path, v.token, body)
+		if errors.Is(err, errVaultForbidden) && v.login != nil && attempt == 0 {
+			v.token = ""
+			continue
+		}
+		return resp, err
+	}
+}
+
+// read reads the secret at path. A KV version 2 secret is unwrapped, so
+// the fields of both versions are in Data.
+func (v *vaultClient) read(ctx context.Context, path string) (*vaultResponse, error) {
+	resp, err := v.request(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Data == nil {
+		return nil, fmt.Errorf("vault has no secret at %s", path)
+	}
+	if data, ok := resp.Data["data"].(map[string]any); ok && resp.Data["metadata"] != nil {
+		resp.Data = data
+	}
+	return resp, nil
+}
+
+// readVaultReference resolves a vault:<path>#<field> reference.
+func readVaultReference(ctx context.Context, reference string) (string, error) {
+	path, field, ok := strings.Cut(strings.TrimPrefix(reference, vaultPrefix), "#")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("%q is not a vault:<path>#<field> reference", reference)
+	}
+	v, err := vaultFromEnv()
+	if err != nil {
+		return "", err
+	}
+	resp, err := v.read(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	value, ok := resp.Data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no field %q", path, field)
+	}
+	return value, nil
+}
+
+// dbCredentials are the database username and password leased from
+// Vault's database secrets engine. The drivers ask for them each time they
+// open a connection, so new connections use the current lease.
+type dbCredentials struct {
+	mu       sync.RWMutex
+	username string
+	password string
+}
+
+func (c *dbCredentials) current() (username, password string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.username, c.password
+}
+
+func (c *dbCredentials) set(username, password string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.username, c.password = username, password
+}
+
+// credentialed is implemented by the drivers that can take their username
+// and password from Vault.
+type credentialed interface {
+	useCredentials(credentials *dbCredentials)
+}
+
+// vaultLease is one lease of database credentials.
+type vaultLease struct {
+	id        string
+	duration  time.Duration
+	renewable bool
+}
+
+// leaseDBCredentials reads a new username and password from
+// VAULT_DB_CREDS_PATH.
+func (r *Reloader) leaseDBCredentials(ctx context.Context) (string, string, vaultLease, error) {
+	v, err := vaultFromEnv()
+	if err != nil {
+		return "", "", vaultLease{}, err
+	}
+	resp, err := v.read(ctx, r.config.VaultDBCredsPath)
+	if err != nil {
+		return "", "", vaultLease{}, fmt.Errorf("failed to read database credentials: %w", err)
+	}
+	username, _ := resp.Data["username"].(string)
+	password, _ := resp.Data["password"].(string)
+	if username == "" {
+		return "", "", vaultLease{}, fmt.Errorf("vault secret %s has no username", r.config.VaultDBCredsPath)
+	}
+	lease := vaultLease{id: resp.LeaseID, duration: time.Duration(resp.LeaseDuration) * time.Second, renewable: resp.Renewable}
+	return username, password, lease, nil
+}
+
+// setupDBCredentials leases the database credentials from Vault when
+// VAULT_DB_CREDS_PATH is set, before the first connection is opened.
+// Without it, a password read from a secret reference is still handed to
+// the driver this way, so refreshing it applies to new connections.
+func (r *Reloader) setupDBCredentials() (*vaultLease, error) {
+	if r.config.VaultDBCredsPath == "" {
+		r.setupPasswordSecret()
+		return nil, nil
+	}
+	storage, ok := r.storage.(credentialed)
+	if !ok {
+		return nil, fmt.Errorf("VAULT_DB_CREDS_PATH is not supported with the %s driver", r.storage.Name())
+	}
+	username, password, lease, err := r.leaseDBCredentials(r.ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.dbCredentials = &dbCredentials{username: username, password: password}
+	storage.useCredentials(r.dbCredentials)
+	r.logger.WithFields(logrus.Fields{
+		"username": username,
+		"lease":    lease.duration.String(),
+	}).Info("Leased database credentials from Vault")
+	return &lease, nil
+}
+
+// renewDBCredentials keeps the lease of the database credentials alive,
+// renewing it at two thirds of its duration. Once Vault will not renew it
+// for the full duration, because the lease reached its max TTL, new
+// credentials are leased: new connections use them, idle ones are closed,
+// the listener reconnects, and the old lease is revoked a minute later.
+// The last lease is left to expire, since shutdown still writes with it.
+func (r *Reloader) renewDBCredentials(lease vaultLease) {
+	logger := r.logger.WithField("component", "vault")
+	v, err := vaultFromEnv()
+	if err != nil {
+		return
+	}
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(max(lease.duration*2/3, 5*time.Second)):
+		}
+
+		if lease.renewable {
+			resp, err := v.request(r.ctx, http.MethodPut, "sys/leases/renew", map[string]any{
+				"lease_id":  lease.id,
+				"increment": int(lease.duration.Seconds()),
+			})
+			if err == nil && time.Duration(resp.LeaseDuration)*time.Second >= lease.duration {
+				vaultLeaseRenewals.WithLabelValues("renewed").Inc()
+				logger.Debug("Renewed database credentials")
+				continue
+			}
+			if err != nil && r.ctx.Err() == nil {
+				logger.WithError(err).Warn("Failed to renew database credentials, leasing new ones")
+			}
+		}
+		if r.ctx.Err() != nil {
+			continue
+		}
+
+		username, password, next, err := r.leaseDBCredentials(r.ctx)
+		if err != nil {
+			vaultLeaseRenewals.WithLabelValues("failed").Inc()
+			logger.WithError(err).Error("Failed to lease new database credentials")
+			lease.duration = max(lease.duration/2, 10*time.Second)
+			continue
+		}
+		r.rotateDBCredentials(username, password)
+		vaultLeaseRenewals.WithLabelValues("rotated").Inc()
+		logger.WithField("username", username).Info("Rotated database credentials")
+
+		old := lease.id
+		time.AfterFunc(vaultRevokeDelay, func() {
+			revokeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if _, err := v.request(revokeCtx, http.MethodPut, "sys/leases/revoke", map[string]string{"lease_id": old}); err != nil {
+				logger.WithError(err).Warn("Failed to revoke rotated database credentials")
+			}
+		})
+		lease = next
+	}
+}
+
+// rotateDBCredentials switches new connections to username and password,
+// closes the idle ones and has the listener reconnect.
+func (r *Reloader) rotateDBCredentials(username, password string) {
+	r.dbCredentials.set(username, password)
+	r.rawDB.SetMaxIdleConns(0)
+	configurePool(r.rawDB, r.config, r.storage)
+	select {
+	case r.credentialsRotated <- struct{}{}:
+	default:
+	}
+}
+
+// reconnectListener replaces the listener after the database credentials
+// were rotated, since pq reconnects with the ones it was created with.
+// Anything sent in between is picked up from the outbox or by a resync.
+func (r *Reloader) reconnectListener() {
+	old := r.listener
+	if err := r.setupListener(); err != nil {
+		r.logger.WithError(err).Error("Failed to reconnect listener with the rotated credentials")
+		return
+	}
+	old.Close()
+	if r.config.Outbox {
+		r.drainOutboxIfEnabled()
+		return
+	}
+	err := r.triggerCoreReload(r.ctx, &DNSChangeNotification{
+		Table:     "listener",
+		Action:    "RESYNC",
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to resync after listener reconnect")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type verifyCheck struct {
+	name     string
+	qtype    uint16
+	expected []string
+}
+
+// verifyReload queries the DNS server for each changed zone until its answers
+// match the zone file on disk: the SOA always, plus the RRset named in the
+// triggering change when it falls inside the zone.
+func (r *Reloader) verifyReload(ctx context.Context, zones []string, change *DNSChangeNotification) (err error) {
+	ctx, span := tracer.Start(ctx, "dns.verify", trace.WithAttributes(
+		attribute.String("dns.verify.address", r.config.VerifyAddress),
+		attribute.StringSlice("dns.zones", zones),
+	))
+	defer func() { endSpan(span, err) }()
+
+	var failed []string
+	for _, zone := range zones {
+		checks, err := r.verifyChecks(zone, change)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", zone, err))
+			continue
+		}
+		for _, check := range checks {
+			if err := r.verifyAnswer(ctx, check); err != nil {
+				failed = append(failed, fmt.Sprintf("%s %s: %v", check.name, dns.TypeToString[check.qtype], err))
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("verification failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (r *Reloader) verifyChecks(zone string, change *DNSChangeNotification) ([]verifyCheck, error) {
+	rrs, err := parseZoneFile(r.zoneFilePath(zone), zone)
+	if err != nil {
+		return nil, err
+	}
+
+	apex := dns.Fqdn(zone)
+	checks := []verifyCheck{{name: apex, qtype: dns.TypeSOA}}
+
+	if change != nil && change.Name != "" && change.Type != "" {
+		name := dns.Fqdn(strings.ToLower(change.Name))
+		qtype, ok := dns.StringToType[strings.ToUpper(change.Type)]
+		if ok && dns.IsSubDomain(apex, name) && qtype != dns.TypeSOA {
+			checks = append(checks, verifyCheck{name: name, qtype: qtype})
+		}
+	}
+
+	for i := range checks {
+		for _, rr := range rrs {
+			if rr.Header().Rrtype == checks[i].qtype && strings.EqualFold(rr.Header().Name, checks[i].name) {
+				checks[i].expected = append(checks[i].expected, rdataString(rr))
+			}
+		}
+		sort.Strings(checks[i].expected)
+	}
+	return checks, nil
+}
+
+func (r *Reloader) verifyAnswer(ctx context.Context, check verifyCheck) error {
+	client := &dns.Client{Timeout: 2 * time.Second}
+	msg := new(dns.Msg)
+	msg.SetQuestion(check.name, check.qtype)
+
+	var lastErr error
+	for attempt := 1; attempt <= r.config.VerifyAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(r.config.VerifyInterval):
+			}
+		}
+
+		resp, _, err := client.Exchange(msg, r.config.VerifyAddress)
+		if err == nil && resp.Truncated {
+			tcpClient := &dns.Client{Net: "tcp", Timeout: 2 * time.Second}
+			resp, _, err = tcpClient.Exchange(msg, r.config.VerifyAddress)
+		}
+		if err != nil {
+			lastErr = fmt.Errorf("query failed: %w", err)
+			continue
+		}
+
+		var answers []string
+		for _, rr := range resp.Answer {
+			if rr.Header().Rrtype == check.qtype {
+				answers = append(answers, rdataString(rr))
+			}
+		}
+		sort.Strings(answers)
+
+		if strings.Join(answers, "\n") == strings.Join(check.expected, "\n") {
+			r.logger.WithFields(logrus.Fields{
+				"name":    check.name,
+				"type":    dns.TypeToString[check.qtype],
+				"attempt": attempt,
+			}).Debug("Canary query matched")
+			return nil
+		}
+		lastErr = fmt.Errorf("got %v, want %v", answers, check.expected)
+	}
+	return lastErr
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// validateViews checks that each of VIEWS names a single directory.
+func validateViews(views []string) error {
+	for _, view := range views {
+		if !viewPattern.MatchString(view) {
+			return fmt.Errorf("view %q must be letters, digits, - or _", view)
+		}
+	}
+	if len(slices.Compact(slices.Sorted(slices.Values(views)))) != len(views) {
+		return fmt.Errorf("views must not repeat")
+	}
+	return nil
+}
+
+// recordsForView are the records of a zone as seen from view: those
+// without a view and those in it. Records in a view are left out of zones
+// written outside any view, so they never leak into one that is public.
+func recordsForView(records []Record, view string) []Record {
+	var visible []Record
+	for _, record := range records {
+		if record.View == nil || *record.View == "" || (view != "" && strings.EqualFold(*record.View, view)) {
+			visible = append(visible, record)
+		}
+	}
+	return visible
+}
+
+// zoneRecords are the records written to the zone file of opts' view and
+// region.
+func zoneRecords(records []Record, opts zoneOptions) []Record {
+	return recordsForRegion(recordsForView(records, opts.View), opts.Region)
+}
+
+// primaryView reports whether opts are those of the first of the zone's
+// views outside any region, whose file is the one transferred, checked for
+// drift and journalled.
+func primaryView(opts zoneOptions) bool {
+	return (len(opts.Views) == 0 || opts.View == opts.Views[0]) && opts.Region == ""
+}
+
+// generateVariants writes a zone file for each of the zone's views, each
+// with the records visible from it, so every CoreDNS server block of a
+// split-horizon setup reads its own directory. With REGIONS each view is
+// also written for every region, with the answers of that region, for the
+// CoreDNS instances at its edge. The result is that of the primary view,
+// Changed if any file changed.
+func (r *Reloader) generateVariants(ctx context.Context, domain Domain, records []Record) (generatedZone, error) {
+	views := domain.Options.Views
+	if len(views) == 0 {
+		views = []string{domain.Options.View}
+	}
+	regions := append([]string{""}, r.config.Regions...)
+	var primary generatedZone
+	for i, view := range views {
+		for j, region := range regions {
+			variant := domain
+			variant.Options.View = view
+			variant.Options.Region = region
+			zone, err := r.generateZoneFile(ctx, variant, zoneRecords(records, variant.Options))
+			if err != nil && len(views) == 1 && len(regions) == 1 {
+				return zone, err
+			}
+			if err != nil {
+				return primary, fmt.Errorf("failed to generate %s: %w", variantName(view, region), err)
+			}
+			if i == 0 && j == 0 {
+				primary = zone
+			}
+			primary.Changed = primary.Changed || zone.Changed
+		}
+	}
+	return primary, nil
+}
+
+// variantName names a view and region variant of a zone in errors.
+func variantName(view, region string) string {
+	var parts []string
+	if view != "" {
+		parts = append(parts, "view "+view)
+	}
+	if region != "" {
+		parts = append(parts, "region "+region)
+	}
+	if len(parts) == 0 {
+		return "zone"
+	}
+	return strings.Join(parts, " in ")
+}
+
+// setRecordTags applies the weight, view, region and schedule of body to
+// record.
+func (r *Reloader) setRecordTags(ctx context.Context, db *gorm.DB, record *Record, body recordRequest) error {
+	if err := r.setRecordWeight(ctx, db, record, body.Weight); err != nil {
+		return err
+	}
+	if err := r.setRecordView(ctx, db, record, body.View); err != nil {
+		return err
+	}
+	if err := r.setRecordRegion(ctx, db, record, body.Region); err != nil {
+		return err
+	}
+	return r.setRecordSchedule(ctx, db, record, body)
+}
+
+// setRecordView puts record in view, or in every view when view is empty.
+func (r *Reloader) setRecordView(ctx context.Context, db *gorm.DB, record *Record, view *string) error {
+	if view == nil {
+		return nil
+	}
+	if err := setRecordTag(ctx, db, record, "view", r.recordViews, *view); err != nil {
+		return err
+	}
+	record.View = tagValue(*view)
+	return nil
+}
+
+// setRecordTag writes a record's view or region column on its own, since
+// schemas without the column must still accept records. An empty tag
+// clears it.
+func setRecordTag(ctx context.Context, db *gorm.DB, record *Record, column string, available bool, tag string) error {
+	if !available {
+		return errValidation([]string{fmt.Sprintf("the records table has no %s column; run the migrations", column)})
+	}
+	var value interface{}
+	if tag != "" {
+		if !viewPattern.MatchString(tag) {
+			return errValidation([]string{fmt.Sprintf("%s %q must be letters, digits, - or _", column, tag)})
+		}
+		value = tag
+	}
+	if err := db.WithContext(ctx).Table(record.TableName()).Where("id = ?", record.ID).UpdateColumn(column, value).Error; err != nil {
+		return fmt.Errorf("failed to set record %s: %w", column, err)
+	}
+	return nil
+}
+
+// tagValue is how a view or region is held on a record, nil for none.
+func tagValue(tag string) *string {
+	if tag == "" {
+		return nil
+	}
+	return &tag
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxRecordWeight is the largest weight the loadbalance plugin accepts.
+const maxRecordWeight = 255
+
+// weightedType reports whether records of rtype form weighted groups.
+func weightedType(rtype string) bool {
+	switch strings.ToUpper(rtype) {
+	case "A", "AAAA", "CNAME":
+		return true
+	}
+	return false
+}
+
+// weightedCNAMEs reports whether a and b may share a name as members of a
+// weighted CNAME group, of which one is rendered at a time.
+func weightedCNAMEs(a, b Record) bool {
+	return strings.EqualFold(a.Type, "CNAME") && strings.EqualFold(b.Type, "CNAME") && a.Weight != nil && b.Weight != nil
+}
+
+// selectWeighted applies record weights to the records of a zone. A and
+// AAAA records of weight 0 are drained and left out, unless every record of
+// their RRset is. Of a weighted CNAME group, one target is chosen by
+// weight for each WEIGHT_ROTATION_INTERVAL starting at or before now, the
+// same on every reloader, so traffic shifts between the targets as the
+// zone is rewritten.
+func (r *Reloader) selectWeighted(records []Record, now time.Time) []Record {
+	groups := make(map[string][]int)
+	for i, record := range records {
+		if record.Weight != nil && !record.Disabled && weightedType(record.Type) {
+			key := strings.ToLower(record.Name) + "/" + strings.ToUpper(record.Type)
+			groups[key] = append(groups[key], i)
+		}
+	}
+	if len(groups) == 0 {
+		return records
+	}
+
+	var slot int64
+	if r.config.WeightRotationInterval > 0 {
+		slot = now.Truncate(r.config.WeightRotationInterval).Unix()
+	}
+	drop := make(map[int]bool)
+	for key, members := range groups {
+		live := slices.DeleteFunc(slices.Clone(members), func(i int) bool { return *records[i].Weight <= 0 })
+		if len(live) == 0 {
+			live = members
+		}
+		if strings.HasSuffix(key, "/CNAME") && len(live) > 1 {
+			r.weightedGroups.Store(true)
+			live = []int{pickWeighted(records, live, fmt.Sprintf("%s/%d", key, slot))}
+		}
+		for _, i := range members {
+			drop[i] = !slices.Contains(live, i)
+		}
+	}
+
+	selected := make([]Record, 0, len(records))
+	for i, record := range records {
+		if !drop[i] {
+			selected = append(selected, record)
+		}
+	}
+	return selected
+}
+
+// pickWeighted chooses one of members with a probability proportional to
+// its weight, from the hash of seed, so the choice is the same for the
+// same seed.
+func pickWeighted(records []Record, members []int, seed string) int {
+	members = slices.Clone(members)
+	slices.SortFunc(members, func(a, b int) int { return strings.Compare(records[a].Content, records[b].Content) })
+	weight := func(i int) uint64 { return uint64(max(*records[i].Weight, 1)) }
+	var total uint64
+	for _, i := range members {
+		total += weight(i)
+	}
+	h := fnv.New64a()
+	h.Write([]byte(seed))
+	n := h.Sum64() % total
+	for _, i := range members {
+		if n < weight(i) {
+			return i
+		}
+		n -= weight(i)
+	}
+	return members[len(members)-1]
+}
+
+// rotateWeights rewrites the zones every WEIGHT_ROTATION_INTERVAL while
+// any has a weighted CNAME group, so each picks its target for the new
+// interval. Called on every listener or polling tick.
+func (r *Reloader) rotateWeights() {
+	if r.config.WeightRotationInterval <= 0 || !r.weightedGroups.Load() {
+		return
+	}
+	slot := time.Now().Truncate(r.config.WeightRotationInterval)
+	if !slot.After(r.lastWeightRotation) {
+		return
+	}
+	r.lastWeightRotation = slot
+
+	change := &DNSChangeNotification{
+		Table:     "weights",
+		Action:    "WEIGHT_ROTATION",
+		Timestamp: time.Now(),
+	}
+	if err := r.triggerCoreReload(r.ctx, change); err != nil {
+		r.logger.WithError(err).Error("Failed to rotate weighted records")
+	}
+}
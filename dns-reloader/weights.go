@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// setRecordWeight writes a record's weight on its own, since databases
+// created before the weight column must still accept records. A nil weight
+// clears it. The row is only touched when the weight changes, so writing a
+// record does not journal a second version.
+func (r *Reloader) setRecordWeight(ctx context.Context, db *gorm.DB, record *Record, weight *int) error {
+	if !r.recordWeights {
+		if weight != nil {
+			return errValidation([]string{"the records table has no weight column; run the migrations"})
+		}
+		return nil
+	}
+	query := db.WithContext(ctx).Table(record.TableName()).Where("id = ?", record.ID)
+	var value interface{}
+	if weight != nil {
+		value = *weight
+		query = query.Where("weight IS NULL OR weight <> ?", *weight)
+	} else {
+		query = query.Where("weight IS NOT NULL")
+	}
+	if err := query.UpdateColumn("weight", value).Error; err != nil {
+		return fmt.Errorf("failed to set record weight: %w", err)
+	}
+	record.Weight = weight
+	return nil
+}
+
+// writeWeightsFile emits per-address weights in the format read by the
+// CoreDNS loadbalance plugin's weighted policy:
+//
+//	www.example.com.
+//	192.0.2.10 3
+//	192.0.2.11 1
+//
+// records are those published in the generated zone files, of their first
+// view, so the weights match what is served. Addresses of weight 0 are
+// drained and left out.
+func (r *Reloader) writeWeightsFile(published []Record) error {
+	if r.config.WeightsFile == "" {
+		return nil
+	}
+
+	var records []Record
+	for _, record := range published {
+		if record.Weight != nil && *record.Weight > 0 && (strings.EqualFold(record.Type, "A") || strings.EqualFold(record.Type, "AAAA")) {
+			records = append(records, record)
+		}
+	}
+
+	byName := make(map[string][]Record)
+	for _, record := range records {
+		name := strings.TrimSuffix(strings.ToLower(record.Name), ".") + "."
+		byName[name] = append(byName[name], record)
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+		sort.Slice(byName[name], func(i, j int) bool { return byName[name][i].Content < byName[name][j].Content })
+	}
+	sort.Strings(names)
+
+	var content strings.Builder
+	content.WriteString("# Generated by dns-reloader, do not edit\n")
+	for _, name := range names {
+		content.WriteString(name + "\n")
+		for _, record := range byName[name] {
+			content.WriteString(fmt.Sprintf("%s %d\n", record.Content, *record.Weight))
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.config.WeightsFile), 0755); err != nil {
+		return fmt.Errorf("failed to create weights directory: %w", err)
+	}
+
+	tempPath := r.config.WeightsFile + ".tmp"
+	if err := os.WriteFile(tempPath, []byte(content.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write temporary weights file: %w", err)
+	}
+	if err := os.Rename(tempPath, r.config.WeightsFile); err != nil {
+		return fmt.Errorf("failed to move weights file: %w", err)
+	}
+
+	r.logger.WithFields(logrus.Fields{
+		"path":    r.config.WeightsFile,
+		"names":   len(names),
+		"records": len(records),
+	}).Debug("Generated weights file")
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWeightedGenerations(t *testing.T) {
+	dir := t.TempDir()
+	r := newTestReloader(t, map[string]string{"WEIGHTS_FILE": filepath.Join(dir, "weights")})
+	ctx := t.Context()
+	createTestZone(t, r, "example.com")
+	weight := func(w int) *int { return &w }
+	records := make(map[string]*Record)
+	for key, body := range map[string]recordRequest{
+		"www-a":   {Name: "www", Type: "A", Content: "192.0.2.10", Weight: weight(3)},
+		"www-b":   {Name: "www", Type: "A", Content: "192.0.2.11", Weight: weight(0)},
+		"app-one": {Name: "app", Type: "CNAME", Content: "one.example.net.", Weight: weight(1)},
+		"app-two": {Name: "app", Type: "CNAME", Content: "two.example.net.", Weight: weight(1)},
+	} {
+		record, err := r.createRecord(ctx, "example.com", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records[key] = record
+	}
+
+	// Each generation builds on the records the previous one left.
+	generations := []struct {
+		name     string
+		change   func(t *testing.T)
+		rotating bool
+		weights  []string
+		drained  []string
+	}{
+		{
+			name:     "weighted addresses and a CNAME group",
+			change:   func(t *testing.T) {},
+			rotating: true,
+			weights:  []string{"www.example.com.", "192.0.2.10 3"},
+			drained:  []string{"192.0.2.11"},
+		},
+		{
+			name: "group reduced to one target and drained address restored",
+			change: func(t *testing.T) {
+				if err := r.deleteRecord(ctx, "example.com", int(records["app-two"].ID)); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := r.updateRecord(ctx, "example.com", int(records["www-b"].ID), recordRequest{Name: "www", Type: "A", Content: "192.0.2.11", Weight: weight(2)}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			rotating: false,
+			weights:  []string{"192.0.2.10 3", "192.0.2.11 2"},
+		},
+	}
+	for _, gen := range generations {
+		t.Run(gen.name, func(t *testing.T) {
+			gen.change(t)
+			if _, err := r.regenerateAllZones(ctx, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := r.weightedGroups.Load(); got != gen.rotating {
+				t.Errorf("weighted CNAME groups = %t, want %t", got, gen.rotating)
+			}
+			content, err := os.ReadFile(r.config.WeightsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, line := range gen.weights {
+				if !strings.Contains(string(content), line+"\n") {
+					t.Errorf("weights file lacks %q:\n%s", line, content)
+				}
+			}
+			for _, address := range gen.drained {
+				if strings.Contains(string(content), address) {
+					t.Errorf("weights file keeps drained %s:\n%s", address, content)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordWeightColumn(t *testing.T) {
+	weight := func(w int) *int { return &w }
+	tests := []struct {
+		name     string
+		baseline bool
+		weight   *int
+		wantErr  string
+	}{
+		{name: "migrated, no weight"},
+		{name: "migrated, weighted", weight: weight(3)},
+		{name: "baseline, no weight", baseline: true},
+		{name: "baseline, weighted", baseline: true, weight: weight(3), wantErr: "no weight column"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r *Reloader
+			if tt.baseline {
+				r = openTestReloader(t, nil)
+				if err := r.db.Exec(baselineSchema).Error; err != nil {
+					t.Fatal(err)
+				}
+				if err := r.connectDB(); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				r = newTestReloader(t, nil)
+			}
+			ctx := t.Context()
+			createTestZone(t, r, "example.com")
+
+			record, err := r.createRecord(ctx, "example.com", recordRequest{Name: "www", Type: "A", Content: "192.0.2.1", Weight: tt.weight})
+			if tt.wantErr != "" {
+				var apiErr *apiError
+				if !errors.As(err, &apiErr) || !strings.Contains(strings.Join(apiErr.problems, "; "), tt.wantErr) {
+					t.Fatalf("got error %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var stored Record
+			if err := r.db.First(&stored, record.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if (stored.Weight == nil) != (tt.weight == nil) || (stored.Weight != nil && *stored.Weight != *tt.weight) {
+				t.Fatalf("stored weight %v, want %v", stored.Weight, tt.weight)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// zoneContentType is the media type for master files (RFC 4027).
+const zoneContentType = "text/dns"
+
+// zonePreview is a zone rendered from the current database state without
+// being written or reloaded.
+type zonePreview struct {
+	Domain   string   `json:"domain"`
+	Serial   string   `json:"serial"`
+	Records  int      `json:"records"`
+	Hash     string   `json:"hash"`
+	Changed  bool     `json:"changed"`
+	Valid    bool     `json:"valid"`
+	Problems []string `json:"problems"`
+	Content  string   `json:"content"`
+}
+
+// handleZone serves GET /api/v1/domains/{id}/zone: the zone rendered from
+// the database in BIND master-file format, as it is published minus the
+// canary line.
+//
+// With ?preview=true it returns JSON instead. Changed compares the rendered
+// zone with the published file, ignoring the canary line; Problems combines
+// record validation with a parse of the output.
+func (r *Reloader) handleZone(w http.ResponseWriter, req *http.Request) {
+	preview := false
+	if v := req.URL.Query().Get("preview"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid preview parameter")
+			return
+		}
+		preview = parsed
+	}
+
+	domain, records, err := r.listRecords(req.Context(), req.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	domains := []Domain{*domain}
+	err = r.loadZoneOptions(req.Context(), r.db, domains)
+	if err == nil {
+		err = r.loadDNSSECKeys(req.Context(), r.db, domains)
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	domain = &domains[0]
+
+	// The preview is compared with the file on disk, which never carries
+	// comments, so they are only included in the download.
+	if !preview {
+		if err := r.attachComments(req.Context(), records); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		content, zone := r.renderZone(*domain, records)
+		w.Header().Set("Content-Type", zoneContentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", domain.Name+".zone"))
+		w.Header().Set("ETag", strconv.Quote(zone.Hash))
+		io.WriteString(w, content)
+		return
+	}
+
+	var body string
+	existing, err := os.ReadFile(r.viewZoneFilePath(domain.Name, domain.Options))
+	if err == nil {
+		unsigned, _, _ := splitSignatures(string(existing))
+		body, _ = r.splitCanary(unsigned)
+	}
+	content, zone := r.renderZoneFile(*domain, records, body)
+	changed := err != nil || body != content
+	problems := zoneProblems(domain.Name, records, content)
+
+	writeJSON(w, http.StatusOK, zonePreview{
+		Domain:   domain.Name,
+		Serial:   zone.Serial,
+		Records:  zone.Records,
+		Hash:     zone.Hash,
+		Changed:  changed,
+		Valid:    len(problems) == 0,
+		Problems: problems,
+		Content:  content,
+	})
+}
+
+// zoneProblems combines record validation with a parse of the rendered
+// content.
+func zoneProblems(domainName string, records []Record, content string) []string {
+	problems := validateZoneRecords(domainName, records)
+	if _, err := parseZone(strings.NewReader(content), domainName, domainName+".zone"); err != nil {
+		problems = append(problems, err.Error())
+	}
+	return problems
+}
+
+// renderedZone is a zone rendered from the database, without the canary
+// line.
+type renderedZone struct {
+	Domain  Domain
+	Records []Record
+	Content string
+	Zone    generatedZone
+}
+
+// renderAllZones renders every zone from the database without writing
+// anything.
+func (r *Reloader) renderAllZones(ctx context.Context) ([]renderedZone, error) {
+	domains, err := r.listDomains(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch domains: %w", err)
+	}
+
+	zones := make([]renderedZone, 0, len(domains))
+	for _, domain := range domains {
+		var records []Record
+		if err := r.db.WithContext(ctx).Where("domain_id = ?", domain.ID).Order("name, type, id").Find(&records).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch records for %s: %w", domain.Name, err)
+		}
+		records = zoneRecords(records, domain.Options)
+		if err := r.attachComments(ctx, records); err != nil {
+			return nil, err
+		}
+		content, zone := r.renderZone(domain, records)
+		zones = append(zones, renderedZone{Domain: domain, Records: records, Content: content, Zone: zone})
+	}
+	return zones, nil
+}
+
+// writeZonesArchive writes zones to w as a gzipped tarball of <domain><ext>
+// entries.
+func writeZonesArchive(w io.Writer, zones []renderedZone, ext string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, z := range zones {
+		header := &tar.Header{
+			Name:    z.Domain.Name + ext,
+			Mode:    0644,
+			Size:    int64(len(z.Content)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(tw, z.Content); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// handleZonesArchive serves GET /api/v1/zones.tar.gz: every zone rendered
+// from the database as <domain>.zone entries. Everything is rendered before
+// the response starts so a database error still gets a proper status.
+func (r *Reloader) handleZonesArchive(w http.ResponseWriter, req *http.Request) {
+	zones, err := r.renderAllZones(req.Context())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="zones.tar.gz"`)
+	if err := writeZonesArchive(w, zones, ".zone"); err != nil {
+		r.logger.WithError(err).Error("Failed to write zone archive")
+	}
+}
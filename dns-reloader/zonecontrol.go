@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ZoneControlBackend drives a nameserver's control utility (rndc, nsd-control,
+// knotc) to reload only the zones that changed. When a per-zone reload fails,
+// typically because the zone is new to the server, it reloads the server
+// configuration and tries once more.
+type ZoneControlBackend struct {
+	name        string
+	binary      string
+	args        []string
+	reloadCmd   string
+	reconfigCmd string
+}
+
+func NewZoneControlBackend(kind string, args []string) *ZoneControlBackend {
+	switch kind {
+	case "nsd":
+		return &ZoneControlBackend{name: kind, binary: "nsd-control", args: args, reloadCmd: "reload", reconfigCmd: "reconfig"}
+	case "knot":
+		return &ZoneControlBackend{name: kind, binary: "knotc", args: args, reloadCmd: "zone-reload", reconfigCmd: "reload"}
+	default:
+		return &ZoneControlBackend{name: "rndc", binary: "rndc", args: args, reloadCmd: "reload", reconfigCmd: "reconfig"}
+	}
+}
+
+func (z *ZoneControlBackend) Name() string {
+	return z.name
+}
+
+func (z *ZoneControlBackend) Reload(ctx context.Context, zones []string) error {
+	var failed []string
+	reconfigured := false
+
+	for _, zone := range zones {
+		err := z.run(ctx, z.reloadCmd, zone)
+		if err != nil && !reconfigured {
+			if rerr := z.run(ctx, z.reconfigCmd); rerr != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", zone, rerr))
+				continue
+			}
+			reconfigured = true
+			err = z.run(ctx, z.reloadCmd, zone)
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", zone, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%s failed for %d zone(s): %s", z.binary, len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (z *ZoneControlBackend) run(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, z.binary, append(append([]string{}, z.args...), args...)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w (output: %s)", z.binary, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// DomainMetadata is a row of the PowerDNS domainmetadata table. Several
+// rows may share a kind, as with ALSO-NOTIFY.
+type DomainMetadata struct {
+	ID       int     `gorm:"primaryKey;column:id"`
+	DomainID int     `gorm:"column:domain_id"`
+	Kind     *string `gorm:"column:kind"`
+	Content  *string `gorm:"column:content"`
+}
+
+func (DomainMetadata) TableName() string {
+	return "domainmetadata"
+}
+
+// Metadata kinds that change how a zone is generated. ALSO-NOTIFY,
+// ALLOW-AXFR-FROM, TSIG-ALLOW-AXFR, AXFR-MASTER-TSIG, ALLOW-DNSUPDATE-FROM,
+// TSIG-ALLOW-DNSUPDATE and NSEC3PARAM have the same meaning as in PowerDNS; the X- kinds are ours,
+// following the PowerDNS convention for custom kinds.
+const (
+	metaDefaultTTL     = "X-DEFAULT-TTL"
+	metaSkipGeneration = "X-SKIP-GENERATION"
+	metaSerialStrategy = "X-SERIAL-STRATEGY"
+	metaView           = "X-VIEW"
+	metaDirectory      = "X-OUTPUT-DIRECTORY"
+	metaAlsoNotify     = "ALSO-NOTIFY"
+	metaAllowAXFR      = "ALLOW-AXFR-FROM"
+	metaTSIGAllowAXFR  = "TSIG-ALLOW-AXFR"
+	metaMasterTSIG     = "AXFR-MASTER-TSIG"
+	metaAllowUpdate    = "ALLOW-DNSUPDATE-FROM"
+	metaTSIGUpdate     = "TSIG-ALLOW-DNSUPDATE"
+	metaCFProxied      = "X-CLOUDFLARE-PROXIED"
+	metaDNSSECSign     = "X-DNSSEC-SIGN"
+	metaNSEC3Param     = "NSEC3PARAM"
+	metaDNSSECSigner   = "X-DNSSEC-SIGNER"
+	metaShard          = "X-SHARD"
+)
+
+var metaKinds = []string{metaDefaultTTL, metaSkipGeneration, metaSerialStrategy, metaView, metaDirectory, metaAlsoNotify, metaAllowAXFR, metaTSIGAllowAXFR, metaMasterTSIG, metaAllowUpdate, metaTSIGUpdate, metaCFProxied, metaDNSSECSign, metaNSEC3Param, metaDNSSECSigner, metaShard}
+
+// Serial strategies for X-SERIAL-STRATEGY. Without one the SOA serial is
+// written as stored.
+var serialStrategies = map[string]bool{"increment": true, "epoch": true, "date": true}
+
+var viewPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// maxNSEC3Iterations caps NSEC3PARAM iterations; RFC 9276 advises 0, and
+// resolvers treat zones above 150 as insecure.
+const maxNSEC3Iterations = 150
+
+// zoneOptions is the per-zone behavior read from domainmetadata, plus
+// whether the domain's type makes it a MASTER zone. CFProxied is the
+// default proxied flag of records synced to Cloudflare. Sign has the zone
+// signed as it is written, with NSEC3 instead of NSEC when NSEC3 is set;
+// Signer is "external" when DNSSEC_SIGNER_COMMAND signs it, which implies
+// Sign. AllowAXFR adds to AXFR_ALLOW_FROM the networks that may transfer
+// the zone and TSIGAllowAXFR to AXFR_TSIG_KEYS the keys that may, the
+// first of which signs its NOTIFYs. MasterTSIG is the key a secondary zone
+// is transferred from its masters with. AllowUpdate and TSIGAllowUpdate
+// add to UPDATE_ALLOW_FROM and UPDATE_TSIG_KEYS who may send it dynamic
+// updates. Directory is the tenant's subdirectory of ZONES_DIRECTORY the
+// zone is written to, from X-OUTPUT-DIRECTORY or else TENANT_DIRECTORIES,
+// with the View below it. Views are the views the zone is written to, from
+// X-VIEW or else VIEWS; the first is View. Region is the region variant
+// being written, under regions/ of ZONES_DIRECTORY. Shard assigns the zone to a shard in place of
+// the hash of its name. Generated is whether this reloader writes its
+// file.
+type zoneOptions struct {
+	TTL             int
+	Skip            bool
+	SerialStrategy  string
+	View            string
+	Views           []string
+	Region          string
+	Directory       string
+	AlsoNotify      []string
+	AllowAXFR       []netip.Prefix
+	TSIGAllowAXFR   []string
+	MasterTSIG      string
+	AllowUpdate     []netip.Prefix
+	TSIGAllowUpdate []string
+	Master          bool
+	Generated       bool
+	CFProxied       bool
+	Sign            bool
+	NSEC3           *dns.NSEC3PARAM
+	Signer          string
+	Shard           *int
+}
+
+// parseZoneOptions builds the options from a domain's metadata rows. Invalid
+// values are reported and otherwise ignored, so one bad row does not stop
+// the zone from being generated.
+func parseZoneOptions(rows []DomainMetadata) (zoneOptions, []string) {
+	var opts zoneOptions
+	var problems []string
+	for _, row := range rows {
+		if row.Kind == nil || row.Content == nil {
+			continue
+		}
+		kind := strings.ToUpper(*row.Kind)
+		value := strings.TrimSpace(*row.Content)
+		switch kind {
+		case metaDefaultTTL:
+			ttl, err := strconv.Atoi(value)
+			if err != nil || ttl <= 0 {
+				problems = append(problems, fmt.Sprintf("%s %q is not a positive number of seconds", kind, value))
+				continue
+			}
+			opts.TTL = ttl
+		case metaSkipGeneration:
+			skip, err := strconv.ParseBool(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s %q is not a boolean", kind, value))
+				continue
+			}
+			opts.Skip = skip
+		case metaCFProxied:
+			proxied, err := strconv.ParseBool(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s %q is not a boolean", kind, value))
+				continue
+			}
+			opts.CFProxied = proxied
+		case metaDNSSECSign:
+			sign, err := strconv.ParseBool(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s %q is not a boolean", kind, value))
+				continue
+			}
+			opts.Sign = sign
+		case metaNSEC3Param:
+			param, err := parseNSEC3Param(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s %q: %v", kind, value, err))
+				continue
+			}
+			opts.NSEC3 = param
+		case metaDNSSECSigner:
+			signer := strings.ToLower(value)
+			if signer != "inline" && signer != signerExternal {
+				problems = append(problems, fmt.Sprintf("%s %q must be inline or %s", kind, value, signerExternal))
+				continue
+			}
+			opts.Signer = ""
+			if signer == signerExternal {
+				opts.Signer = signer
+			}
+		case metaSerialStrategy:
+			strategy := strings.ToLower(value)
+			if !serialStrategies[strategy] {
+				problems = append(problems, fmt.Sprintf("unknown %s %q", kind, value))
+				continue
+			}
+			opts.SerialStrategy = strategy
+		case metaView:
+			if !viewPattern.MatchString(value) {
+				problems = append(problems, fmt.Sprintf("%s %q must be letters, digits, - or _", kind, value))
+				continue
+			}
+			opts.View = value
+		case metaDirectory:
+			if !viewPattern.MatchString(value) {
+				problems = append(problems, fmt.Sprintf("%s %q must be letters, digits, - or _", kind, value))
+				continue
+			}
+			opts.Directory = value
+		case metaShard:
+			shard, err := strconv.Atoi(value)
+			if err != nil || shard < 0 {
+				problems = append(problems, fmt.Sprintf("%s %q is not a shard number", kind, value))
+				continue
+			}
+			opts.Shard = &shard
+		case metaAlsoNotify:
+			opts.AlsoNotify = append(opts.AlsoNotify, parseList(value)...)
+		case metaAllowAXFR:
+			networks, err := parsePrefixes(parseList(value))
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s %q: %v", kind, value, err))
+				continue
+			}
+			opts.AllowAXFR = append(opts.AllowAXFR, networks...)
+		case metaTSIGAllowAXFR:
+			for _, name := range parseList(value) {
+				opts.TSIGAllowAXFR = append(opts.TSIGAllowAXFR, dns.Fqdn(strings.ToLower(name)))
+			}
+		case metaAllowUpdate:
+			networks, err := parsePrefixes(parseList(value))
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s %q: %v", kind, value, err))
+				continue
+			}
+			opts.AllowUpdate = append(opts.AllowUpdate, networks...)
+		case metaTSIGUpdate:
+			for _, name := range parseList(value) {
+				opts.TSIGAllowUpdate = append(opts.TSIGAllowUpdate, dns.Fqdn(strings.ToLower(name)))
+			}
+		case metaMasterTSIG:
+			if value != "" {
+				opts.MasterTSIG = dns.Fqdn(strings.ToLower(value))
+			}
+		}
+	}
+	if opts.Signer == signerExternal {
+		opts.Sign = true
+	}
+	return opts, problems
+}
+
+// parseNSEC3Param reads NSEC3PARAM metadata as PowerDNS writes it: hash
+// algorithm, flags, iterations and salt, as in "1 0 0 -". Flags 1 is
+// opt-out, leaving unsigned delegations out of the chain.
+func parseNSEC3Param(value string) (*dns.NSEC3PARAM, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("want algorithm, flags, iterations and salt")
+	}
+	if fields[0] != "1" {
+		return nil, fmt.Errorf("hash algorithm must be 1 (SHA-1)")
+	}
+	flags, err := strconv.ParseUint(fields[1], 10, 8)
+	if err != nil || flags > 1 {
+		return nil, fmt.Errorf("flags must be 0 or 1 (opt-out)")
+	}
+	iterations, err := strconv.ParseUint(fields[2], 10, 16)
+	if err != nil || iterations > maxNSEC3Iterations {
+		return nil, fmt.Errorf("iterations must be 0 to %d", maxNSEC3Iterations)
+	}
+	salt := strings.ToUpper(fields[3])
+	if salt == "-" {
+		salt = ""
+	}
+	if len(salt)%2 != 0 || len(salt) > 510 || strings.Trim(salt, "0123456789ABCDEF") != "" {
+		return nil, fmt.Errorf("salt must be hex or -")
+	}
+	return &dns.NSEC3PARAM{
+		Hash:       dns.SHA1,
+		Flags:      uint8(flags),
+		Iterations: uint16(iterations),
+		SaltLength: uint8(len(salt) / 2),
+		Salt:       salt,
+	}, nil
+}
+
+// loadZoneOptions reads the metadata for domains from db and sets each
+// domain's Options.
+func (r *Reloader) loadZoneOptions(ctx context.Context, db *gorm.DB, domains []Domain) error {
+	if len(domains) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(domains))
+	for i, domain := range domains {
+		ids[i] = domain.ID
+	}
+
+	var rows []DomainMetadata
+	if err := db.WithContext(ctx).Where("domain_id IN ? AND UPPER(kind) IN ?", ids, metaKinds).Order("id").Find(&rows).Error; err != nil {
+		return fmt.Errorf("failed to read domain metadata: %w", err)
+	}
+	byDomain := make(map[int][]DomainMetadata)
+	for _, row := range rows {
+		byDomain[row.DomainID] = append(byDomain[row.DomainID], row)
+	}
+
+	for i := range domains {
+		opts, problems := parseZoneOptions(byDomain[int(domains[i].ID)])
+		if opts.Shard != nil && *opts.Shard >= max(r.config.ShardCount, 1) {
+			problems = append(problems, fmt.Sprintf("%s %d is not below SHARD_COUNT %d", metaShard, *opts.Shard, r.config.ShardCount))
+			opts.Shard = nil
+		}
+		if len(problems) > 0 {
+			r.logger.WithFields(logrus.Fields{
+				"domain":   domains[i].Name,
+				"problems": problems,
+			}).Warn("Ignoring invalid domain metadata")
+		}
+		opts.Master = domainType(domains[i]) == "MASTER"
+		if opts.View != "" {
+			opts.Views = []string{opts.View}
+		} else if len(r.config.Views) > 0 {
+			opts.Views = r.config.Views
+			opts.View = r.config.Views[0]
+		}
+		if opts.Directory == "" && domains[i].Account != nil {
+			opts.Directory = r.tenantDirectory(*domains[i].Account)
+		}
+		domains[i].Options = opts
+	}
+	return nil
+}
+
+// domainType is the domain's PowerDNS type, NATIVE when unset.
+func domainType(domain Domain) string {
+	if domain.Type == "" {
+		return "NATIVE"
+	}
+	return strings.ToUpper(domain.Type)
+}
+
+// generates reports whether the reloader writes domain's zone file: its
+// type is in GENERATE_DOMAIN_TYPES, X-SKIP-GENERATION is not set and it
+// belongs to this reloader's shard. SLAVE zones are left out by default
+// since a transfer process owns them, unless SECONDARY_MODE has this
+// reloader transfer them itself.
+func (r *Reloader) generates(domain Domain) bool {
+	if domain.Options.Skip || !r.ownsDomain(domain) {
+		return false
+	}
+	return slices.Contains(r.config.GenerateTypes, domainType(domain)) || r.isSecondary(domain)
+}
+
+// rememberZoneOptions records the options zones were last generated with,
+// so paths, NOTIFY targets and transfer ACLs can be looked up by zone name
+// alone. The catalog zone is always generated.
+func (r *Reloader) rememberZoneOptions(domains []Domain) {
+	opts := make(map[string]zoneOptions, len(domains))
+	for _, domain := range domains {
+		domain.Options.Generated = r.generates(domain)
+		opts[domain.Name] = domain.Options
+	}
+	if catalog := r.catalogZone(); catalog != "" {
+		opts[catalog] = zoneOptions{Generated: true}
+	}
+	r.zoneOptsMu.Lock()
+	r.zoneOpts = opts
+	r.zoneOptsMu.Unlock()
+}
+
+func (r *Reloader) zoneOptions(zone string) zoneOptions {
+	r.zoneOptsMu.RLock()
+	defer r.zoneOptsMu.RUnlock()
+	return r.zoneOpts[zone]
+}
+
+// viewZoneFilePath is where a zone is written with opts: under
+// ZONES_DIRECTORY, in the tenant's directory and then the view's, either
+// of which may be empty. A region variant is written to the same place
+// under regions/<region>, so each edge reads a tree of its own.
+func (r *Reloader) viewZoneFilePath(zone string, opts zoneOptions) string {
+	root := r.config.ZonesDirectory
+	if opts.Region != "" {
+		root = filepath.Join(root, regionsDirectory, opts.Region)
+	}
+	return filepath.Join(root, opts.Directory, opts.View, fmt.Sprintf("db.%s", zone))
+}
+
+// renderZoneFile renders domain as it belongs on disk, given the body of
+// the current file (empty if there is none). With X-SERIAL-STRATEGY the
+// serial on disk is kept while nothing else changes and advanced by the
+// strategy once something does.
+func (r *Reloader) renderZoneFile(domain Domain, records []Record, existing string) (string, generatedZone) {
+	content, zone := r.renderZone(domain, records)
+	if domain.Options.SerialStrategy == "" || zone.Serial == "" {
+		return content, zone
+	}
+
+	current, onDisk := fileSerial(existing)
+	if onDisk {
+		if content, zone := r.renderZone(domain, withSerial(records, current)); content == existing {
+			return content, zone
+		}
+	}
+	stored, _ := strconv.ParseUint(zone.Serial, 10, 32)
+	next := nextSerial(domain.Options.SerialStrategy, uint32(stored), current, onDisk, time.Now().UTC())
+	return r.renderZone(domain, withSerial(records, next))
+}
+
+// nextSerial returns the serial for a changed zone. It is always greater
+// than the one on disk, so secondaries pick the change up.
+func nextSerial(strategy string, stored, current uint32, onDisk bool, now time.Time) uint32 {
+	var next uint32
+	switch strategy {
+	case "increment":
+		next = stored
+		if onDisk {
+			next = current + 1
+		}
+	case "epoch":
+		next = uint32(now.Unix())
+	case "date":
+		date, _ := strconv.ParseUint(now.Format("20060102")+"00", 10, 32)
+		next = uint32(date)
+	}
+	if onDisk && next <= current {
+		next = current + 1
+	}
+	return next
+}
+
+// fileSerial finds the SOA serial in a rendered zone file.
+func fileSerial(content string) (uint32, bool) {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 7 && fields[2] == "IN" && fields[3] == "SOA" {
+			serial, err := strconv.ParseUint(fields[6], 10, 32)
+			return uint32(serial), err == nil
+		}
+	}
+	return 0, false
+}
+
+// withSerial returns a copy of records with serial in every SOA.
+func withSerial(records []Record, serial uint32) []Record {
+	out := make([]Record, len(records))
+	copy(out, records)
+	for i, record := range out {
+		if !strings.EqualFold(record.Type, "SOA") {
+			continue
+		}
+		fields := strings.Fields(record.Content)
+		if len(fields) >= 3 {
+			fields[2] = strconv.FormatUint(uint64(serial), 10)
+			out[i].Content = strings.Join(fields, " ")
+		}
+	}
+	return out
+}